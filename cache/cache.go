@@ -5,28 +5,85 @@
 package cache
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/owasp-amass/asset-db/repository"
 	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
 	"github.com/owasp-amass/open-asset-model/property"
 )
 
+// Cache is a Repository that serves reads from the cache repository and writes changes to
+// the database repository through a queue processed by a single worker goroutine.
+//
+// Locking discipline: the Cache methods hold no lock while calling a repository. The queue
+// mutex, the entity LRU mutex and errLock are leaf locks, held only to update the queue or the recorded errors, and
+// the worker releases the queue mutex before executing a callback. The queued callbacks only
+// call the database repository, never a Cache method, so a callback can't wait on the worker
+// or on a lock held by the caller that queued it.
 type Cache struct {
-	start time.Time
-	freq  time.Duration
-	cache repository.Repository
-	db    repository.Repository
+	start     time.Time
+	freq      time.Duration
+	cache     repository.Repository
+	db        repository.Repository
+	queue     *dbQueue
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+	errLock   sync.Mutex
+	dberrs    []error
+	dropped   int
+	retries   int
+	backoff   time.Duration
+	hits      atomic.Int64
+	misses    atomic.Int64
+	lru       *entityLRU
+	onError   func(error)
+	lastErr   error
 }
 
-func New(cache, database repository.Repository, freq time.Duration) (*Cache, error) {
+// Option configures a Cache created by New.
+type Option func(*Cache)
+
+// WithRetry makes the queue worker retry a failed database write up to the provided number of times,
+// waiting for the backoff before the first retry and doubling the wait before each one that follows.
+// The worker retries a write before executing the writes queued after it, so the order is preserved.
+// The error is only recorded after the retries have been exhausted.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *Cache) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithErrorHandler sets the function called with each error produced by a database write from the queue,
+// after the retries configured by WithRetry have been exhausted. The handler is called by the queue worker,
+// so it must not call Flush or Close, and a slow handler delays the writes that follow.
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *Cache) {
+		c.onError = handler
+	}
+}
+
+func New(cache, database repository.Repository, freq time.Duration, opts ...Option) (*Cache, error) {
 	c := &Cache{
 		start: time.Now(),
 		freq:  freq,
 		cache: cache,
 		db:    database,
+		queue: newDBQueue(),
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 
+	c.wg.Add(1)
+	go c.processDBQueue()
 	return c, nil
 }
 
@@ -36,8 +93,61 @@ func (c *Cache) StartTime() time.Time {
 }
 
 // Close implements the Repository interface.
+// Writes already queued for the database are completed, as done by Flush, and the worker has exited
+// before Close returns. Any errors produced by the queued database writes are returned with the error
+// from closing the cache.
 func (c *Cache) Close() error {
-	return c.cache.Close()
+	c.closeOnce.Do(func() {
+		err := c.Flush()
+
+		c.queue.close()
+		close(c.done)
+		c.wg.Wait()
+
+		c.closeErr = errors.Join(err, c.dbError(), c.cache.Close())
+	})
+	return c.closeErr
+}
+
+// ParseErrors implements the Repository interface.
+// The parse errors of the cache and the database repositories are added together.
+func (c *Cache) ParseErrors() int64 {
+	return c.cache.ParseErrors() + c.db.ParseErrors()
+}
+
+// Stats implements the Repository interface.
+// The connection pool statistics of the database are returned with the read counts of the cache, the depth
+// of the database write queue, the last error of a database write and the number of entities held by the cache.
+func (c *Cache) Stats() (types.RepositoryStats, error) {
+	stats, err := c.db.Stats()
+	if err != nil {
+		return types.RepositoryStats{}, err
+	}
+
+	stats.CacheHits = c.hits.Load()
+	stats.CacheMisses = c.misses.Load()
+	stats.QueuedWrites = c.queue.stats().Depth
+	stats.LastError = c.lastDBError()
+	for _, atype := range oam.AssetList {
+		if count, err := c.cache.CountEntitiesByType(atype, time.Time{}); err == nil {
+			stats.CachedEntities += count
+		}
+	}
+	return stats, nil
+}
+
+// countRead counts a read served by the cache as a hit, and a read that queried the database as a miss.
+func (c *Cache) countRead(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// EnsureIndexes implements the Repository interface.
+func (c *Cache) EnsureIndexes() ([]string, error) {
+	return c.db.EnsureIndexes()
 }
 
 // GetDBType implements the Repository interface.
@@ -10,12 +10,18 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	assetdb "github.com/owasp-amass/asset-db"
 	"github.com/owasp-amass/asset-db/repository"
 	"github.com/owasp-amass/asset-db/repository/sqlrepo"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,6 +72,572 @@ func TestGetDBType(t *testing.T) {
 	}
 }
 
+func TestCloseDrainsDBQueue(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+
+	var names []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("host%d.owasp.org", i)
+		names = append(names, name)
+
+		_, err := c.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+	// close without waiting for the database writes to complete
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+
+	for _, name := range names {
+		ents, err := db2.FindEntitiesByContent(&domain.FQDN{Name: name}, time.Time{})
+		assert.NoError(t, err)
+		assert.Len(t, ents, 1)
+	}
+}
+
+type slowRepository struct {
+	repository.Repository
+	delay time.Duration
+}
+
+func (r *slowRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	time.Sleep(r.delay)
+	return r.Repository.CreateAsset(asset)
+}
+
+func TestQueueStatsLag(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, &slowRepository{Repository: db2, delay: 100 * time.Millisecond}, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := c.CreateAsset(&domain.FQDN{Name: fmt.Sprintf("lag%d.owasp.org", i)})
+		assert.NoError(t, err)
+	}
+
+	first := c.QueueStats()
+	assert.Greater(t, first.Depth, 0)
+
+	time.Sleep(250 * time.Millisecond)
+	second := c.QueueStats()
+	assert.Greater(t, second.Depth, 0)
+	assert.Greater(t, second.OldestAge, first.OldestAge)
+}
+
+type failingRepository struct {
+	repository.Repository
+}
+
+func (r *failingRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	return nil, errors.New("the database is unavailable")
+}
+
+func TestQueueErrorMetadata(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, &failingRepository{Repository: db2}, time.Minute)
+	assert.NoError(t, err)
+
+	entity, err := c.CreateAsset(&domain.FQDN{Name: "failure.owasp.org"})
+	assert.NoError(t, err)
+
+	err = c.Close()
+	assert.Error(t, err)
+
+	var qerr *QueueError
+	if assert.True(t, errors.As(err, &qerr)) {
+		assert.Equal(t, "CreateAsset", qerr.Operation)
+		assert.Equal(t, entity.ID, qerr.ID)
+	}
+	assert.Equal(t, 1, c.QueueStats().Failures["CreateAsset"])
+}
+
+func TestQueuedWriteMissingEntity(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// the entity is only created in the cache, so the queued write of the tag can't find it in the database
+	entity, err := c.cache.CreateAsset(&domain.FQDN{Name: "missing.owasp.org"})
+	assert.NoError(t, err)
+
+	_, err = c.CreateEntityProperty(entity, &property.SimpleProperty{PropertyName: "test", PropertyValue: "foobar"})
+	assert.NoError(t, err)
+
+	// a read through to the database neither fails with nor consumes the error of the unrelated write
+	_, err = c.CountEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+
+	err = c.Flush()
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+
+	var qerr *QueueError
+	if assert.True(t, errors.As(err, &qerr)) {
+		assert.Equal(t, "CreateEntityProperty", qerr.Operation)
+	}
+}
+
+func TestDBErrorsCapped(t *testing.T) {
+	c := &Cache{}
+	for i := 0; i < maxDBErrors+50; i++ {
+		c.recordDBError(fmt.Errorf("failure %d", i))
+	}
+	assert.Len(t, c.dberrs, maxDBErrors)
+
+	err := c.dbError()
+	assert.ErrorContains(t, err, "50 earlier database write errors were dropped")
+	assert.ErrorContains(t, err, fmt.Sprintf("failure %d", maxDBErrors+49))
+	assert.NotContains(t, err.Error(), "failure 49\n")
+	// the errors are cleared when they are returned
+	assert.NoError(t, c.dbError())
+}
+
+func TestErrorHandler(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	var handled []error
+	c, err := New(db1, &failingRepository{Repository: db2}, time.Minute, WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	assert.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.CreateAsset(&domain.FQDN{Name: "handler.owasp.org"})
+	assert.NoError(t, err)
+	assert.Error(t, c.Flush())
+
+	// the worker called the handler before Flush returned
+	var qerr *QueueError
+	if assert.Len(t, handled, 1) && assert.True(t, errors.As(handled[0], &qerr)) {
+		assert.Equal(t, "CreateAsset", qerr.Operation)
+	}
+
+	stats, err := c.Stats()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, stats.LastError, qerr)
+}
+
+type flakyRepository struct {
+	repository.Repository
+	sync.Mutex
+	failures int
+	order    []string
+}
+
+func (r *flakyRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		return nil, errors.New("the database is temporarily unavailable")
+	}
+	r.order = append(r.order, asset.Key())
+	return r.Repository.CreateAsset(asset)
+}
+
+func TestQueueRetry(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	flaky := &flakyRepository{Repository: db2, failures: 2}
+	c, err := New(db1, flaky, time.Minute, WithRetry(3, 10*time.Millisecond))
+	assert.NoError(t, err)
+
+	names := []string{"retry1.owasp.org", "retry2.owasp.org", "retry3.owasp.org"}
+	for _, name := range names {
+		_, err := c.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, c.Close())
+	assert.Empty(t, c.QueueStats().Failures)
+	assert.Equal(t, names, flaky.order)
+
+	for _, name := range names {
+		ents, err := db2.FindEntitiesByContent(&domain.FQDN{Name: name}, time.Time{})
+		assert.NoError(t, err)
+		assert.Len(t, ents, 1)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, &slowRepository{Repository: db2, delay: 20 * time.Millisecond}, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("flush%d.owasp.org", i)
+		names = append(names, name)
+
+		_, err := c.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, c.Flush())
+	assert.Equal(t, 0, c.QueueStats().Depth)
+
+	for _, name := range names {
+		ents, err := db2.FindEntitiesByContent(&domain.FQDN{Name: name}, time.Time{})
+		assert.NoError(t, err)
+		assert.Len(t, ents, 1)
+	}
+
+	failing, err := New(db1, &failingRepository{Repository: db2}, time.Minute)
+	assert.NoError(t, err)
+	defer failing.Close()
+
+	_, err = failing.CreateAsset(&domain.FQDN{Name: "flush-failure.owasp.org"})
+	assert.NoError(t, err)
+	assert.Error(t, failing.Flush())
+	// the errors are only returned once
+	assert.NoError(t, failing.Flush())
+}
+
+func TestStats(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.CreateAsset(&domain.FQDN{Name: "stats.owasp.org"})
+	assert.NoError(t, err)
+
+	// the first read is served by the cache and the second queries the database
+	_, err = c.FindEntitiesByContent(&domain.FQDN{Name: "stats.owasp.org"}, time.Time{})
+	assert.NoError(t, err)
+	_, err = c.FindEntitiesByContent(&domain.FQDN{Name: "missing.stats.owasp.org"}, time.Time{})
+	assert.Error(t, err)
+
+	stats, err := c.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.CacheHits)
+	assert.Equal(t, int64(1), stats.CacheMisses)
+	assert.GreaterOrEqual(t, stats.CachedEntities, int64(1))
+	if assert.NotNil(t, stats.Pool) {
+		assert.Equal(t, 3, stats.Pool.MaxOpenConnections)
+	}
+}
+
+func TestMaxEntities(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute, WithMaxEntities(2))
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var entities []*types.Entity
+	for i := 1; i <= 3; i++ {
+		entity, err := c.CreateAsset(&domain.FQDN{Name: fmt.Sprintf("lru%d.owasp.org", i)})
+		assert.NoError(t, err)
+		_, err = c.CreateEntityProperty(entity, &property.SimpleProperty{PropertyName: "lru", PropertyValue: entity.Asset.Key()})
+		assert.NoError(t, err)
+		entities = append(entities, entity)
+	}
+
+	// the least recently used entity was evicted from the cache, but not from the database
+	_, err = db1.FindEntityById(entities[0].ID)
+	assert.Error(t, err)
+	_, err = db1.FindEntityById(entities[2].ID)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		ents, err := db2.FindEntitiesByContent(entities[0].Asset, time.Time{})
+		if err != nil || len(ents) != 1 {
+			return false
+		}
+		tags, err := db2.GetEntityTags(ents[0], time.Time{}, "lru")
+		return err == nil && len(tags) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	reloaded, err := c.FindEntityById(entities[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entities[0].Asset.Key(), reloaded.Asset.Key())
+
+	tags, err := c.GetEntityTags(entities[0], time.Time{}, "lru")
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, "lru1.owasp.org", tags[0].Property.Value())
+	}
+
+	stats, err := c.Stats()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, stats.CachedEntities, int64(2))
+}
+
+func TestEvictedAssetsBounded(t *testing.T) {
+	l := newEntityLRU(2)
+	for i := 1; i <= 10; i++ {
+		l.touch(&types.Entity{ID: fmt.Sprintf("%d", i), Asset: &domain.FQDN{Name: fmt.Sprintf("lru%d.owasp.org", i)}})
+	}
+
+	// only the assets of the two most recent evictions are kept
+	assert.Len(t, l.evicted, 2)
+	assert.Equal(t, 2, l.evictedOrder.Len())
+	_, found := l.evictedAsset("1")
+	assert.False(t, found)
+	asset, found := l.evictedAsset("8")
+	assert.True(t, found)
+	assert.Equal(t, "lru8.owasp.org", asset.Key())
+
+	l.forget("8")
+	_, found = l.evictedAsset("8")
+	assert.False(t, found)
+	assert.Equal(t, 1, l.evictedOrder.Len())
+}
+
+// TestConcurrentOperations runs creates, reads and deletes from many goroutines while the queue
+// worker writes to the database. Run it with -race to detect data races.
+func TestConcurrentOperations(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+
+	apex, err := c.CreateAsset(&domain.FQDN{Name: "stress.owasp.org"})
+	assert.NoError(t, err)
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+
+			go func(w int) {
+				defer wg.Done()
+
+				for i := 0; i < 25; i++ {
+					name := fmt.Sprintf("host%d-%d.stress.owasp.org", w, i)
+
+					entity, err := c.CreateAsset(&domain.FQDN{Name: name})
+					if err != nil {
+						continue
+					}
+
+					_, _ = c.CreateEdge(&types.Edge{
+						Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+						FromEntity: entity,
+						ToEntity:   apex,
+					})
+					_, _ = c.FindEntitiesByContent(&domain.FQDN{Name: name}, time.Time{})
+					_, _ = c.OutgoingEdges(entity, time.Time{})
+					_ = c.QueueStats()
+
+					if i%5 == 0 {
+						_ = c.DeleteEntity(entity.ID)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		_ = c.Close()
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Minute):
+		t.Fatal("the concurrent operations did not complete, the cache may be deadlocked")
+	}
+	assert.Equal(t, 0, c.QueueStats().Depth)
+}
+
+// TestConcurrentTagOperations runs tag creates, reads and deletes from many goroutines while the
+// queue is being processed. Callers reuse the property slices passed to the cache, so the queued
+// writes must not read them after the call returns. Run with -race to detect unsafe accesses.
+func TestConcurrentTagOperations(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+
+	apex, err := c.CreateAsset(&domain.FQDN{Name: "tagstress.owasp.org"})
+	assert.NoError(t, err)
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+
+			go func(w int) {
+				defer wg.Done()
+
+				props := make([]oam.Property, 2)
+				for i := 0; i < 25; i++ {
+					entity, err := c.CreateAsset(&domain.FQDN{Name: fmt.Sprintf("host%d-%d.tagstress.owasp.org", w, i)})
+					if err != nil {
+						continue
+					}
+
+					etag, err := c.CreateEntityProperty(entity, &property.SimpleProperty{
+						PropertyName:  "worker",
+						PropertyValue: fmt.Sprintf("%d", w),
+					})
+					if err != nil {
+						continue
+					}
+
+					edge, err := c.CreateEdge(&types.Edge{
+						Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+						FromEntity: entity,
+						ToEntity:   apex,
+					})
+					if err != nil {
+						continue
+					}
+
+					props[0] = &property.SimpleProperty{PropertyName: "iteration", PropertyValue: fmt.Sprintf("%d", i)}
+					props[1] = &property.SimpleProperty{PropertyName: "worker", PropertyValue: fmt.Sprintf("%d", w)}
+					tags, _ := c.CreateEdgeTags(edge, props)
+					// overwrite the slice while the queued write may still be pending
+					props[0], props[1] = nil, nil
+
+					_, _ = c.GetEntityTags(entity, time.Time{})
+					_, _ = c.GetEdgeTags(edge, time.Time{})
+					_, _ = c.IncomingEdges(apex, time.Time{})
+					_, _ = c.FindEntitiesByType(oam.FQDN, time.Time{})
+					_ = c.QueueStats()
+
+					if i%5 == 0 {
+						_ = c.DeleteEntityTag(etag.ID)
+						for _, tag := range tags {
+							_ = c.DeleteEdgeTag(tag.ID)
+						}
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		_ = c.Close()
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Minute):
+		t.Fatal("the concurrent tag operations did not complete, the cache may be deadlocked")
+	}
+	assert.Equal(t, 0, c.QueueStats().Depth)
+}
+
+func TestQueuedWritesCopyInputs(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// hold the worker, so the inputs are modified before the queued writes are executed
+	release := make(chan struct{})
+	c.appendToDBQueue("Block", "", func() error {
+		<-release
+		return nil
+	})
+
+	fqdn := &domain.FQDN{Name: "owasp.org"}
+	entity, err := c.CreateAsset(fqdn)
+	assert.NoError(t, err)
+
+	prop := &property.SimpleProperty{PropertyName: "test", PropertyValue: "foobar"}
+	_, err = c.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+
+	fqdn.Name = "changed.owasp.org"
+	prop.PropertyValue = "changed"
+	close(release)
+	assert.NoError(t, c.Flush())
+
+	dbents, err := c.db.FindEntitiesByContent(&domain.FQDN{Name: "owasp.org"}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, dbents, 1)
+
+	dbtags, err := c.db.GetEntityTags(dbents[0], time.Time{}, "test")
+	assert.NoError(t, err)
+	assert.Len(t, dbtags, 1)
+	assert.Equal(t, "foobar", dbtags[0].Property.Value())
+}
+
 func createTestRepositories() (repository.Repository, repository.Repository, string, error) {
 	dir, err := os.MkdirTemp("", fmt.Sprintf("test-%d", rand.Intn(100)))
 	if err != nil {
@@ -5,27 +5,35 @@
 package cache
 
 import (
-	"reflect"
 	"time"
 
 	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
 )
 
 // CreateEdge implements the Repository interface.
 func (c *Cache) CreateEdge(edge *types.Edge) (*types.Edge, error) {
-	e, err := c.cache.CreateEdge(edge)
+	e, _, err := c.UpsertEdge(edge)
+	return e, err
+}
+
+// UpsertEdge implements the Repository interface.
+// The returned bool reports whether the edge was new to the cache. The edge is written to the database
+// asynchronously, so an edge that is new to the cache may already exist in the database.
+func (c *Cache) UpsertEdge(edge *types.Edge) (*types.Edge, bool, error) {
+	e, created, err := c.cache.UpsertEdge(edge)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	sub, err := c.cache.FindEntityById(e.FromEntity.ID)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	obj, err := c.cache.FindEntityById(e.ToEntity.ID)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	if tag, last, found := c.checkCacheEdgeTag(edge, "cache_create_edge"); !found || last.Add(c.freq).Before(time.Now()) {
@@ -34,26 +42,50 @@ func (c *Cache) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 		}
 		_ = c.createCacheEdgeTag(e, "cache_create_edge", time.Now())
 
-		s, err := c.db.FindEntitiesByContent(sub.Asset, time.Time{})
-		if err != nil || len(s) != 1 {
-			return nil, err
+		// the queued write must not read the returned edge, which the caller is free to modify
+		created, seen := edge.CreatedAt, edge.LastSeen
+		rel, err := types.CopyRelation(e.Relation)
+		if err != nil {
+			return nil, false, err
 		}
+		c.appendToDBQueue("CreateEdge", e.ID, func() error {
+			s, err := c.findDBEntity(sub)
+			if err != nil {
+				return err
+			}
 
-		o, err := c.db.FindEntitiesByContent(obj.Asset, time.Time{})
-		if err != nil || len(o) != 1 {
-			return nil, err
-		}
+			o, err := c.findDBEntity(obj)
+			if err != nil {
+				return err
+			}
 
-		_, _ = c.db.CreateEdge(&types.Edge{
-			CreatedAt:  edge.CreatedAt,
-			LastSeen:   edge.LastSeen,
-			Relation:   e.Relation,
-			FromEntity: s[0],
-			ToEntity:   o[0],
+			_, err = c.db.CreateEdge(&types.Edge{
+				CreatedAt:  created,
+				LastSeen:   seen,
+				Relation:   rel,
+				FromEntity: s,
+				ToEntity:   o,
+			})
+			return err
 		})
 	}
 
-	return e, err
+	return e, created, nil
+}
+
+// CreateEdges implements the Repository interface.
+// Each edge is created in the cache and queued for the database, as done by CreateEdge.
+func (c *Cache) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	var results []*types.Edge
+
+	for _, edge := range edges {
+		e, err := c.CreateEdge(edge)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	return results, nil
 }
 
 // FindEdgeById implements the Repository interface.
@@ -63,6 +95,31 @@ func (c *Cache) FindEdgeById(id string) (*types.Edge, error) {
 
 // IncomingEdges implements the Repository interface.
 func (c *Cache) IncomingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadIncomingEdges(entity, since); err != nil {
+		return nil, err
+	}
+
+	return c.cache.IncomingEdges(entity, since, labels...)
+}
+
+// IncomingEdgesBetween implements the Repository interface.
+func (c *Cache) IncomingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	entity = c.resolveEntity(entity)
+	if err := c.loadIncomingEdges(entity, start); err != nil {
+		return nil, err
+	}
+
+	return c.cache.IncomingEdgesBetween(entity, start, end, labels...)
+}
+
+// loadIncomingEdges populates the cache with the incoming edges of the entity from the database,
+// when they have not already been obtained for the since parameter.
+func (c *Cache) loadIncomingEdges(entity *types.Entity, since time.Time) error {
 	var dbquery bool
 
 	if since.IsZero() || since.Before(c.start) {
@@ -74,8 +131,11 @@ func (c *Cache) IncomingEdges(entity *types.Entity, since time.Time, labels ...s
 			_ = c.createCacheEntityTag(entity, "cache_incoming_edges", since)
 		}
 	}
+	c.countRead(!dbquery)
 
 	if dbquery {
+		c.syncDB()
+
 		var dberr error
 		var dbedges []*types.Edge
 
@@ -91,7 +151,7 @@ func (c *Cache) IncomingEdges(entity *types.Entity, since time.Time, labels ...s
 
 		if dberr == nil && len(dbedges) > 0 {
 			for _, edge := range dbedges {
-				e, err := c.cache.CreateEntity(&types.Entity{
+				e, err := c.cacheEntity(&types.Entity{
 					CreatedAt: edge.ToEntity.CreatedAt,
 					LastSeen:  edge.ToEntity.LastSeen,
 					Asset:     edge.ToEntity.Asset,
@@ -110,11 +170,111 @@ func (c *Cache) IncomingEdges(entity *types.Entity, since time.Time, labels ...s
 		}
 	}
 
-	return c.cache.IncomingEdges(entity, since, labels...)
+	return nil
 }
 
 // OutgoingEdges implements the Repository interface.
 func (c *Cache) OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadOutgoingEdges(entity, since); err != nil {
+		return nil, err
+	}
+
+	return c.cache.OutgoingEdges(entity, since, labels...)
+}
+
+// OutgoingEdgesBetween implements the Repository interface.
+func (c *Cache) OutgoingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	entity = c.resolveEntity(entity)
+	if err := c.loadOutgoingEdges(entity, start); err != nil {
+		return nil, err
+	}
+
+	return c.cache.OutgoingEdgesBetween(entity, start, end, labels...)
+}
+
+// CountOutgoingEdges implements the Repository interface.
+func (c *Cache) CountOutgoingEdges(entity *types.Entity, since time.Time, labels ...string) (int64, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadOutgoingEdges(entity, since); err != nil {
+		return 0, err
+	}
+
+	return c.cache.CountOutgoingEdges(entity, since, labels...)
+}
+
+// AdjacentEdges implements the Repository interface.
+func (c *Cache) AdjacentEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadIncomingEdges(entity, since); err != nil {
+		return nil, err
+	}
+	if err := c.loadOutgoingEdges(entity, since); err != nil {
+		return nil, err
+	}
+
+	return c.cache.AdjacentEdges(entity, since, labels...)
+}
+
+// TraverseNeighborhood implements the Repository interface.
+// The edges of the entities reached at each hop are loaded into the cache before the next hop is taken.
+func (c *Cache) TraverseNeighborhood(entity *types.Entity, depth int, direction types.Direction, labels ...string) ([]*types.Entity, []*types.Edge, error) {
+	if err := types.ValidateTraversal(depth, direction); err != nil {
+		return nil, nil, err
+	}
+
+	loaded := make(map[string]struct{})
+	frontier := []*types.Entity{entity}
+	for i := 0; i < depth && len(frontier) > 0; i++ {
+		for _, e := range frontier {
+			loaded[e.ID] = struct{}{}
+
+			if direction != types.Incoming {
+				if err := c.loadOutgoingEdges(e, time.Time{}); err != nil {
+					return nil, nil, err
+				}
+			}
+			if direction != types.Outgoing {
+				if err := c.loadIncomingEdges(e, time.Time{}); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+
+		entities, _, err := c.cache.TraverseNeighborhood(entity, i+1, direction, labels...)
+		if err != nil {
+			break
+		}
+
+		frontier = nil
+		for _, e := range entities {
+			if _, found := loaded[e.ID]; !found {
+				frontier = append(frontier, e)
+			}
+		}
+	}
+
+	return c.cache.TraverseNeighborhood(entity, depth, direction, labels...)
+}
+
+// OutgoingEdgesForEntities implements the Repository interface.
+func (c *Cache) OutgoingEdgesForEntities(entities []*types.Entity, since time.Time, labels ...string) (map[string][]*types.Edge, error) {
+	for _, entity := range entities {
+		if err := c.loadOutgoingEdges(entity, since); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.cache.OutgoingEdgesForEntities(entities, since, labels...)
+}
+
+// loadOutgoingEdges populates the cache with the outgoing edges of the entity from the database,
+// when they have not already been obtained for the since parameter.
+func (c *Cache) loadOutgoingEdges(entity *types.Entity, since time.Time) error {
 	var dbquery bool
 
 	if since.IsZero() || since.Before(c.start) {
@@ -126,8 +286,11 @@ func (c *Cache) OutgoingEdges(entity *types.Entity, since time.Time, labels ...s
 			_ = c.createCacheEntityTag(entity, "cache_outgoing_edges", since)
 		}
 	}
+	c.countRead(!dbquery)
 
 	if dbquery {
+		c.syncDB()
+
 		var dberr error
 		var dbedges []*types.Edge
 
@@ -143,7 +306,7 @@ func (c *Cache) OutgoingEdges(entity *types.Entity, since time.Time, labels ...s
 
 		if dberr == nil && len(dbedges) > 0 {
 			for _, edge := range dbedges {
-				e, err := c.cache.CreateEntity(&types.Entity{
+				e, err := c.cacheEntity(&types.Entity{
 					CreatedAt: edge.ToEntity.CreatedAt,
 					LastSeen:  edge.ToEntity.LastSeen,
 					Asset:     edge.ToEntity.Asset,
@@ -162,55 +325,151 @@ func (c *Cache) OutgoingEdges(entity *types.Entity, since time.Time, labels ...s
 		}
 	}
 
-	return c.cache.OutgoingEdges(entity, since, labels...)
+	return nil
 }
 
-// DeleteEdge implements the Repository interface.
-func (c *Cache) DeleteEdge(id string) error {
-	edge, err := c.cache.FindEdgeById(id)
+// FindHubEntities implements the Repository interface.
+func (c *Cache) FindHubEntities(minDegree int, direction string, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindHubEntities(minDegree, direction, since)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	sub, err := c.cache.FindEntityById(edge.FromEntity.ID)
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// DistinctRelationLabels implements the Repository interface.
+// The labels are read from the database, since the cache only holds the edges that were recently accessed.
+func (c *Cache) DistinctRelationLabels(since time.Time) ([]string, error) {
+	c.syncDB()
+
+	return c.db.DistinctRelationLabels(since)
+}
+
+// FindEntitiesWithEdgeTo implements the Repository interface.
+// The database is searched, since the cache only holds the edges that were recently accessed.
+func (c *Cache) FindEntitiesWithEdgeTo(fromType oam.AssetType, label string, toType oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesWithEdgeTo(fromType, label, toType, since)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	obj, err := c.cache.FindEntityById(edge.ToEntity.ID)
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEdgesByRun implements the Repository interface.
+func (c *Cache) FindEdgesByRun(runID string) ([]*types.Edge, error) {
+	c.syncDB()
+
+	dbedges, err := c.db.FindEdgesByRun(runID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := c.cache.DeleteEdge(id); err != nil {
+	var results []*types.Edge
+	for _, edge := range dbedges {
+		from, err := c.cacheEntityFromDB(edge.FromEntity.ID)
+		if err != nil {
+			continue
+		}
+
+		to, err := c.cacheEntityFromDB(edge.ToEntity.ID)
+		if err != nil {
+			continue
+		}
+
+		if e, err := c.cache.CreateEdge(&types.Edge{
+			CreatedAt:  edge.CreatedAt,
+			LastSeen:   edge.LastSeen,
+			Relation:   edge.Relation,
+			FromEntity: from,
+			ToEntity:   to,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero edges found")
+	}
+	return results, nil
+}
+
+// cacheEntityFromDB loads the database entity with the provided ID into the cache.
+func (c *Cache) cacheEntityFromDB(id string) (*types.Entity, error) {
+	entity, err := c.db.FindEntityById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.cacheEntity(&types.Entity{
+		CreatedAt: entity.CreatedAt,
+		LastSeen:  entity.LastSeen,
+		Asset:     entity.Asset,
+	})
+}
+
+// DeleteEdge implements the Repository interface.
+func (c *Cache) DeleteEdge(id string) error {
+	edge, err := c.cache.FindEdgeById(id)
+	if err != nil {
 		return err
 	}
 
-	s, err := c.db.FindEntitiesByContent(sub.Asset, time.Time{})
-	if err != nil || len(s) != 1 {
+	sub, err := c.cache.FindEntityById(edge.FromEntity.ID)
+	if err != nil {
 		return err
 	}
 
-	o, err := c.db.FindEntitiesByContent(obj.Asset, time.Time{})
-	if err != nil || len(o) != 1 {
+	obj, err := c.cache.FindEntityById(edge.ToEntity.ID)
+	if err != nil {
 		return err
 	}
 
-	edges, err := c.db.OutgoingEdges(s[0], time.Time{}, edge.Relation.Label())
-	if err != nil || len(edges) == 0 {
+	if err := c.cache.DeleteEdge(id); err != nil {
 		return err
 	}
 
-	var target *types.Edge
-	for _, e := range edges {
-		if e.ToEntity.ID == o[0].ID && reflect.DeepEqual(e.Relation, edge.Relation) {
-			target = e
-			break
+	c.appendToDBQueue("DeleteEdge", id, func() error {
+		target, err := c.findDBEdge(sub, obj, edge.Relation)
+		if err != nil {
+			return err
 		}
-	}
-	if target != nil {
-		err = c.db.DeleteEdge(target.ID)
-	}
 
-	return err
+		return c.db.DeleteEdge(target.ID)
+	})
+
+	return nil
 }
@@ -5,7 +5,9 @@
 package cache
 
 import (
-	"reflect"
+	"bytes"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/owasp-amass/asset-db/types"
@@ -43,33 +45,22 @@ func (c *Cache) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag) (*types.Ed
 		return nil, err
 	}
 
-	s, err := c.db.FindEntitiesByContent(sub.Asset, time.Time{})
-	if err != nil || len(s) != 1 {
-		return nil, err
-	}
-
-	o, err := c.db.FindEntitiesByContent(obj.Asset, time.Time{})
-	if err != nil || len(o) != 1 {
-		return nil, err
-	}
-
-	edges, err := c.db.OutgoingEdges(s[0], time.Time{}, edge.Relation.Label())
-	if err != nil || len(edges) == 0 {
+	// the queued write must not read the input, which the caller is free to modify after the return
+	prop, err := types.CopyProperty(input.Property)
+	if err != nil {
 		return nil, err
 	}
-
-	var target *types.Edge
-	for _, e := range edges {
-		if e.ID == o[0].ID && reflect.DeepEqual(e.Relation, edge2.Relation) {
-			target = e
-			break
+	c.appendToDBQueue("CreateEdgeTag", tag.ID, func() error {
+		target, err := c.findDBEdge(sub, obj, edge2.Relation)
+		if err != nil {
+			return err
 		}
-	}
-	if target != nil {
-		_, err = c.db.CreateEdgeProperty(target, input.Property)
-	}
 
-	return tag, err
+		_, err = c.db.CreateEdgeProperty(target, prop)
+		return err
+	})
+
+	return tag, nil
 }
 
 // CreateEdgeProperty implements the Repository interface.
@@ -103,33 +94,182 @@ func (c *Cache) CreateEdgeProperty(edge *types.Edge, property oam.Property) (*ty
 		return nil, err
 	}
 
-	s, err := c.db.FindEntitiesByContent(sub.Asset, time.Time{})
-	if err != nil || len(s) != 1 {
+	prop, err := types.CopyProperty(property)
+	if err != nil {
 		return nil, err
 	}
+	c.appendToDBQueue("CreateEdgeProperty", tag.ID, func() error {
+		target, err := c.findDBEdge(sub, obj, edge2.Relation)
+		if err != nil {
+			return err
+		}
 
-	o, err := c.db.FindEntitiesByContent(obj.Asset, time.Time{})
-	if err != nil || len(o) != 1 {
+		_, err = c.db.CreateEdgeProperty(target, prop)
+		return err
+	})
+
+	return tag, nil
+}
+
+// CreateEdgeTags implements the Repository interface.
+func (c *Cache) CreateEdgeTags(edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error) {
+	tags, err := c.cache.CreateEdgeTags(edge, props)
+	if err != nil {
+		return nil, err
+	}
+
+	edge2, err := c.cache.FindEdgeById(edge.ID)
+	if err != nil {
 		return nil, err
 	}
 
-	edges, err := c.db.OutgoingEdges(s[0], time.Time{}, edge.Relation.Label())
-	if err != nil || len(edges) == 0 {
+	sub, err := c.cache.FindEntityById(edge2.FromEntity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := c.cache.FindEntityById(edge2.ToEntity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the queued write must not read the properties, which the caller is free to reuse after the return
+	dbprops, err := copyProperties(props)
+	if err != nil {
+		return nil, err
+	}
+	c.appendToDBQueue("CreateEdgeTags", edge.ID, func() error {
+		target, err := c.findDBEdge(sub, obj, edge2.Relation)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.db.CreateEdgeTags(target, dbprops)
+		return err
+	})
+
+	return tags, nil
+}
+
+// CreateEdgeWithProperties implements the Repository interface.
+// The edge and the tags are written to the database by a single queued call, so they are stored atomically.
+func (c *Cache) CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error) {
+	e, tags, err := c.cache.CreateEdgeWithProperties(edge, props)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sub, err := c.cache.FindEntityById(e.FromEntity.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := c.cache.FindEntityById(e.ToEntity.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created, seen := edge.CreatedAt, edge.LastSeen
+	rel, err := types.CopyRelation(e.Relation)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbprops, err := copyProperties(props)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.appendToDBQueue("CreateEdgeWithProperties", e.ID, func() error {
+		s, err := c.findDBEntity(sub)
+		if err != nil {
+			return err
+		}
+
+		o, err := c.findDBEntity(obj)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = c.db.CreateEdgeWithProperties(&types.Edge{
+			CreatedAt:  created,
+			LastSeen:   seen,
+			Relation:   rel,
+			FromEntity: s,
+			ToEntity:   o,
+		}, dbprops)
+		return err
+	})
+
+	return e, tags, nil
+}
+
+// CreateEdgeTagsBatch implements the Repository interface.
+// The tags of each edge are written to the database in a separate transaction.
+func (c *Cache) CreateEdgeTagsBatch(props map[string][]oam.Property) (map[string][]*types.EdgeTag, error) {
+	results := make(map[string][]*types.EdgeTag, len(props))
+
+	for id, list := range props {
+		edge, err := c.cache.FindEdgeById(id)
+		if err != nil {
+			return nil, err
+		}
+
+		tags, err := c.CreateEdgeTags(edge, list)
+		if err != nil {
+			return nil, err
+		}
+		results[id] = tags
+	}
+	return results, nil
+}
+
+// findDBEdge returns the database edge with the relation between the entities matching the cache entities.
+// An error wrapping ErrEntityNotFound or ErrEdgeNotFound is returned when the database does not hold the edge.
+func (c *Cache) findDBEdge(sub, obj *types.Entity, rel oam.Relation) (*types.Edge, error) {
+	s, err := c.findDBEntity(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := c.findDBEntity(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	edges, err := c.db.OutgoingEdges(s, time.Time{}, rel.Label())
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
 		return nil, err
 	}
 
-	var target *types.Edge
 	for _, e := range edges {
-		if e.ID == o[0].ID && reflect.DeepEqual(e.Relation, edge2.Relation) {
-			target = e
-			break
+		if e.ToEntity.ID == o.ID && sameRelation(e.Relation, rel) {
+			return e, nil
 		}
 	}
-	if target != nil {
-		_, err = c.db.CreateEdgeProperty(target, property)
+	return nil, fmt.Errorf("%w: %s -%s-> %s in the database", types.ErrEdgeNotFound, sub.Asset.Key(), rel.Label(), obj.Asset.Key())
+}
+
+// sameRelation reports whether the relations have the same type and canonical JSON content, so a relation
+// held by value matches the same relation read back by pointer.
+func sameRelation(r1, r2 oam.Relation) bool {
+	if r1.RelationType() != r2.RelationType() {
+		return false
+	}
+
+	c1, err := r1.JSON()
+	if err != nil {
+		return false
+	}
+	c2, err := r2.JSON()
+	if err != nil {
+		return false
 	}
 
-	return tag, err
+	c1, err = types.CanonicalJSON(c1)
+	if err != nil {
+		return false
+	}
+	c2, err = types.CanonicalJSON(c2)
+	return err == nil && bytes.Equal(c1, c2)
 }
 
 // FindEdgeTagById implements the Repository interface.
@@ -140,6 +280,8 @@ func (c *Cache) FindEdgeTagById(id string) (*types.EdgeTag, error) {
 // FindEdgeTagsByContent implements the Repository interface.
 func (c *Cache) FindEdgeTagsByContent(prop oam.Property, since time.Time) ([]*types.EdgeTag, error) {
 	if since.IsZero() || since.Before(c.start) {
+		c.syncDB()
+
 		var dbedges []*types.Edge
 		var froms, tos []*types.Entity
 
@@ -164,7 +306,7 @@ func (c *Cache) FindEdgeTagsByContent(prop oam.Property, since time.Time) ([]*ty
 
 		if dberr == nil {
 			for i, tag := range dbtags {
-				from, err := c.cache.CreateEntity(&types.Entity{
+				from, err := c.cacheEntity(&types.Entity{
 					CreatedAt: froms[i].CreatedAt,
 					LastSeen:  froms[i].LastSeen,
 					Asset:     froms[i].Asset,
@@ -173,7 +315,7 @@ func (c *Cache) FindEdgeTagsByContent(prop oam.Property, since time.Time) ([]*ty
 					continue
 				}
 
-				to, err := c.cache.CreateEntity(&types.Entity{
+				to, err := c.cacheEntity(&types.Entity{
 					CreatedAt: tos[i].CreatedAt,
 					LastSeen:  tos[i].LastSeen,
 					Asset:     tos[i].Asset,
@@ -205,8 +347,57 @@ func (c *Cache) FindEdgeTagsByContent(prop oam.Property, since time.Time) ([]*ty
 	return c.cache.FindEdgeTagsByContent(prop, since)
 }
 
+// FindAllTagsByContent implements the Repository interface.
+// The entity tags and edge tags are each found as done by FindEntityTagsByContent and FindEdgeTagsByContent.
+func (c *Cache) FindAllTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, []*types.EdgeTag, error) {
+	entityTags, _ := c.FindEntityTagsByContent(prop, since)
+	edgeTags, _ := c.FindEdgeTagsByContent(prop, since)
+
+	if len(entityTags) == 0 && len(edgeTags) == 0 {
+		return nil, nil, types.NoResults("zero tags found")
+	}
+	return entityTags, edgeTags, nil
+}
+
 // GetEdgeTags implements the Repository interface.
 func (c *Cache) GetEdgeTags(edge *types.Edge, since time.Time, names ...string) ([]*types.EdgeTag, error) {
+	if err := c.loadEdgeTags(edge, since); err != nil {
+		return nil, err
+	}
+	return c.cache.GetEdgeTags(edge, since, names...)
+}
+
+// GetEdgeTagsBetween implements the Repository interface.
+func (c *Cache) GetEdgeTagsBetween(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	if err := c.loadEdgeTags(edge, start); err != nil {
+		return nil, err
+	}
+	return c.cache.GetEdgeTagsBetween(edge, start, end, names...)
+}
+
+// GetEdgeTagsByType implements the Repository interface.
+func (c *Cache) GetEdgeTagsByType(edge *types.Edge, since time.Time, ptypes ...oam.PropertyType) ([]*types.EdgeTag, error) {
+	if err := c.loadEdgeTags(edge, since); err != nil {
+		return nil, err
+	}
+	return c.cache.GetEdgeTagsByType(edge, since, ptypes...)
+}
+
+// CountEdgeTags implements the Repository interface.
+func (c *Cache) CountEdgeTags(edge *types.Edge, since time.Time, names ...string) (int64, error) {
+	if err := c.loadEdgeTags(edge, since); err != nil {
+		return 0, err
+	}
+	return c.cache.CountEdgeTags(edge, since, names...)
+}
+
+// loadEdgeTags copies the tags of the edge last seen after the since parameter from the database into the cache,
+// unless the cache already holds them.
+func (c *Cache) loadEdgeTags(edge *types.Edge, since time.Time) error {
 	var dbquery bool
 
 	if since.IsZero() || since.Before(c.start) {
@@ -218,45 +409,30 @@ func (c *Cache) GetEdgeTags(edge *types.Edge, since time.Time, names ...string)
 			_ = c.createCacheEdgeTag(edge, "cache_get_edge_tags", since)
 		}
 	}
+	c.countRead(!dbquery)
 
 	if dbquery {
+		c.syncDB()
+
 		sub, err := c.cache.FindEntityById(edge.FromEntity.ID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		obj, err := c.cache.FindEntityById(edge.ToEntity.ID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		var dberr error
 		var dbtags []*types.EdgeTag
 
-		s, err := c.db.FindEntitiesByContent(sub.Asset, time.Time{})
-		if err != nil || len(s) != 1 {
-			return nil, err
-		}
-
-		o, err := c.db.FindEntitiesByContent(obj.Asset, time.Time{})
-		if err != nil || len(o) != 1 {
-			return nil, err
-		}
-
-		edges, err := c.db.OutgoingEdges(s[0], time.Time{}, edge.Relation.Label())
-		if err != nil || len(edges) == 0 {
-			return nil, err
-		}
-
-		var target *types.Edge
-		for _, e := range edges {
-			if e.ID == o[0].ID && reflect.DeepEqual(e.Relation, edge.Relation) {
-				target = e
-				break
-			}
-		}
-		if target != nil {
+		target, err := c.findDBEdge(sub, obj, edge.Relation)
+		if err == nil {
 			dbtags, dberr = c.db.GetEdgeTags(target, since)
+		} else if !errors.Is(err, types.ErrEntityNotFound) && !errors.Is(err, types.ErrEdgeNotFound) {
+			// an edge missing from the database has no tags to load
+			return err
 		}
 
 		if dberr == nil && len(dbtags) > 0 {
@@ -270,7 +446,7 @@ func (c *Cache) GetEdgeTags(edge *types.Edge, since time.Time, names ...string)
 		}
 	}
 
-	return c.cache.GetEdgeTags(edge, since, names...)
+	return nil
 }
 
 // DeleteEdgeTag implements the Repository interface.
@@ -299,39 +475,34 @@ func (c *Cache) DeleteEdgeTag(id string) error {
 		return err
 	}
 
-	s, err := c.db.FindEntitiesByContent(sub.Asset, time.Time{})
-	if err != nil || len(s) != 1 {
-		return err
-	}
-
-	o, err := c.db.FindEntitiesByContent(obj.Asset, time.Time{})
-	if err != nil || len(o) != 1 {
-		return err
-	}
-
-	edges, err := c.db.OutgoingEdges(s[0], time.Time{}, tag.Edge.Relation.Label())
-	if err != nil || len(edges) == 0 {
-		return err
-	}
+	c.appendToDBQueue("DeleteEdgeTag", id, func() error {
+		target, err := c.findDBEdge(sub, obj, edge2.Relation)
+		if err != nil {
+			return err
+		}
 
-	var target *types.Edge
-	for _, e := range edges {
-		if e.ID == o[0].ID && reflect.DeepEqual(e.Relation, edge2.Relation) {
-			target = e
-			break
+		tags, err := c.db.GetEdgeTags(target, time.Time{}, tag.Property.Name())
+		if err != nil && !errors.Is(err, types.ErrNoResults) {
+			return err
 		}
-	}
-	if target == nil {
-		return err
-	}
 
-	if tags, err := c.db.GetEdgeTags(target, time.Time{}, tag.Property.Name()); err == nil && len(tags) > 0 {
+		var errs []error
 		for _, t := range tags {
 			if tag.Property.Value() == t.Property.Value() {
-				_ = c.db.DeleteEdgeTag(t.ID)
+				errs = append(errs, c.db.DeleteEdgeTag(t.ID))
 			}
 		}
-	}
+		return errors.Join(errs...)
+	})
 
 	return nil
 }
+
+// DeleteEdgeTagsByNameGlobal implements the Repository interface.
+func (c *Cache) DeleteEdgeTagsByNameGlobal(name string) (int64, error) {
+	if _, err := c.cache.DeleteEdgeTagsByNameGlobal(name); err != nil {
+		return 0, err
+	}
+	c.syncDB()
+	return c.db.DeleteEdgeTagsByNameGlobal(name)
+}
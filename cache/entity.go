@@ -6,6 +6,8 @@ package cache
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/owasp-amass/asset-db/types"
@@ -14,25 +16,58 @@ import (
 
 // CreateEntity implements the Repository interface.
 func (c *Cache) CreateEntity(input *types.Entity) (*types.Entity, error) {
-	entity, err := c.cache.CreateEntity(input)
+	entity, err := c.cacheEntity(input)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := c.queueCreateEntity(entity, input); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// CreateEntityWithPrevLastSeen implements the Repository interface.
+// The previous last seen time is the one held by the cache, so an entity that is new to the cache
+// is reported as created even when it already exists in the database.
+func (c *Cache) CreateEntityWithPrevLastSeen(input *types.Entity) (*types.Entity, time.Time, error) {
+	entity, prev, err := c.cache.CreateEntityWithPrevLastSeen(input)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	c.touch(entity)
+
+	if err := c.queueCreateEntity(entity, input); err != nil {
+		return nil, time.Time{}, err
+	}
+	return entity, prev, nil
+}
+
+// queueCreateEntity writes the entity to the database, unless it was written within the cache frequency.
+func (c *Cache) queueCreateEntity(entity, input *types.Entity) error {
 	if tag, last, found := c.checkCacheEntityTag(entity, "cache_create_entity"); !found || last.Add(c.freq).Before(time.Now()) {
 		if found {
 			_ = c.cache.DeleteEntityTag(tag.ID)
 		}
 		_ = c.createCacheEntityTag(entity, "cache_create_entity", time.Now())
 
-		_, err = c.db.CreateEntity(&types.Entity{
+		// the queued write must not read the asset, which the caller is free to modify after the return
+		asset, err := types.CopyAsset(input.Asset)
+		if err != nil {
+			return err
+		}
+
+		dbinput := &types.Entity{
 			CreatedAt: input.CreatedAt,
 			LastSeen:  input.LastSeen,
-			Asset:     input.Asset,
+			Asset:     asset,
+		}
+		c.appendToDBQueue("CreateEntity", entity.ID, func() error {
+			_, err := c.db.CreateEntity(dbinput)
+			return err
 		})
 	}
-
-	return entity, err
+	return nil
 }
 
 // CreateAsset implements the Repository interface.
@@ -41,6 +76,36 @@ func (c *Cache) CreateAsset(asset oam.Asset) (*types.Entity, error) {
 	if err != nil {
 		return nil, err
 	}
+	c.touch(entity)
+
+	if tag, last, found := c.checkCacheEntityTag(entity, "cache_create_asset"); !found || last.Add(c.freq).Before(time.Now()) {
+		if found {
+			_ = c.cache.DeleteEntityTag(tag.ID)
+		}
+		_ = c.createCacheEntityTag(entity, "cache_create_asset", time.Now())
+
+		dbasset, err := types.CopyAsset(asset)
+		if err != nil {
+			return nil, err
+		}
+		c.appendToDBQueue("CreateAsset", entity.ID, func() error {
+			_, err := c.db.CreateAsset(dbasset)
+			return err
+		})
+	}
+
+	return entity, nil
+}
+
+// UpsertEntity implements the Repository interface.
+// The returned bool reports whether the entity was new to the cache. The entity is written to the database
+// asynchronously, so an entity that is new to the cache may already exist in the database.
+func (c *Cache) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	entity, created, err := c.cache.UpsertEntity(asset)
+	if err != nil {
+		return nil, false, err
+	}
+	c.touch(entity)
 
 	if tag, last, found := c.checkCacheEntityTag(entity, "cache_create_asset"); !found || last.Add(c.freq).Before(time.Now()) {
 		if found {
@@ -48,28 +113,59 @@ func (c *Cache) CreateAsset(asset oam.Asset) (*types.Entity, error) {
 		}
 		_ = c.createCacheEntityTag(entity, "cache_create_asset", time.Now())
 
-		_, err = c.db.CreateAsset(asset)
+		dbasset, err := types.CopyAsset(asset)
+		if err != nil {
+			return nil, false, err
+		}
+		c.appendToDBQueue("UpsertEntity", entity.ID, func() error {
+			_, _, err := c.db.UpsertEntity(dbasset)
+			return err
+		})
 	}
 
-	return entity, err
+	return entity, created, nil
 }
 
 // FindEntityById implements the Repository interface.
+// An entity evicted from the cache, as configured by WithMaxEntities, is reloaded from the database.
 func (c *Cache) FindEntityById(id string) (*types.Entity, error) {
-	return c.cache.FindEntityById(id)
+	entity, err := c.cache.FindEntityById(id)
+	if err == nil {
+		c.touch(entity)
+		return entity, nil
+	}
+	if c.lru == nil {
+		return nil, err
+	}
+
+	asset, found := c.lru.evictedAsset(id)
+	if !found {
+		return nil, err
+	}
+
+	entities, err := c.FindEntitiesByContent(asset, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrEntityNotFound, err)
+	}
+	return entities[0], nil
 }
 
 // FindEntitiesByContent implements the Repository interface.
 func (c *Cache) FindEntitiesByContent(asset oam.Asset, since time.Time) ([]*types.Entity, error) {
 	entities, err := c.cache.FindEntitiesByContent(asset, since)
 	if err == nil && len(entities) > 0 {
+		c.countRead(true)
+		c.touch(entities...)
 		return entities, nil
 	}
 
 	if !since.IsZero() && !since.Before(c.start) {
+		c.countRead(true)
 		return nil, err
 	}
 
+	c.countRead(false)
+	c.syncDB()
 	dbentities, dberr := c.db.FindEntitiesByContent(asset, since)
 	if dberr != nil {
 		return entities, err
@@ -77,7 +173,66 @@ func (c *Cache) FindEntitiesByContent(asset oam.Asset, since time.Time) ([]*type
 
 	var results []*types.Entity
 	for _, entity := range dbentities {
-		if e, err := c.cache.CreateEntity(&types.Entity{
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntityByContentLatest implements the Repository interface.
+func (c *Cache) FindEntityByContentLatest(asset oam.Asset) (*types.Entity, error) {
+	if entity, err := c.cache.FindEntityByContentLatest(asset); err == nil {
+		c.countRead(true)
+		c.touch(entity)
+		return entity, nil
+	}
+
+	c.countRead(false)
+	c.syncDB()
+	dbentity, err := c.db.FindEntityByContentLatest(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.cacheEntity(&types.Entity{
+		CreatedAt: dbentity.CreatedAt,
+		LastSeen:  dbentity.LastSeen,
+		Asset:     dbentity.Asset,
+	})
+}
+
+// FindEntitiesByContentFold implements the Repository interface.
+func (c *Cache) FindEntitiesByContentFold(asset oam.Asset, since time.Time) ([]*types.Entity, error) {
+	entities, err := c.cache.FindEntitiesByContentFold(asset, since)
+	if err == nil && len(entities) > 0 {
+		c.countRead(true)
+		return entities, nil
+	}
+
+	if !since.IsZero() && !since.Before(c.start) {
+		c.countRead(true)
+		return nil, err
+	}
+
+	c.countRead(false)
+	c.syncDB()
+	dbentities, dberr := c.db.FindEntitiesByContentFold(asset, since)
+	if dberr != nil {
+		return entities, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
 			CreatedAt: entity.CreatedAt,
 			LastSeen:  entity.LastSeen,
 			Asset:     entity.Asset,
@@ -87,7 +242,7 @@ func (c *Cache) FindEntitiesByContent(asset oam.Asset, since time.Time) ([]*type
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero entities found")
+		return nil, types.NoResults("zero entities found")
 	}
 	return results, nil
 }
@@ -97,13 +252,17 @@ func (c *Cache) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*typ
 	entities, err := c.cache.FindEntitiesByType(atype, since)
 	if err == nil && len(entities) > 0 {
 		if !since.IsZero() && !since.Before(c.start) {
+			c.countRead(true)
 			return entities, err
 		}
 		if _, last, found := c.checkCacheEntityTag(entities[0], "cache_find_entities_by_type"); found && !since.Before(last) {
+			c.countRead(true)
 			return entities, err
 		}
 	}
 
+	c.countRead(false)
+	c.syncDB()
 	dbentities, dberr := c.db.FindEntitiesByType(atype, since)
 	if dberr != nil {
 		return entities, err
@@ -111,23 +270,519 @@ func (c *Cache) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*typ
 
 	var results []*types.Entity
 	for _, entity := range dbentities {
-		if e, err := c.cache.CreateEntity(&types.Entity{
+		if e, err := c.cacheEntity(&types.Entity{
 			CreatedAt: entity.CreatedAt,
 			LastSeen:  entity.LastSeen,
 			Asset:     entity.Asset,
 		}); err == nil {
 			results = append(results, e)
-			if tags, err := c.cache.GetEntityTags(entity, c.start, "cache_find_entities_by_type"); err == nil && len(tags) > 0 {
+			if tags, err := c.cache.GetEntityTags(e, c.start, "cache_find_entities_by_type"); err == nil && len(tags) > 0 {
 				for _, tag := range tags {
 					_ = c.cache.DeleteEntityTag(tag.ID)
 				}
 			}
-			_ = c.createCacheEntityTag(entity, "cache_find_entities_by_type", since)
+			_ = c.createCacheEntityTag(e, "cache_find_entities_by_type", since)
+		}
+	}
+	return results, nil
+}
+
+// FindEntitiesByTypeBetween implements the Repository interface.
+// The database is searched, since the cache only holds the entities used since it was created.
+func (c *Cache) FindEntitiesByTypeBetween(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByTypeBetween(atype, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindStaleEntities implements the Repository interface.
+// The database is searched, since the cache only holds the entities used since it was created.
+func (c *Cache) FindStaleEntities(atype oam.AssetType, olderThan time.Duration) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindStaleEntities(atype, olderThan)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// NewEntitiesCountByType implements the Repository interface.
+// The count is obtained from the database, since the cache only holds the entities used since it was created.
+func (c *Cache) NewEntitiesCountByType(since time.Time) (map[oam.AssetType]int64, error) {
+	c.syncDB()
+
+	return c.db.NewEntitiesCountByType(since)
+}
+
+// CountEntitiesByType implements the Repository interface.
+// The count is obtained from the database, since the cache only holds the entities used since it was created.
+func (c *Cache) CountEntitiesByType(atype oam.AssetType, since time.Time) (int64, error) {
+	c.syncDB()
+
+	return c.db.CountEntitiesByType(atype, since)
+}
+
+// ChildNetblocks implements the Repository interface.
+func (c *Cache) ChildNetblocks(parent *types.Entity, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.ChildNetblocks(parent, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
 		}
 	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
 	return results, nil
 }
 
+// ParentNetblock implements the Repository interface.
+func (c *Cache) ParentNetblock(child *types.Entity, since time.Time) (*types.Entity, error) {
+	c.syncDB()
+
+	entity, err := c.db.ParentNetblock(child, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.cacheEntity(&types.Entity{
+		CreatedAt: entity.CreatedAt,
+		LastSeen:  entity.LastSeen,
+		Asset:     entity.Asset,
+	})
+}
+
+// FindEntitiesByTypePagedWithTotal implements the Repository interface.
+// The page is selected by the database in the order the entities were written, as done by the other
+// backends. See FindEntitiesByTypePaged with OrderByInsertion.
+func (c *Cache) FindEntitiesByTypePagedWithTotal(atype oam.AssetType, since time.Time, limit, offset int) ([]*types.Entity, int64, error) {
+	return c.FindEntitiesByTypePaged(atype, since, OrderByInsertion, limit, offset)
+}
+
+// EntityOrder selects the key used to order the entities returned by FindEntitiesByTypePaged.
+type EntityOrder int
+
+const (
+	// OrderByKey orders the entities by the key of the asset, ascending.
+	OrderByKey EntityOrder = iota
+	// OrderByLastSeen orders the most recently seen entities first.
+	OrderByLastSeen
+	// OrderByCreatedAt orders the oldest entities first.
+	OrderByCreatedAt
+	// OrderByInsertion orders the entities as they were written to the database, which is the order
+	// of FindEntitiesByTypePagedWithTotal on every backend, so the limit and offset are applied by the database.
+	OrderByInsertion
+)
+
+// FindEntitiesByTypePaged returns the page of entities of the asset type last seen after the since parameter,
+// sorted by the order, with at most limit entities starting at offset. A negative limit returns all remaining entities.
+//
+// The queued writes are executed first, so the database holds every entity of the cache, and the total is the
+// number of matching entities in the database. Under OrderByInsertion, the page is selected by the database query
+// and the since parameter is applied to the last seen times in the database. The other orders can't be
+// sorted by the database, so every matching entity is read from the database and merged with the cache by the
+// asset key, using the earliest creation time and latest last seen time of the two, since the cache holds the
+// re-observations made within the cache frequency that were not written to the database. Entities with an
+// equal ordering key are ordered by the asset key, so the pages are stable between calls.
+// Returns the page of entities, the total number of matching entities, or an error if no entities are found.
+func (c *Cache) FindEntitiesByTypePaged(atype oam.AssetType, since time.Time, order EntityOrder, limit, offset int) ([]*types.Entity, int64, error) {
+	c.syncDB()
+
+	if order == OrderByInsertion {
+		dbentities, total, err := c.db.FindEntitiesByTypePagedWithTotal(atype, since, limit, offset)
+		if err != nil {
+			return nil, total, err
+		}
+
+		var results []*types.Entity
+		for _, entity := range dbentities {
+			e, err := c.pageEntity(entity)
+			if err != nil {
+				return nil, total, err
+			}
+			results = append(results, e)
+		}
+
+		if len(results) == 0 {
+			return nil, total, types.NoResults("no entities of the specified type")
+		}
+		return results, total, nil
+	}
+
+	dbentities, err := c.db.FindEntitiesByType(atype, since)
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, 0, err
+	}
+
+	type mergedEntity struct {
+		entity *types.Entity
+		cached bool
+	}
+
+	merged := make(map[string]*mergedEntity, len(dbentities))
+	for _, e := range dbentities {
+		merged[e.Asset.Key()] = &mergedEntity{entity: e}
+	}
+	// the cache times are applied before sorting, since they can change the order
+	if entities, err := c.cache.FindEntitiesByType(atype, since); err == nil {
+		for _, e := range entities {
+			m, found := merged[e.Asset.Key()]
+			if !found {
+				merged[e.Asset.Key()] = &mergedEntity{entity: e, cached: true}
+				continue
+			}
+
+			entity := *e
+			if m.entity.CreatedAt.Before(entity.CreatedAt) {
+				entity.CreatedAt = m.entity.CreatedAt
+			}
+			if m.entity.LastSeen.After(entity.LastSeen) {
+				entity.LastSeen = m.entity.LastSeen
+			}
+			m.entity, m.cached = &entity, true
+		}
+	}
+
+	all := make([]*mergedEntity, 0, len(merged))
+	for _, m := range merged {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].entity, all[j].entity
+
+		switch order {
+		case OrderByLastSeen:
+			if !a.LastSeen.Equal(b.LastSeen) {
+				return a.LastSeen.After(b.LastSeen)
+			}
+		case OrderByCreatedAt:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		}
+		return a.Asset.Key() < b.Asset.Key()
+	})
+	total := int64(len(all))
+
+	var page []*mergedEntity
+	if offset >= 0 && offset < len(all) {
+		page = all[offset:]
+		if limit >= 0 && limit < len(page) {
+			page = page[:limit]
+		}
+	}
+
+	var results []*types.Entity
+	for _, m := range page {
+		if m.cached {
+			c.touch(m.entity)
+			results = append(results, m.entity)
+			continue
+		}
+
+		// entities only found in the database are added to the cache, as done by the other searches
+		e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: m.entity.CreatedAt,
+			LastSeen:  m.entity.LastSeen,
+			Asset:     m.entity.Asset,
+		})
+		if err != nil {
+			return nil, total, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return nil, total, types.NoResults("no entities of the specified type")
+	}
+	return results, total, nil
+}
+
+// pageEntity returns the cache entity with the asset of the provided entity, using the earliest creation time
+// and latest last seen time of the two. An entity that is not held by the cache is added to it, as done by
+// the other searches.
+func (c *Cache) pageEntity(entity *types.Entity) (*types.Entity, error) {
+	cached, err := c.cache.FindEntitiesByContent(entity.Asset, time.Time{})
+	if err != nil || len(cached) == 0 {
+		return c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		})
+	}
+	c.touch(cached[0])
+
+	e := *cached[0]
+	if entity.CreatedAt.Before(e.CreatedAt) {
+		e.CreatedAt = entity.CreatedAt
+	}
+	if entity.LastSeen.After(e.LastSeen) {
+		e.LastSeen = entity.LastSeen
+	}
+	return &e, nil
+}
+
+// FindEntitiesByField implements the Repository interface.
+func (c *Cache) FindEntitiesByField(atype oam.AssetType, field string, value any, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByField(atype, field, value, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByFieldRange implements the Repository interface.
+func (c *Cache) FindEntitiesByFieldRange(atype oam.AssetType, field string, min, max any, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByFieldRange(atype, field, min, max, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByFieldRegex implements the Repository interface.
+func (c *Cache) FindEntitiesByFieldRegex(atype oam.AssetType, field, pattern string, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByFieldRegex(atype, field, pattern, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// SearchFQDNs implements the Repository interface.
+func (c *Cache) SearchFQDNs(substr string, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.SearchFQDNs(substr, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// SearchEntities implements the Repository interface.
+func (c *Cache) SearchEntities(query string, atypes []oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.SearchEntities(query, atypes, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindServicesByAttribute implements the Repository interface.
+func (c *Cache) FindServicesByAttribute(key, value string, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindServicesByAttribute(key, value, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByRun implements the Repository interface.
+func (c *Cache) FindEntitiesByRun(runID string) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByRun(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// UpdateEntityContentCAS implements the Repository interface.
+func (c *Cache) UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error) {
+	applied, err := c.cache.UpdateEntityContentCAS(id, expected, new)
+	if err != nil || !applied {
+		return applied, err
+	}
+
+	dbexpected, err := types.CopyAsset(expected)
+	if err != nil {
+		return false, err
+	}
+	dbnew, err := types.CopyAsset(new)
+	if err != nil {
+		return false, err
+	}
+	c.appendToDBQueue("UpdateEntityContentCAS", id, func() error {
+		e, err := c.findDBEntity(&types.Entity{Asset: dbexpected})
+		if err != nil {
+			return err
+		}
+
+		if ok, err := c.db.UpdateEntityContentCAS(e.ID, dbexpected, dbnew); err != nil {
+			return err
+		} else if !ok {
+			return errors.New("the database content did not match the expected asset")
+		}
+		return nil
+	})
+
+	return true, nil
+}
+
 // DeleteEntity implements the Repository interface.
 func (c *Cache) DeleteEntity(id string) error {
 	entity, err := c.cache.FindEntityById(id)
@@ -139,12 +794,33 @@ func (c *Cache) DeleteEntity(id string) error {
 	if err != nil {
 		return err
 	}
+	if c.lru != nil {
+		c.lru.forget(id)
+	}
 
-	if ents, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{}); err == nil && len(ents) > 0 {
-		for _, e := range ents {
-			_ = c.db.DeleteEntity(e.ID)
+	c.appendToDBQueue("DeleteEntity", id, func() error {
+		var errs []error
+		if ents, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{}); err == nil && len(ents) > 0 {
+			for _, e := range ents {
+				errs = append(errs, c.db.DeleteEntity(e.ID))
+			}
 		}
-	}
+		return errors.Join(errs...)
+	})
 
 	return nil
 }
+
+// findDBEntity returns the database entity with the asset of the cache entity. An error wrapping
+// ErrEntityNotFound is returned when the database does not hold the entity, so a queued write that
+// depends on the entity is reported instead of being dropped.
+func (c *Cache) findDBEntity(entity *types.Entity) (*types.Entity, error) {
+	ents, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, err
+	}
+	if len(ents) == 0 {
+		return nil, fmt.Errorf("%w: %s %s in the database", types.ErrEntityNotFound, entity.Asset.AssetType(), entity.Asset.Key())
+	}
+	return ents[0], nil
+}
@@ -5,6 +5,7 @@
 package cache
 
 import (
+	"errors"
 	"time"
 
 	"github.com/owasp-amass/asset-db/types"
@@ -13,6 +14,7 @@ import (
 
 // CreateEntityTag implements the Repository interface.
 func (c *Cache) CreateEntityTag(entity *types.Entity, input *types.EntityTag) (*types.EntityTag, error) {
+	entity = c.resolveEntity(entity)
 	// if the tag already exists, then do not create it again
 	if tags, err := c.cache.GetEntityTags(entity, time.Time{}, input.Property.Name()); err == nil && len(tags) > 0 {
 		for _, tag := range tags {
@@ -27,19 +29,37 @@ func (c *Cache) CreateEntityTag(entity *types.Entity, input *types.EntityTag) (*
 		return nil, err
 	}
 
-	if e, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{}); err == nil && len(e) == 1 {
-		_, _ = c.db.CreateEntityTag(e[0], &types.EntityTag{
-			CreatedAt: input.CreatedAt,
-			LastSeen:  input.LastSeen,
-			Property:  input.Property,
-		})
+	// the queued write must not read the entity or the property, which the caller is free to modify after the return
+	asset, err := types.CopyAsset(entity.Asset)
+	if err != nil {
+		return nil, err
 	}
+	prop, err := types.CopyProperty(input.Property)
+	if err != nil {
+		return nil, err
+	}
+
+	dbinput := &types.EntityTag{
+		CreatedAt: input.CreatedAt,
+		LastSeen:  input.LastSeen,
+		Property:  prop,
+	}
+	c.appendToDBQueue("CreateEntityTag", tag.ID, func() error {
+		e, err := c.findDBEntity(&types.Entity{Asset: asset})
+		if err != nil {
+			return err
+		}
+
+		_, err = c.db.CreateEntityTag(e, dbinput)
+		return err
+	})
 
 	return tag, nil
 }
 
 // CreateEntityProperty implements the Repository interface.
 func (c *Cache) CreateEntityProperty(entity *types.Entity, property oam.Property) (*types.EntityTag, error) {
+	entity = c.resolveEntity(entity)
 	// if the tag already exists, then do not create it again
 	if tags, err := c.cache.GetEntityTags(entity, time.Time{}, property.Name()); err == nil && len(tags) > 0 {
 		for _, tag := range tags {
@@ -54,13 +74,61 @@ func (c *Cache) CreateEntityProperty(entity *types.Entity, property oam.Property
 		return nil, err
 	}
 
-	if e, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{}); err == nil && len(e) == 1 {
-		_, _ = c.db.CreateEntityProperty(e[0], property)
+	asset, err := types.CopyAsset(entity.Asset)
+	if err != nil {
+		return nil, err
 	}
+	prop, err := types.CopyProperty(property)
+	if err != nil {
+		return nil, err
+	}
+
+	c.appendToDBQueue("CreateEntityProperty", tag.ID, func() error {
+		e, err := c.findDBEntity(&types.Entity{Asset: asset})
+		if err != nil {
+			return err
+		}
+
+		_, err = c.db.CreateEntityProperty(e, prop)
+		return err
+	})
 
 	return tag, nil
 }
 
+// CreateEntityTags implements the Repository interface.
+// The tags are written to the database by a single queued call.
+func (c *Cache) CreateEntityTags(entity *types.Entity, props []oam.Property) ([]*types.EntityTag, error) {
+	entity = c.resolveEntity(entity)
+
+	tags, err := c.cache.CreateEntityTags(entity, props)
+	if err != nil {
+		return nil, err
+	}
+
+	// the queued write must not read the entity or the properties, which the caller is free to reuse after the return
+	asset, err := types.CopyAsset(entity.Asset)
+	if err != nil {
+		return nil, err
+	}
+	dbprops, err := copyProperties(props)
+	if err != nil {
+		return nil, err
+	}
+
+	c.appendToDBQueue("CreateEntityTags", entity.ID, func() error {
+		e, err := c.findDBEntity(&types.Entity{Asset: asset})
+		if err != nil {
+			return err
+		}
+
+		_, err = c.db.CreateEntityTags(e, dbprops)
+		return err
+	})
+
+	return tags, nil
+}
+
 // FindEntityTagById implements the Repository interface.
 func (c *Cache) FindEntityTagById(id string) (*types.EntityTag, error) {
 	return c.cache.FindEntityTagById(id)
@@ -69,6 +137,8 @@ func (c *Cache) FindEntityTagById(id string) (*types.EntityTag, error) {
 // FindEntityTagsByContent implements the Repository interface.
 func (c *Cache) FindEntityTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, error) {
 	if since.IsZero() || since.Before(c.start) {
+		c.syncDB()
+
 		var dbentities []*types.Entity
 
 		dbtags, dberr := c.db.FindEntityTagsByContent(prop, since)
@@ -82,7 +152,7 @@ func (c *Cache) FindEntityTagsByContent(prop oam.Property, since time.Time) ([]*
 
 		if dberr == nil {
 			for i, tag := range dbtags {
-				if entity, err := c.cache.CreateEntity(&types.Entity{
+				if entity, err := c.cacheEntity(&types.Entity{
 					CreatedAt: dbentities[i].CreatedAt,
 					LastSeen:  dbentities[i].LastSeen,
 					Asset:     dbentities[i].Asset,
@@ -101,8 +171,157 @@ func (c *Cache) FindEntityTagsByContent(prop oam.Property, since time.Time) ([]*
 	return c.cache.FindEntityTagsByContent(prop, since)
 }
 
+// FindEntityTagsBySource implements the Repository interface.
+func (c *Cache) FindEntityTagsBySource(source string, since time.Time) ([]*types.EntityTag, error) {
+	c.syncDB()
+
+	dbtags, err := c.db.FindEntityTagsBySource(source, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, tag := range dbtags {
+		dbentity, err := c.db.FindEntityById(tag.Entity.ID)
+		if err != nil || dbentity == nil {
+			continue
+		}
+
+		entity, err := c.cacheEntity(&types.Entity{
+			CreatedAt: dbentity.CreatedAt,
+			LastSeen:  dbentity.LastSeen,
+			Asset:     dbentity.Asset,
+		})
+		if err != nil || entity == nil {
+			continue
+		}
+
+		if t, err := c.cache.CreateEntityTag(entity, &types.EntityTag{
+			CreatedAt: tag.CreatedAt,
+			LastSeen:  tag.LastSeen,
+			Property:  tag.Property,
+			Entity:    entity,
+		}); err == nil {
+			results = append(results, t)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntityTagsByValuePrefix implements the Repository interface.
+func (c *Cache) FindEntityTagsByValuePrefix(name, prefix string, since time.Time) ([]*types.EntityTag, error) {
+	c.syncDB()
+
+	dbtags, err := c.db.FindEntityTagsByValuePrefix(name, prefix, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, tag := range dbtags {
+		dbentity, err := c.db.FindEntityById(tag.Entity.ID)
+		if err != nil || dbentity == nil {
+			continue
+		}
+
+		entity, err := c.cacheEntity(&types.Entity{
+			CreatedAt: dbentity.CreatedAt,
+			LastSeen:  dbentity.LastSeen,
+			Asset:     dbentity.Asset,
+		})
+		if err != nil || entity == nil {
+			continue
+		}
+
+		if t, err := c.cache.CreateEntityTag(entity, &types.EntityTag{
+			CreatedAt: tag.CreatedAt,
+			LastSeen:  tag.LastSeen,
+			Property:  tag.Property,
+			Entity:    entity,
+		}); err == nil {
+			results = append(results, t)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByTags implements the Repository interface.
+func (c *Cache) FindEntitiesByTags(predicates []types.TagPredicate, combine types.AndOr, since time.Time) ([]*types.Entity, error) {
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByTags(predicates, combine, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, entity := range dbentities {
+		if e, err := c.cacheEntity(&types.Entity{
+			CreatedAt: entity.CreatedAt,
+			LastSeen:  entity.LastSeen,
+			Asset:     entity.Asset,
+		}); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
 // GetEntityTags implements the Repository interface.
 func (c *Cache) GetEntityTags(entity *types.Entity, since time.Time, names ...string) ([]*types.EntityTag, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadEntityTags(entity, since); err != nil {
+		return nil, err
+	}
+	return c.cache.GetEntityTags(entity, since, names...)
+}
+
+// GetEntityTagsBetween implements the Repository interface.
+func (c *Cache) GetEntityTagsBetween(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	entity = c.resolveEntity(entity)
+	if err := c.loadEntityTags(entity, start); err != nil {
+		return nil, err
+	}
+	return c.cache.GetEntityTagsBetween(entity, start, end, names...)
+}
+
+// GetEntityTagsByType implements the Repository interface.
+func (c *Cache) GetEntityTagsByType(entity *types.Entity, since time.Time, ptypes ...oam.PropertyType) ([]*types.EntityTag, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadEntityTags(entity, since); err != nil {
+		return nil, err
+	}
+	return c.cache.GetEntityTagsByType(entity, since, ptypes...)
+}
+
+// CountEntityTags implements the Repository interface.
+func (c *Cache) CountEntityTags(entity *types.Entity, since time.Time, names ...string) (int64, error) {
+	entity = c.resolveEntity(entity)
+	if err := c.loadEntityTags(entity, since); err != nil {
+		return 0, err
+	}
+	return c.cache.CountEntityTags(entity, since, names...)
+}
+
+// loadEntityTags copies the tags of the entity last seen after the since parameter from the database into the cache,
+// unless the cache already holds them.
+func (c *Cache) loadEntityTags(entity *types.Entity, since time.Time) error {
 	var dbquery bool
 
 	if since.IsZero() || since.Before(c.start) {
@@ -114,8 +333,11 @@ func (c *Cache) GetEntityTags(entity *types.Entity, since time.Time, names ...st
 			_ = c.createCacheEntityTag(entity, "cache_get_entity_tags", since)
 		}
 	}
+	c.countRead(!dbquery)
 
 	if dbquery {
+		c.syncDB()
+
 		var dberr error
 		var dbtags []*types.EntityTag
 
@@ -134,7 +356,44 @@ func (c *Cache) GetEntityTags(entity *types.Entity, since time.Time, names ...st
 		}
 	}
 
-	return c.cache.GetEntityTags(entity, since, names...)
+	return nil
+}
+
+// EntityTagTimeline implements the Repository interface.
+// The tags are found in the database, since the cache may only hold the tags used since it was created.
+func (c *Cache) EntityTagTimeline(id string) ([]*types.EntityTag, error) {
+	entity, err := c.cache.FindEntityById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.syncDB()
+
+	dbentities, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(dbentities) != 1 {
+		return nil, errors.New("failed to find the entity in the database")
+	}
+
+	tags, err := c.db.EntityTagTimeline(dbentities[0].ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Entity = entity
+	}
+	return tags, nil
+}
+
+// ExistingEntityTags implements the Repository interface.
+func (c *Cache) ExistingEntityTags(entity *types.Entity, props []oam.Property) ([]oam.Property, error) {
+	// an entity without tags results in an error, which is not a failure here
+	tags, _ := c.GetEntityTags(entity, time.Time{})
+
+	return types.ExistingProperties(tags, props), nil
 }
 
 // DeleteEntityTag implements the Repository interface.
@@ -153,15 +412,34 @@ func (c *Cache) DeleteEntityTag(id string) error {
 		return err
 	}
 
-	if e, err := c.db.FindEntitiesByContent(entity.Asset, time.Time{}); err == nil && len(e) == 1 {
-		if tags, err := c.db.GetEntityTags(e[0], time.Time{}, tag.Property.Name()); err == nil && len(tags) > 0 {
-			for _, t := range tags {
-				if t.Property.Value() == tag.Property.Value() {
-					_ = c.db.DeleteEntityTag(t.ID)
-				}
+	c.appendToDBQueue("DeleteEntityTag", id, func() error {
+		e, err := c.findDBEntity(entity)
+		if err != nil {
+			return err
+		}
+
+		tags, err := c.db.GetEntityTags(e, time.Time{}, tag.Property.Name())
+		if err != nil && !errors.Is(err, types.ErrNoResults) {
+			return err
+		}
+
+		var errs []error
+		for _, t := range tags {
+			if t.Property.Value() == tag.Property.Value() {
+				errs = append(errs, c.db.DeleteEntityTag(t.ID))
 			}
 		}
-	}
+		return errors.Join(errs...)
+	})
 
 	return nil
 }
+
+// DeleteEntityTagsByNameGlobal implements the Repository interface.
+func (c *Cache) DeleteEntityTagsByNameGlobal(name string) (int64, error) {
+	if _, err := c.cache.DeleteEntityTagsByNameGlobal(name); err != nil {
+		return 0, err
+	}
+	c.syncDB()
+	return c.db.DeleteEntityTagsByNameGlobal(name)
+}
@@ -5,12 +5,14 @@
 package cache
 
 import (
+	"errors"
 	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/caffix/stringset"
+	"github.com/owasp-amass/asset-db/repository"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
 	"github.com/owasp-amass/open-asset-model/domain"
@@ -376,6 +378,102 @@ func TestFindEntitiesByType(t *testing.T) {
 	}
 }
 
+func TestFindEntitiesByTypePaged(t *testing.T) {
+	db1, db2, dir, err := createTestRepositories()
+	assert.NoError(t, err)
+	defer func() {
+		db1.Close()
+		db2.Close()
+		os.RemoveAll(dir)
+	}()
+
+	c, err := New(db1, db2, time.Minute)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	now := time.Now()
+	// entities that are only in the database, with the oldest creation times
+	for i, name := range []string{"b.owasp.org", "d.owasp.org", "f.owasp.org"} {
+		_, err := c.db.CreateEntity(&types.Entity{
+			CreatedAt: now.Add(-time.Duration(10-i) * time.Hour),
+			LastSeen:  now.Add(-time.Duration(10-i) * time.Hour),
+			Asset:     &domain.FQDN{Name: name},
+		})
+		assert.NoError(t, err)
+	}
+	// entities added through the cache, which are also queued for the database
+	for i, name := range []string{"a.owasp.org", "c.owasp.org", "e.owasp.org"} {
+		_, err := c.CreateEntity(&types.Entity{
+			CreatedAt: now.Add(-time.Duration(5-i) * time.Hour),
+			LastSeen:  now.Add(-time.Duration(5-i) * time.Hour),
+			Asset:     &domain.FQDN{Name: name},
+		})
+		assert.NoError(t, err)
+	}
+
+	pages := func(order EntityOrder) []string {
+		var names []string
+
+		for offset := 0; ; offset += 2 {
+			page, total, err := c.FindEntitiesByTypePaged(oam.FQDN, time.Time{}, order, 2, offset)
+			assert.Equal(t, int64(6), total)
+			if err != nil {
+				break
+			}
+
+			assert.LessOrEqual(t, len(page), 2)
+			for _, e := range page {
+				names = append(names, e.Asset.Key())
+			}
+		}
+		return names
+	}
+
+	byKey := []string{"a.owasp.org", "b.owasp.org", "c.owasp.org", "d.owasp.org", "e.owasp.org", "f.owasp.org"}
+	assert.Equal(t, byKey, pages(OrderByKey))
+	assert.Equal(t, []string{"b.owasp.org", "d.owasp.org", "f.owasp.org",
+		"a.owasp.org", "c.owasp.org", "e.owasp.org"}, pages(OrderByCreatedAt))
+	assert.Equal(t, []string{"e.owasp.org", "c.owasp.org", "a.owasp.org",
+		"f.owasp.org", "d.owasp.org", "b.owasp.org"}, pages(OrderByLastSeen))
+	// the database pages the entities in the order they were written
+	assert.Equal(t, []string{"b.owasp.org", "d.owasp.org", "f.owasp.org",
+		"a.owasp.org", "c.owasp.org", "e.owasp.org"}, pages(OrderByInsertion))
+
+	// the cached entities are counted once after the queued writes reach the database
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(t, byKey, pages(OrderByKey))
+
+	page, total, err := c.FindEntitiesByTypePagedWithTotal(oam.FQDN, time.Time{}, 4, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), total)
+	if assert.Len(t, page, 4) {
+		assert.Equal(t, "f.owasp.org", page[0].Asset.Key())
+		assert.Equal(t, "e.owasp.org", page[3].Asset.Key())
+	}
+
+	// the errors of the database are returned instead of an incomplete page
+	failing, err := New(db1, &pagingFailureRepository{Repository: db2}, time.Minute)
+	assert.NoError(t, err)
+	defer failing.Close()
+
+	for _, order := range []EntityOrder{OrderByKey, OrderByInsertion} {
+		_, _, err = failing.FindEntitiesByTypePaged(oam.FQDN, time.Time{}, order, 2, 0)
+		assert.ErrorContains(t, err, "the database is unavailable")
+	}
+}
+
+type pagingFailureRepository struct {
+	repository.Repository
+}
+
+func (r *pagingFailureRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	return nil, errors.New("the database is unavailable")
+}
+
+func (r *pagingFailureRepository) FindEntitiesByTypePagedWithTotal(atype oam.AssetType, since time.Time, limit, offset int) ([]*types.Entity, int64, error) {
+	return nil, 0, errors.New("the database is unavailable")
+}
+
 func TestDeleteEntity(t *testing.T) {
 	db1, db2, dir, err := createTestRepositories()
 	assert.NoError(t, err)
@@ -0,0 +1,206 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// WithMaxEntities limits the cache to holding the provided number of entities. When the limit is exceeded,
+// the least recently used entities are evicted from the cache with their tags and edges, and the database
+// is left intact. An evicted entity is reloaded from the database when it is requested again by its cache ID,
+// until the same number of entities have been evicted after it.
+// The limit should exceed the number of entities loaded by a single read, such as the endpoints of the
+// outgoing edges of an entity, since the entity being read could otherwise be evicted by the read itself.
+func WithMaxEntities(n int) Option {
+	return func(c *Cache) {
+		if n > 0 {
+			c.lru = newEntityLRU(n)
+		}
+	}
+}
+
+// entityLRU tracks the order in which the entities of the cache were used. The assets of the evicted
+// entities are kept by their cache IDs, so the entities can be found in the database when requested.
+// At most max evicted assets are kept, and the oldest evictions are aged out first, so the memory held
+// for the evicted entities is bounded as well.
+type entityLRU struct {
+	sync.Mutex
+	max          int
+	order        *list.List
+	entries      map[string]*list.Element
+	evictedOrder *list.List
+	evicted      map[string]*list.Element
+}
+
+type lruEntry struct {
+	entity *types.Entity
+}
+
+type evictedEntry struct {
+	id    string
+	asset oam.Asset
+}
+
+func newEntityLRU(max int) *entityLRU {
+	return &entityLRU{
+		max:          max,
+		order:        list.New(),
+		entries:      make(map[string]*list.Element),
+		evictedOrder: list.New(),
+		evicted:      make(map[string]*list.Element),
+	}
+}
+
+// touch marks the entity as the most recently used, and returns the least recently used entities that
+// exceed the limit. The returned entities are no longer tracked and must be evicted by the caller.
+func (l *entityLRU) touch(entity *types.Entity) []*types.Entity {
+	l.Lock()
+	defer l.Unlock()
+
+	if elem, found := l.entries[entity.ID]; found {
+		l.order.MoveToFront(elem)
+		return nil
+	}
+	l.entries[entity.ID] = l.order.PushFront(&lruEntry{entity: entity})
+
+	var victims []*types.Entity
+	for l.order.Len() > l.max {
+		elem := l.order.Back()
+		victim := elem.Value.(*lruEntry).entity
+
+		l.order.Remove(elem)
+		delete(l.entries, victim.ID)
+		l.addEvicted(victim)
+		victims = append(victims, victim)
+	}
+	return victims
+}
+
+// addEvicted keeps the asset of the evicted entity, and ages out the oldest evictions beyond the limit.
+// The caller must hold the lock.
+func (l *entityLRU) addEvicted(entity *types.Entity) {
+	l.removeEvicted(entity.ID)
+	l.evicted[entity.ID] = l.evictedOrder.PushFront(&evictedEntry{id: entity.ID, asset: entity.Asset})
+
+	for l.evictedOrder.Len() > l.max {
+		l.removeEvicted(l.evictedOrder.Back().Value.(*evictedEntry).id)
+	}
+}
+
+// removeEvicted forgets the asset of the evicted entity. The caller must hold the lock.
+func (l *entityLRU) removeEvicted(id string) {
+	if elem, found := l.evicted[id]; found {
+		l.evictedOrder.Remove(elem)
+		delete(l.evicted, id)
+	}
+}
+
+// forget stops tracking the entity, such as after it has been deleted.
+func (l *entityLRU) forget(id string) {
+	l.Lock()
+	defer l.Unlock()
+
+	if elem, found := l.entries[id]; found {
+		l.order.Remove(elem)
+		delete(l.entries, id)
+	}
+	l.removeEvicted(id)
+}
+
+// evictedAsset returns the asset of the entity that was evicted with the provided cache ID.
+func (l *entityLRU) evictedAsset(id string) (oam.Asset, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	elem, found := l.evicted[id]
+	if !found {
+		return nil, false
+	}
+	return elem.Value.(*evictedEntry).asset, true
+}
+
+// cacheEntity creates the entity in the cache and marks it as used.
+func (c *Cache) cacheEntity(input *types.Entity) (*types.Entity, error) {
+	entity, err := c.cache.CreateEntity(input)
+	if err == nil {
+		c.touch(entity)
+	}
+	return entity, err
+}
+
+// touch marks the entities as used, and evicts the entities that exceed the limit set by WithMaxEntities.
+func (c *Cache) touch(entities ...*types.Entity) {
+	if c.lru == nil {
+		return
+	}
+
+	for _, entity := range entities {
+		if entity == nil {
+			continue
+		}
+		for _, victim := range c.lru.touch(entity) {
+			c.evict(victim)
+		}
+	}
+}
+
+// evict removes the entity, its tags and its edges from the cache. The neighbors of the entity lose
+// the record of their edges being loaded, so the edges are read from the database again when needed.
+func (c *Cache) evict(entity *types.Entity) {
+	if tags, err := c.cache.GetEntityTags(entity, time.Time{}); err == nil {
+		for _, tag := range tags {
+			_ = c.cache.DeleteEntityTag(tag.ID)
+		}
+	}
+
+	if edges, err := c.cache.IncomingEdges(entity, time.Time{}); err == nil {
+		for _, edge := range edges {
+			c.evictEdge(edge)
+			c.forgetLoadedEdges(edge.FromEntity, "cache_outgoing_edges")
+		}
+	}
+	if edges, err := c.cache.OutgoingEdges(entity, time.Time{}); err == nil {
+		for _, edge := range edges {
+			c.evictEdge(edge)
+			c.forgetLoadedEdges(edge.ToEntity, "cache_incoming_edges")
+		}
+	}
+
+	_ = c.cache.DeleteEntity(entity.ID)
+}
+
+func (c *Cache) evictEdge(edge *types.Edge) {
+	if tags, err := c.cache.GetEdgeTags(edge, time.Time{}); err == nil {
+		for _, tag := range tags {
+			_ = c.cache.DeleteEdgeTag(tag.ID)
+		}
+	}
+	_ = c.cache.DeleteEdge(edge.ID)
+}
+
+func (c *Cache) forgetLoadedEdges(entity *types.Entity, name string) {
+	if tag, _, found := c.checkCacheEntityTag(entity, name); found {
+		_ = c.cache.DeleteEntityTag(tag.ID)
+	}
+}
+
+// resolveEntity returns the entity held by the cache for the provided entity, which is reloaded from
+// the database when it has been evicted.
+func (c *Cache) resolveEntity(entity *types.Entity) *types.Entity {
+	if c.lru == nil || entity == nil {
+		return entity
+	}
+
+	if e, err := c.FindEntityById(entity.ID); err == nil {
+		return e
+	}
+	return entity
+}
@@ -0,0 +1,294 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// QueueStats describes the database writes that are waiting in the cache queue.
+type QueueStats struct {
+	// Depth is the number of writes waiting to be executed.
+	Depth int
+	// OldestAge is how long the oldest waiting write has been in the queue.
+	OldestAge time.Duration
+	// OldestOperation is the name of the oldest waiting write.
+	OldestOperation string
+	// Failures is the number of failed writes for each operation name.
+	Failures map[string]int
+}
+
+// QueueError describes a database write from the cache queue that failed.
+type QueueError struct {
+	// Operation is the name of the Repository method that queued the write.
+	Operation string
+	// ID identifies the entity, edge or tag in the cache that the write was for.
+	ID  string
+	Err error
+}
+
+// Error implements the error interface.
+func (e *QueueError) Error() string {
+	return fmt.Sprintf("%s for %s: %v", e.Operation, e.ID, e.Err)
+}
+
+// Unwrap returns the error produced by the database write.
+func (e *QueueError) Unwrap() error {
+	return e.Err
+}
+
+// maxDBErrors is the number of database write errors kept between the calls to Flush, so a cache
+// that is never flushed does not accumulate the errors without bound. The oldest errors are dropped.
+const maxDBErrors = 100
+
+// dbQueue is an unbounded FIFO of the callbacks that write cache changes to the database.
+type dbQueue struct {
+	sync.Mutex
+	closed   bool
+	signal   chan struct{}
+	items    []*queuedCallback
+	failures map[string]int
+}
+
+type queuedCallback struct {
+	op       string
+	id       string
+	queued   time.Time
+	callback func() error
+}
+
+func newDBQueue() *dbQueue {
+	return &dbQueue{
+		signal:   make(chan struct{}, 1),
+		failures: make(map[string]int),
+	}
+}
+
+// append adds the item to the queue and returns false if the queue has been closed.
+func (q *dbQueue) append(item *queuedCallback) bool {
+	q.Lock()
+	if q.closed {
+		q.Unlock()
+		return false
+	}
+	q.items = append(q.items, item)
+	q.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (q *dbQueue) next() (*queuedCallback, bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, false
+	}
+
+	item := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return item, true
+}
+
+func (q *dbQueue) failed(op string) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.failures[op]++
+}
+
+func (q *dbQueue) stats() QueueStats {
+	q.Lock()
+	defer q.Unlock()
+
+	stats := QueueStats{
+		Depth:    len(q.items),
+		Failures: make(map[string]int, len(q.failures)),
+	}
+	if stats.Depth > 0 {
+		stats.OldestAge = time.Since(q.items[0].queued)
+		stats.OldestOperation = q.items[0].op
+	}
+	for op, count := range q.failures {
+		stats.Failures[op] = count
+	}
+	return stats
+}
+
+// close prevents additional callbacks from being appended to the queue.
+func (q *dbQueue) close() {
+	q.Lock()
+	defer q.Unlock()
+
+	q.closed = true
+}
+
+// QueueStats returns the depth of the database write queue, the age of the oldest waiting write
+// and the number of failed writes per operation. A growing age indicates that the database is
+// falling behind the cache.
+func (c *Cache) QueueStats() QueueStats {
+	return c.queue.stats()
+}
+
+// appendToDBQueue queues the callback that writes the change made by the named operation,
+// for the entity, edge or tag with the provided ID in the cache, to the database.
+// The callback must only call c.db, since calling a Cache method from the worker could queue
+// more writes or block on the queue it is draining.
+func (c *Cache) appendToDBQueue(op, id string, callback func() error) {
+	item := &queuedCallback{
+		op:       op,
+		id:       id,
+		queued:   time.Now(),
+		callback: callback,
+	}
+
+	if !c.queue.append(item) {
+		c.dbFailure(item, errors.New("the cache has been closed"))
+	}
+}
+
+// copyProperties returns deep copies of the properties for a queued write, which must not read
+// the values owned by the caller.
+func copyProperties(props []oam.Property) ([]oam.Property, error) {
+	copies := make([]oam.Property, 0, len(props))
+	for _, prop := range props {
+		c, err := types.CopyProperty(prop)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, c)
+	}
+	return copies, nil
+}
+
+// Flush blocks until the database writes queued before the call have been executed, and returns the
+// errors produced by the database writes since the last call to Flush or Close.
+// Flush must not be called from a queued callback, since the worker would wait on itself.
+func (c *Cache) Flush() error {
+	c.waitDBQueue("Flush")
+	return c.dbError()
+}
+
+// syncDB waits for the database writes queued before the call to be executed, so a read that goes to the
+// database observes the changes already made through the cache. The errors produced by the queued writes
+// are left for Flush, Close and the error handler, since they are unrelated to the read.
+// syncDB must not be called from a queued callback.
+func (c *Cache) syncDB() {
+	c.waitDBQueue("syncDB")
+}
+
+// waitDBQueue blocks until a barrier callback, queued behind the waiting writes, has been executed.
+func (c *Cache) waitDBQueue(op string) {
+	done := make(chan struct{})
+	if c.queue.append(&queuedCallback{
+		op:     op,
+		queued: time.Now(),
+		callback: func() error {
+			close(done)
+			return nil
+		},
+	}) {
+		<-done
+	}
+}
+
+func (c *Cache) processDBQueue() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.done:
+			// execute the remaining callbacks before exiting
+			c.drainDBQueue()
+			return
+		case <-c.queue.signal:
+			c.drainDBQueue()
+		}
+	}
+}
+
+func (c *Cache) drainDBQueue() {
+	for item, ok := c.queue.next(); ok; item, ok = c.queue.next() {
+		if err := c.executeCallback(item); err != nil {
+			c.dbFailure(item, err)
+		}
+	}
+}
+
+// executeCallback executes the callback and retries it, as configured by WithRetry, while it fails.
+func (c *Cache) executeCallback(item *queuedCallback) error {
+	err := item.callback()
+
+	delay := c.backoff
+	for i := 0; err != nil && i < c.retries; i++ {
+		time.Sleep(delay)
+		delay *= 2
+		err = item.callback()
+	}
+	return err
+}
+
+func (c *Cache) dbFailure(item *queuedCallback, err error) {
+	qerr := &QueueError{
+		Operation: item.op,
+		ID:        item.id,
+		Err:       err,
+	}
+
+	c.queue.failed(item.op)
+	c.recordDBError(qerr)
+	if c.onError != nil {
+		c.onError(qerr)
+	}
+}
+
+func (c *Cache) recordDBError(err error) {
+	c.errLock.Lock()
+	defer c.errLock.Unlock()
+
+	if len(c.dberrs) >= maxDBErrors {
+		c.dberrs[0] = nil
+		c.dberrs = c.dberrs[1:]
+		c.dropped++
+	}
+	c.dberrs = append(c.dberrs, err)
+	c.lastErr = err
+}
+
+// lastDBError returns the last error produced by a database write, which is kept after it has been
+// returned by Flush or Close.
+func (c *Cache) lastDBError() error {
+	c.errLock.Lock()
+	defer c.errLock.Unlock()
+
+	return c.lastErr
+}
+
+// dbError returns the errors produced by the database writes since the last call, with the number of
+// the errors that were dropped when more than maxDBErrors were recorded.
+func (c *Cache) dbError() error {
+	c.errLock.Lock()
+	defer c.errLock.Unlock()
+
+	errs := c.dberrs
+	if c.dropped > 0 {
+		errs = append([]error{fmt.Errorf("%d earlier database write errors were dropped", c.dropped)}, errs...)
+	}
+
+	c.dberrs = nil
+	c.dropped = 0
+	return errors.Join(errs...)
+}
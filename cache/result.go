@@ -0,0 +1,354 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// ResultCache is an optional Repository wrapper that keeps the results of repeated
+// identical queries for a limited time. Cached results are invalidated by writes
+// that affect them. Methods that are not cached are passed to the wrapped repository.
+// The results are copied when stored and when returned, so callers can't modify the cached results.
+type ResultCache struct {
+	repository.Repository
+	sync.Mutex
+	size    int
+	ttl     time.Duration
+	lru     *list.List
+	entries map[string]*list.Element
+	groups  map[string]map[string]*list.Element
+	// gen is incremented by each invalidation, so a result read from the repository before a
+	// write that invalidated it is not stored.
+	gen uint64
+}
+
+type resultEntry struct {
+	key     string
+	group   string
+	expires time.Time
+	value   interface{}
+}
+
+// NewResultCache returns a ResultCache wrapping the repository that keeps up to size
+// results, each for the duration of the ttl.
+func NewResultCache(repo repository.Repository, size int, ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		Repository: repo,
+		size:       size,
+		ttl:        ttl,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		groups:     make(map[string]map[string]*list.Element),
+	}
+}
+
+// FindEntitiesByType implements the Repository interface.
+func (r *ResultCache) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	key := resultKey("FindEntitiesByType", atype, since.UnixNano())
+	v, gen, found := r.get(key)
+	if found {
+		if entities, err := copyEntities(v.([]*types.Entity)); err == nil {
+			return entities, nil
+		}
+	}
+
+	entities, err := r.Repository.FindEntitiesByType(atype, since)
+	if err == nil {
+		if stored, cerr := copyEntities(entities); cerr == nil {
+			r.put(key, typeGroup(atype), gen, stored)
+		}
+	}
+	return entities, err
+}
+
+// OutgoingEdges implements the Repository interface.
+func (r *ResultCache) OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+
+	key := resultKey("OutgoingEdges", entity.ID, since.UnixNano(), strings.Join(sorted, ","))
+	v, gen, found := r.get(key)
+	if found {
+		if edges, err := copyEdges(v.([]*types.Edge)); err == nil {
+			return edges, nil
+		}
+	}
+
+	edges, err := r.Repository.OutgoingEdges(entity, since, labels...)
+	if err == nil {
+		if stored, cerr := copyEdges(edges); cerr == nil {
+			r.put(key, edgeGroup(entity.ID), gen, stored)
+		}
+	}
+	return edges, err
+}
+
+// CreateEntity implements the Repository interface.
+func (r *ResultCache) CreateEntity(entity *types.Entity) (*types.Entity, error) {
+	e, err := r.Repository.CreateEntity(entity)
+	if err == nil {
+		r.invalidate(typeGroup(e.Asset.AssetType()))
+	}
+	return e, err
+}
+
+// CreateEntityWithPrevLastSeen implements the Repository interface.
+func (r *ResultCache) CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error) {
+	e, prev, err := r.Repository.CreateEntityWithPrevLastSeen(entity)
+	if err == nil {
+		r.invalidate(typeGroup(e.Asset.AssetType()))
+	}
+	return e, prev, err
+}
+
+// CreateAsset implements the Repository interface.
+func (r *ResultCache) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	e, err := r.Repository.CreateAsset(asset)
+	if err == nil {
+		r.invalidate(typeGroup(asset.AssetType()))
+	}
+	return e, err
+}
+
+// UpsertEntity implements the Repository interface.
+func (r *ResultCache) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	e, created, err := r.Repository.UpsertEntity(asset)
+	if err == nil {
+		r.invalidate(typeGroup(asset.AssetType()))
+	}
+	return e, created, err
+}
+
+// UpdateEntityContentCAS implements the Repository interface.
+func (r *ResultCache) UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error) {
+	applied, err := r.Repository.UpdateEntityContentCAS(id, expected, new)
+	if err == nil && applied {
+		r.invalidate(typeGroup(new.AssetType()))
+		// the outgoing edges hold copies of the entity content
+		r.invalidatePrefix("edges:")
+	}
+	return applied, err
+}
+
+// DeleteEntity implements the Repository interface.
+func (r *ResultCache) DeleteEntity(id string) error {
+	entity, ferr := r.Repository.FindEntityById(id)
+
+	err := r.Repository.DeleteEntity(id)
+	if err == nil {
+		if ferr == nil {
+			r.invalidate(typeGroup(entity.Asset.AssetType()))
+		}
+		// edges of other entities referencing this entity were removed as well
+		r.invalidatePrefix("edges:")
+	}
+	return err
+}
+
+// CreateEdge implements the Repository interface.
+func (r *ResultCache) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	e, err := r.Repository.CreateEdge(edge)
+	if err == nil {
+		r.invalidate(edgeGroup(edge.FromEntity.ID))
+	}
+	return e, err
+}
+
+// UpsertEdge implements the Repository interface.
+func (r *ResultCache) UpsertEdge(edge *types.Edge) (*types.Edge, bool, error) {
+	e, created, err := r.Repository.UpsertEdge(edge)
+	if err == nil {
+		r.invalidate(edgeGroup(edge.FromEntity.ID))
+	}
+	return e, created, err
+}
+
+// CreateEdges implements the Repository interface.
+// The cached edges of every source entity in the batch are invalidated, since a failed batch
+// may have created some of the edges.
+func (r *ResultCache) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	results, err := r.Repository.CreateEdges(edges)
+
+	for _, edge := range edges {
+		if edge != nil && edge.FromEntity != nil {
+			r.invalidate(edgeGroup(edge.FromEntity.ID))
+		}
+	}
+	return results, err
+}
+
+// CreateEdgeWithProperties implements the Repository interface.
+func (r *ResultCache) CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error) {
+	e, tags, err := r.Repository.CreateEdgeWithProperties(edge, props)
+	if err == nil {
+		r.invalidate(edgeGroup(edge.FromEntity.ID))
+	}
+	return e, tags, err
+}
+
+// DeleteEdge implements the Repository interface.
+func (r *ResultCache) DeleteEdge(id string) error {
+	edge, ferr := r.Repository.FindEdgeById(id)
+
+	err := r.Repository.DeleteEdge(id)
+	if err == nil {
+		if ferr == nil {
+			r.invalidate(edgeGroup(edge.FromEntity.ID))
+		} else {
+			r.invalidatePrefix("edges:")
+		}
+	}
+	return err
+}
+
+// get returns the unexpired result stored with the key, and the generation to provide to put
+// when the result is not found and is read from the repository.
+func (r *ResultCache) get(key string) (interface{}, uint64, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	element, found := r.entries[key]
+	if !found {
+		return nil, r.gen, false
+	}
+
+	entry := element.Value.(*resultEntry)
+	if time.Now().After(entry.expires) {
+		r.remove(element)
+		return nil, r.gen, false
+	}
+
+	r.lru.MoveToFront(element)
+	return entry.value, r.gen, true
+}
+
+// put stores the result with the key, unless a result was invalidated since the generation was
+// returned by get, since the result may have been read before the write that invalidated it.
+func (r *ResultCache) put(key, group string, gen uint64, value interface{}) {
+	if r.size <= 0 || r.ttl <= 0 {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if gen != r.gen {
+		return
+	}
+	if element, found := r.entries[key]; found {
+		r.remove(element)
+	}
+
+	element := r.lru.PushFront(&resultEntry{
+		key:     key,
+		group:   group,
+		expires: time.Now().Add(r.ttl),
+		value:   value,
+	})
+	r.entries[key] = element
+
+	members, found := r.groups[group]
+	if !found {
+		members = make(map[string]*list.Element)
+		r.groups[group] = members
+	}
+	members[key] = element
+
+	for r.lru.Len() > r.size {
+		r.remove(r.lru.Back())
+	}
+}
+
+func (r *ResultCache) invalidate(group string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.gen++
+	for _, element := range r.groups[group] {
+		r.remove(element)
+	}
+}
+
+func (r *ResultCache) invalidatePrefix(prefix string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.gen++
+	for group, members := range r.groups {
+		if strings.HasPrefix(group, prefix) {
+			for _, element := range members {
+				r.remove(element)
+			}
+		}
+	}
+}
+
+func (r *ResultCache) remove(element *list.Element) {
+	entry := element.Value.(*resultEntry)
+
+	r.lru.Remove(element)
+	delete(r.entries, entry.key)
+	if members, found := r.groups[entry.group]; found {
+		delete(members, entry.key)
+		if len(members) == 0 {
+			delete(r.groups, entry.group)
+		}
+	}
+}
+
+// resultKey returns the key of the result produced by the method with the arguments.
+// Each argument is quoted, so a separator in an argument can't make two keys equal.
+func resultKey(method string, args ...interface{}) string {
+	var b strings.Builder
+
+	b.WriteString(method)
+	for _, arg := range args {
+		b.WriteString("|" + strconv.Quote(fmt.Sprint(arg)))
+	}
+	return b.String()
+}
+
+func copyEntities(entities []*types.Entity) ([]*types.Entity, error) {
+	results := make([]*types.Entity, 0, len(entities))
+	for _, entity := range entities {
+		c, err := entity.Copy()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+	return results, nil
+}
+
+func copyEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	results := make([]*types.Edge, 0, len(edges))
+	for _, edge := range edges {
+		c, err := edge.Copy()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+	return results, nil
+}
+
+func typeGroup(atype oam.AssetType) string {
+	return "type:" + string(atype)
+}
+
+func edgeGroup(id string) string {
+	return "edges:" + id
+}
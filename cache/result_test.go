@@ -0,0 +1,148 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingRepository struct {
+	repository.Repository
+	byType   int
+	outgoing int
+}
+
+func (r *countingRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	r.byType++
+	return []*types.Entity{{ID: "1", Asset: &domain.FQDN{Name: "owasp.org"}}}, nil
+}
+
+func (r *countingRepository) OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	r.outgoing++
+	return []*types.Edge{{ID: "2", FromEntity: entity}}, nil
+}
+
+func (r *countingRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	return &types.Entity{ID: "3", Asset: asset}, nil
+}
+
+func (r *countingRepository) CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error) {
+	return &types.Entity{ID: "4", Asset: entity.Asset}, time.Time{}, nil
+}
+
+func TestResultCacheHits(t *testing.T) {
+	fake := &countingRepository{}
+	r := NewResultCache(fake, 10, time.Minute)
+
+	_, err := r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	_, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.byType)
+
+	entity := &types.Entity{ID: "1"}
+	_, err = r.OutgoingEdges(entity, time.Time{}, "dns_record", "node")
+	assert.NoError(t, err)
+	_, err = r.OutgoingEdges(entity, time.Time{}, "node", "dns_record")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.outgoing)
+
+	// a different argument is a different result
+	_, err = r.OutgoingEdges(entity, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.outgoing)
+
+	// writing an asset of the same type invalidates the cached result
+	_, err = r.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+	_, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.byType)
+
+	_, _, err = r.CreateEntityWithPrevLastSeen(&types.Entity{Asset: &domain.FQDN{Name: "mail.owasp.org"}})
+	assert.NoError(t, err)
+	_, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fake.byType)
+}
+
+func TestResultCacheExpiration(t *testing.T) {
+	fake := &countingRepository{}
+	r := NewResultCache(fake, 1, 100*time.Millisecond)
+
+	_, _ = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	time.Sleep(150 * time.Millisecond)
+	_, _ = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.Equal(t, 2, fake.byType)
+
+	// the size limit evicts the least recently used result
+	_, _ = r.FindEntitiesByType(oam.IPAddress, time.Time{})
+	_, _ = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.Equal(t, 4, fake.byType)
+}
+
+type racingRepository struct {
+	*countingRepository
+	during func()
+}
+
+func (r *racingRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	if r.during != nil {
+		r.during()
+	}
+	return r.countingRepository.FindEntitiesByType(atype, since)
+}
+
+func TestResultCacheIsolation(t *testing.T) {
+	fake := &countingRepository{}
+	r := NewResultCache(fake, 10, time.Minute)
+
+	entities, err := r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	entities[0].Asset.(*domain.FQDN).Name = "example.com"
+
+	// changes made by the caller are not seen in the cached result
+	entities, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.byType)
+	assert.Equal(t, "owasp.org", entities[0].Asset.(*domain.FQDN).Name)
+	entities[0].ID = "5"
+
+	entities, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", entities[0].ID)
+}
+
+func TestResultCacheInvalidatedDuringRead(t *testing.T) {
+	fake := &racingRepository{countingRepository: &countingRepository{}}
+	r := NewResultCache(fake, 10, time.Minute)
+
+	// a write completing while the result is read from the repository prevents it from being stored
+	fake.during = func() {
+		_, _ = r.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	}
+	_, err := r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+
+	fake.during = nil
+	_, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	_, err = r.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.byType)
+}
+
+func TestResultKey(t *testing.T) {
+	// the separator in an argument doesn't produce the key of other arguments
+	assert.NotEqual(t, resultKey("OutgoingEdges", "1|2", "3"), resultKey("OutgoingEdges", "1", "2|3"))
+	assert.Equal(t, resultKey("OutgoingEdges", "1", "2"), resultKey("OutgoingEdges", "1", "2"))
+}
@@ -0,0 +1,43 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"testing"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/repository/memrepo"
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/repository/repotest"
+	"github.com/owasp-amass/asset-db/repository/sqlrepo"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	for _, dbtype := range []string{memrepo.Memory, sqlrepo.SQLiteMemory} {
+		t.Run(dbtype, func(t *testing.T) {
+			repotest.RunRepositoryConformance(t, func() repository.Repository {
+				db, err := New(dbtype, "")
+				if err != nil {
+					t.Fatalf("failed to create the %s repository: %v", dbtype, err)
+				}
+				return db
+			})
+		})
+	}
+}
+
+func TestEmptyResultsConformance(t *testing.T) {
+	for _, dbtype := range []string{memrepo.Memory, sqlrepo.SQLiteMemory} {
+		t.Run(dbtype, func(t *testing.T) {
+			repotest.RunEmptyResultsConformance(t, func() repository.Repository {
+				db, err := New(dbtype, "", options.WithEmptyResults())
+				if err != nil {
+					t.Fatalf("failed to create the %s repository: %v", dbtype, err)
+				}
+				return db
+			})
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	sqlitemigrations "github.com/owasp-amass/asset-db/migrations/sqlite3"
 	"github.com/owasp-amass/asset-db/repository"
 	"github.com/owasp-amass/asset-db/repository/neo4j"
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/repository/sqlrepo"
 	migrate "github.com/rubenv/sql-migrate"
 	"gorm.io/driver/postgres"
@@ -29,12 +30,13 @@ import (
 
 // New creates a new assetDB instance.
 // It initializes the asset database with the specified database type and DSN.
-func New(dbtype, dsn string) (repository.Repository, error) {
+// The provided options are used to configure the repository.
+func New(dbtype, dsn string, opts ...options.Option) (repository.Repository, error) {
 	if dbtype == sqlrepo.SQLiteMemory {
 		dsn = fmt.Sprintf("file:mem%d?mode=memory&cache=shared", rand.Intn(1000))
 	}
 
-	db, err := repository.New(dbtype, dsn)
+	db, err := repository.New(dbtype, dsn, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,370 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/relation"
+)
+
+// ImportEdgesCSV reads rows of (from-key, label, to-key) from r and creates an edge in the repository for each row.
+// Each key has the form AssetType:value, such as FQDN:owasp.org, and the value is matched against the field that
+// identifies assets of that type. The label is stored as a SimpleRelation and validated against the taxonomy.
+// The label may be prefixed with the relation type, such as SimpleRelation:node, and rows with any other relation
+// type are rejected, since the other relation types hold content that a label can't provide.
+// An optional header row starting with "from" is skipped.
+// The edges are created with CreateEdges in batches of the BatchSize option, or of a default size when it's zero.
+// Rows with unknown endpoints, invalid labels or unsupported relation types do not stop the import, and are reported
+// with their line numbers in the returned error.
+func ImportEdgesCSV(db repository.Repository, r io.Reader, opts ...options.Option) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	size := options.New(opts...).BatchSize
+	if size <= 0 {
+		size = defaultImportBatchSize
+	}
+
+	var errs []error
+	var lines []int
+	var batch []*types.Edge
+	flush := func() {
+		errs = append(errs, createEdgeBatch(db, batch, lines)...)
+		batch, lines = nil, nil
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if errors.Is(err, csv.ErrFieldCount) {
+			// the parse error includes the line number
+			errs = append(errs, err)
+			continue
+		} else if err != nil {
+			flush()
+			return errors.Join(append(errs, err)...)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if line == 1 && strings.EqualFold(row[0], "from") {
+			continue
+		}
+
+		edge, err := edgeFromRow(db, row)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+
+		batch = append(batch, edge)
+		lines = append(lines, line)
+		if len(batch) >= size {
+			flush()
+		}
+	}
+
+	flush()
+	return errors.Join(errs...)
+}
+
+// createEdgeBatch creates the edges with CreateEdges. When the batch fails, the edges are created one at a time,
+// so the rows that caused the failure are reported with their line numbers.
+func createEdgeBatch(db repository.Repository, edges []*types.Edge, lines []int) []error {
+	if len(edges) == 0 {
+		return nil
+	}
+	if _, err := db.CreateEdges(edges); err == nil {
+		return nil
+	}
+
+	var errs []error
+	for i, edge := range edges {
+		if _, err := db.CreateEdge(edge); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", lines[i], err))
+		}
+	}
+	return errs
+}
+
+func edgeFromRow(db repository.Repository, row []string) (*types.Edge, error) {
+	from, err := findEntityByKey(db, row[0])
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := findEntityByKey(db, row[2])
+	if err != nil {
+		return nil, err
+	}
+
+	label := strings.TrimSpace(row[1])
+	if rtype, name, found := strings.Cut(label, ":"); found {
+		if oam.RelationType(rtype) != oam.SimpleRelation {
+			return nil, fmt.Errorf("the relation type %s is not supported, only %s can be imported", rtype, oam.SimpleRelation)
+		}
+		label = strings.TrimSpace(name)
+	}
+
+	if !oam.ValidRelationship(from.Asset.AssetType(), label, oam.SimpleRelation, to.Asset.AssetType()) {
+		return nil, fmt.Errorf("%s -%s-> %s is not valid in the taxonomy", from.Asset.AssetType(), label, to.Asset.AssetType())
+	}
+
+	return &types.Edge{
+		Relation:   &relation.SimpleRelation{Name: label},
+		FromEntity: from,
+		ToEntity:   to,
+	}, nil
+}
+
+func findEntityByKey(db repository.Repository, key string) (*types.Entity, error) {
+	atype, value, found := strings.Cut(strings.TrimSpace(key), ":")
+	if !found || value == "" {
+		return nil, fmt.Errorf("the key %s is not in the form AssetType:value", key)
+	}
+
+	asset, err := types.AssetFromKey(oam.AssetType(atype), value)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := db.FindEntitiesByContent(asset, time.Time{})
+	if err != nil || len(entities) == 0 {
+		return nil, fmt.Errorf("the entity %s was not found", key)
+	}
+	return entities[0], nil
+}
+
+// The kinds of the records read by ImportJSONStream and written by ExportJSONL.
+const (
+	KindEntity    string = "entity"
+	KindEdge      string = "edge"
+	KindEntityTag string = "entity_tag"
+	KindEdgeTag   string = "edge_tag"
+)
+
+// ImportRecord is a record of the JSON stream read by ImportJSONStream. Exactly one of the record fields is set.
+// The Kind names the field that is set. It is written by ExportJSONL and is optional on import, but a record
+// with a Kind that does not match the field that is set is rejected.
+// The IDs in the record are ignored, and the entities are matched by the content of their assets, so a
+// record that refers to an entity, such as an edge, must include the asset of the entity.
+type ImportRecord struct {
+	Kind      string           `json:"kind,omitempty"`
+	Entity    *types.Entity    `json:"entity,omitempty"`
+	Edge      *types.Edge      `json:"edge,omitempty"`
+	EntityTag *types.EntityTag `json:"entity_tag,omitempty"`
+	EdgeTag   *types.EdgeTag   `json:"edge_tag,omitempty"`
+}
+
+// ImportProgress describes the records handled by ImportJSONStream so far.
+type ImportProgress struct {
+	// Records is the number of records that were read from the stream.
+	Records int
+	// Errors is the number of records that could not be written to the repository.
+	Errors int
+}
+
+// defaultImportBatchSize is the number of records or rows read before they are written, when no batch size is provided.
+const defaultImportBatchSize int = 100
+
+// ImportJSONStream reads a stream of JSON ImportRecords from r and writes them to the repository.
+// The records are decoded one at a time and written in batches of batchSize records, so the memory used
+// by the import does not depend on the size of the stream. When batchSize is not positive, a default is used.
+// Each batch is written within a single transaction when the repository supports them. When the transaction
+// fails or is not supported, the records of the batch are written one at a time, so the records that cannot be
+// written are reported with their record numbers.
+// The progress callback, when not nil, is invoked after each batch has been written.
+// Records that cannot be written do not stop the import, and are reported with their record numbers in the
+// returned error. The import stops when the stream is malformed or the context is cancelled.
+func ImportJSONStream(ctx context.Context, db repository.Repository, r io.Reader, batchSize int, progress func(ImportProgress)) error {
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	dec := json.NewDecoder(r)
+	batch := make([]*ImportRecord, 0, batchSize)
+
+	var errs []error
+	var state ImportProgress
+	for {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		batch = batch[:0]
+		for len(batch) < batchSize && dec.More() {
+			var rec ImportRecord
+			if err := dec.Decode(&rec); err != nil {
+				return errors.Join(append(errs, fmt.Errorf("record %d: %v", state.Records+len(batch)+1, err))...)
+			}
+			batch = append(batch, &rec)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		batchErrs := importBatch(db, batch, state.Records)
+		state.Records += len(batch)
+		state.Errors += len(batchErrs)
+		errs = append(errs, batchErrs...)
+
+		if progress != nil {
+			progress(state)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// importBatch writes the records within a transaction. When the transaction fails, the records are written one
+// at a time, and the records that fail are reported with their record numbers, which follow the offset.
+func importBatch(db repository.Repository, batch []*ImportRecord, offset int) []error {
+	err := repository.Transaction(db, func(tx repository.Repository) error {
+		for _, rec := range batch {
+			if err := importRecord(tx, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	var errs []error
+	for i, rec := range batch {
+		if err := importRecord(db, rec); err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %v", offset+i+1, err))
+		}
+	}
+	return errs
+}
+
+// kind returns the kind of the field that is set in the record, or an empty string when the record is empty.
+func (rec *ImportRecord) kind() string {
+	switch {
+	case rec.Entity != nil:
+		return KindEntity
+	case rec.Edge != nil:
+		return KindEdge
+	case rec.EntityTag != nil:
+		return KindEntityTag
+	case rec.EdgeTag != nil:
+		return KindEdgeTag
+	}
+	return ""
+}
+
+func importRecord(db repository.Repository, rec *ImportRecord) error {
+	if kind := rec.kind(); rec.Kind != "" && rec.Kind != kind {
+		return fmt.Errorf("the record of kind %s holds the field of kind %s", rec.Kind, kind)
+	}
+
+	switch {
+	case rec.Entity != nil:
+		_, err := importEntity(db, rec.Entity)
+		return err
+	case rec.Edge != nil:
+		_, err := importEdge(db, rec.Edge)
+		return err
+	case rec.EntityTag != nil:
+		if rec.EntityTag.Property == nil {
+			return errors.New("the entity tag has no property")
+		}
+
+		entity, err := resolveEntity(db, rec.EntityTag.Entity)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.CreateEntityTag(entity, &types.EntityTag{
+			CreatedAt: rec.EntityTag.CreatedAt,
+			LastSeen:  rec.EntityTag.LastSeen,
+			Property:  rec.EntityTag.Property,
+		})
+		return err
+	case rec.EdgeTag != nil:
+		if rec.EdgeTag.Property == nil {
+			return errors.New("the edge tag has no property")
+		}
+
+		edge, err := importEdge(db, rec.EdgeTag.Edge)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.CreateEdgeTag(edge, &types.EdgeTag{
+			CreatedAt: rec.EdgeTag.CreatedAt,
+			LastSeen:  rec.EdgeTag.LastSeen,
+			Property:  rec.EdgeTag.Property,
+		})
+		return err
+	}
+	return errors.New("the record is empty")
+}
+
+// importEntity creates the entity, or updates the existing entity with the same asset content.
+func importEntity(db repository.Repository, entity *types.Entity) (*types.Entity, error) {
+	if entity == nil || entity.Asset == nil {
+		return nil, errors.New("the entity has no asset")
+	}
+
+	return db.CreateEntity(&types.Entity{
+		CreatedAt: entity.CreatedAt,
+		LastSeen:  entity.LastSeen,
+		Asset:     entity.Asset,
+	})
+}
+
+// resolveEntity returns the existing entity with the same asset content, or creates the entity when it does not exist.
+func resolveEntity(db repository.Repository, entity *types.Entity) (*types.Entity, error) {
+	if entity == nil || entity.Asset == nil {
+		return nil, errors.New("the entity has no asset")
+	}
+
+	if entities, err := db.FindEntitiesByContent(entity.Asset, time.Time{}); err == nil && len(entities) > 0 {
+		return entities[0], nil
+	}
+	return importEntity(db, entity)
+}
+
+// importEdge creates the edge between the entities, and the entities when they do not exist.
+func importEdge(db repository.Repository, edge *types.Edge) (*types.Edge, error) {
+	if edge == nil || edge.Relation == nil {
+		return nil, errors.New("the edge has no relation")
+	}
+
+	from, err := resolveEntity(db, edge.FromEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := resolveEntity(db, edge.ToEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.CreateEdge(&types.Edge{
+		CreatedAt:  edge.CreatedAt,
+		LastSeen:   edge.LastSeen,
+		Relation:   edge.Relation,
+		FromEntity: from,
+		ToEntity:   to,
+	})
+}
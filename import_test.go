@@ -0,0 +1,128 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/repository/sqlrepo"
+	"github.com/owasp-amass/asset-db/types"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+type batchCountingRepository struct {
+	repository.Repository
+	batches []int
+}
+
+func (r *batchCountingRepository) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	r.batches = append(r.batches, len(edges))
+	return r.Repository.CreateEdges(edges)
+}
+
+func TestImportEdgesCSV(t *testing.T) {
+	db, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	from, err := db.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	to, err := db.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+	_, err = db.CreateAsset(&domain.FQDN{Name: "api.owasp.org"})
+	assert.NoError(t, err)
+	_, err = db.CreateAsset(&domain.FQDN{Name: "mail.owasp.org"})
+	assert.NoError(t, err)
+
+	input := strings.Join([]string{
+		"from,label,to",
+		"FQDN:owasp.org,node,FQDN:www.owasp.org",
+		"FQDN:owasp.org,node,FQDN:missing.owasp.org",
+		"FQDN:owasp.org,BasicDNSRelation:dns_record,FQDN:www.owasp.org",
+		"FQDN:owasp.org,SimpleRelation:node,FQDN:api.owasp.org",
+		"FQDN:owasp.org,node,FQDN:mail.owasp.org",
+	}, "\n")
+
+	counter := &batchCountingRepository{Repository: db}
+	err = ImportEdgesCSV(counter, strings.NewReader(input), options.WithBatchSize(2))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+	assert.Contains(t, err.Error(), "line 4")
+	assert.NotContains(t, err.Error(), "line 2")
+	assert.NotContains(t, err.Error(), "line 5")
+	assert.Equal(t, []int{2, 1}, counter.batches)
+
+	edges, err := db.OutgoingEdges(from, time.Time{}, "node")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 3)
+
+	var found bool
+	for _, edge := range edges {
+		if edge.ToEntity.ID == to.ID {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestImportJSONStream(t *testing.T) {
+	db, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, name := range []string{"stream.owasp.org", "www.stream.owasp.org", "api.stream.owasp.org"} {
+		assert.NoError(t, enc.Encode(&ImportRecord{Entity: &types.Entity{Asset: &domain.FQDN{Name: name}}}))
+	}
+	assert.NoError(t, enc.Encode(&ImportRecord{Edge: &types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: &types.Entity{Asset: &domain.FQDN{Name: "stream.owasp.org"}},
+		ToEntity:   &types.Entity{Asset: &domain.FQDN{Name: "www.stream.owasp.org"}},
+	}}))
+	assert.NoError(t, enc.Encode(&ImportRecord{}))
+	stream := buf.Bytes()
+
+	var updates []ImportProgress
+	err = ImportJSONStream(context.Background(), db, bytes.NewReader(stream), 2, func(p ImportProgress) {
+		updates = append(updates, p)
+	})
+	assert.ErrorContains(t, err, "record 5")
+	assert.Equal(t, []ImportProgress{{Records: 2}, {Records: 4}, {Records: 5, Errors: 1}}, updates)
+
+	from, err := db.FindEntitiesByContent(&domain.FQDN{Name: "stream.owasp.org"}, time.Time{})
+	assert.NoError(t, err)
+	edges, err := db.OutgoingEdges(from[0], time.Time{}, "node")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 1)
+
+	// cancelling the context after the first batch stops the import
+	db2, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db2.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates = nil
+	err = ImportJSONStream(ctx, db2, bytes.NewReader(stream), 2, func(p ImportProgress) {
+		updates = append(updates, p)
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, updates, 1)
+
+	_, err = db2.FindEntitiesByContent(&domain.FQDN{Name: "api.stream.owasp.org"}, time.Time{})
+	assert.Error(t, err)
+}
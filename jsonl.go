@@ -0,0 +1,104 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// ExportJSONL writes every entity, edge, entity tag and edge tag in the repository to w as one ImportRecord per line,
+// with the Kind of each record set. The records refer to entities by their assets, so the dump can be read by
+// ImportJSONL or ImportJSONStream into a repository that assigns different IDs.
+// The entities are read one asset type at a time, and each entity is followed by its tags, its outgoing edges
+// and the tags of those edges, so only the entities of a single asset type are held in memory.
+// The export only uses the Repository interface, which allows moving the data between database backends.
+func ExportJSONL(db repository.Repository, w io.Writer) error {
+	enc := json.NewEncoder(w)
+
+	for _, atype := range oam.AssetList {
+		entities, err := db.FindEntitiesByType(atype, time.Time{})
+		if errors.Is(err, types.ErrNoResults) {
+			// no entities of the asset type
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to find the %s entities: %w", atype, err)
+		}
+
+		for _, e := range entities {
+			if err := exportEntity(db, enc, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportEntity writes the entity, its tags, its outgoing edges and the tags of the edges.
+func exportEntity(db repository.Repository, enc *json.Encoder, e *types.Entity) error {
+	if err := enc.Encode(&ImportRecord{Kind: KindEntity, Entity: e}); err != nil {
+		return err
+	}
+
+	tags, err := db.GetEntityTags(e, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return fmt.Errorf("failed to get the tags of entity %s: %w", e.ID, err)
+	}
+	for _, tag := range tags {
+		tag.Entity = e
+		if err := enc.Encode(&ImportRecord{Kind: KindEntityTag, EntityTag: tag}); err != nil {
+			return err
+		}
+	}
+
+	edges, err := db.OutgoingEdges(e, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return fmt.Errorf("failed to find the edges of entity %s: %w", e.ID, err)
+	}
+	for _, edge := range edges {
+		edge.FromEntity = e
+		// some repositories only provide the ID of the entities of an edge
+		if edge.ToEntity != nil && edge.ToEntity.Asset == nil {
+			to, err := db.FindEntityById(edge.ToEntity.ID)
+			if err != nil {
+				return fmt.Errorf("failed to find the entity %s: %w", edge.ToEntity.ID, err)
+			}
+			edge.ToEntity = to
+		}
+		if err := enc.Encode(&ImportRecord{Kind: KindEdge, Edge: edge}); err != nil {
+			return err
+		}
+
+		tags, err := db.GetEdgeTags(edge, time.Time{})
+		if err != nil && !errors.Is(err, types.ErrNoResults) {
+			return fmt.Errorf("failed to get the tags of edge %s: %w", edge.ID, err)
+		}
+		for _, tag := range tags {
+			tag.Edge = edge
+			if err := enc.Encode(&ImportRecord{Kind: KindEdgeTag, EdgeTag: tag}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ImportJSONL reads the JSON Lines dump written by ExportJSONL from r and recreates the records in the repository,
+// using ImportJSONStream with the default batch size. The entities are matched by their assets, so the records
+// receive the IDs assigned by the repository, and the created and last seen times are preserved for new records.
+// The entities of an edge are created before the edge when they are not in the repository yet.
+// Records that cannot be created do not stop the import, and are reported with their record numbers, which are
+// the line numbers of the dump, in the returned error. The import stops when the dump is malformed.
+func ImportJSONL(db repository.Repository, r io.Reader) error {
+	return ImportJSONStream(context.Background(), db, r, 0, nil)
+}
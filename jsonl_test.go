@@ -0,0 +1,156 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/repository/sqlrepo"
+	"github.com/owasp-amass/asset-db/types"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLRoundTrip(t *testing.T) {
+	src, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer src.Close()
+
+	created := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	seen := created.Add(24 * time.Hour)
+
+	apex, err := src.CreateEntity(&types.Entity{CreatedAt: created, LastSeen: seen, Asset: &domain.FQDN{Name: "jsonl.owasp.org"}})
+	assert.NoError(t, err)
+	www, err := src.CreateAsset(&domain.FQDN{Name: "www.jsonl.owasp.org"})
+	assert.NoError(t, err)
+	_, err = src.CreateEntityProperty(apex, &property.SourceProperty{Source: "dns", Confidence: 90})
+	assert.NoError(t, err)
+
+	edge, err := src.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+	_, err = src.CreateEdgeProperty(edge, &property.SimpleProperty{PropertyName: "note", PropertyValue: "cname"})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, ExportJSONL(src, &buf))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	var first ImportRecord
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, KindEntity, first.Kind)
+
+	dst, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer dst.Close()
+
+	// an unrelated entity shifts the IDs assigned by the destination repository
+	_, err = dst.CreateAsset(&domain.FQDN{Name: "other.owasp.org"})
+	assert.NoError(t, err)
+	assert.NoError(t, ImportJSONL(dst, &buf))
+
+	found, err := dst.FindEntitiesByContent(&domain.FQDN{Name: "jsonl.owasp.org"}, time.Time{})
+	assert.NoError(t, err)
+	if !assert.Len(t, found, 1) {
+		return
+	}
+	assert.True(t, created.Equal(found[0].CreatedAt))
+	assert.True(t, seen.Equal(found[0].LastSeen))
+
+	tags, err := dst.GetEntityTags(found[0], time.Time{}, "dns")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	ins, err := dst.IncomingEdges(found[0], time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	if assert.Len(t, ins, 1) {
+		assert.Equal(t, edge.Relation, ins[0].Relation)
+
+		etags, err := dst.GetEdgeTags(ins[0], time.Time{}, "note")
+		assert.NoError(t, err)
+		assert.Len(t, etags, 1)
+	}
+}
+
+type failingTagsRepository struct {
+	repository.Repository
+}
+
+func (r *failingTagsRepository) GetEntityTags(entity *types.Entity, since time.Time, names ...string) ([]*types.EntityTag, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestExportJSONLErrors(t *testing.T) {
+	db, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// the asset types without entities are exported without errors
+	var buf bytes.Buffer
+	assert.NoError(t, ExportJSONL(db, &buf))
+
+	_, err = db.CreateAsset(&domain.FQDN{Name: "export.owasp.org"})
+	assert.NoError(t, err)
+
+	// the failures of the repository are not mistaken for missing records
+	err = ExportJSONL(&failingTagsRepository{Repository: db}, &buf)
+	assert.ErrorContains(t, err, "connection reset")
+}
+
+func TestImportJSONLRecordOrder(t *testing.T) {
+	db, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	edge := &types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: &types.Entity{Asset: &domain.FQDN{Name: "order.owasp.org"}},
+		ToEntity:   &types.Entity{Asset: &domain.FQDN{Name: "www.order.owasp.org"}},
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// the edge and its tag precede the entities of the edge
+	assert.NoError(t, enc.Encode(&ImportRecord{Kind: KindEdge, Edge: edge}))
+	assert.NoError(t, enc.Encode(&ImportRecord{Kind: KindEdgeTag, EdgeTag: &types.EdgeTag{
+		Property: &property.SimpleProperty{PropertyName: "note", PropertyValue: "early"},
+		Edge:     edge,
+	}}))
+	assert.NoError(t, enc.Encode(&ImportRecord{Kind: KindEntity, Entity: edge.FromEntity}))
+	assert.NoError(t, enc.Encode(&ImportRecord{Entity: edge.ToEntity}))
+	// the kind does not match the field that is set
+	assert.NoError(t, enc.Encode(&ImportRecord{Kind: KindEdge, Entity: &types.Entity{Asset: &domain.FQDN{Name: "kind.owasp.org"}}}))
+
+	err = ImportJSONL(db, &buf)
+	assert.ErrorContains(t, err, "record 5")
+	assert.NotContains(t, err.Error(), "record 1")
+
+	_, err = db.FindEntitiesByContent(&domain.FQDN{Name: "kind.owasp.org"}, time.Time{})
+	assert.Error(t, err)
+
+	from, err := db.FindEntitiesByContent(&domain.FQDN{Name: "order.owasp.org"}, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, from, 1) {
+		edges, err := db.OutgoingEdges(from[0], time.Time{}, "node")
+		assert.NoError(t, err)
+		if assert.Len(t, edges, 1) {
+			tags, err := db.GetEdgeTags(edges[0], time.Time{}, "note")
+			assert.NoError(t, err)
+			assert.Len(t, tags, 1)
+		}
+	}
+}
@@ -29,6 +29,16 @@ func InitializeSchema(driver neo4jdb.DriverWithContext, dbname string) error {
 		return err
 	}
 
+	err = executeQuery(driver, dbname, "CREATE INDEX entities_range_index_etype_updated_at IF NOT EXISTS FOR (n:Entity) ON (n.etype, n.updated_at)")
+	if err != nil {
+		return err
+	}
+
+	err = executeQuery(driver, dbname, "CREATE INDEX entities_range_index_run_id IF NOT EXISTS FOR (n:Entity) ON (n.run_id)")
+	if err != nil {
+		return err
+	}
+
 	err = executeQuery(driver, dbname, "CREATE CONSTRAINT constraint_enttag_tag_id IF NOT EXISTS FOR (n:EntityTag) REQUIRE n.tag_id IS UNIQUE")
 	if err != nil {
 		return err
@@ -44,6 +54,11 @@ func InitializeSchema(driver neo4jdb.DriverWithContext, dbname string) error {
 		return err
 	}
 
+	err = executeQuery(driver, dbname, "CREATE INDEX enttag_range_index_ttype_updated_at IF NOT EXISTS FOR (n:EntityTag) ON (n.ttype, n.updated_at)")
+	if err != nil {
+		return err
+	}
+
 	err = executeQuery(driver, dbname, "CREATE INDEX enttag_range_index_entity_id IF NOT EXISTS FOR (n:EntityTag) ON (n.entity_id)")
 	if err != nil {
 		return err
@@ -64,6 +79,11 @@ func InitializeSchema(driver neo4jdb.DriverWithContext, dbname string) error {
 		return err
 	}
 
+	err = executeQuery(driver, dbname, "CREATE INDEX edgetag_range_index_ttype_updated_at IF NOT EXISTS FOR (n:EdgeTag) ON (n.ttype, n.updated_at)")
+	if err != nil {
+		return err
+	}
+
 	err = executeQuery(driver, dbname, "CREATE INDEX edgetag_range_index_edge_id IF NOT EXISTS FOR (n:EdgeTag) ON (n.edge_id)")
 	if err != nil {
 		return err
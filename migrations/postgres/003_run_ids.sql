@@ -0,0 +1,15 @@
+-- +migrate Up
+
+ALTER TABLE entities ADD COLUMN run_id VARCHAR(255);
+ALTER TABLE edges ADD COLUMN run_id VARCHAR(255);
+
+CREATE INDEX idx_entities_run_id ON entities (run_id);
+CREATE INDEX idx_edge_run_id ON edges (run_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_edge_run_id;
+DROP INDEX IF EXISTS idx_entities_run_id;
+
+ALTER TABLE edges DROP COLUMN run_id;
+ALTER TABLE entities DROP COLUMN run_id;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+CREATE INDEX idx_entities_etype_updated_at ON entities (etype, updated_at);
+CREATE INDEX idx_enttag_ttype_updated_at ON entity_tags (ttype, updated_at);
+CREATE INDEX idx_edgetag_ttype_updated_at ON edge_tags (ttype, updated_at);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_edgetag_ttype_updated_at;
+DROP INDEX IF EXISTS idx_enttag_ttype_updated_at;
+DROP INDEX IF EXISTS idx_entities_etype_updated_at;
@@ -0,0 +1,23 @@
+-- +migrate Up
+
+ALTER TABLE entities ADD COLUMN deleted_at DATETIME;
+ALTER TABLE entity_tags ADD COLUMN deleted_at DATETIME;
+ALTER TABLE edges ADD COLUMN deleted_at DATETIME;
+ALTER TABLE edge_tags ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX idx_entities_deleted_at ON entities (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_enttag_deleted_at ON entity_tags (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_edge_deleted_at ON edges (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_edgetag_deleted_at ON edge_tags (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_edgetag_deleted_at;
+DROP INDEX IF EXISTS idx_edge_deleted_at;
+DROP INDEX IF EXISTS idx_enttag_deleted_at;
+DROP INDEX IF EXISTS idx_entities_deleted_at;
+
+ALTER TABLE edge_tags DROP COLUMN deleted_at;
+ALTER TABLE edges DROP COLUMN deleted_at;
+ALTER TABLE entity_tags DROP COLUMN deleted_at;
+ALTER TABLE entities DROP COLUMN deleted_at;
@@ -0,0 +1,34 @@
+-- +migrate Up
+
+-- the duplicate edges are merged into the earliest edge before the unique index is created,
+-- which keeps the latest last seen time of the duplicates
+UPDATE edges SET updated_at = (
+    SELECT MAX(d.updated_at) FROM edges d
+    WHERE d.from_entity_id = edges.from_entity_id AND d.to_entity_id = edges.to_entity_id
+        AND d.etype = edges.etype AND d.content = edges.content AND d.deleted_at IS NULL
+) WHERE deleted_at IS NULL;
+
+UPDATE edge_tags SET edge_id = (
+    SELECT MIN(k.edge_id) FROM edges k, edges d
+    WHERE d.edge_id = edge_tags.edge_id AND k.from_entity_id = d.from_entity_id AND k.to_entity_id = d.to_entity_id
+        AND k.etype = d.etype AND k.content = d.content AND k.deleted_at IS NULL
+) WHERE edge_id IN (SELECT edge_id FROM edges WHERE deleted_at IS NULL);
+
+DELETE FROM edges WHERE deleted_at IS NULL AND EXISTS (
+    SELECT 1 FROM edges k
+    WHERE k.from_entity_id = edges.from_entity_id AND k.to_entity_id = edges.to_entity_id
+        AND k.etype = edges.etype AND k.content = edges.content AND k.deleted_at IS NULL AND k.edge_id < edges.edge_id
+);
+
+CREATE UNIQUE INDEX idx_edge_unique ON edges (from_entity_id, to_entity_id, etype, content) WHERE deleted_at IS NULL;
+
+-- the label of the edges written under the EdgeDedupLabel mode, which makes a single edge per label
+ALTER TABLE edges ADD COLUMN dedup_label TEXT;
+CREATE UNIQUE INDEX idx_edge_unique_label ON edges (from_entity_id, to_entity_id, dedup_label)
+    WHERE dedup_label IS NOT NULL AND deleted_at IS NULL;
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_edge_unique_label;
+ALTER TABLE edges DROP COLUMN dedup_label;
+DROP INDEX IF EXISTS idx_edge_unique;
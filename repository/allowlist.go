@@ -0,0 +1,159 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// Allowlist is a Repository wrapper that restricts the asset types and relation labels a writer
+// may create. Disallowed writes are rejected before they reach the wrapped repository.
+// Methods that do not create entities or edges are passed to the wrapped repository.
+type Allowlist struct {
+	Repository
+	atypes map[oam.AssetType]struct{}
+	labels map[string]struct{}
+}
+
+// NewAllowlist returns an Allowlist wrapping the repository that only permits the creation of
+// entities with the provided asset types and edges with the provided relation labels.
+// Relation labels are compared without regard to case.
+func NewAllowlist(repo Repository, atypes []oam.AssetType, labels []string) *Allowlist {
+	a := &Allowlist{
+		Repository: repo,
+		atypes:     make(map[oam.AssetType]struct{}, len(atypes)),
+		labels:     make(map[string]struct{}, len(labels)),
+	}
+
+	for _, atype := range atypes {
+		a.atypes[atype] = struct{}{}
+	}
+	for _, label := range labels {
+		a.labels[strings.ToLower(label)] = struct{}{}
+	}
+	return a
+}
+
+// CreateEntity implements the Repository interface.
+func (a *Allowlist) CreateEntity(entity *types.Entity) (*types.Entity, error) {
+	if entity == nil || entity.Asset == nil {
+		return nil, errors.New("the input entity is nil")
+	}
+	if err := a.checkAssetType(entity.Asset.AssetType()); err != nil {
+		return nil, err
+	}
+	return a.Repository.CreateEntity(entity)
+}
+
+// CreateEntityWithPrevLastSeen implements the Repository interface.
+func (a *Allowlist) CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error) {
+	if entity == nil || entity.Asset == nil {
+		return nil, time.Time{}, errors.New("the input entity is nil")
+	}
+	if err := a.checkAssetType(entity.Asset.AssetType()); err != nil {
+		return nil, time.Time{}, err
+	}
+	return a.Repository.CreateEntityWithPrevLastSeen(entity)
+}
+
+// CreateAsset implements the Repository interface.
+func (a *Allowlist) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	if asset == nil {
+		return nil, errors.New("the asset is nil")
+	}
+	if err := a.checkAssetType(asset.AssetType()); err != nil {
+		return nil, err
+	}
+	return a.Repository.CreateAsset(asset)
+}
+
+// UpsertEntity implements the Repository interface.
+func (a *Allowlist) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	if asset == nil {
+		return nil, false, errors.New("the asset is nil")
+	}
+	if err := a.checkAssetType(asset.AssetType()); err != nil {
+		return nil, false, err
+	}
+	return a.Repository.UpsertEntity(asset)
+}
+
+// UpdateEntityContentCAS implements the Repository interface.
+func (a *Allowlist) UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error) {
+	if new == nil {
+		return false, errors.New("the new asset is nil")
+	}
+	if err := a.checkAssetType(new.AssetType()); err != nil {
+		return false, err
+	}
+	return a.Repository.UpdateEntityContentCAS(id, expected, new)
+}
+
+// CreateEdge implements the Repository interface.
+func (a *Allowlist) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	if edge == nil || edge.Relation == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := a.checkLabel(edge.Relation.Label()); err != nil {
+		return nil, err
+	}
+	return a.Repository.CreateEdge(edge)
+}
+
+// UpsertEdge implements the Repository interface.
+func (a *Allowlist) UpsertEdge(edge *types.Edge) (*types.Edge, bool, error) {
+	if edge == nil || edge.Relation == nil {
+		return nil, false, errors.New("failed input validation checks")
+	}
+	if err := a.checkLabel(edge.Relation.Label()); err != nil {
+		return nil, false, err
+	}
+	return a.Repository.UpsertEdge(edge)
+}
+
+// CreateEdges implements the Repository interface.
+// No edges are created when the relation label of any edge is not in the allowlist.
+func (a *Allowlist) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	for _, edge := range edges {
+		if edge == nil || edge.Relation == nil {
+			return nil, errors.New("failed input validation checks")
+		}
+		if err := a.checkLabel(edge.Relation.Label()); err != nil {
+			return nil, err
+		}
+	}
+	return a.Repository.CreateEdges(edges)
+}
+
+// CreateEdgeWithProperties implements the Repository interface.
+func (a *Allowlist) CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error) {
+	if edge == nil || edge.Relation == nil {
+		return nil, nil, errors.New("failed input validation checks")
+	}
+	if err := a.checkLabel(edge.Relation.Label()); err != nil {
+		return nil, nil, err
+	}
+	return a.Repository.CreateEdgeWithProperties(edge, props)
+}
+
+func (a *Allowlist) checkLabel(label string) error {
+	if _, found := a.labels[strings.ToLower(label)]; !found {
+		return fmt.Errorf("the %s relation label is not in the allowlist", label)
+	}
+	return nil
+}
+
+func (a *Allowlist) checkAssetType(atype oam.AssetType) error {
+	if _, found := a.atypes[atype]; !found {
+		return fmt.Errorf("the %s asset type is not in the allowlist", atype)
+	}
+	return nil
+}
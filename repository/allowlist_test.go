@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package repository
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingRepository struct {
+	Repository
+	writes int
+}
+
+func (r *recordingRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	r.writes++
+	return &types.Entity{ID: "1", Asset: asset}, nil
+}
+
+func (r *recordingRepository) CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error) {
+	r.writes++
+	return &types.Entity{ID: "1", Asset: entity.Asset}, time.Time{}, nil
+}
+
+func (r *recordingRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	r.writes++
+	return &types.Edge{ID: "1", Relation: edge.Relation, FromEntity: edge.FromEntity, ToEntity: edge.ToEntity}, nil
+}
+
+func TestAllowlist(t *testing.T) {
+	repo := &recordingRepository{}
+	dns := NewAllowlist(repo, []oam.AssetType{oam.FQDN, oam.IPAddress}, []string{"dns_record"})
+
+	fqdn, err := dns.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+
+	ip, err := dns.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.0.2.1"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	_, err = dns.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+		FromEntity: fqdn,
+		ToEntity:   ip,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, repo.writes)
+
+	_, err = dns.CreateAsset(&org.Organization{Name: "OWASP"})
+	assert.ErrorContains(t, err, "Organization asset type is not in the allowlist")
+
+	_, err = dns.CreateEntity(&types.Entity{Asset: &org.Organization{Name: "OWASP"}})
+	assert.Error(t, err)
+
+	_, _, err = dns.CreateEntityWithPrevLastSeen(&types.Entity{Asset: &org.Organization{Name: "OWASP"}})
+	assert.ErrorContains(t, err, "Organization asset type is not in the allowlist")
+
+	_, err = dns.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: fqdn,
+		ToEntity:   fqdn,
+	})
+	assert.ErrorContains(t, err, "node relation label is not in the allowlist")
+
+	// the rejected writes never reached the wrapped repository
+	assert.Equal(t, 3, repo.writes)
+
+	_, _, err = dns.CreateEntityWithPrevLastSeen(&types.Entity{Asset: &domain.FQDN{Name: "www.owasp.org"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, repo.writes)
+}
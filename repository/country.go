@@ -0,0 +1,96 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package repository
+
+import (
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// CountryStep is a hop of the traversal from the entities that record a country to the assets in the country.
+type CountryStep struct {
+	// Incoming selects the edges that arrive at the entities, instead of the edges that leave them.
+	Incoming bool
+	// Labels are the relation labels of the edges followed. When empty, the edges of all labels are followed.
+	Labels []string
+}
+
+// DefaultCountryPaths are the traversals used by FindEntitiesByCountry when no paths are provided.
+// The country of an IPNetRecord applies to the registered netblock and the addresses it contains,
+// and the country of a Location applies to the contact records at the location and the assets they describe.
+var DefaultCountryPaths = map[oam.AssetType][]CountryStep{
+	oam.IPNetRecord: {
+		{Incoming: true, Labels: []string{"registration"}},
+		{Labels: []string{"contains"}},
+	},
+	oam.Location: {
+		{Incoming: true, Labels: []string{"location"}},
+		{Incoming: true},
+	},
+}
+
+// FindEntitiesByCountry finds the entities linked to the IPNetRecords and Locations with the country, following the
+// traversal for the asset type in the paths, or in DefaultCountryPaths when the paths are nil. Every entity reached
+// along a path is returned, so the netblocks of the default IPNetRecord traversal are returned with their addresses.
+// Only the entities and edges last seen after the since parameter are considered. If since.IsZero(), the parameter
+// will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func FindEntitiesByCountry(db Repository, country string, since time.Time, paths map[oam.AssetType][]CountryStep) ([]*types.Entity, error) {
+	if paths == nil {
+		paths = DefaultCountryPaths
+	}
+
+	var results []*types.Entity
+	seen := make(map[string]struct{})
+	for atype, steps := range paths {
+		frontier, err := db.FindEntitiesByField(atype, "country", country, since)
+		if err != nil {
+			continue
+		}
+
+		for _, step := range steps {
+			var next []*types.Entity
+
+			for _, entity := range frontier {
+				var edges []*types.Edge
+				if step.Incoming {
+					edges, err = db.IncomingEdges(entity, since, step.Labels...)
+				} else {
+					edges, err = db.OutgoingEdges(entity, since, step.Labels...)
+				}
+				if err != nil {
+					continue
+				}
+
+				for _, edge := range edges {
+					id := edge.ToEntity.ID
+					if step.Incoming {
+						id = edge.FromEntity.ID
+					}
+					if _, found := seen[id]; found {
+						continue
+					}
+
+					e, err := db.FindEntityById(id)
+					if err != nil {
+						continue
+					}
+
+					seen[id] = struct{}{}
+					results = append(results, e)
+					next = append(next, e)
+				}
+			}
+			frontier = next
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, types.NoResults("zero entities found")
+	}
+	return results, nil
+}
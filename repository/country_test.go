@@ -0,0 +1,79 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package repository
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository/memrepo"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/network"
+	oamreg "github.com/owasp-amass/open-asset-model/registration"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindEntitiesByCountry(t *testing.T) {
+	db := memrepo.New()
+
+	link := func(from, to *types.Entity, label string) {
+		_, err := db.CreateEdge(&types.Edge{
+			Relation:   &relation.SimpleRelation{Name: label},
+			FromEntity: from,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+	}
+
+	for _, tc := range []struct {
+		cidr    string
+		addr    string
+		country string
+	}{
+		{cidr: "198.51.100.0/24", addr: "198.51.100.7", country: "DE"},
+		{cidr: "203.0.113.0/24", addr: "203.0.113.7", country: "FR"},
+	} {
+		prefix := netip.MustParsePrefix(tc.cidr)
+
+		netblock, err := db.CreateAsset(&network.Netblock{CIDR: prefix, Type: "IPv4"})
+		assert.NoError(t, err)
+		record, err := db.CreateAsset(&oamreg.IPNetRecord{
+			CIDR:    prefix,
+			Handle:  "NET-" + tc.country,
+			Type:    "IPv4",
+			Country: tc.country,
+		})
+		assert.NoError(t, err)
+		ip, err := db.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr(tc.addr), Type: "IPv4"})
+		assert.NoError(t, err)
+
+		link(netblock, record, "registration")
+		link(netblock, ip, "contains")
+	}
+
+	entities, err := FindEntitiesByCountry(db, "DE", time.Time{}, nil)
+	assert.NoError(t, err)
+
+	var keys []string
+	for _, e := range entities {
+		keys = append(keys, e.Asset.Key())
+	}
+	assert.ElementsMatch(t, []string{"198.51.100.0/24", "198.51.100.7"}, keys)
+
+	// only the configured traversal is followed
+	entities, err = FindEntitiesByCountry(db, "DE", time.Time{}, map[oam.AssetType][]CountryStep{
+		oam.IPNetRecord: {{Incoming: true, Labels: []string{"registration"}}},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, oam.Netblock, entities[0].Asset.AssetType())
+	}
+
+	_, err = FindEntitiesByCountry(db, "JP", time.Time{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// Memory is the database type of the in-memory repository.
+const Memory string = "memory"
+
+// memRepository is a Repository that keeps the entities, edges and tags in maps.
+// It follows the deduplication, taxonomy and since semantics of the SQL repository,
+// which makes it suitable for testing code that uses the Repository interface.
+type memRepository struct {
+	sync.RWMutex
+	opts       *options.Options
+	seq        uint64
+	entities   map[string]*entityRecord
+	edges      map[string]*edgeRecord
+	entityTags map[string]*entityTagRecord
+	edgeTags   map[string]*edgeTagRecord
+}
+
+type entityRecord struct {
+	seq    uint64
+	runID  string
+	entity types.Entity
+}
+
+type edgeRecord struct {
+	seq   uint64
+	runID string
+	edge  types.Edge
+}
+
+type entityTagRecord struct {
+	seq      uint64
+	entityID string
+	tag      types.EntityTag
+}
+
+type edgeTagRecord struct {
+	seq    uint64
+	edgeID string
+	tag    types.EdgeTag
+}
+
+// New creates a new instance of the in-memory repository.
+func New(opts ...options.Option) *memRepository {
+	return &memRepository{
+		opts:       options.New(opts...),
+		entities:   make(map[string]*entityRecord),
+		edges:      make(map[string]*edgeRecord),
+		entityTags: make(map[string]*entityTagRecord),
+		edgeTags:   make(map[string]*edgeTagRecord),
+	}
+}
+
+// Close implements the Repository interface.
+// The contents of the repository are discarded.
+func (m *memRepository) Close() error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.entities = make(map[string]*entityRecord)
+	m.edges = make(map[string]*edgeRecord)
+	m.entityTags = make(map[string]*entityTagRecord)
+	m.edgeTags = make(map[string]*edgeTagRecord)
+	return nil
+}
+
+// ParseErrors always returns zero, since the repository holds the parsed entities, edges and tags.
+func (m *memRepository) ParseErrors() int64 {
+	return 0
+}
+
+// Stats returns no metrics, since the repository does not use a database or a cache.
+func (m *memRepository) Stats() (types.RepositoryStats, error) {
+	return types.RepositoryStats{}, nil
+}
+
+// EnsureIndexes returns no index names, since the repository does not use indexes.
+func (m *memRepository) EnsureIndexes() ([]string, error) {
+	return nil, nil
+}
+
+// GetDBType returns the type of the repository.
+func (m *memRepository) GetDBType() string {
+	return Memory
+}
+
+// nextID returns a new unique identifier. The caller must hold the write lock.
+func (m *memRepository) nextID() (uint64, string) {
+	m.seq++
+	return m.seq, strconv.FormatUint(m.seq, 10)
+}
+
+// lastSeen returns the provided time, or the current time when it is zero.
+func lastSeen(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+// seenSince reports whether the last seen time is not before the since parameter.
+// If since.IsZero(), the parameter is ignored.
+func seenSince(last, since time.Time) bool {
+	return since.IsZero() || !last.Before(since)
+}
+
+// seenBetween reports whether the last seen time is within the range from start to end, inclusive.
+// A zero start leaves the range without a lower bound.
+func seenBetween(last, start, end time.Time) bool {
+	return seenSince(last, start) && !last.After(end)
+}
+
+// matchesLabel reports whether the label is one of the labels, or labels is empty.
+// It is also used to match property names.
+func matchesLabel(label string, labels []string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPropertyType reports whether the property type is one of the ptypes, or ptypes is empty.
+func matchesPropertyType(ptype oam.PropertyType, ptypes []oam.PropertyType) bool {
+	if len(ptypes) == 0 {
+		return true
+	}
+
+	for _, t := range ptypes {
+		if t == ptype {
+			return true
+		}
+	}
+	return false
+}
+
+// sameProperty reports whether the two properties have the same type, name and value.
+func sameProperty(p1, p2 oam.Property) bool {
+	return p1.PropertyType() == p2.PropertyType() && p1.Name() == p2.Name() && p1.Value() == p2.Value()
+}
+
+func (r *entityRecord) copy() *types.Entity {
+	e := r.entity
+	return &e
+}
+
+func (r *edgeRecord) copy() *types.Edge {
+	e := r.edge
+	e.FromEntity = &types.Entity{ID: r.edge.FromEntity.ID}
+	e.ToEntity = &types.Entity{ID: r.edge.ToEntity.ID}
+	return &e
+}
+
+func (r *entityTagRecord) copy() *types.EntityTag {
+	t := r.tag
+	t.Entity = &types.Entity{ID: r.entityID}
+	return &t
+}
+
+func (r *edgeTagRecord) copy() *types.EdgeTag {
+	t := r.tag
+	t.Edge = &types.Edge{ID: r.edgeID}
+	return &t
+}
+
+// sortedEntities returns copies of the entity records in the order they were created.
+func sortedEntities(records []*entityRecord) []*types.Entity {
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]*types.Entity, 0, len(records))
+	for _, r := range records {
+		results = append(results, r.copy())
+	}
+	return results
+}
+
+// sortedEdges returns copies of the edge records in the order they were created.
+func sortedEdges(records []*edgeRecord) []*types.Edge {
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]*types.Edge, 0, len(records))
+	for _, r := range records {
+		results = append(results, r.copy())
+	}
+	return results
+}
+
+// sortedEntityTags returns copies of the entity tag records in the order they were created.
+func sortedEntityTags(records []*entityTagRecord) []*types.EntityTag {
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]*types.EntityTag, 0, len(records))
+	for _, r := range records {
+		results = append(results, r.copy())
+	}
+	return results
+}
+
+// sortedEdgeTags returns copies of the edge tag records in the order they were created.
+func sortedEdgeTags(records []*edgeTagRecord) []*types.EdgeTag {
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]*types.EdgeTag, 0, len(records))
+	for _, r := range records {
+		results = append(results, r.copy())
+	}
+	return results
+}
+
+// matchesAssetType reports whether the asset type is one of the atypes, or atypes is empty.
+func matchesAssetType(atype oam.AssetType, atypes []oam.AssetType) bool {
+	if len(atypes) == 0 {
+		return true
+	}
+
+	for _, t := range atypes {
+		if t == atype {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,466 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// CreateEdge creates an edge between two entities in the repository.
+// An edge equal to an existing edge between the same entities updates the last seen time of the existing edge.
+// Returns the created edge as a types.Edge or an error if the link creation fails.
+func (m *memRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	e, _, err := m.UpsertEdge(edge)
+	return e, err
+}
+
+// UpsertEdge creates an edge between two entities in the repository, as done by CreateEdge.
+// Returns the edge, and true if the edge was inserted or false if an existing edge was updated.
+func (m *memRepository) UpsertEdge(edge *types.Edge) (*types.Edge, bool, error) {
+	if edge == nil || edge.Relation == nil || edge.FromEntity == nil ||
+		edge.FromEntity.Asset == nil || edge.ToEntity == nil || edge.ToEntity.Asset == nil {
+		return nil, false, errors.New("failed input validation checks")
+	}
+
+	if !oam.ValidRelationship(edge.FromEntity.Asset.AssetType(),
+		edge.Relation.Label(), edge.Relation.RelationType(), edge.ToEntity.Asset.AssetType()) {
+		return &types.Edge{}, false, fmt.Errorf("%s -%s-> %s is not valid in the taxonomy",
+			edge.FromEntity.Asset.AssetType(), edge.Relation.Label(), edge.ToEntity.Asset.AssetType())
+	}
+
+	edge, err := m.resolveEndpoints(edge)
+	if err != nil {
+		return nil, false, err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if _, found := m.entities[edge.FromEntity.ID]; !found {
+		return nil, false, types.EndpointNotFound("from", edge.FromEntity.ID)
+	}
+	if _, found := m.entities[edge.ToEntity.ID]; !found {
+		return nil, false, types.EndpointNotFound("to", edge.ToEntity.ID)
+	}
+
+	updated := lastSeen(edge.LastSeen)
+	// ensure that duplicate relationships are not entered into the repository
+	for _, r := range m.edges {
+		if r.edge.FromEntity.ID == edge.FromEntity.ID && r.edge.ToEntity.ID == edge.ToEntity.ID &&
+			r.edge.Relation.Label() == edge.Relation.Label() && m.opts.DuplicateRelations(edge.Relation, r.edge.Relation) {
+			r.edge.LastSeen = updated
+			if m.opts.RunID != "" {
+				r.runID = m.opts.RunID
+			}
+			return r.copy(), false, nil
+		}
+	}
+
+	seq, id := m.nextID()
+	r := &edgeRecord{
+		seq:   seq,
+		runID: m.opts.RunID,
+		edge: types.Edge{
+			ID:         id,
+			CreatedAt:  lastSeen(edge.CreatedAt),
+			LastSeen:   updated,
+			Relation:   edge.Relation,
+			FromEntity: &types.Entity{ID: edge.FromEntity.ID},
+			ToEntity:   &types.Entity{ID: edge.ToEntity.ID},
+		},
+	}
+	m.edges[id] = r
+	return r.copy(), true, nil
+}
+
+// resolveEndpoints returns the edge when the entities at both ends exist. Otherwise, when the MissingEndpoints
+// option is set to create them, a copy of the edge is returned with the missing entities created from their assets.
+func (m *memRepository) resolveEndpoints(edge *types.Edge) (*types.Edge, error) {
+	from, err := m.resolveEndpoint("from", edge.FromEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := m.resolveEndpoint("to", edge.ToEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == edge.FromEntity && to == edge.ToEntity {
+		return edge, nil
+	}
+
+	resolved := *edge
+	resolved.FromEntity = from
+	resolved.ToEntity = to
+	return &resolved, nil
+}
+
+func (m *memRepository) resolveEndpoint(side string, entity *types.Entity) (*types.Entity, error) {
+	if _, err := m.FindEntityById(entity.ID); err == nil {
+		return entity, nil
+	}
+
+	if m.opts.MissingEndpoints != options.MissingEndpointCreate {
+		return nil, types.EndpointNotFound(side, entity.ID)
+	}
+	return m.CreateEntity(&types.Entity{Asset: entity.Asset})
+}
+
+// CreateEdges creates the provided edges in the repository, as done by CreateEdge.
+// Returns the created or existing edges in the order provided, or an error if the creation of an edge fails.
+func (m *memRepository) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	var results []*types.Edge
+
+	for _, edge := range edges {
+		e, err := m.CreateEdge(edge)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	return results, nil
+}
+
+// FindEdgeById finds an edge in the repository by the ID.
+// Returns the found edge as a types.Edge or an error if the edge is not found.
+func (m *memRepository) FindEdgeById(id string) (*types.Edge, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, found := m.edges[id]
+	if !found {
+		return nil, types.ErrEdgeNotFound
+	}
+	return r.copy(), nil
+}
+
+// IncomingEdges finds all edges pointing to the entity of the specified labels and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all incoming edges are returned.
+func (m *memRepository) IncomingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdges(func(r *edgeRecord) bool {
+		return r.edge.ToEntity.ID == entity.ID && seenSince(r.edge.LastSeen, since) &&
+			matchesLabel(r.edge.Relation.Label(), labels)
+	})
+}
+
+// OutgoingEdges finds all edges from the entity of the specified labels and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are returned.
+func (m *memRepository) OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdges(func(r *edgeRecord) bool {
+		return r.edge.FromEntity.ID == entity.ID && seenSince(r.edge.LastSeen, since) &&
+			matchesLabel(r.edge.Relation.Label(), labels)
+	})
+}
+
+// IncomingEdgesBetween finds all edges to the entity of the specified labels and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no labels are specified, all incoming edges within the range are returned.
+func (m *memRepository) IncomingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdges(func(r *edgeRecord) bool {
+		return r.edge.ToEntity.ID == entity.ID && seenBetween(r.edge.LastSeen, start, end) &&
+			matchesLabel(r.edge.Relation.Label(), labels)
+	})
+}
+
+// OutgoingEdgesBetween finds all edges from the entity of the specified labels and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no labels are specified, all outgoing edges within the range are returned.
+func (m *memRepository) OutgoingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdges(func(r *edgeRecord) bool {
+		return r.edge.FromEntity.ID == entity.ID && seenBetween(r.edge.LastSeen, start, end) &&
+			matchesLabel(r.edge.Relation.Label(), labels)
+	})
+}
+
+// CountOutgoingEdges counts the edges from the entity of the specified labels and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are counted.
+func (m *memRepository) CountOutgoingEdges(entity *types.Entity, since time.Time, labels ...string) (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var count int64
+	for _, r := range m.edges {
+		if r.edge.FromEntity.ID == entity.ID && seenSince(r.edge.LastSeen, since) &&
+			matchesLabel(r.edge.Relation.Label(), labels) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// AdjacentEdges finds all edges to or from the entity of the specified labels and last seen after the since parameter.
+// The direction of each edge is indicated by whether the entity is the FromEntity or the ToEntity.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all adjacent edges are returned.
+func (m *memRepository) AdjacentEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdges(func(r *edgeRecord) bool {
+		return (r.edge.FromEntity.ID == entity.ID || r.edge.ToEntity.ID == entity.ID) &&
+			seenSince(r.edge.LastSeen, since) && matchesLabel(r.edge.Relation.Label(), labels)
+	})
+}
+
+// TraverseNeighborhood finds the entities within depth hops of the entity, following the edges in the direction
+// with the specified labels, and the edges that were followed to reach them.
+// If no labels are specified, edges of all labels are followed.
+// Returns the entity, followed by the reached entities ordered by their distance, and the followed edges,
+// or an error if the traversal fails.
+func (m *memRepository) TraverseNeighborhood(entity *types.Entity, depth int, direction types.Direction, labels ...string) ([]*types.Entity, []*types.Edge, error) {
+	if err := types.ValidateTraversal(depth, direction); err != nil {
+		return nil, nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	seed, found := m.entities[entity.ID]
+	if !found {
+		return nil, nil, types.ErrEntityNotFound
+	}
+
+	results := []*types.Entity{seed.copy()}
+	reached := map[string]struct{}{entity.ID: {}}
+	followed := make(map[string]*edgeRecord)
+	frontier := map[string]struct{}{entity.ID: {}}
+	for i := 0; i < depth && len(frontier) > 0; i++ {
+		var level []*entityRecord
+		next := make(map[string]struct{})
+
+		for id, r := range m.edges {
+			if !matchesLabel(r.edge.Relation.Label(), labels) {
+				continue
+			}
+
+			var ends []string
+			if _, found := frontier[r.edge.FromEntity.ID]; found && direction != types.Incoming {
+				ends = append(ends, r.edge.ToEntity.ID)
+			}
+			if _, found := frontier[r.edge.ToEntity.ID]; found && direction != types.Outgoing {
+				ends = append(ends, r.edge.FromEntity.ID)
+			}
+			if len(ends) == 0 {
+				continue
+			}
+
+			followed[id] = r
+			for _, end := range ends {
+				if _, found := reached[end]; found {
+					continue
+				}
+				reached[end] = struct{}{}
+				next[end] = struct{}{}
+
+				if er, found := m.entities[end]; found {
+					level = append(level, er)
+				}
+			}
+		}
+
+		results = append(results, sortedEntities(level)...)
+		frontier = next
+	}
+
+	edges := make([]*edgeRecord, 0, len(followed))
+	for _, r := range followed {
+		edges = append(edges, r)
+	}
+	return results, sortedEdges(edges), nil
+}
+
+// OutgoingEdgesForEntities finds all edges from the provided entities of the specified labels and last seen after
+// the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are returned.
+// Returns the edges grouped by the ID of the source entity. Entities without matching edges are not included.
+func (m *memRepository) OutgoingEdgesForEntities(entities []*types.Entity, since time.Time, labels ...string) (map[string][]*types.Edge, error) {
+	ids := make(map[string]struct{}, len(entities))
+	for _, entity := range entities {
+		ids[entity.ID] = struct{}{}
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	edges, err := m.filterEdges(func(r *edgeRecord) bool {
+		_, found := ids[r.edge.FromEntity.ID]
+		return found && seenSince(r.edge.LastSeen, since) && matchesLabel(r.edge.Relation.Label(), labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]*types.Edge)
+	for _, e := range edges {
+		results[e.FromEntity.ID] = append(results[e.FromEntity.ID], e)
+	}
+	return results, nil
+}
+
+// FindHubEntities finds all entities with a degree of at least minDegree, counting only edges last seen after the since parameter.
+// The direction must be "outgoing", "incoming", or "total", which counts the edges in both directions.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindHubEntities(minDegree int, direction string, since time.Time) ([]*types.Entity, error) {
+	var outgoing, incoming bool
+	switch strings.ToLower(direction) {
+	case "outgoing":
+		outgoing = true
+	case "incoming":
+		incoming = true
+	case "total":
+		outgoing, incoming = true, true
+	default:
+		return nil, fmt.Errorf("unknown edge direction: %s", direction)
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	degrees := make(map[string]int)
+	for _, r := range m.edges {
+		if !seenSince(r.edge.LastSeen, since) {
+			continue
+		}
+		if outgoing {
+			degrees[r.edge.FromEntity.ID]++
+		}
+		if incoming {
+			degrees[r.edge.ToEntity.ID]++
+		}
+	}
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		d, found := degrees[r.entity.ID]
+		return found && d >= minDegree
+	}, "zero entities found")
+}
+
+// DistinctRelationLabels returns the sorted labels of the relations stored by edges last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the relation labels.
+func (m *memRepository) DistinctRelationLabels(since time.Time) ([]string, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	set := make(map[string]struct{})
+	for _, r := range m.edges {
+		if seenSince(r.edge.LastSeen, since) {
+			set[r.edge.Relation.Label()] = struct{}{}
+		}
+	}
+
+	labels := make([]string, 0, len(set))
+	for label := range set {
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// FindEntitiesWithEdgeTo finds all entities of the fromType with at least one outgoing edge with the relation label
+// to an entity of the toType, considering only edges last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesWithEdgeTo(fromType oam.AssetType, label string, toType oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	from := make(map[string]struct{})
+	for _, r := range m.edges {
+		if r.edge.Relation.Label() != label || !seenSince(r.edge.LastSeen, since) {
+			continue
+		}
+		if to, found := m.entities[r.edge.ToEntity.ID]; found && to.entity.Asset.AssetType() == toType {
+			from[r.edge.FromEntity.ID] = struct{}{}
+		}
+	}
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		_, found := from[r.entity.ID]
+		return found && r.entity.Asset.AssetType() == fromType
+	}, "zero entities found")
+}
+
+// FindEdgesByRun finds all edges last written by the scan run with the provided ID.
+// Returns a slice of matching edges as []*types.Edge or an error if the search fails.
+func (m *memRepository) FindEdgesByRun(runID string) ([]*types.Edge, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdges(func(r *edgeRecord) bool {
+		return r.runID == runID
+	})
+}
+
+// filterEdges returns copies of the edges accepted by the filter, in the order they were created.
+// The caller must hold the lock.
+func (m *memRepository) filterEdges(filter func(r *edgeRecord) bool) ([]*types.Edge, error) {
+	var matches []*edgeRecord
+	for _, r := range m.edges {
+		if filter(r) {
+			matches = append(matches, r)
+		}
+	}
+
+	if len(matches) == 0 {
+		return []*types.Edge{}, m.opts.NoResults("zero edges found")
+	}
+	return sortedEdges(matches), nil
+}
+
+// DeleteEdge removes an edge in the repository by its ID, along with the tags of the edge.
+// Removing an edge that does not exist is not an error.
+func (m *memRepository) DeleteEdge(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.deleteEdge(id)
+	return nil
+}
+
+// deleteEdge removes the edge and the edge tags. The caller must hold the write lock.
+func (m *memRepository) deleteEdge(id string) {
+	delete(m.edges, id)
+	for tid, t := range m.edgeTags {
+		if t.edgeID == id {
+			delete(m.edgeTags, tid)
+		}
+	}
+}
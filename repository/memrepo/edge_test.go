@@ -0,0 +1,445 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEdges(t *testing.T) {
+	store := New()
+
+	fqdn, err := store.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.0.2.1"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	edge := &types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+		FromEntity: fqdn,
+		ToEntity:   ip,
+	}
+	e1, err := store.CreateEdge(edge)
+	assert.NoError(t, err)
+	assert.Equal(t, fqdn.ID, e1.FromEntity.ID)
+	assert.Equal(t, ip.ID, e1.ToEntity.ID)
+
+	time.Sleep(10 * time.Millisecond)
+
+	// the duplicate relationship updates the existing edge
+	e2, created, err := store.UpsertEdge(edge)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, e1.ID, e2.ID)
+	assert.True(t, e2.LastSeen.After(e1.LastSeen))
+
+	// the relationship is not valid in the taxonomy
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "not_in_taxonomy"},
+		FromEntity: ip,
+		ToEntity:   fqdn,
+	})
+	assert.Error(t, err)
+
+	outs, err := store.OutgoingEdges(fqdn, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, outs, 1)
+
+	_, err = store.OutgoingEdges(fqdn, time.Time{}, "node")
+	assert.Error(t, err)
+
+	ins, err := store.IncomingEdges(ip, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, ins, 1)
+
+	byEntity, err := store.OutgoingEdgesForEntities([]*types.Entity{fqdn, ip}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, byEntity, 1)
+	assert.Len(t, byEntity[fqdn.ID], 1)
+
+	hubs, err := store.FindHubEntities(1, "incoming", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, hubs, 1)
+	assert.Equal(t, ip.ID, hubs[0].ID)
+
+	_, err = store.FindHubEntities(1, "sideways", time.Time{})
+	assert.Error(t, err)
+
+	assert.NoError(t, store.DeleteEdge(e1.ID))
+	_, err = store.FindEdgeById(e1.ID)
+	assert.Error(t, err)
+}
+
+func TestAdjacentEdges(t *testing.T) {
+	store := New()
+
+	alias, err := store.CreateAsset(&domain.FQDN{Name: "alias.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+	ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.0.2.10"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	in, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: alias,
+		ToEntity:   www,
+	})
+	assert.NoError(t, err)
+	out, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+		FromEntity: www,
+		ToEntity:   ip,
+	})
+	assert.NoError(t, err)
+
+	edges, err := store.AdjacentEdges(www, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, edges, 2) {
+		for _, edge := range edges {
+			switch edge.ID {
+			case in.ID:
+				assert.False(t, edge.OutgoingFrom(www.ID))
+				assert.Equal(t, alias.ID, edge.FromEntity.ID)
+			case out.ID:
+				assert.True(t, edge.OutgoingFrom(www.ID))
+				assert.Equal(t, ip.ID, edge.ToEntity.ID)
+			default:
+				t.Errorf("unexpected edge %s", edge.ID)
+			}
+		}
+	}
+
+	edges, err = store.AdjacentEdges(www, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 2)
+
+	_, err = store.AdjacentEdges(www, time.Time{}, "node")
+	assert.Error(t, err)
+}
+
+func TestTraverseNeighborhood(t *testing.T) {
+	store := New()
+
+	var chain []*types.Entity
+	for _, name := range []string{"traverse.owasp.org", "www.traverse.owasp.org", "api.traverse.owasp.org", "dev.traverse.owasp.org"} {
+		e, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		if n := len(chain); n > 0 {
+			_, err = store.CreateEdge(&types.Edge{
+				Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+				FromEntity: chain[n-1],
+				ToEntity:   e,
+			})
+			assert.NoError(t, err)
+		}
+		chain = append(chain, e)
+	}
+
+	other, err := store.CreateAsset(&domain.FQDN{Name: "other.traverse.owasp.org"})
+	assert.NoError(t, err)
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: other,
+		ToEntity:   chain[0],
+	})
+	assert.NoError(t, err)
+
+	ids := func(entities []*types.Entity) []string {
+		var results []string
+		for _, e := range entities {
+			results = append(results, e.ID)
+		}
+		return results
+	}
+
+	entities, edges, err := store.TraverseNeighborhood(chain[0], 2, types.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chain[0].ID, chain[1].ID, chain[2].ID}, ids(entities))
+	assert.Len(t, edges, 2)
+
+	entities, edges, err = store.TraverseNeighborhood(chain[2], 3, types.Incoming)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chain[2].ID, chain[1].ID, chain[0].ID, other.ID}, ids(entities))
+	assert.Len(t, edges, 3)
+
+	entities, edges, err = store.TraverseNeighborhood(chain[0], 1, types.Both)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{chain[0].ID, chain[1].ID, other.ID}, ids(entities))
+	assert.Len(t, edges, 2)
+
+	// only the edges with the labels are followed
+	entities, edges, err = store.TraverseNeighborhood(chain[0], 2, types.Both, "dns_record")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chain[0].ID, chain[1].ID, chain[2].ID}, ids(entities))
+	assert.Len(t, edges, 2)
+
+	_, _, err = store.TraverseNeighborhood(chain[0], 0, types.Outgoing)
+	assert.Error(t, err)
+}
+
+func TestDeleteEntityRemovesEdgesAndTags(t *testing.T) {
+	store := New()
+
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+
+	etag, err := store.CreateEntityProperty(www, &property.SimpleProperty{PropertyName: "test", PropertyValue: "foo"})
+	assert.NoError(t, err)
+	rtag, err := store.CreateEdgeProperty(edge, &property.SimpleProperty{PropertyName: "test", PropertyValue: "bar"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.DeleteEntity(www.ID))
+
+	_, err = store.FindEntityById(www.ID)
+	assert.Error(t, err)
+	_, err = store.FindEdgeById(edge.ID)
+	assert.Error(t, err)
+	_, err = store.FindEntityTagById(etag.ID)
+	assert.Error(t, err)
+	_, err = store.FindEdgeTagById(rtag.ID)
+	assert.Error(t, err)
+
+	_, err = store.FindEntityById(apex.ID)
+	assert.NoError(t, err)
+}
+
+func TestFindByRun(t *testing.T) {
+	store := New(options.WithRunID("run-1"))
+
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "run.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.run.owasp.org"})
+	assert.NoError(t, err)
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+
+	entities, err := store.FindEntitiesByRun("run-1")
+	assert.NoError(t, err)
+	assert.Len(t, entities, 2)
+
+	edges, err := store.FindEdgesByRun("run-1")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 1)
+	assert.Equal(t, edge.ID, edges[0].ID)
+
+	_, err = store.FindEntitiesByRun("run-2")
+	assert.Error(t, err)
+}
+
+func TestMissingEndpoint(t *testing.T) {
+	rel := &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}}
+	missing := &types.Entity{ID: "missing", Asset: &domain.FQDN{Name: "missing.owasp.org"}}
+
+	store := New()
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	// the missing endpoint is reported by default
+	_, err = store.CreateEdge(&types.Edge{Relation: rel, FromEntity: www, ToEntity: missing})
+	assert.ErrorIs(t, err, types.ErrEndpointNotFound)
+	assert.ErrorContains(t, err, "the to entity")
+
+	_, err = store.CreateEdge(&types.Edge{Relation: rel, FromEntity: missing, ToEntity: www})
+	assert.ErrorIs(t, err, types.ErrEndpointNotFound)
+	assert.ErrorContains(t, err, "the from entity")
+
+	_, err = store.FindEntitiesByContent(missing.Asset, time.Time{})
+	assert.Error(t, err)
+
+	store = New(options.WithMissingEndpointPolicy(options.MissingEndpointCreate))
+	www, err = store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := store.CreateEdge(&types.Edge{Relation: rel, FromEntity: www, ToEntity: missing})
+	assert.NoError(t, err)
+
+	created, err := store.FindEntitiesByContent(missing.Asset, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, created[0].ID, edge.ToEntity.ID)
+	}
+}
+
+func TestDistinctRelationLabels(t *testing.T) {
+	store := New()
+
+	from, err := store.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	to, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	labels, err := store.DistinctRelationLabels(time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, labels)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	labels, err = store.DistinctRelationLabels(time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dns_record", "node"}, labels)
+
+	labels, err = store.DistinctRelationLabels(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, labels)
+}
+
+func TestFindEntitiesWithEdgeTo(t *testing.T) {
+	store := New()
+
+	with, err := store.CreateAsset(&domain.FQDN{Name: "a.owasp.org"})
+	assert.NoError(t, err)
+	without, err := store.CreateAsset(&domain.FQDN{Name: "cname.owasp.org"})
+	assert.NoError(t, err)
+	ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.0.2.10"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+		FromEntity: with,
+		ToEntity:   ip,
+	})
+	assert.NoError(t, err)
+	// the edge from the other FQDN leads to an FQDN instead of an IP address
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: without,
+		ToEntity:   with,
+	})
+	assert.NoError(t, err)
+
+	entities, err := store.FindEntitiesWithEdgeTo(oam.FQDN, "dns_record", oam.IPAddress, time.Time{})
+	assert.NoError(t, err)
+	var ids []string
+	for _, e := range entities {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{with.ID}, ids)
+
+	_, err = store.FindEntitiesWithEdgeTo(oam.FQDN, "dns_record", oam.IPAddress, time.Now().Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestBetweenQueries(t *testing.T) {
+	store := New()
+
+	now := time.Now()
+	before, inside, after := now.Add(-3*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour)
+	start, end := now.Add(-150*time.Minute), now.Add(-90*time.Minute)
+
+	from, err := store.CreateAsset(&domain.FQDN{Name: "between.owasp.org"})
+	assert.NoError(t, err)
+
+	var entities []*types.Entity
+	var edges []*types.Edge
+	for i, seen := range []time.Time{before, inside, after} {
+		e, err := store.CreateEntity(&types.Entity{
+			CreatedAt: seen,
+			LastSeen:  seen,
+			Asset:     &domain.FQDN{Name: string(rune('a'+i)) + ".between.owasp.org"},
+		})
+		assert.NoError(t, err)
+		entities = append(entities, e)
+
+		edge, err := store.CreateEdge(&types.Edge{
+			CreatedAt:  seen,
+			LastSeen:   seen,
+			Relation:   &relation.SimpleRelation{Name: "node"},
+			FromEntity: from,
+			ToEntity:   e,
+		})
+		assert.NoError(t, err)
+		edges = append(edges, edge)
+
+		_, err = store.CreateEntityTag(from, &types.EntityTag{
+			CreatedAt: seen,
+			LastSeen:  seen,
+			Property:  &property.SimpleProperty{PropertyName: "between", PropertyValue: string(rune('a' + i))},
+		})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEdgeTag(edges[0], &types.EdgeTag{
+			CreatedAt: seen,
+			LastSeen:  seen,
+			Property:  &property.SimpleProperty{PropertyName: "between", PropertyValue: string(rune('a' + i))},
+		})
+		assert.NoError(t, err)
+	}
+
+	found, err := store.FindEntitiesByTypeBetween(oam.FQDN, start, end)
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, entities[1].ID, found[0].ID)
+	}
+
+	out, err := store.OutgoingEdgesBetween(from, start, end, "node")
+	assert.NoError(t, err)
+	if assert.Len(t, out, 1) {
+		assert.Equal(t, edges[1].ID, out[0].ID)
+	}
+
+	in, err := store.IncomingEdgesBetween(entities[2], start, end)
+	assert.Error(t, err)
+	assert.Empty(t, in)
+
+	// a zero start leaves the range without a lower bound
+	in, err = store.IncomingEdgesBetween(entities[0], time.Time{}, end)
+	assert.NoError(t, err)
+	assert.Len(t, in, 1)
+
+	etags, err := store.GetEntityTagsBetween(from, start, end, "between")
+	assert.NoError(t, err)
+	if assert.Len(t, etags, 1) {
+		assert.Equal(t, "b", etags[0].Property.Value())
+	}
+
+	dtags, err := store.GetEdgeTagsBetween(edges[0], start, end)
+	assert.NoError(t, err)
+	if assert.Len(t, dtags, 1) {
+		assert.Equal(t, "b", dtags[0].Property.Value())
+	}
+
+	_, err = store.FindEntitiesByTypeBetween(oam.FQDN, end, start)
+	assert.Error(t, err)
+	_, err = store.GetEntityTagsBetween(from, start, time.Time{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,662 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/service"
+)
+
+// CreateEntity creates a new entity in the repository.
+// An entity with the same asset type and key as an existing entity replaces the content of the existing entity.
+// When content validation is enabled, assets missing required fields are rejected.
+// Returns the created entity as a types.Entity or an error if the creation fails.
+func (m *memRepository) CreateEntity(input *types.Entity) (*types.Entity, error) {
+	e, _, err := m.upsertEntity(input)
+	return e, err
+}
+
+// CreateEntityWithPrevLastSeen creates a new entity in the repository, as done by CreateEntity.
+// Returns the entity and the last seen time of the matched entity before the update, which is zero
+// when the entity was created, or an error if the creation fails.
+func (m *memRepository) CreateEntityWithPrevLastSeen(input *types.Entity) (*types.Entity, time.Time, error) {
+	return m.upsertEntity(input)
+}
+
+// CreateAsset creates a new entity in the repository.
+// Returns the created entity as a types.Entity or an error if the creation fails.
+func (m *memRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	return m.CreateEntity(&types.Entity{Asset: asset})
+}
+
+// UpsertEntity ensures that an entity exists in the repository for the asset, as done by CreateAsset.
+// The search and the creation are performed while holding the write lock, so concurrent calls for the
+// same asset produce a single entity.
+// Returns the entity, and true if the entity was created or false if an existing entity was updated.
+func (m *memRepository) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	e, prev, err := m.upsertEntity(&types.Entity{Asset: asset})
+	if err != nil {
+		return nil, false, err
+	}
+	return e, prev.IsZero(), nil
+}
+
+// upsertEntity creates the entity, or updates the existing entity with the same asset type and key.
+// Returns the entity and the last seen time of the existing entity, which is zero when the entity was created.
+func (m *memRepository) upsertEntity(input *types.Entity) (*types.Entity, time.Time, error) {
+	if input == nil || input.Asset == nil {
+		return nil, time.Time{}, errors.New("the input entity is nil")
+	}
+	if m.opts.ValidateContent {
+		if err := types.ValidateAsset(input.Asset); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	// ensure that duplicate entities are not entered into the repository
+	matches, err := m.findByContent(input.Asset, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, time.Time{}, err
+	}
+	if len(matches) > 0 {
+		r := matches[0]
+		prev := r.entity.LastSeen
+
+		// the stored fields missing from the input are kept, since the match may come from a secondary key
+		merged, err := types.MergeAsset(r.entity.Asset, input.Asset)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		content, err := merged.JSON()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		// coalesce rapid re-observations of an unchanged entity
+		if existing, err := r.entity.Asset.JSON(); err == nil &&
+			bytes.Equal(existing, content) && m.opts.SkipLastSeenUpdate(prev) {
+			return r.copy(), prev, nil
+		}
+
+		r.entity.Asset = merged
+		r.entity.LastSeen = time.Now()
+		if m.opts.RunID != "" {
+			r.runID = m.opts.RunID
+		}
+		return r.copy(), prev, nil
+	}
+
+	seq, id := m.nextID()
+	r := &entityRecord{
+		seq:   seq,
+		runID: m.opts.RunID,
+		entity: types.Entity{
+			ID:        id,
+			CreatedAt: lastSeen(input.CreatedAt),
+			LastSeen:  lastSeen(input.LastSeen),
+			Asset:     input.Asset,
+		},
+	}
+	m.entities[id] = r
+	return r.copy(), time.Time{}, nil
+}
+
+// FindEntityById finds an entity in the repository by the ID.
+// Returns the found entity as a types.Entity or an error if the entity is not found.
+func (m *memRepository) FindEntityById(id string) (*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, found := m.entities[id]
+	if !found {
+		return nil, types.ErrEntityNotFound
+	}
+	return r.copy(), nil
+}
+
+// FindEntitiesByContent finds entities in the repository with the same asset type and key as the provided asset,
+// last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesByContent(asset oam.Asset, since time.Time) ([]*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	matches, err := m.findByContent(asset, since)
+	if err != nil {
+		return nil, err
+	}
+	return sortedEntities(matches), nil
+}
+
+// findByContent returns the records with the same asset type and key as the provided asset. When no record
+// matches the primary key, the other candidate key fields populated in the asset are tried in order.
+// The caller must hold the lock.
+func (m *memRepository) findByContent(asset oam.Asset, since time.Time) ([]*entityRecord, error) {
+	keys, err := types.AssetKeyCandidates(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		var matches []*entityRecord
+		for _, r := range m.entities {
+			if r.entity.Asset.AssetType() != asset.AssetType() || !seenSince(r.entity.LastSeen, since) {
+				continue
+			}
+			if v, err := types.AssetFieldValue(r.entity.Asset, k.Field); err == nil && reflect.DeepEqual(v, k.Value) {
+				matches = append(matches, r)
+			}
+		}
+
+		if len(matches) > 0 {
+			return matches, nil
+		}
+	}
+	return []*entityRecord{}, m.opts.NoResults("zero entities found")
+}
+
+// FindEntityByContentLatest finds the entity in the repository with the same content as the provided asset that was
+// seen most recently.
+// Returns the matching entity as a types.Entity or types.ErrEntityNotFound if there is no match.
+func (m *memRepository) FindEntityByContentLatest(asset oam.Asset) (*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	matches, err := m.findByContent(asset, time.Time{})
+	if err != nil || len(matches) == 0 {
+		return nil, types.ErrEntityNotFound
+	}
+
+	latest := matches[0]
+	for _, r := range matches[1:] {
+		if r.entity.LastSeen.After(latest.entity.LastSeen) {
+			latest = r
+		}
+	}
+	return latest.copy(), nil
+}
+
+// FindEntitiesByContentFold finds entities in the repository with the same asset type and a key equal to the
+// key of the provided asset under case folding, last seen after the since parameter.
+// Asset types without a string key field are matched exactly, as done by FindEntitiesByContent.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesByContentFold(asset oam.Asset, since time.Time) ([]*types.Entity, error) {
+	_, value, err := types.AssetKey(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := value.(string)
+	if !ok {
+		return m.FindEntitiesByContent(asset, since)
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		if r.entity.Asset.AssetType() != asset.AssetType() || !seenSince(r.entity.LastSeen, since) {
+			return false
+		}
+
+		_, v, err := types.AssetKey(r.entity.Asset)
+		if err != nil {
+			return false
+		}
+		k, ok := v.(string)
+		return ok && strings.EqualFold(k, key)
+	}, "zero entities found")
+}
+
+// FindEntitiesByType finds all entities in the repository of the provided asset type and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		return r.entity.Asset.AssetType() == atype && seenSince(r.entity.LastSeen, since)
+	}, "no entities of the specified type")
+}
+
+// FindEntitiesByTypeBetween finds all entities in the repository of the provided asset type last seen
+// between start and end, inclusive. If start.IsZero(), the range has no lower bound.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesByTypeBetween(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		return r.entity.Asset.AssetType() == atype && seenBetween(r.entity.LastSeen, start, end)
+	}, "no entities of the specified type")
+}
+
+// FindStaleEntities finds all entities in the repository of the provided asset type that were last seen
+// more than olderThan before now.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindStaleEntities(atype oam.AssetType, olderThan time.Duration) ([]*types.Entity, error) {
+	cutoff := time.Now().Add(-olderThan)
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		return r.entity.Asset.AssetType() == atype && r.entity.LastSeen.Before(cutoff)
+	}, "zero entities found")
+}
+
+// NewEntitiesCountByType counts the entities in the repository created after the since parameter, grouped by the
+// asset type. If since.IsZero(), all entities are counted.
+// Returns the number of new entities for each asset type.
+func (m *memRepository) NewEntitiesCountByType(since time.Time) (map[oam.AssetType]int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	results := make(map[oam.AssetType]int64)
+	for _, r := range m.entities {
+		if seenSince(r.entity.CreatedAt, since) {
+			results[r.entity.Asset.AssetType()]++
+		}
+	}
+	return results, nil
+}
+
+// CountEntitiesByType counts the entities in the repository of the provided asset type and last seen after
+// the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+func (m *memRepository) CountEntitiesByType(atype oam.AssetType, since time.Time) (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var count int64
+	for _, r := range m.entities {
+		if r.entity.Asset.AssetType() == atype && seenSince(r.entity.LastSeen, since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ChildNetblocks finds the Netblock or IPNetRecord entities in the repository that are the immediate children of the
+// parent in the allocation tree, last seen after the since parameter. IPNetRecords are related by the handle and
+// parent handle fields, while Netblocks are related by CIDR containment.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of the child entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) ChildNetblocks(parent *types.Entity, since time.Time) ([]*types.Entity, error) {
+	if parent == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := types.ValidateNetblock(parent.Asset); err != nil {
+		return nil, err
+	}
+
+	candidates, err := m.FindEntitiesByType(parent.Asset.AssetType(), since)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := types.ChildNetblocks(parent, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return []*types.Entity{}, m.opts.NoResults("zero entities found")
+	}
+	return children, nil
+}
+
+// ParentNetblock finds the Netblock or IPNetRecord entity in the repository that is the parent of the child in the
+// allocation tree, last seen after the since parameter. For Netblocks, the smallest containing CIDR is the parent.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the parent entity as a types.Entity or an error if the parent is not found.
+func (m *memRepository) ParentNetblock(child *types.Entity, since time.Time) (*types.Entity, error) {
+	if child == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := types.ValidateNetblock(child.Asset); err != nil {
+		return nil, err
+	}
+
+	// without any netblocks of the type, the parent is not found
+	candidates, err := m.FindEntitiesByType(child.Asset.AssetType(), since)
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, err
+	}
+
+	parent, err := types.ParentNetblock(child, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("the parent netblock was not found: %w", types.ErrEntityNotFound)
+	}
+	return parent, nil
+}
+
+// FindEntitiesByTypePagedWithTotal finds a page of entities in the repository of the provided asset type and last seen
+// after the since parameter, along with the total number of entities matching the same criteria.
+// The entities are ordered by the time they were created. A negative limit returns all remaining entities.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the page of matching entities as []*types.Entity, the total count, or an error if the search fails.
+func (m *memRepository) FindEntitiesByTypePagedWithTotal(atype oam.AssetType, since time.Time, limit, offset int) ([]*types.Entity, int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	all, _ := m.filterEntities(func(r *entityRecord) bool {
+		return r.entity.Asset.AssetType() == atype && seenSince(r.entity.LastSeen, since)
+	}, "")
+	total := int64(len(all))
+
+	var results []*types.Entity
+	if offset >= 0 && offset < len(all) {
+		results = all[offset:]
+		if limit >= 0 && limit < len(results) {
+			results = results[:limit]
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, total, m.opts.NoResults("no entities of the specified type")
+	}
+	return results, total, nil
+}
+
+// FindEntitiesByField finds all entities in the repository of the provided asset type, where the named field of the
+// asset content equals the value, and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field is not valid or the search fails.
+func (m *memRepository) FindEntitiesByField(atype oam.AssetType, field string, value any, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	// compare the values as they appear in the serialized asset content
+	want, err := jsonValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		if r.entity.Asset.AssetType() != atype || !seenSince(r.entity.LastSeen, since) {
+			return false
+		}
+
+		content, err := r.entity.Asset.JSON()
+		if err != nil {
+			return false
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(content, &fields); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(fields[field], want)
+	}, "zero entities found")
+}
+
+// FindEntitiesByFieldRange finds all entities in the repository of the provided asset type, where the named numeric
+// field of the asset content is within the inclusive range from min to max, and last seen after the since parameter.
+// A nil bound leaves that side of the range open. If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field is not numeric or the search fails.
+func (m *memRepository) FindEntitiesByFieldRange(atype oam.AssetType, field string, min, max any, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateNumericAssetField(atype, field); err != nil {
+		return nil, err
+	}
+	if _, _, err := types.NumericBound(min); err != nil {
+		return nil, err
+	}
+	if _, _, err := types.NumericBound(max); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		return r.entity.Asset.AssetType() == atype && seenSince(r.entity.LastSeen, since) &&
+			types.AssetFieldInRange(r.entity.Asset, field, min, max)
+	}, "zero entities found")
+}
+
+// FindEntitiesByFieldRegex finds all entities in the repository of the provided asset type, where the text of the
+// named field of the asset content matches the regular expression pattern, and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field or pattern is not valid or the search fails.
+func (m *memRepository) FindEntitiesByFieldRegex(atype oam.AssetType, field, pattern string, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		return r.entity.Asset.AssetType() == atype && seenSince(r.entity.LastSeen, since) &&
+			types.AssetFieldMatches(r.entity.Asset, field, re)
+	}, "zero entities found")
+}
+
+// jsonValue returns the value as it is decoded from JSON.
+func jsonValue(value any) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SearchFQDNs finds all FQDN entities with a name containing the provided substring, ignoring case, and last seen after
+// the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) SearchFQDNs(substr string, since time.Time) ([]*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		var name string
+		switch v := r.entity.Asset.(type) {
+		case *domain.FQDN:
+			name = v.Name
+		case domain.FQDN:
+			name = v.Name
+		default:
+			return false
+		}
+		return strings.Contains(strings.ToLower(name), strings.ToLower(substr)) && seenSince(r.entity.LastSeen, since)
+	}, "zero entities found")
+}
+
+// SearchEntities finds all entities with serialized content containing the query, ignoring case, that are of one of
+// the provided asset types and last seen after the since parameter. The query is matched against the JSON
+// serialization of each asset, so field names also match.
+// Every entity of the asset types is serialized for the search, so the types should be provided whenever possible.
+// The query must have at least types.MinSearchQueryLength characters.
+// If no asset types are provided, entities of all types are searched.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) SearchEntities(query string, atypes []oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateSearchQuery(query); err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		if !matchesAssetType(r.entity.Asset.AssetType(), atypes) {
+			return false
+		}
+		if !seenSince(r.entity.LastSeen, since) {
+			return false
+		}
+
+		content, err := r.entity.Asset.JSON()
+		if err != nil {
+			return false
+		}
+		return strings.Contains(strings.ToLower(string(content)), query)
+	}, "zero entities found")
+}
+
+// FindServicesByAttribute finds all Service entities with the provided value among the values of the
+// header attribute with the provided key, last seen after the since parameter.
+// The key is matched exactly, so canonical header keys must be used for headers set with http.Header.Set.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindServicesByAttribute(key, value string, since time.Time) ([]*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		var headers map[string][]string
+		switch v := r.entity.Asset.(type) {
+		case *service.Service:
+			headers = v.Headers
+		case service.Service:
+			headers = v.Headers
+		default:
+			return false
+		}
+		if !seenSince(r.entity.LastSeen, since) {
+			return false
+		}
+
+		for _, v := range headers[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}, "zero entities found")
+}
+
+// FindEntitiesByRun finds all entities last written by the scan run with the provided ID.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesByRun(runID string) ([]*types.Entity, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		return r.runID == runID
+	}, "zero entities found")
+}
+
+// filterEntities returns copies of the entities accepted by the filter, in the order they were created,
+// or an error with the provided message when no entities are accepted. The caller must hold the lock.
+func (m *memRepository) filterEntities(filter func(r *entityRecord) bool, msg string) ([]*types.Entity, error) {
+	var matches []*entityRecord
+	for _, r := range m.entities {
+		if filter(r) {
+			matches = append(matches, r)
+		}
+	}
+
+	if len(matches) == 0 {
+		return []*types.Entity{}, m.opts.NoResults(msg)
+	}
+	return sortedEntities(matches), nil
+}
+
+// UpdateEntityContentCAS replaces the content of the entity with the provided ID, only when the stored content
+// is equal to the expected asset. The comparison and the update are performed while holding the write lock.
+// Returns true if the content was updated, or an error if the entity is not found.
+func (m *memRepository) UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error) {
+	if expected == nil || new == nil {
+		return false, errors.New("the expected and new assets must not be nil")
+	}
+	if expected.AssetType() != new.AssetType() {
+		return false, errors.New("the new asset type does not match the expected asset type")
+	}
+	if m.opts.ValidateContent {
+		if err := types.ValidateAsset(new); err != nil {
+			return false, err
+		}
+	}
+
+	expectedContent, err := expected.JSON()
+	if err != nil {
+		return false, err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	r, found := m.entities[id]
+	if !found {
+		return false, types.ErrEntityNotFound
+	}
+	if r.entity.Asset.AssetType() != expected.AssetType() {
+		return false, nil
+	}
+
+	currentContent, err := r.entity.Asset.JSON()
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(currentContent, expectedContent) {
+		return false, nil
+	}
+
+	r.entity.Asset = new
+	r.entity.LastSeen = time.Now()
+	return true, nil
+}
+
+// DeleteEntity removes an entity in the repository by its ID, along with the edges and tags of the entity.
+// Removing an entity that does not exist is not an error.
+func (m *memRepository) DeleteEntity(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.entities, id)
+	for tid, t := range m.entityTags {
+		if t.entityID == id {
+			delete(m.entityTags, tid)
+		}
+	}
+	for eid, e := range m.edges {
+		if e.edge.FromEntity.ID == id || e.edge.ToEntity.ID == id {
+			m.deleteEdge(eid)
+		}
+	}
+	return nil
+}
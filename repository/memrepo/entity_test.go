@@ -0,0 +1,432 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	oamreg "github.com/owasp-amass/open-asset-model/registration"
+	"github.com/owasp-amass/open-asset-model/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDBType(t *testing.T) {
+	assert.Equal(t, Memory, New().GetDBType())
+}
+
+func TestLastSeenUpdates(t *testing.T) {
+	store := New()
+
+	ip, _ := netip.ParseAddr("45.73.25.1")
+	asset := &network.IPAddress{Address: ip, Type: "IPv4"}
+	a1, err := store.CreateAsset(asset)
+	assert.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	a2, err := store.CreateAsset(asset)
+	assert.NoError(t, err)
+	assert.Equal(t, a1.ID, a2.ID)
+	assert.Equal(t, a1.CreatedAt, a2.CreatedAt)
+	assert.True(t, a2.LastSeen.After(a1.LastSeen))
+}
+
+func TestLastSeenWindow(t *testing.T) {
+	store := New(options.WithLastSeenWindow(time.Hour))
+
+	a1, err := store.CreateAsset(&domain.FQDN{Name: "window.owasp.org"})
+	assert.NoError(t, err)
+
+	a2, err := store.CreateAsset(&domain.FQDN{Name: "window.owasp.org"})
+	assert.NoError(t, err)
+	assert.Equal(t, a1.ID, a2.ID)
+	assert.Equal(t, a1.LastSeen, a2.LastSeen)
+}
+
+func TestFindEntities(t *testing.T) {
+	store := New()
+	since := time.Now().Add(-time.Minute)
+
+	_, err := store.CreateEntity(&types.Entity{
+		LastSeen: time.Now().Add(-time.Hour),
+		Asset:    &domain.FQDN{Name: "old.owasp.org"},
+	})
+	assert.NoError(t, err)
+
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	found, err := store.FindEntityById(www.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, www.Asset, found.Asset)
+
+	entities, err := store.FindEntitiesByContent(&domain.FQDN{Name: "www.owasp.org"}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	entities, err = store.FindEntitiesByContentFold(&domain.FQDN{Name: "WWW.OWASP.org"}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	entities, err = store.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 2)
+
+	entities, err = store.FindEntitiesByType(oam.FQDN, since)
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, www.ID, entities[0].ID)
+
+	entities, err = store.FindStaleEntities(oam.FQDN, 30*time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "old.owasp.org", entities[0].Asset.Key())
+
+	entities, err = store.SearchFQDNs("owasp", since)
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	entities, err = store.SearchFQDNs("OWASP", since)
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	entities, err = store.FindEntitiesByField(oam.FQDN, "name", "old.owasp.org", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	_, err = store.FindEntitiesByField(oam.FQDN, "unknown", "old.owasp.org", time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByType(oam.IPAddress, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntityByContentLatest(t *testing.T) {
+	store := New()
+
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	entity, err := store.FindEntityByContentLatest(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+	assert.Equal(t, www.ID, entity.ID)
+
+	_, err = store.FindEntityByContentLatest(&domain.FQDN{Name: "missing.owasp.org"})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	store := New()
+
+	_, err := store.FindEntityById("missing")
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+	_, err = store.FindEdgeById("missing")
+	assert.ErrorIs(t, err, types.ErrEdgeNotFound)
+	_, err = store.FindEntityTagById("missing")
+	assert.ErrorIs(t, err, types.ErrTagNotFound)
+	_, err = store.FindEdgeTagById("missing")
+	assert.ErrorIs(t, err, types.ErrTagNotFound)
+
+	_, err = store.FindEntitiesByContent(&domain.FQDN{Name: "missing.owasp.org"}, time.Time{})
+	assert.ErrorIs(t, err, types.ErrNoResults)
+	_, err = store.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.ErrorIs(t, err, types.ErrNoResults)
+}
+
+func TestSearchEntities(t *testing.T) {
+	store := New()
+
+	_, err := store.CreateAsset(&domain.FQDN{Name: "www.Search.owasp.org"})
+	assert.NoError(t, err)
+	_, err = store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.168.10.1"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	entities, err := store.SearchEntities("SEARCH.owasp", nil, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "www.Search.owasp.org", entities[0].Asset.Key())
+
+	entities, err = store.SearchEntities("192.168", []oam.AssetType{oam.IPAddress}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	_, err = store.SearchEntities("192.168", []oam.AssetType{oam.FQDN}, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.SearchEntities("search.owasp", nil, time.Now().Add(time.Hour))
+	assert.Error(t, err)
+
+	_, err = store.SearchEntities(" ow ", nil, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestUpsertEntity(t *testing.T) {
+	store := New()
+	asset := &domain.FQDN{Name: "upsert.owasp.org"}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var created int
+	ids := make(map[string]struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			e, c, err := store.UpsertEntity(asset)
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if c {
+				created++
+			}
+			ids[e.ID] = struct{}{}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, created)
+	assert.Len(t, ids, 1)
+
+	entities, err := store.FindEntitiesByContent(asset, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+}
+
+func TestCreateEntityWithPrevLastSeen(t *testing.T) {
+	store := New()
+
+	seen := time.Now().Add(-time.Hour)
+	first, prev, err := store.CreateEntityWithPrevLastSeen(&types.Entity{
+		CreatedAt: seen,
+		LastSeen:  seen,
+		Asset:     &domain.FQDN{Name: "prev.owasp.org"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, prev.IsZero())
+
+	second, prev, err := store.CreateEntityWithPrevLastSeen(&types.Entity{Asset: &domain.FQDN{Name: "prev.owasp.org"}})
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.True(t, prev.Equal(first.LastSeen))
+	assert.True(t, second.LastSeen.After(prev))
+}
+
+func TestSecondaryKeyDedup(t *testing.T) {
+	store := New()
+
+	phone, err := store.CreateAsset(&contact.Phone{Raw: "+1 (555) 555-0100", E164: "+15555550100"})
+	assert.NoError(t, err)
+
+	// the asset only populates the secondary key, so the primary key finds nothing
+	partial := &contact.Phone{E164: "+15555550100"}
+	entities, err := store.FindEntitiesByContent(partial, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, phone.ID, entities[0].ID)
+	}
+
+	again, err := store.CreateAsset(partial)
+	assert.NoError(t, err)
+	assert.Equal(t, phone.ID, again.ID)
+	// the stored fields missing from the partial asset are kept
+	assert.Equal(t, "+1 (555) 555-0100", again.Asset.(*contact.Phone).Raw)
+
+	found, err := store.FindEntityById(phone.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &contact.Phone{Raw: "+1 (555) 555-0100", E164: "+15555550100"}, found.Asset)
+
+	_, err = store.FindEntitiesByContent(&contact.Phone{E164: "+15555550199"}, time.Time{})
+	assert.Error(t, err)
+
+	assert.Error(t, types.RegisterAssetKeyFields(oam.Phone, "e164", "raw"))
+	assert.Error(t, types.RegisterAssetKeyFields(oam.Phone, "raw", "missing"))
+}
+
+func TestFindEntitiesByFieldRegex(t *testing.T) {
+	store := New()
+
+	for _, name := range []string{"dev1.owasp.org", "staging22.owasp.org", "prod1.owasp.org"} {
+		_, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+
+	entities, err := store.FindEntitiesByFieldRegex(oam.FQDN, "name", `^(dev|staging)\d+\.`, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 2)
+
+	_, err = store.FindEntitiesByFieldRegex(oam.FQDN, "name", `^qa\d+\.`, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByFieldRegex(oam.FQDN, "name", `^(dev`, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByFieldRange(t *testing.T) {
+	store := New()
+
+	for _, num := range []int{64500, 64510, 64520, 64530} {
+		_, err := store.CreateAsset(&network.AutonomousSystem{Number: num})
+		assert.NoError(t, err)
+	}
+
+	entities, err := store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 64505, 64520, time.Time{})
+	assert.NoError(t, err)
+	var nums []int
+	for _, e := range entities {
+		nums = append(nums, e.Asset.(*network.AutonomousSystem).Number)
+	}
+	assert.ElementsMatch(t, []int{64510, 64520}, nums)
+
+	entities, err = store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 64525, nil, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	_, err = store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 70000, 80000, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByFieldRange(oam.FQDN, "name", 1, 2, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", "64500", nil, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByTypePagedWithTotal(t *testing.T) {
+	store := New()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		e, err := store.CreateAsset(&domain.FQDN{Name: "page" + strconv.Itoa(i) + ".owasp.org"})
+		assert.NoError(t, err)
+		ids = append(ids, e.ID)
+	}
+
+	page, total, err := store.FindEntitiesByTypePagedWithTotal(oam.FQDN, time.Time{}, 2, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Len(t, page, 2)
+	assert.Equal(t, ids[2], page[0].ID)
+	assert.Equal(t, ids[3], page[1].ID)
+
+	_, total, err = store.FindEntitiesByTypePagedWithTotal(oam.FQDN, time.Time{}, 2, 10)
+	assert.Error(t, err)
+	assert.Equal(t, int64(5), total)
+}
+
+func TestFindServicesByAttribute(t *testing.T) {
+	store := New()
+
+	headers := http.Header{}
+	headers.Set("Server", "nginx")
+	_, err := store.CreateAsset(&service.Service{
+		Identifier: "222.222.222.222:443",
+		Banner:     "HTTP/1.1 200 OK",
+		BannerLen:  15,
+		Headers:    headers,
+	})
+	assert.NoError(t, err)
+
+	entities, err := store.FindServicesByAttribute("Server", "nginx", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	_, err = store.FindServicesByAttribute("Server", "apache", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestUpdateEntityContentCAS(t *testing.T) {
+	store := New()
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "cas.owasp.org"})
+	assert.NoError(t, err)
+
+	ok, err := store.UpdateEntityContentCAS(entity.ID, &domain.FQDN{Name: "other.owasp.org"}, &domain.FQDN{Name: "new.owasp.org"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.UpdateEntityContentCAS(entity.ID, &domain.FQDN{Name: "cas.owasp.org"}, &domain.FQDN{Name: "new.owasp.org"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	found, err := store.FindEntityById(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "new.owasp.org", found.Asset.Key())
+}
+
+func TestCreateEntityContentValidation(t *testing.T) {
+	store := New(options.WithContentValidation())
+
+	_, err := store.CreateAsset(&domain.FQDN{})
+	assert.Error(t, err)
+
+	_, err = store.CreateAsset(&domain.FQDN{Name: "valid.owasp.org"})
+	assert.NoError(t, err)
+}
+
+func TestNetblockHierarchy(t *testing.T) {
+	store := New()
+
+	parent, err := store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.0.0.0/16"), Type: "IPv4"})
+	assert.NoError(t, err)
+	child1, err := store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.0.1.0/24"), Type: "IPv4"})
+	assert.NoError(t, err)
+	child2, err := store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.0.2.0/24"), Type: "IPv4"})
+	assert.NoError(t, err)
+	// the /26 is a child of the first /24, not the /16
+	_, err = store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.0.1.0/26"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	children, err := store.ChildNetblocks(parent, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, children, 2) {
+		assert.Equal(t, child1.ID, children[0].ID)
+		assert.Equal(t, child2.ID, children[1].ID)
+	}
+
+	p, err := store.ParentNetblock(child2, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, parent.ID, p.ID)
+
+	_, err = store.ParentNetblock(parent, time.Time{})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+
+	rparent, err := store.CreateAsset(&oamreg.IPNetRecord{
+		CIDR:   netip.MustParsePrefix("172.16.0.0/16"),
+		Type:   "IPv4",
+		Handle: "NET-172-16-0-0-1",
+	})
+	assert.NoError(t, err)
+	rchild, err := store.CreateAsset(&oamreg.IPNetRecord{
+		CIDR:         netip.MustParsePrefix("172.16.5.0/24"),
+		Type:         "IPv4",
+		Handle:       "NET-172-16-5-0-1",
+		ParentHandle: "NET-172-16-0-0-1",
+	})
+	assert.NoError(t, err)
+
+	children, err = store.ChildNetblocks(rparent, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, children, 1) {
+		assert.Equal(t, rchild.ID, children[0].ID)
+	}
+
+	p, err = store.ParentNetblock(rchild, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, rparent.ID, p.ID)
+}
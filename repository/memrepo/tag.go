@@ -0,0 +1,697 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/property"
+)
+
+// CreateEntityTag creates a new entity tag in the repository.
+// A property equal to an existing tag on the entity updates the last seen time of the existing tag instead.
+// Returns the created entity tag as a types.EntityTag or an error if the creation fails.
+func (m *memRepository) CreateEntityTag(entity *types.Entity, input *types.EntityTag) (*types.EntityTag, error) {
+	if entity == nil || input == nil || input.Property == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	r, err := m.createEntityTag(entity.ID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := r.copy()
+	tag.Property = input.Property
+	tag.Entity = entity
+	return tag, nil
+}
+
+// createEntityTag creates or updates the tag for the property on the entity. The caller must hold the write lock.
+func (m *memRepository) createEntityTag(entityID string, input *types.EntityTag) (*entityTagRecord, error) {
+	if _, found := m.entities[entityID]; !found {
+		return nil, errors.New("the entity does not exist")
+	}
+
+	// ensure that duplicate entity tags are not entered into the repository
+	for _, r := range m.entityTags {
+		if r.entityID == entityID && sameProperty(r.tag.Property, input.Property) {
+			r.tag.LastSeen = time.Now()
+			return r, nil
+		}
+	}
+
+	seq, id := m.nextID()
+	r := &entityTagRecord{
+		seq:      seq,
+		entityID: entityID,
+		tag: types.EntityTag{
+			ID:        id,
+			CreatedAt: lastSeen(input.CreatedAt),
+			LastSeen:  lastSeen(input.LastSeen),
+			Property:  input.Property,
+		},
+	}
+	m.entityTags[id] = r
+	return r, nil
+}
+
+// CreateEntityProperty creates a new entity tag in the repository.
+// Returns the created entity tag as a types.EntityTag or an error if the creation fails.
+func (m *memRepository) CreateEntityProperty(entity *types.Entity, prop oam.Property) (*types.EntityTag, error) {
+	return m.CreateEntityTag(entity, &types.EntityTag{Property: prop})
+}
+
+// CreateEntityTags creates entity tags in the repository for each of the provided properties.
+// A property equal to an existing tag on the entity, or to an earlier property of the batch, updates the
+// last seen time of the existing tag instead.
+// Returns the created or updated entity tags, one for each distinct property, or an error if the entity is not found.
+func (m *memRepository) CreateEntityTags(entity *types.Entity, props []oam.Property) ([]*types.EntityTag, error) {
+	if entity == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if _, found := m.entities[entity.ID]; !found {
+		return nil, errors.New("the entity does not exist")
+	}
+
+	var seen []oam.Property
+	var results []*types.EntityTag
+loop:
+	for _, prop := range props {
+		if prop == nil {
+			continue
+		}
+		for _, p := range seen {
+			if sameProperty(p, prop) {
+				continue loop
+			}
+		}
+		seen = append(seen, prop)
+
+		r, err := m.createEntityTag(entity.ID, &types.EntityTag{Property: prop})
+		if err != nil {
+			return nil, err
+		}
+
+		tag := r.copy()
+		tag.Property = prop
+		tag.Entity = entity
+		results = append(results, tag)
+	}
+	return results, nil
+}
+
+// FindEntityTagById finds an entity tag in the repository by the ID.
+// Returns the discovered tag as a types.EntityTag or an error if the tag is not found.
+func (m *memRepository) FindEntityTagById(id string) (*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, found := m.entityTags[id]
+	if !found {
+		return nil, fmt.Errorf("entity %w", types.ErrTagNotFound)
+	}
+	return r.copy(), nil
+}
+
+// FindEntityTagsByContent finds entity tags in the repository with the same type, name and value as the
+// provided property, last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (m *memRepository) FindEntityTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntityTags(func(r *entityTagRecord) bool {
+		return sameProperty(r.tag.Property, prop) && seenSince(r.tag.LastSeen, since)
+	}, "zero entity tags found")
+}
+
+// FindEntityTagsBySource finds all SourceProperty entity tags, across all entities, with the provided source and
+// last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (m *memRepository) FindEntityTagsBySource(source string, since time.Time) ([]*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntityTags(func(r *entityTagRecord) bool {
+		return r.tag.Property.PropertyType() == oam.SourceProperty &&
+			r.tag.Property.Name() == source && seenSince(r.tag.LastSeen, since)
+	}, "zero entity tags found")
+}
+
+// FindEntityTagsByValuePrefix finds all SimpleProperty entity tags, across all entities, with the provided name,
+// a value starting with the prefix and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (m *memRepository) FindEntityTagsByValuePrefix(name, prefix string, since time.Time) ([]*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEntityTags(func(r *entityTagRecord) bool {
+		p, ok := r.tag.Property.(*property.SimpleProperty)
+		return ok && p.PropertyName == name &&
+			strings.HasPrefix(p.PropertyValue, prefix) && seenSince(r.tag.LastSeen, since)
+	}, "zero entity tags found")
+}
+
+// FindEntitiesByTags finds all entities with tags matching the predicates, combined by the AndOr, considering only
+// tags last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (m *memRepository) FindEntitiesByTags(predicates []types.TagPredicate, combine types.AndOr, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateTagPredicates(predicates, combine); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	// the IDs of the entities with a tag matching each predicate
+	tagged := make([]map[string]struct{}, len(predicates))
+	for i := range tagged {
+		tagged[i] = make(map[string]struct{})
+	}
+	for _, r := range m.entityTags {
+		if !seenSince(r.tag.LastSeen, since) {
+			continue
+		}
+
+		for i, p := range predicates {
+			if p.Matches(r.tag.Property) {
+				tagged[i][r.entityID] = struct{}{}
+			}
+		}
+	}
+
+	return m.filterEntities(func(r *entityRecord) bool {
+		for _, ids := range tagged {
+			_, found := ids[r.entity.ID]
+			if combine == types.Or && found {
+				return true
+			} else if combine == types.And && !found {
+				return false
+			}
+		}
+		return combine == types.And
+	}, "zero entities found")
+}
+
+// GetEntityTags finds all tags for the entity with the specified names and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified entity are returned.
+func (m *memRepository) GetEntityTags(entity *types.Entity, since time.Time, names ...string) ([]*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEntityTags(func(r *entityTagRecord) bool {
+		return r.entityID == entity.ID && seenSince(r.tag.LastSeen, since) &&
+			matchesLabel(r.tag.Property.Name(), names)
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Entity = entity
+	}
+	return tags, nil
+}
+
+// GetEntityTagsBetween finds all tags for the entity with the specified names and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no names are specified, all tags for the specified entity within the range are returned.
+func (m *memRepository) GetEntityTagsBetween(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEntityTags(func(r *entityTagRecord) bool {
+		return r.entityID == entity.ID && seenBetween(r.tag.LastSeen, start, end) &&
+			matchesLabel(r.tag.Property.Name(), names)
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Entity = entity
+	}
+	return tags, nil
+}
+
+// GetEntityTagsByType finds all tags for the entity with the specified property types and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no property types are specified, all tags for the specified entity are returned.
+func (m *memRepository) GetEntityTagsByType(entity *types.Entity, since time.Time, ptypes ...oam.PropertyType) ([]*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEntityTags(func(r *entityTagRecord) bool {
+		return r.entityID == entity.ID && seenSince(r.tag.LastSeen, since) &&
+			matchesPropertyType(r.tag.Property.PropertyType(), ptypes)
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Entity = entity
+	}
+	return tags, nil
+}
+
+// CountEntityTags counts the tags for the entity with the specified names and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified entity are counted.
+func (m *memRepository) CountEntityTags(entity *types.Entity, since time.Time, names ...string) (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var count int64
+	for _, r := range m.entityTags {
+		if r.entityID == entity.ID && seenSince(r.tag.LastSeen, since) &&
+			matchesLabel(r.tag.Property.Name(), names) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// EntityTagTimeline finds all tags for the entity with the provided ID, ordered chronologically by the time each tag
+// was created and then by the time it was last updated.
+// Returns the entity tags as []*types.EntityTag or an error if the search fails.
+func (m *memRepository) EntityTagTimeline(id string) ([]*types.EntityTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEntityTags(func(r *entityTagRecord) bool {
+		return r.entityID == id
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		if !tags[i].CreatedAt.Equal(tags[j].CreatedAt) {
+			return tags[i].CreatedAt.Before(tags[j].CreatedAt)
+		}
+		return tags[i].LastSeen.Before(tags[j].LastSeen)
+	})
+	return tags, nil
+}
+
+// ExistingEntityTags returns the subset of the provided properties that are already present as tags on the
+// entity, matched by name and value.
+// Returns an empty slice when none of the properties are present.
+func (m *memRepository) ExistingEntityTags(entity *types.Entity, props []oam.Property) ([]oam.Property, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, _ := m.filterEntityTags(func(r *entityTagRecord) bool {
+		return r.entityID == entity.ID
+	}, "")
+	return types.ExistingProperties(tags, props), nil
+}
+
+// DeleteEntityTag removes an entity tag in the repository by its ID.
+// Removing a tag that does not exist is not an error.
+func (m *memRepository) DeleteEntityTag(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.entityTags, id)
+	return nil
+}
+
+// DeleteEntityTagsByNameGlobal removes all entity tags in the repository with the specified property name.
+// Returns the number of entity tags that were removed.
+func (m *memRepository) DeleteEntityTagsByNameGlobal(name string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var count int64
+	for id, r := range m.entityTags {
+		if r.tag.Property.Name() == name {
+			delete(m.entityTags, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// filterEntityTags returns copies of the entity tags accepted by the filter, in the order they were created,
+// or an error with the provided message when no tags are accepted. The caller must hold the lock.
+func (m *memRepository) filterEntityTags(filter func(r *entityTagRecord) bool, msg string) ([]*types.EntityTag, error) {
+	var matches []*entityTagRecord
+	for _, r := range m.entityTags {
+		if filter(r) {
+			matches = append(matches, r)
+		}
+	}
+
+	if len(matches) == 0 {
+		return []*types.EntityTag{}, m.opts.NoResults(msg)
+	}
+	return sortedEntityTags(matches), nil
+}
+
+// CreateEdgeTag creates a new edge tag in the repository.
+// A property equal to an existing tag on the edge updates the last seen time of the existing tag instead.
+// Returns the created edge tag as a types.EdgeTag or an error if the creation fails.
+func (m *memRepository) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag) (*types.EdgeTag, error) {
+	if edge == nil || input == nil || input.Property == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	r, err := m.createEdgeTag(edge.ID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := r.copy()
+	tag.Property = input.Property
+	tag.Edge = edge
+	return tag, nil
+}
+
+// createEdgeTag creates or updates the tag for the property on the edge. The caller must hold the write lock.
+func (m *memRepository) createEdgeTag(edgeID string, input *types.EdgeTag) (*edgeTagRecord, error) {
+	if _, found := m.edges[edgeID]; !found {
+		return nil, errors.New("the edge does not exist")
+	}
+
+	// ensure that duplicate edge tags are not entered into the repository
+	for _, r := range m.edgeTags {
+		if r.edgeID == edgeID && sameProperty(r.tag.Property, input.Property) {
+			r.tag.LastSeen = time.Now()
+			return r, nil
+		}
+	}
+
+	seq, id := m.nextID()
+	r := &edgeTagRecord{
+		seq:    seq,
+		edgeID: edgeID,
+		tag: types.EdgeTag{
+			ID:        id,
+			CreatedAt: lastSeen(input.CreatedAt),
+			LastSeen:  lastSeen(input.LastSeen),
+			Property:  input.Property,
+		},
+	}
+	m.edgeTags[id] = r
+	return r, nil
+}
+
+// CreateEdgeProperty creates a new edge tag in the repository.
+// Returns the created edge tag as a types.EdgeTag or an error if the creation fails.
+func (m *memRepository) CreateEdgeProperty(edge *types.Edge, prop oam.Property) (*types.EdgeTag, error) {
+	return m.CreateEdgeTag(edge, &types.EdgeTag{Property: prop})
+}
+
+// CreateEdgeTags creates edge tags in the repository for each of the provided properties.
+// A property equal to an existing tag on the edge updates the last seen time of the existing tag instead.
+// Returns the created or updated edge tags, one for each distinct property, or an error if the edge is not found.
+func (m *memRepository) CreateEdgeTags(edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error) {
+	if edge == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	return m.createEdgeTags(edge, props)
+}
+
+// CreateEdgeWithProperties creates the edge, as done by CreateEdge, and the edge tags for the provided properties,
+// as done by CreateEdgeTags. The edge is deleted when it was created by the call and the tags cannot be created.
+// Returns the edge and the created or updated edge tags, or an error if the creation fails.
+func (m *memRepository) CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error) {
+	e, created, err := m.UpsertEdge(edge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags, err := m.CreateEdgeTags(e, props)
+	if err != nil {
+		if created {
+			_ = m.DeleteEdge(e.ID)
+		}
+		return nil, nil, err
+	}
+	return e, tags, nil
+}
+
+// CreateEdgeTagsBatch creates the edge tags for the properties provided for each edge ID, using the same
+// deduplication as CreateEdgeTags. No tags are created when one of the edges is not found.
+// Returns the created or updated edge tags by edge ID, or an error if an edge is not found.
+func (m *memRepository) CreateEdgeTagsBatch(props map[string][]oam.Property) (map[string][]*types.EdgeTag, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for id := range props {
+		if _, found := m.edges[id]; !found {
+			return nil, errors.New("the edge does not exist")
+		}
+	}
+
+	results := make(map[string][]*types.EdgeTag, len(props))
+	for id, list := range props {
+		tags, err := m.createEdgeTags(&types.Edge{ID: id}, list)
+		if err != nil {
+			return nil, err
+		}
+		results[id] = tags
+	}
+	return results, nil
+}
+
+// createEdgeTags creates or updates the tags for the distinct properties on the edge.
+// The caller must hold the write lock.
+func (m *memRepository) createEdgeTags(edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error) {
+	if _, found := m.edges[edge.ID]; !found {
+		return nil, errors.New("the edge does not exist")
+	}
+
+	var seen []oam.Property
+	var results []*types.EdgeTag
+loop:
+	for _, prop := range props {
+		if prop == nil {
+			continue
+		}
+		for _, p := range seen {
+			if sameProperty(p, prop) {
+				continue loop
+			}
+		}
+		seen = append(seen, prop)
+
+		r, err := m.createEdgeTag(edge.ID, &types.EdgeTag{Property: prop})
+		if err != nil {
+			return nil, err
+		}
+
+		tag := r.copy()
+		tag.Property = prop
+		tag.Edge = edge
+		results = append(results, tag)
+	}
+	return results, nil
+}
+
+// FindEdgeTagById finds an edge tag in the repository by the ID.
+// Returns the discovered tag as a types.EdgeTag or an error if the tag is not found.
+func (m *memRepository) FindEdgeTagById(id string) (*types.EdgeTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, found := m.edgeTags[id]
+	if !found {
+		return nil, fmt.Errorf("edge %w", types.ErrTagNotFound)
+	}
+	return r.copy(), nil
+}
+
+// FindEdgeTagsByContent finds edge tags in the repository with the same type, name and value as the
+// provided property, last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching edge tags as []*types.EdgeTag or an error if the search fails.
+func (m *memRepository) FindEdgeTagsByContent(prop oam.Property, since time.Time) ([]*types.EdgeTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.filterEdgeTags(func(r *edgeTagRecord) bool {
+		return sameProperty(r.tag.Property, prop) && seenSince(r.tag.LastSeen, since)
+	}, "zero edge tags found")
+}
+
+// FindAllTagsByContent finds both the entity tags and the edge tags in the repository with the same type, name
+// and value as the provided property, last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the matching entity tags and edge tags, or an error if neither kind of tag was found.
+func (m *memRepository) FindAllTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, []*types.EdgeTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	entityTags, _ := m.filterEntityTags(func(r *entityTagRecord) bool {
+		return sameProperty(r.tag.Property, prop) && seenSince(r.tag.LastSeen, since)
+	}, "zero entity tags found")
+	edgeTags, _ := m.filterEdgeTags(func(r *edgeTagRecord) bool {
+		return sameProperty(r.tag.Property, prop) && seenSince(r.tag.LastSeen, since)
+	}, "zero edge tags found")
+
+	if len(entityTags) == 0 && len(edgeTags) == 0 {
+		return []*types.EntityTag{}, []*types.EdgeTag{}, m.opts.NoResults("zero tags found")
+	}
+	return entityTags, edgeTags, nil
+}
+
+// GetEdgeTags finds all tags for the edge with the specified names and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified edge are returned.
+func (m *memRepository) GetEdgeTags(edge *types.Edge, since time.Time, names ...string) ([]*types.EdgeTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEdgeTags(func(r *edgeTagRecord) bool {
+		return r.edgeID == edge.ID && seenSince(r.tag.LastSeen, since) &&
+			matchesLabel(r.tag.Property.Name(), names)
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Edge = edge
+	}
+	return tags, nil
+}
+
+// GetEdgeTagsBetween finds all tags for the edge with the specified names and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no names are specified, all tags for the specified edge within the range are returned.
+func (m *memRepository) GetEdgeTagsBetween(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEdgeTags(func(r *edgeTagRecord) bool {
+		return r.edgeID == edge.ID && seenBetween(r.tag.LastSeen, start, end) &&
+			matchesLabel(r.tag.Property.Name(), names)
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Edge = edge
+	}
+	return tags, nil
+}
+
+// GetEdgeTagsByType finds all tags for the edge with the specified property types and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no property types are specified, all tags for the specified edge are returned.
+func (m *memRepository) GetEdgeTagsByType(edge *types.Edge, since time.Time, ptypes ...oam.PropertyType) ([]*types.EdgeTag, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	tags, err := m.filterEdgeTags(func(r *edgeTagRecord) bool {
+		return r.edgeID == edge.ID && seenSince(r.tag.LastSeen, since) &&
+			matchesPropertyType(r.tag.Property.PropertyType(), ptypes)
+	}, "zero tags found")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		tag.Edge = edge
+	}
+	return tags, nil
+}
+
+// CountEdgeTags counts the tags for the edge with the specified names and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified edge are counted.
+func (m *memRepository) CountEdgeTags(edge *types.Edge, since time.Time, names ...string) (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var count int64
+	for _, r := range m.edgeTags {
+		if r.edgeID == edge.ID && seenSince(r.tag.LastSeen, since) &&
+			matchesLabel(r.tag.Property.Name(), names) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteEdgeTag removes an edge tag in the repository by its ID.
+// Removing a tag that does not exist is not an error.
+func (m *memRepository) DeleteEdgeTag(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.edgeTags, id)
+	return nil
+}
+
+// DeleteEdgeTagsByNameGlobal removes all edge tags in the repository with the specified property name.
+// Returns the number of edge tags that were removed.
+func (m *memRepository) DeleteEdgeTagsByNameGlobal(name string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var count int64
+	for id, r := range m.edgeTags {
+		if r.tag.Property.Name() == name {
+			delete(m.edgeTags, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// filterEdgeTags returns copies of the edge tags accepted by the filter, in the order they were created,
+// or an error with the provided message when no tags are accepted. The caller must hold the lock.
+func (m *memRepository) filterEdgeTags(filter func(r *edgeTagRecord) bool, msg string) ([]*types.EdgeTag, error) {
+	var matches []*edgeTagRecord
+	for _, r := range m.edgeTags {
+		if filter(r) {
+			matches = append(matches, r)
+		}
+	}
+
+	if len(matches) == 0 {
+		return []*types.EdgeTag{}, m.opts.NoResults(msg)
+	}
+	return sortedEdgeTags(matches), nil
+}
@@ -0,0 +1,460 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package memrepo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityTag(t *testing.T) {
+	store := New()
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "utica.edu"})
+	assert.NoError(t, err)
+
+	prop := &property.SimpleProperty{PropertyName: "test", PropertyValue: "foo"}
+	ct, err := store.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+	assert.Equal(t, oam.SimpleProperty, ct.Property.PropertyType())
+
+	time.Sleep(10 * time.Millisecond)
+
+	// the duplicate property updates the existing tag
+	ct2, err := store.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+	assert.Equal(t, ct.ID, ct2.ID)
+	assert.True(t, ct2.LastSeen.After(ct.LastSeen))
+
+	ct3, err := store.CreateEntityProperty(entity, &property.SimpleProperty{PropertyName: "test", PropertyValue: "bar"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, ct.ID, ct3.ID)
+
+	tags, err := store.GetEntityTags(entity, time.Time{}, "test")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+
+	tags, err = store.FindEntityTagsByContent(prop, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, entity.ID, tags[0].Entity.ID)
+
+	existing, err := store.ExistingEntityTags(entity, []oam.Property{
+		prop,
+		&property.SimpleProperty{PropertyName: "test", PropertyValue: "baz"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 1)
+
+	_, err = store.CreateEntityProperty(entity, &property.SourceProperty{Source: "dns", Confidence: 100})
+	assert.NoError(t, err)
+	tags, err = store.FindEntityTagsBySource("dns", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	count, err := store.DeleteEntityTagsByNameGlobal("test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = store.FindEntityTagById(ct3.ID)
+	assert.Error(t, err)
+}
+
+func TestCreateEntityTags(t *testing.T) {
+	store := New()
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "bulk.tags.utica.edu"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "bulk_source", Confidence: 80},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "two"},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "one"},
+		nil,
+	}
+
+	tags, err := store.CreateEntityTags(entity, props)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 3)
+
+	// creating the same tags again updates the existing tags
+	time.Sleep(10 * time.Millisecond)
+	again, err := store.CreateEntityTags(entity, props[1:2])
+	assert.NoError(t, err)
+	if assert.Len(t, again, 1) {
+		assert.Equal(t, tags[1].ID, again[0].ID)
+		assert.True(t, again[0].LastSeen.After(tags[1].LastSeen))
+	}
+
+	existing, err := store.GetEntityTags(entity, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 3)
+
+	_, err = store.CreateEntityTags(&types.Entity{ID: "missing"}, props)
+	assert.Error(t, err)
+}
+
+func TestFindEntityTagsByValuePrefix(t *testing.T) {
+	store := New()
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "prefix.tags.owasp.org"})
+	assert.NoError(t, err)
+
+	for _, value := range []string{"dns/crtsh", "web/wayback", "dns_%/literal"} {
+		_, err = store.CreateEntityProperty(entity, &property.SimpleProperty{
+			PropertyName:  "prefix_path",
+			PropertyValue: value,
+		})
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.FindEntityTagsByValuePrefix("prefix_path", "dns/", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, "dns/crtsh", tags[0].Property.Value())
+		assert.Equal(t, entity.ID, tags[0].Entity.ID)
+	}
+
+	// the wildcard characters in the prefix are matched literally
+	tags, err = store.FindEntityTagsByValuePrefix("prefix_path", "dns_%", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, "dns_%/literal", tags[0].Property.Value())
+	}
+
+	_, err = store.FindEntityTagsByValuePrefix("prefix_missing", "dns/", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByTags(t *testing.T) {
+	store := New()
+
+	both, err := store.CreateAsset(&domain.FQDN{Name: "both.predicates.owasp.org"})
+	assert.NoError(t, err)
+	one, err := store.CreateAsset(&domain.FQDN{Name: "one.predicates.owasp.org"})
+	assert.NoError(t, err)
+	neither, err := store.CreateAsset(&domain.FQDN{Name: "neither.predicates.owasp.org"})
+	assert.NoError(t, err)
+
+	for _, tag := range []struct {
+		entity *types.Entity
+		prop   oam.Property
+	}{
+		{both, &property.SourceProperty{Source: "predicate_dns", Confidence: 90}},
+		{both, &property.SimpleProperty{PropertyName: "predicate_env", PropertyValue: "prod"}},
+		{one, &property.SourceProperty{Source: "predicate_dns", Confidence: 80}},
+		{one, &property.SimpleProperty{PropertyName: "predicate_env", PropertyValue: "dev"}},
+		{neither, &property.SourceProperty{Source: "predicate_dns", Confidence: 20}},
+	} {
+		_, err := store.CreateEntityProperty(tag.entity, tag.prop)
+		assert.NoError(t, err)
+	}
+
+	predicates := []types.TagPredicate{
+		{Name: "predicate_dns", Op: types.TagGreaterEqual, Value: "80"},
+		{Name: "predicate_env", Op: types.TagEqual, Value: "prod"},
+	}
+	ids := func(entities []*types.Entity) []string {
+		var results []string
+		for _, e := range entities {
+			results = append(results, e.ID)
+		}
+		return results
+	}
+
+	entities, err := store.FindEntitiesByTags(predicates, types.And, time.Time{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{both.ID}, ids(entities))
+
+	entities, err = store.FindEntitiesByTags(predicates, types.Or, time.Time{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{both.ID, one.ID}, ids(entities))
+
+	// the predicate without an operator matches any value of the tag
+	entities, err = store.FindEntitiesByTags([]types.TagPredicate{{Name: "predicate_env"}}, types.And, time.Time{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{both.ID, one.ID}, ids(entities))
+
+	_, err = store.FindEntitiesByTags(predicates, types.And, time.Now().Add(time.Hour))
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByTags(nil, types.And, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestEdgeTag(t *testing.T) {
+	store := New()
+
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+
+	foo := &property.SimpleProperty{PropertyName: "test", PropertyValue: "foo"}
+	bar := &property.SimpleProperty{PropertyName: "test", PropertyValue: "bar"}
+
+	first, err := store.CreateEdgeProperty(edge, foo)
+	assert.NoError(t, err)
+
+	// the repeated property and the existing tag produce a single tag
+	tags, err := store.CreateEdgeTags(edge, []oam.Property{foo, bar, foo})
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	assert.Equal(t, first.ID, tags[0].ID)
+
+	all, err := store.GetEdgeTags(edge, time.Time{}, "test")
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	found, err := store.FindEdgeTagsByContent(bar, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, edge.ID, found[0].Edge.ID)
+
+	_, err = store.CreateEdgeTagsBatch(map[string][]oam.Property{"missing": {foo}})
+	assert.Error(t, err)
+
+	assert.NoError(t, store.DeleteEdgeTag(first.ID))
+	_, err = store.FindEdgeTagById(first.ID)
+	assert.Error(t, err)
+
+	count, err := store.DeleteEdgeTagsByNameGlobal("test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestEntityTagTimeline(t *testing.T) {
+	store := New()
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "timeline.owasp.org"})
+	assert.NoError(t, err)
+
+	now := time.Now()
+	for i, offset := range []time.Duration{-2 * time.Hour, -3 * time.Hour, -time.Hour} {
+		_, err := store.CreateEntityTag(entity, &types.EntityTag{
+			CreatedAt: now.Add(offset),
+			LastSeen:  now.Add(offset),
+			Property: &property.SimpleProperty{
+				PropertyName:  "timeline",
+				PropertyValue: string(rune('a' + i)),
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.EntityTagTimeline(entity.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 3) {
+		assert.Equal(t, "b", tags[0].Property.Value())
+		assert.Equal(t, "a", tags[1].Property.Value())
+		assert.Equal(t, "c", tags[2].Property.Value())
+	}
+
+	_, err = store.EntityTagTimeline("missing")
+	assert.Error(t, err)
+}
+
+func TestCreateEdgeWithProperties(t *testing.T) {
+	store := New()
+
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.owasp.org"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "props_source", Confidence: 90},
+		&property.SimpleProperty{PropertyName: "props_tag", PropertyValue: "one"},
+	}
+
+	edge, tags, err := store.CreateEdgeWithProperties(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	}, props)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+
+	existing, err := store.GetEdgeTags(edge, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 2)
+
+	// an invalid edge produces neither the edge nor the tags
+	_, _, err = store.CreateEdgeWithProperties(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "not_in_taxonomy"},
+		FromEntity: www,
+		ToEntity:   apex,
+	}, props)
+	assert.Error(t, err)
+
+	outs, err := store.OutgoingEdges(www, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, outs, 1)
+}
+
+func TestGetTagsByType(t *testing.T) {
+	store := New()
+
+	from, err := store.CreateAsset(&domain.FQDN{Name: "bytype.owasp.org"})
+	assert.NoError(t, err)
+	to, err := store.CreateAsset(&domain.FQDN{Name: "bytype1.owasp.org"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "bytype_source", Confidence: 90},
+		&property.SimpleProperty{PropertyName: "bytype_one", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "bytype_two", PropertyValue: "two"},
+	}
+	for _, prop := range props {
+		_, err := store.CreateEntityProperty(from, prop)
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.GetEntityTagsByType(from, time.Time{}, oam.SimpleProperty)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	for _, tag := range tags {
+		assert.Equal(t, oam.SimpleProperty, tag.Property.PropertyType())
+		assert.Equal(t, from.ID, tag.Entity.ID)
+	}
+
+	tags, err = store.GetEntityTagsByType(from, time.Time{}, oam.SourceProperty)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "bytype_source", tags[0].Property.Name())
+
+	// no property types returns all the tags
+	tags, err = store.GetEntityTagsByType(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, tags, 3)
+
+	_, err = store.GetEntityTagsByType(from, time.Time{}, oam.VulnProperty)
+	assert.Error(t, err)
+
+	edge, _, err := store.CreateEdgeWithProperties(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: from,
+		ToEntity:   to,
+	}, props)
+	assert.NoError(t, err)
+
+	etags, err := store.GetEdgeTagsByType(edge, time.Time{}, oam.SourceProperty)
+	assert.NoError(t, err)
+	assert.Len(t, etags, 1)
+	assert.Equal(t, oam.SourceProperty, etags[0].Property.PropertyType())
+	assert.Equal(t, edge.ID, etags[0].Edge.ID)
+
+	etags, err = store.GetEdgeTagsByType(edge, time.Time{}, oam.SimpleProperty, oam.SourceProperty)
+	assert.NoError(t, err)
+	assert.Len(t, etags, 3)
+}
+
+func TestCountMethods(t *testing.T) {
+	store := New()
+
+	before, err := store.CountEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+
+	from, err := store.CreateAsset(&domain.FQDN{Name: "count.owasp.org"})
+	assert.NoError(t, err)
+	for _, name := range []string{"count1.owasp.org", "count2.owasp.org"} {
+		to, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEdge(&types.Edge{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+			FromEntity: from,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+	}
+
+	after, err := store.CountEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, before+3, after)
+
+	total, err := store.CountEntitiesByType(oam.IPAddress, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+
+	total, err = store.CountOutgoingEdges(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.CountOutgoingEdges(from, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.CountOutgoingEdges(from, time.Time{}, "node")
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+
+	for _, prop := range []oam.Property{
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "two"},
+		&property.SourceProperty{Source: "count_source", Confidence: 90},
+		&property.VulnProperty{ID: "CVE-2024-0001", Description: "count vuln"},
+	} {
+		_, err := store.CreateEntityProperty(from, prop)
+		assert.NoError(t, err)
+	}
+
+	total, err = store.CountEntityTags(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = store.CountEntityTags(from, time.Time{}, "count_tag", "count_source", "CVE-2024-0001")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = store.CountEntityTags(from, time.Time{}, "count_source")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	total, err = store.CountEntityTags(from, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+
+	edges, err := store.OutgoingEdges(from, time.Time{})
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, edges) {
+		return
+	}
+
+	tags, err := store.CreateEdgeTags(edges[0], []oam.Property{
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "two"},
+		&property.SourceProperty{Source: "count_source", Confidence: 90},
+	})
+	assert.NoError(t, err)
+
+	// the count matches the number of tags created
+	total, err = store.CountEdgeTags(edges[0], time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(tags)), total)
+
+	total, err = store.CountEdgeTags(edges[0], time.Time{}, "count_tag")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.CountEdgeTags(edges[0], time.Time{}, "missing")
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+}
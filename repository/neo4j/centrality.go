@@ -0,0 +1,106 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// ErrGDSUnavailable is returned by Centrality when the Graph Data Science plugin is not installed in the database.
+var ErrGDSUnavailable = errors.New("the neo4j graph data science plugin is not available")
+
+// centralityProcedures maps the algorithms supported by Centrality to their GDS procedures.
+var centralityProcedures = map[string]string{
+	"degree":      "gds.degree",
+	"betweenness": "gds.betweenness",
+	"pagerank":    "gds.pageRank",
+}
+
+// Centrality scores the entities of the graph with a centrality algorithm of the Graph Data Science plugin,
+// which identifies the pivotal assets. The algo is one of degree, betweenness or pagerank. The entities and
+// edges last seen after the since parameter are projected into a temporary graph, which is dropped afterwards.
+// Edges to entities last seen before the since parameter are not projected.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the scores keyed by the entity IDs, or an error wrapping ErrGDSUnavailable if the plugin is absent.
+func (neo *neoRepository) Centrality(algo string, since time.Time) (map[string]float64, error) {
+	proc, found := centralityProcedures[strings.ToLower(algo)]
+	if !found {
+		return nil, fmt.Errorf("unsupported centrality algorithm: %s", algo)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if _, err := executeQuery(ctx, neo, "RETURN gds.version() AS version", nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGDSUnavailable, err)
+	}
+
+	graph := fmt.Sprintf("asset-db-centrality-%d", time.Now().UnixNano())
+	params := map[string]interface{}{"graph": graph}
+
+	var sfilter, rfilter string
+	if !since.IsZero() {
+		ts := timeToNeo4jTime(since)
+
+		sfilter = fmt.Sprintf(" WHERE s.updated_at >= localDateTime('%s')", ts)
+		rfilter = fmt.Sprintf(" WHERE r.updated_at >= localDateTime('%s') AND t.updated_at >= localDateTime('%s')", ts, ts)
+	}
+
+	query := fmt.Sprintf("MATCH (s:Entity)%s OPTIONAL MATCH (s)-[r]->(t:Entity)%s "+
+		"WITH gds.graph.project($graph, s, t) AS g RETURN g.graphName AS graph", sfilter, rfilter)
+	if _, err := executeQuery(ctx, neo, query, params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	); err != nil {
+		return nil, err
+	}
+	defer func() {
+		dctx, dcancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer dcancel()
+
+		_, _ = executeQuery(dctx, neo, "CALL gds.graph.drop($graph, false) YIELD graphName RETURN graphName", params,
+			neo4jdb.EagerResultTransformer,
+			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+		)
+	}()
+
+	result, err := executeQuery(ctx, neo,
+		fmt.Sprintf("CALL %s.stream($graph) YIELD nodeId, score RETURN gds.util.asNode(nodeId).entity_id AS eid, score", proc),
+		params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64, len(result.Records))
+	for _, record := range result.Records {
+		eid, isnil, err := neo4jdb.GetRecordValue[string](record, "eid")
+		if err != nil || isnil {
+			continue
+		}
+
+		score, isnil, err := neo4jdb.GetRecordValue[float64](record, "score")
+		if err != nil || isnil {
+			continue
+		}
+		scores[eid] = score
+	}
+
+	if len(scores) == 0 {
+		return scores, neo.opts.NoResults("zero entities found")
+	}
+	return scores, nil
+}
@@ -0,0 +1,69 @@
+//go:build integration
+
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCentrality(t *testing.T) {
+	since := time.Now().Add(-time.Second)
+
+	hub, err := store.CreateEntity(&types.Entity{Asset: &domain.FQDN{Name: "hub.centrality"}})
+	assert.NoError(t, err)
+
+	var spokes []*types.Entity
+	for _, name := range []string{"spoke1.centrality", "spoke2.centrality", "spoke3.centrality"} {
+		spoke, err := store.CreateEntity(&types.Entity{Asset: &domain.FQDN{Name: name}})
+		assert.NoError(t, err)
+		spokes = append(spokes, spoke)
+
+		_, err = store.CreateEdge(&types.Edge{
+			Relation:   &relation.SimpleRelation{Name: "node"},
+			FromEntity: hub,
+			ToEntity:   spoke,
+		})
+		assert.NoError(t, err)
+	}
+
+	// the edge is recent, but the entity it points to was last seen before since
+	stale, err := store.CreateEntity(&types.Entity{
+		Asset:    &domain.FQDN{Name: "stale.centrality"},
+		LastSeen: since.Add(-time.Hour),
+	})
+	assert.NoError(t, err)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: hub,
+		ToEntity:   stale,
+	})
+	assert.NoError(t, err)
+
+	scores, err := store.Centrality("degree", since)
+	if errors.Is(err, ErrGDSUnavailable) {
+		t.Skip("the graph data science plugin is not installed")
+	}
+	assert.NoError(t, err)
+
+	if assert.Contains(t, scores, hub.ID) {
+		for _, spoke := range spokes {
+			assert.Greater(t, scores[hub.ID], scores[spoke.ID])
+		}
+	}
+	assert.NotContains(t, scores, stale.ID)
+
+	_, err = store.Centrality("unknown", since)
+	assert.Error(t, err)
+}
@@ -9,22 +9,31 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
 )
 
 const Neo4j string = "neo4j"
 
+// defaultBatchSize is the number of nodes processed by each query during bulk operations.
+const defaultBatchSize int = 1000
+
 // neoRepository is a repository implementation using Neo4j as the underlying DBMS.
 type neoRepository struct {
-	db     neo4jdb.DriverWithContext
-	dbname string
+	db          neo4jdb.DriverWithContext
+	dbname      string
+	opts        *options.Options
+	parseErrors atomic.Int64
+	status      connStatus
 }
 
 // New creates a new instance of the asset database repository.
-func New(dbtype, dsn string) (*neoRepository, error) {
+func New(dbtype, dsn string, opts ...options.Option) (*neoRepository, error) {
 	u, err := url.Parse(dsn)
 	if err != nil {
 		return nil, err
@@ -55,6 +64,7 @@ func New(dbtype, dsn string) (*neoRepository, error) {
 	return &neoRepository{
 		db:     driver,
 		dbname: dbname,
+		opts:   options.New(opts...),
 	}, driver.VerifyConnectivity(ctx)
 }
 
@@ -67,3 +77,54 @@ func (neo *neoRepository) Close() error {
 func (neo *neoRepository) GetDBType() string {
 	return Neo4j
 }
+
+// batchSize returns the number of nodes processed by each query during bulk operations.
+func (neo *neoRepository) batchSize() int {
+	if neo.opts != nil && neo.opts.BatchSize > 0 {
+		return neo.opts.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// ParseErrors returns the number of times that a node or relationship read from the database could not be
+// converted, such as when the content was written by a newer version of the Open Asset Model.
+func (neo *neoRepository) ParseErrors() int64 {
+	return neo.parseErrors.Load()
+}
+
+// Stats returns no metrics, since the driver does not expose the statistics of its connection pool.
+func (neo *neoRepository) Stats() (types.RepositoryStats, error) {
+	return types.RepositoryStats{}, nil
+}
+
+// parsed counts the error returned by a conversion from a node or relationship, if there is one.
+func (neo *neoRepository) parsed(err error) error {
+	if err != nil {
+		neo.parseErrors.Add(1)
+	}
+	return err
+}
+
+// toEntity converts the node to an entity, counting the failures.
+func (neo *neoRepository) toEntity(node neo4jdb.Node) (*types.Entity, error) {
+	e, err := nodeToEntity(node)
+	return e, neo.parsed(err)
+}
+
+// toEntityTag converts the node to an entity tag, counting the failures.
+func (neo *neoRepository) toEntityTag(node neo4jdb.Node) (*types.EntityTag, error) {
+	t, err := nodeToEntityTag(node)
+	return t, neo.parsed(err)
+}
+
+// toEdgeTag converts the node to an edge tag, counting the failures.
+func (neo *neoRepository) toEdgeTag(node neo4jdb.Node) (*types.EdgeTag, error) {
+	t, err := nodeToEdgeTag(node)
+	return t, neo.parsed(err)
+}
+
+// toEdge converts the relationship to an edge, counting the failures.
+func (neo *neoRepository) toEdge(rel neo4jdb.Relationship) (*types.Edge, error) {
+	e, err := relationshipToEdge(rel)
+	return e, neo.parsed(err)
+}
@@ -8,11 +8,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
 )
@@ -21,14 +22,53 @@ import (
 // The edge is established by creating a new Edge in the database, linking the two entities.
 // Returns the created edge as a types.Edge or an error if the link creation fails.
 func (neo *neoRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	e, _, err := neo.UpsertEdge(edge)
+	return e, err
+}
+
+// UpsertEdge creates an edge between two entities in the database, as done by CreateEdge.
+// Returns the edge, and true if the edge was inserted or false if an existing edge was updated
+// by the deduplication of relationships.
+func (neo *neoRepository) UpsertEdge(edge *types.Edge) (*types.Edge, bool, error) {
+	return neo.upsertEdge(edge, nil)
+}
+
+// CreateEdges creates the provided edges in the database, as done by CreateEdge.
+// The outgoing edges of each source entity are read once for the batch, instead of once for each edge,
+// when checking for duplicate relationships.
+// Returns the created or existing edges in the order provided, or an error if the creation of an edge fails.
+func (neo *neoRepository) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	outs := make(outgoingEdgeCache)
+
+	var results []*types.Edge
+	for _, edge := range edges {
+		e, _, err := neo.upsertEdge(edge, outs)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	return results, nil
+}
+
+// outgoingEdgeCache holds the outgoing edges of source entities, keyed by the entity ID and relation label,
+// so the links made within a batch do not repeat the reads of the same outgoing edges.
+type outgoingEdgeCache map[string][]*types.Edge
+
+func outgoingEdgeKey(edge *types.Edge) string {
+	return edge.FromEntity.ID + ":" + edge.Relation.Label()
+}
+
+// upsertEdge creates or updates the edge, using the cache of outgoing edges when it is not nil.
+func (neo *neoRepository) upsertEdge(edge *types.Edge, outs outgoingEdgeCache) (*types.Edge, bool, error) {
 	if edge == nil || edge.Relation == nil || edge.FromEntity == nil ||
 		edge.FromEntity.Asset == nil || edge.ToEntity == nil || edge.ToEntity.Asset == nil {
-		return nil, errors.New("failed input validation checks")
+		return nil, false, errors.New("failed input validation checks")
 	}
 
 	if !oam.ValidRelationship(edge.FromEntity.Asset.AssetType(),
 		edge.Relation.Label(), edge.Relation.RelationType(), edge.ToEntity.Asset.AssetType()) {
-		return &types.Edge{}, fmt.Errorf("%s -%s-> %s is not valid in the taxonomy",
+		return &types.Edge{}, false, fmt.Errorf("%s -%s-> %s is not valid in the taxonomy",
 			edge.FromEntity.Asset.AssetType(), edge.Relation.Label(), edge.ToEntity.Asset.AssetType())
 	}
 
@@ -36,8 +76,20 @@ func (neo *neoRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 		edge.LastSeen = time.Now()
 	}
 	// ensure that duplicate relationships are not entered into the database
-	if e, found := neo.isDuplicateEdge(edge, edge.LastSeen); found {
-		return e, nil
+	if e, found := neo.isDuplicateEdge(edge, edge.LastSeen, outs); found {
+		return e, false, nil
+	}
+
+	resolved, err := neo.resolveEndpoints(edge)
+	if err != nil {
+		return nil, false, err
+	}
+	if resolved != edge {
+		// the entities created for the missing endpoints may already be linked by the relationship
+		if e, found := neo.isDuplicateEdge(resolved, edge.LastSeen, outs); found {
+			return e, false, nil
+		}
+		edge = resolved
 	}
 
 	if edge.CreatedAt.IsZero() {
@@ -46,7 +98,10 @@ func (neo *neoRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 
 	props, err := edgePropsMap(edge)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if neo.opts.RunID != "" {
+		props["run_id"] = neo.opts.RunID
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -54,59 +109,101 @@ func (neo *neoRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 
 	from := fmt.Sprintf("MATCH (from:Entity {entity_id: '%s'})", edge.FromEntity.ID)
 	to := fmt.Sprintf("MATCH (to:Entity {entity_id: '%s'})", edge.ToEntity.ID)
-	query := fmt.Sprintf("%s %s CREATE (from)-[r:%s $props]->(to) RETURN r", from, to, strings.ToUpper(edge.Relation.Label()))
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
+	query := fmt.Sprintf("%s %s CREATE (from)-[r:%s $props]->(to) RETURN r", from, to, relationshipType(edge.Relation.Label()))
+	result, err := executeQuery(ctx, neo, query,
 		map[string]interface{}{"props": props},
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no records returned from the query")
+		return nil, false, errors.New("no records returned from the query")
 	}
 
 	rel, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](result.Records[0], "r")
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if isnil {
-		return nil, errors.New("the record value for the relationship is nil")
+		return nil, false, errors.New("the record value for the relationship is nil")
 	}
 
-	r, err := relationshipToEdge(rel)
+	r, err := neo.toEdge(rel)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	r.FromEntity = edge.FromEntity
 	r.ToEntity = edge.ToEntity
 
-	return r, nil
+	if outs != nil {
+		key := outgoingEdgeKey(edge)
+		outs[key] = append(outs[key], r)
+	}
+	return r, true, nil
+}
+
+// resolveEndpoints returns the edge when the entities at both ends exist. Otherwise, when the MissingEndpoints
+// option is set to create them, a copy of the edge is returned with the missing entities created from their assets.
+func (neo *neoRepository) resolveEndpoints(edge *types.Edge) (*types.Edge, error) {
+	from, err := neo.resolveEndpoint("from", edge.FromEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := neo.resolveEndpoint("to", edge.ToEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == edge.FromEntity && to == edge.ToEntity {
+		return edge, nil
+	}
+
+	resolved := *edge
+	resolved.FromEntity = from
+	resolved.ToEntity = to
+	return &resolved, nil
+}
+
+func (neo *neoRepository) resolveEndpoint(side string, entity *types.Entity) (*types.Entity, error) {
+	if _, err := neo.FindEntityById(entity.ID); err == nil {
+		return entity, nil
+	}
+
+	if neo.opts.MissingEndpoints != options.MissingEndpointCreate {
+		return nil, types.EndpointNotFound(side, entity.ID)
+	}
+	return neo.CreateEntity(&types.Entity{Asset: entity.Asset})
 }
 
 // isDuplicateEdge checks if the relationship between source and dest already exists.
-func (neo *neoRepository) isDuplicateEdge(edge *types.Edge, updated time.Time) (*types.Edge, bool) {
-	var dup bool
-	var e *types.Edge
-
-	if outs, err := neo.OutgoingEdges(edge.FromEntity, time.Time{}, edge.Relation.Label()); err == nil {
-		for _, out := range outs {
-			if edge.ToEntity.ID == out.ToEntity.ID && reflect.DeepEqual(edge.Relation, out.Relation) {
-				_ = neo.edgeSeen(out, updated)
-
-				e, err = neo.FindEdgeById(out.ID)
-				if err != nil {
-					return nil, false
-				}
-
-				dup = true
-				break
-			}
+// When the cache of outgoing edges is not nil, the outgoing edges of the source are only read from the
+// database the first time that the source and relation label are seen.
+func (neo *neoRepository) isDuplicateEdge(edge *types.Edge, updated time.Time, cache outgoingEdgeCache) (*types.Edge, bool) {
+	key := outgoingEdgeKey(edge)
+	outs, cached := cache[key]
+	if !cached {
+		// an error indicates that the source has no outgoing edges with the label
+		outs, _ = neo.OutgoingEdges(edge.FromEntity, time.Time{}, edge.Relation.Label())
+		if cache != nil {
+			cache[key] = outs
 		}
 	}
 
-	return e, dup
+	for _, out := range outs {
+		if edge.ToEntity.ID == out.ToEntity.ID && neo.opts.DuplicateRelations(edge.Relation, out.Relation) {
+			_ = neo.edgeSeen(out, updated)
+
+			e, err := neo.FindEdgeById(out.ID)
+			if err != nil {
+				return nil, false
+			}
+			return e, true
+		}
+	}
+	return nil, false
 }
 
 // edgeSeen updates the updated_at timestamp for the specified edge.
@@ -115,9 +212,13 @@ func (neo *neoRepository) edgeSeen(rel *types.Edge, updated time.Time) error {
 	defer cancel()
 
 	query := fmt.Sprintf("MATCH ()-[r]->() WHERE elementId(r) = $eid SET r.updated_at = localDateTime('%s')", timeToNeo4jTime(updated))
-	_, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
+	if neo.opts.RunID != "" {
+		query += ", r.run_id = $rid"
+	}
+	_, err := executeQuery(ctx, neo, query,
 		map[string]interface{}{
 			"eid": rel.ID,
+			"rid": neo.opts.RunID,
 		},
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
@@ -129,7 +230,7 @@ func (neo *neoRepository) FindEdgeById(id string) (*types.Edge, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	result, err := executeQuery(ctx, neo,
 		"MATCH (from:Entity)-[r]->(to:Entity) WHERE elementId(r) = $eid RETURN r, from.entity_id AS fid, to.entity_id AS tid",
 		map[string]interface{}{
 			"eid": id,
@@ -142,7 +243,7 @@ func (neo *neoRepository) FindEdgeById(id string) (*types.Edge, error) {
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no edge was found")
+		return nil, fmt.Errorf("%w: %s", types.ErrEdgeNotFound, id)
 	}
 
 	r, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](result.Records[0], "r")
@@ -169,7 +270,7 @@ func (neo *neoRepository) FindEdgeById(id string) (*types.Edge, error) {
 		return nil, errors.New("the record value for the to entity ID is nil")
 	}
 
-	edge, err := relationshipToEdge(r)
+	edge, err := neo.toEdge(r)
 	if err != nil {
 		return nil, err
 	}
@@ -182,15 +283,26 @@ func (neo *neoRepository) FindEdgeById(id string) (*types.Edge, error) {
 // If since.IsZero(), the parameter will be ignored.
 // If no labels are specified, all incoming eges are returned.
 func (neo *neoRepository) IncomingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	return neo.incomingEdges(entity, since, time.Time{}, labels...)
+}
+
+// IncomingEdgesBetween finds all edges pointing to the entity of the specified labels and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no labels are specified, all incoming edges within the range are returned.
+func (neo *neoRepository) IncomingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return neo.incomingEdges(entity, start, end, labels...)
+}
+
+func (neo *neoRepository) incomingEdges(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	query := "MATCH (:Entity {entity_id: $eid})<-[r]-(from:Entity) RETURN r, from.entity_id AS fid"
-	if !since.IsZero() {
-		query = fmt.Sprintf("MATCH (:Entity {entity_id: $eid})<-[r]-(from:Entity) WHERE r.updated_at >= localDateTime('%s') RETURN r, from.entity_id AS fid", timeToNeo4jTime(since))
-	}
+	query := fmt.Sprintf("MATCH (:Entity {entity_id: $eid})<-[r]-(from:Entity)%s RETURN r, from.entity_id AS fid", lastSeenBetween("r", start, end))
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
+	result, err := executeQuery(ctx, neo, query,
 		map[string]interface{}{
 			"eid": entity.ID,
 		},
@@ -211,19 +323,8 @@ func (neo *neoRepository) IncomingEdges(entity *types.Entity, since time.Time, l
 			continue
 		}
 
-		if len(labels) > 0 {
-			var found bool
-
-			for _, label := range labels {
-				if strings.EqualFold(label, r.Type) {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				continue
-			}
+		if !matchesRelationshipType(r.Type, labels) {
+			continue
 		}
 
 		fid, isnil, err := neo4jdb.GetRecordValue[string](record, "fid")
@@ -234,7 +335,7 @@ func (neo *neoRepository) IncomingEdges(entity *types.Entity, since time.Time, l
 			continue
 		}
 
-		edge, err := relationshipToEdge(r)
+		edge, err := neo.toEdge(r)
 		if err != nil {
 			continue
 		}
@@ -244,7 +345,7 @@ func (neo *neoRepository) IncomingEdges(entity *types.Entity, since time.Time, l
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero edges found")
+		return []*types.Edge{}, neo.opts.NoResults("zero edges found")
 	}
 	return results, nil
 }
@@ -253,15 +354,119 @@ func (neo *neoRepository) IncomingEdges(entity *types.Entity, since time.Time, l
 // If since.IsZero(), the parameter will be ignored.
 // If no labels are specified, all outgoing edges are returned.
 func (neo *neoRepository) OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	return neo.outgoingEdges(entity, since, time.Time{}, labels...)
+}
+
+// OutgoingEdgesBetween finds all edges from the entity of the specified labels and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no labels are specified, all outgoing edges within the range are returned.
+func (neo *neoRepository) OutgoingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return neo.outgoingEdges(entity, start, end, labels...)
+}
+
+func (neo *neoRepository) outgoingEdges(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	query := "MATCH (:Entity {entity_id: $eid})-[r]->(to:Entity) RETURN r, to.entity_id AS tid"
+	query := fmt.Sprintf("MATCH (:Entity {entity_id: $eid})-[r]->(to:Entity)%s RETURN r, to.entity_id AS tid", lastSeenBetween("r", start, end))
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"eid": entity.ID,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Edge
+	for _, record := range result.Records {
+		r, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](record, "r")
+		if err != nil {
+			continue
+		}
+		if isnil {
+			continue
+		}
+
+		if !matchesRelationshipType(r.Type, labels) {
+			continue
+		}
+
+		tid, isnil, err := neo4jdb.GetRecordValue[string](record, "tid")
+		if err != nil {
+			continue
+		}
+		if isnil {
+			continue
+		}
+
+		edge, err := neo.toEdge(r)
+		if err != nil {
+			continue
+		}
+		edge.FromEntity = entity
+		edge.ToEntity = &types.Entity{ID: tid}
+		results = append(results, edge)
+	}
+
+	if len(results) == 0 {
+		return []*types.Edge{}, neo.opts.NoResults("zero edges found")
+	}
+	return results, nil
+}
+
+// CountOutgoingEdges counts the edges from the entity of the specified labels and last seen after the since parameter,
+// without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are counted.
+func (neo *neoRepository) CountOutgoingEdges(entity *types.Entity, since time.Time, labels ...string) (int64, error) {
+	var conds []string
+	params := map[string]interface{}{"eid": entity.ID}
+
+	if len(labels) > 0 {
+		rtypes := make([]string, 0, len(labels))
+		for _, label := range labels {
+			rtypes = append(rtypes, relationshipType(label))
+		}
+
+		conds = append(conds, "type(r) IN $rtypes")
+		params["rtypes"] = rtypes
+	}
+	if !since.IsZero() {
+		conds = append(conds, fmt.Sprintf("r.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+
+	query := "MATCH (:Entity {entity_id: $eid})-[r]->(:Entity)"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " RETURN count(r) AS total"
+
+	return neo.count(query, params)
+}
+
+// AdjacentEdges finds all edges to or from the entity of the specified labels and last seen after the since parameter.
+// The direction of each edge is indicated by whether the entity is the FromEntity or the ToEntity.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all adjacent edges are returned.
+func (neo *neoRepository) AdjacentEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	match := "MATCH (:Entity {entity_id: $eid})-[r]-(:Entity)"
 	if !since.IsZero() {
-		query = fmt.Sprintf("MATCH (:Entity {entity_id: $eid})-[r]->(to:Entity) WHERE r.updated_at >= localDateTime('%s') RETURN r, to.entity_id AS tid", timeToNeo4jTime(since))
+		match += fmt.Sprintf(" WHERE r.updated_at >= localDateTime('%s')", timeToNeo4jTime(since))
 	}
+	// the undirected pattern matches a self-referencing edge twice
+	query := match + " RETURN DISTINCT r, startNode(r).entity_id AS fid, endNode(r).entity_id AS tid"
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
+	result, err := executeQuery(ctx, neo, query,
 		map[string]interface{}{
 			"eid": entity.ID,
 		},
@@ -275,47 +480,393 @@ func (neo *neoRepository) OutgoingEdges(entity *types.Entity, since time.Time, l
 	var results []*types.Edge
 	for _, record := range result.Records {
 		r, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](record, "r")
+		if err != nil || isnil {
+			continue
+		}
+
+		if !matchesRelationshipType(r.Type, labels) {
+			continue
+		}
+
+		fid, isnil, err := neo4jdb.GetRecordValue[string](record, "fid")
+		if err != nil || isnil {
+			continue
+		}
+
+		tid, isnil, err := neo4jdb.GetRecordValue[string](record, "tid")
+		if err != nil || isnil {
+			continue
+		}
+
+		edge, err := neo.toEdge(r)
 		if err != nil {
 			continue
 		}
-		if isnil {
+		edge.FromEntity = &types.Entity{ID: fid}
+		edge.ToEntity = &types.Entity{ID: tid}
+		if fid == entity.ID {
+			edge.FromEntity = entity
+		}
+		if tid == entity.ID {
+			edge.ToEntity = entity
+		}
+		results = append(results, edge)
+	}
+
+	if len(results) == 0 {
+		return []*types.Edge{}, neo.opts.NoResults("zero edges found")
+	}
+	return results, nil
+}
+
+// TraverseNeighborhood finds the entities within depth hops of the entity, following the edges in the direction
+// with the specified labels, and the edges that were followed to reach them.
+// The traversal uses a variable-length path pattern, which enumerates every path up to the depth, so a large
+// depth over densely connected entities can be expensive.
+// If no labels are specified, edges of all labels are followed.
+// Returns the entity, followed by the reached entities ordered by their distance, and the followed edges,
+// or an error if the traversal fails.
+func (neo *neoRepository) TraverseNeighborhood(entity *types.Entity, depth int, direction types.Direction, labels ...string) ([]*types.Entity, []*types.Edge, error) {
+	if err := types.ValidateTraversal(depth, direction); err != nil {
+		return nil, nil, err
+	}
+
+	seed, err := neo.FindEntityById(entity.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rels := fmt.Sprintf("[rels*1..%d]", depth)
+	switch direction {
+	case types.Outgoing:
+		rels = "-" + rels + "->"
+	case types.Incoming:
+		rels = "<-" + rels + "-"
+	default:
+		rels = "-" + rels + "-"
+	}
+
+	match := "MATCH p = (:Entity {entity_id: $eid})" + rels + "(b:Entity)"
+	params := map[string]interface{}{"eid": entity.ID}
+	if len(labels) > 0 {
+		var rtypes []string
+		for _, label := range labels {
+			rtypes = append(rtypes, relationshipType(label))
+		}
+
+		match += " WHERE all(r IN rels WHERE type(r) IN $rtypes)"
+		params["rtypes"] = rtypes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo,
+		match+" WITH b, min(length(p)) AS hops WHERE b.entity_id <> $eid RETURN b ORDER BY hops, b.entity_id",
+		params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := []*types.Entity{seed}
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "b")
+		if err != nil || isnil {
+			continue
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			continue
+		}
+		results = append(results, e)
+	}
+
+	result, err = executeQuery(ctx, neo,
+		match+" UNWIND rels AS r RETURN DISTINCT r, startNode(r).entity_id AS fid, endNode(r).entity_id AS tid",
+		params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	edges := []*types.Edge{}
+	for _, record := range result.Records {
+		r, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](record, "r")
+		if err != nil || isnil {
 			continue
 		}
 
-		if len(labels) > 0 {
-			var found bool
+		fid, isnil, err := neo4jdb.GetRecordValue[string](record, "fid")
+		if err != nil || isnil {
+			continue
+		}
+
+		tid, isnil, err := neo4jdb.GetRecordValue[string](record, "tid")
+		if err != nil || isnil {
+			continue
+		}
+
+		edge, err := neo.toEdge(r)
+		if err != nil {
+			continue
+		}
+		edge.FromEntity = &types.Entity{ID: fid}
+		edge.ToEntity = &types.Entity{ID: tid}
+		edges = append(edges, edge)
+	}
+	return results, edges, nil
+}
+
+// OutgoingEdgesForEntities finds all edges from the provided entities of the specified labels and last seen after
+// the since parameter. The edges are obtained using a single query per batch of entities instead of a query per entity.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are returned.
+// Returns the edges grouped by the ID of the source entity. Entities without matching edges are not included.
+func (neo *neoRepository) OutgoingEdgesForEntities(entities []*types.Entity, since time.Time, labels ...string) (map[string][]*types.Edge, error) {
+	query := "MATCH (from:Entity)-[r]->(to:Entity) WHERE from.entity_id IN $eids RETURN r, from.entity_id AS fid, to.entity_id AS tid"
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (from:Entity)-[r]->(to:Entity) WHERE from.entity_id IN $eids AND r.updated_at >= localDateTime('%s') RETURN r, from.entity_id AS fid, to.entity_id AS tid", timeToNeo4jTime(since))
+	}
+
+	byid := make(map[string]*types.Entity, len(entities))
+	for _, entity := range entities {
+		byid[entity.ID] = entity
+	}
+
+	results := make(map[string][]*types.Edge)
+	for start := 0; start < len(entities); start += neo.batchSize() {
+		end := min(start+neo.batchSize(), len(entities))
+
+		var eids []string
+		for _, entity := range entities[start:end] {
+			eids = append(eids, entity.ID)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		result, err := executeQuery(ctx, neo, query,
+			map[string]interface{}{
+				"eids": eids,
+			},
+			neo4jdb.EagerResultTransformer,
+			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+		)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
 
-			for _, label := range labels {
-				if strings.EqualFold(label, r.Type) {
-					found = true
-					break
-				}
+		for _, record := range result.Records {
+			r, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](record, "r")
+			if err != nil || isnil {
+				continue
+			}
+
+			if !matchesRelationshipType(r.Type, labels) {
+				continue
+			}
+
+			fid, isnil, err := neo4jdb.GetRecordValue[string](record, "fid")
+			if err != nil || isnil {
+				continue
+			}
+
+			tid, isnil, err := neo4jdb.GetRecordValue[string](record, "tid")
+			if err != nil || isnil {
+				continue
 			}
 
-			if !found {
+			edge, err := neo.toEdge(r)
+			if err != nil {
 				continue
 			}
+			edge.FromEntity = byid[fid]
+			edge.ToEntity = &types.Entity{ID: tid}
+			results[fid] = append(results[fid], edge)
 		}
+	}
 
-		tid, isnil, err := neo4jdb.GetRecordValue[string](record, "tid")
-		if err != nil {
+	if len(results) == 0 {
+		return map[string][]*types.Edge{}, neo.opts.NoResults("zero edges found")
+	}
+	return results, nil
+}
+
+// FindHubEntities finds all entities with a degree of at least minDegree, counting only edges last seen after the since parameter.
+// The direction must be "outgoing", "incoming", or "total", which counts the edges in both directions.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindHubEntities(minDegree int, direction string, since time.Time) ([]*types.Entity, error) {
+	var pattern string
+	switch strings.ToLower(direction) {
+	case "outgoing":
+		pattern = "(a:Entity)-[r]->(:Entity)"
+	case "incoming":
+		pattern = "(a:Entity)<-[r]-(:Entity)"
+	case "total":
+		pattern = "(a:Entity)-[r]-(:Entity)"
+	default:
+		return nil, fmt.Errorf("unknown edge direction: %s", direction)
+	}
+
+	query := "MATCH " + pattern + " WITH a, count(r) AS degree WHERE degree >= $min RETURN a"
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH %s WHERE r.updated_at >= localDateTime('%s') WITH a, count(r) AS degree WHERE degree >= $min RETURN a", pattern, timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{"min": int64(minDegree)},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil || isnil {
 			continue
 		}
-		if isnil {
+
+		if e, err := neo.toEntity(node); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// DistinctRelationLabels returns the sorted labels of the relations stored by edges last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the relation labels or an error if the search fails.
+func (neo *neoRepository) DistinctRelationLabels(since time.Time) ([]string, error) {
+	query := "MATCH (:Entity)-[r]->(:Entity) RETURN DISTINCT type(r) AS rtype"
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (:Entity)-[r]->(:Entity) WHERE r.updated_at >= localDateTime('%s') RETURN DISTINCT type(r) AS rtype", timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(result.Records))
+	for _, record := range result.Records {
+		rtype, isnil, err := neo4jdb.GetRecordValue[string](record, "rtype")
+		if err != nil || isnil {
+			continue
+		}
+		labels = append(labels, relationLabel(rtype))
+	}
+
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// FindEntitiesWithEdgeTo finds all entities of the fromType with at least one outgoing edge with the relation label
+// to an entity of the toType, considering only edges last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindEntitiesWithEdgeTo(fromType oam.AssetType, label string, toType oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	where := "type(r) = $rtype"
+	if !since.IsZero() {
+		where += fmt.Sprintf(" AND r.updated_at >= localDateTime('%s')", timeToNeo4jTime(since))
+	}
+	query := fmt.Sprintf("MATCH (a:%s) WHERE EXISTS { MATCH (a)-[r]->(:%s) WHERE %s } RETURN a", string(fromType), string(toType), where)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{"rtype": relationshipType(label)},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil || isnil {
+			continue
+		}
+
+		if e, err := neo.toEntity(node); err == nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEdgesByRun finds all edges last written by the scan run with the provided ID.
+// Returns a slice of matching edges as []*types.Edge or an error if the search fails.
+func (neo *neoRepository) FindEdgesByRun(runID string) ([]*types.Edge, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo,
+		"MATCH (from:Entity)-[r {run_id: $rid}]->(to:Entity) RETURN r, from.entity_id AS fid, to.entity_id AS tid",
+		map[string]interface{}{"rid": runID},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Edge
+	for _, record := range result.Records {
+		r, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Relationship](record, "r")
+		if err != nil || isnil {
 			continue
 		}
 
-		edge, err := relationshipToEdge(r)
+		fid, isnil, err := neo4jdb.GetRecordValue[string](record, "fid")
+		if err != nil || isnil {
+			continue
+		}
+
+		tid, isnil, err := neo4jdb.GetRecordValue[string](record, "tid")
+		if err != nil || isnil {
+			continue
+		}
+
+		edge, err := neo.toEdge(r)
 		if err != nil {
 			continue
 		}
-		edge.FromEntity = entity
+		edge.FromEntity = &types.Entity{ID: fid}
 		edge.ToEntity = &types.Entity{ID: tid}
 		results = append(results, edge)
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero edges found")
+		return []*types.Edge{}, neo.opts.NoResults("zero edges found")
 	}
 	return results, nil
 }
@@ -327,7 +878,7 @@ func (neo *neoRepository) DeleteEdge(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	_, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	_, err := executeQuery(ctx, neo,
 		"MATCH ()-[r]->() WHERE elementId(r) = $eid DELETE r",
 		map[string]interface{}{
 			"eid": id,
@@ -8,9 +8,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
@@ -49,7 +49,7 @@ func (neo *neoRepository) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag)
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+		result, err := executeQuery(ctx, neo,
 			"MATCH "+qnode+" SET p = $props RETURN p",
 			map[string]interface{}{"props": props},
 			neo4jdb.EagerResultTransformer,
@@ -70,11 +70,12 @@ func (neo *neoRepository) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag)
 			return nil, errors.New("the record value for the node is nil")
 		}
 
-		if extracted, err := nodeToEdgeTag(node); err == nil && extracted != nil {
+		if extracted, err := neo.toEdgeTag(node); err == nil && extracted != nil {
 			tag = extracted
 		}
 	} else {
-		if input.ID == "" {
+		generated := input.ID == ""
+		if generated {
 			input.ID = neo.uniqueEdgeTagID()
 		}
 		if input.CreatedAt.IsZero() {
@@ -90,15 +91,8 @@ func (neo *neoRepository) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag)
 			return nil, err
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
 		query := fmt.Sprintf("CREATE (p:EdgeTag:%s $props) RETURN p", input.Property.PropertyType())
-		result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
-			map[string]interface{}{"props": props},
-			neo4jdb.EagerResultTransformer,
-			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
-		)
+		result, err := neo.createNode(query, props, "tag_id", generated, neo.uniqueEdgeTagID)
 		if err != nil {
 			return nil, err
 		}
@@ -114,7 +108,7 @@ func (neo *neoRepository) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag)
 			return nil, errors.New("the record value for the node is nil")
 		}
 
-		if t, err := nodeToEdgeTag(node); err == nil && t != nil {
+		if t, err := neo.toEdgeTag(node); err == nil && t != nil {
 			tag = t
 		}
 	}
@@ -134,12 +128,154 @@ func (neo *neoRepository) CreateEdgeProperty(edge *types.Edge, prop oam.Property
 }
 
 func (neo *neoRepository) uniqueEdgeTagID() string {
-	for {
-		id := uuid.New().String()
-		if _, err := neo.FindEdgeTagById(id); err != nil {
-			return id
+	return neo.newID(func(id string) bool {
+		_, err := neo.FindEdgeTagById(id)
+		return err == nil
+	})
+}
+
+// CreateEdgeTags creates edge tags in the database for each of the provided properties within a single transaction.
+// A property equal to an existing tag on the edge updates the last seen time of the existing tag instead.
+// Returns the created or updated edge tags, one for each distinct property, or an error if the transaction fails.
+func (neo *neoRepository) CreateEdgeTags(edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error) {
+	results, err := neo.CreateEdgeTagsBatch(map[string][]oam.Property{edge.ID: props})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := results[edge.ID]
+	for _, tag := range tags {
+		tag.Edge = edge
+	}
+	return tags, nil
+}
+
+// CreateEdgeWithProperties creates the edge, as done by CreateEdge, and the edge tags for the provided properties,
+// as done by CreateEdgeTags. The edge is deleted when it was created by the call and the tags cannot be created.
+// Returns the edge and the created or updated edge tags, or an error if the creation fails.
+func (neo *neoRepository) CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error) {
+	e, created, err := neo.UpsertEdge(edge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags, err := neo.CreateEdgeTags(e, props)
+	if err != nil {
+		if created {
+			_ = neo.DeleteEdge(e.ID)
 		}
+		return nil, nil, err
 	}
+	return e, tags, nil
+}
+
+// CreateEdgeTagsBatch creates the edge tags for the properties provided for each edge ID within a single transaction,
+// using the same deduplication as CreateEdgeTags.
+// Returns the created or updated edge tags by edge ID, or an error if the transaction fails.
+func (neo *neoRepository) CreateEdgeTagsBatch(props map[string][]oam.Property) (map[string][]*types.EdgeTag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	session := neo.db.NewSession(ctx, neo4jdb.SessionConfig{
+		AccessMode:   neo4jdb.AccessModeWrite,
+		DatabaseName: neo.dbname,
+	})
+	defer session.Close(ctx)
+
+	results, err := session.ExecuteWrite(ctx, func(tx neo4jdb.ManagedTransaction) (any, error) {
+		results := make(map[string][]*types.EdgeTag, len(props))
+
+		for id, list := range props {
+			for _, prop := range list {
+				if prop == nil {
+					continue
+				}
+
+				tag, err := neo.createEdgeTagTx(ctx, tx, id, prop)
+				if err != nil {
+					return nil, err
+				}
+				results[id] = appendDistinctEdgeTag(results[id], tag)
+			}
+		}
+		return results, nil
+	})
+	neo.status.record(err, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return results.(map[string][]*types.EdgeTag), nil
+}
+
+// createEdgeTagTx updates the last seen time of the tag on the edge equal to the property,
+// or creates the tag when it does not exist, using the provided transaction.
+func (neo *neoRepository) createEdgeTagTx(ctx context.Context, tx neo4jdb.ManagedTransaction, id string, prop oam.Property) (*types.EdgeTag, error) {
+	qnode, err := queryNodeByPropertyKeyValue("p", "EdgeTag", prop)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := tx.Run(ctx, "MATCH "+qnode+" WHERE p.edge_id = $eid SET p.updated_at = $now RETURN p",
+		map[string]interface{}{
+			"eid": id,
+			"now": timeToNeo4jTime(now),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := result.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		props, err := edgeTagPropsMap(&types.EdgeTag{
+			ID:        neo.uniqueEdgeTagID(),
+			CreatedAt: now,
+			LastSeen:  now,
+			Property:  prop,
+			Edge:      &types.Edge{ID: id},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		query := fmt.Sprintf("CREATE (p:EdgeTag:%s $props) RETURN p", prop.PropertyType())
+		result, err = tx.Run(ctx, query, map[string]interface{}{"props": props})
+		if err != nil {
+			return nil, err
+		}
+
+		records, err = result.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.New("no records returned from the query")
+		}
+	}
+
+	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](records[0], "p")
+	if err != nil {
+		return nil, err
+	}
+	if isnil {
+		return nil, errors.New("the record value for the node is nil")
+	}
+	return neo.toEdgeTag(node)
+}
+
+// appendDistinctEdgeTag appends the tag unless a tag with the same ID is already in the slice.
+func appendDistinctEdgeTag(tags []*types.EdgeTag, tag *types.EdgeTag) []*types.EdgeTag {
+	for _, t := range tags {
+		if t.ID == tag.ID {
+			return tags
+		}
+	}
+	return append(tags, tag)
 }
 
 // FindEdgeTagById finds an edge tag in the database by the ID.
@@ -149,7 +285,7 @@ func (neo *neoRepository) FindEdgeTagById(id string) (*types.EdgeTag, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	result, err := executeQuery(ctx, neo,
 		"MATCH (p:EdgeTag {tag_id: $tid}) RETURN p",
 		map[string]interface{}{"tid": id},
 		neo4jdb.EagerResultTransformer,
@@ -159,7 +295,7 @@ func (neo *neoRepository) FindEdgeTagById(id string) (*types.EdgeTag, error) {
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, fmt.Errorf("the edge tag with ID %s was not found", id)
+		return nil, fmt.Errorf("edge %w: %s", types.ErrTagNotFound, id)
 	}
 
 	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "p")
@@ -169,7 +305,7 @@ func (neo *neoRepository) FindEdgeTagById(id string) (*types.EdgeTag, error) {
 	if isnil {
 		return nil, errors.New("the record value for the node is nil")
 	}
-	return nodeToEdgeTag(node)
+	return neo.toEdgeTag(node)
 }
 
 // FindEdgeTagsByContent finds edge tags in the database that match the provided property data and updated_at after the since parameter.
@@ -191,7 +327,7 @@ func (neo *neoRepository) FindEdgeTagsByContent(prop oam.Property, since time.Ti
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, nil,
+	result, err := executeQuery(ctx, neo, query, nil,
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
@@ -199,7 +335,7 @@ func (neo *neoRepository) FindEdgeTagsByContent(prop oam.Property, since time.Ti
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no edge tags found")
+		return []*types.EdgeTag{}, neo.opts.NoResults("no edge tags found")
 	}
 
 	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "p")
@@ -210,26 +346,106 @@ func (neo *neoRepository) FindEdgeTagsByContent(prop oam.Property, since time.Ti
 		return nil, errors.New("the record value for the node is nil")
 	}
 
-	tag, err := nodeToEdgeTag(node)
+	tag, err := neo.toEdgeTag(node)
 	if err != nil {
 		return nil, err
 	}
 	return []*types.EdgeTag{tag}, nil
 }
 
+// FindAllTagsByContent finds both the entity tags and the edge tags in the database that match the provided property
+// data and updated_at after the since parameter. Both kinds of tags are retrieved with a single query.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the matching entity tags and edge tags, or an error if the search fails or neither kind of tag was found.
+func (neo *neoRepository) FindAllTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, []*types.EdgeTag, error) {
+	entityNode, err := queryNodeByPropertyKeyValue("p", "EntityTag", prop)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	edgeNode, err := queryNodeByPropertyKeyValue("p", "EdgeTag", prop)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var where string
+	if !since.IsZero() {
+		where = fmt.Sprintf(" WHERE p.updated_at >= localDateTime('%s')", timeToNeo4jTime(since))
+	}
+	query := fmt.Sprintf("MATCH %s%s RETURN p, 'entity' AS kind UNION ALL MATCH %s%s RETURN p, 'edge' AS kind",
+		entityNode, where, edgeNode, where)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(result.Records) == 0 {
+		return []*types.EntityTag{}, []*types.EdgeTag{}, neo.opts.NoResults("no tags found")
+	}
+
+	var entityTags []*types.EntityTag
+	var edgeTags []*types.EdgeTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil {
+			return nil, nil, err
+		}
+		if isnil {
+			continue
+		}
+
+		kind, _, err := neo4jdb.GetRecordValue[string](record, "kind")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if kind == "entity" {
+			tag, err := neo.toEntityTag(node)
+			if err != nil {
+				return nil, nil, err
+			}
+			entityTags = append(entityTags, tag)
+		} else {
+			tag, err := neo.toEdgeTag(node)
+			if err != nil {
+				return nil, nil, err
+			}
+			edgeTags = append(edgeTags, tag)
+		}
+	}
+	return entityTags, edgeTags, nil
+}
+
 // GetEdgeTags finds all tags for the edge with the specified names and last seen after the since parameter.
 // If since.IsZero(), the parameter will be ignored.
 // If no names are specified, all tags for the specified edge are returned.
 func (neo *neoRepository) GetEdgeTags(edge *types.Edge, since time.Time, names ...string) ([]*types.EdgeTag, error) {
-	query := fmt.Sprintf("MATCH (p:EdgeTag {edge_id: '%s'}) RETURN p", edge.ID)
-	if !since.IsZero() {
-		query = fmt.Sprintf("MATCH (p:EdgeTag {edge_id: '%s'}) WHERE p.updated_at >= localDateTime('%s') RETURN p", edge.ID, timeToNeo4jTime(since))
+	return neo.getEdgeTags(edge, since, time.Time{}, names...)
+}
+
+// GetEdgeTagsBetween finds all tags for the edge with the specified names and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no names are specified, all tags for the specified edge within the range are returned.
+func (neo *neoRepository) GetEdgeTagsBetween(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
 	}
+	return neo.getEdgeTags(edge, start, end, names...)
+}
+
+func (neo *neoRepository) getEdgeTags(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error) {
+	query := fmt.Sprintf("MATCH (p:EdgeTag {edge_id: '%s'})%s RETURN p", edge.ID, lastSeenBetween("p", start, end))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, nil,
+	result, err := executeQuery(ctx, neo, query, nil,
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
@@ -237,7 +453,7 @@ func (neo *neoRepository) GetEdgeTags(edge *types.Edge, since time.Time, names .
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no edge tags found")
+		return []*types.EdgeTag{}, neo.opts.NoResults("no edge tags found")
 	}
 
 	var results []*types.EdgeTag
@@ -250,7 +466,7 @@ func (neo *neoRepository) GetEdgeTags(edge *types.Edge, since time.Time, names .
 			continue
 		}
 
-		tag, err := nodeToEdgeTag(node)
+		tag, err := neo.toEdgeTag(node)
 		if err != nil {
 			continue
 		}
@@ -274,11 +490,93 @@ func (neo *neoRepository) GetEdgeTags(edge *types.Edge, since time.Time, names .
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero tags found")
+		return []*types.EdgeTag{}, neo.opts.NoResults("zero tags found")
+	}
+	return results, nil
+}
+
+// GetEdgeTagsByType finds all tags for the edge with the specified property types and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no property types are specified, all tags for the specified edge are returned.
+func (neo *neoRepository) GetEdgeTagsByType(edge *types.Edge, since time.Time, ptypes ...oam.PropertyType) ([]*types.EdgeTag, error) {
+	var conds []string
+	params := map[string]interface{}{"id": edge.ID}
+
+	if len(ptypes) > 0 {
+		values := make([]string, 0, len(ptypes))
+		for _, ptype := range ptypes {
+			values = append(values, string(ptype))
+		}
+
+		conds = append(conds, "p.ttype IN $ptypes")
+		params["ptypes"] = values
+	}
+	if !since.IsZero() {
+		conds = append(conds, fmt.Sprintf("p.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+
+	query := "MATCH (p:EdgeTag {edge_id: $id})"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " RETURN p"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EdgeTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil || isnil {
+			continue
+		}
+
+		tag, err := neo.toEdgeTag(node)
+		if err != nil {
+			continue
+		}
+		results = append(results, tag)
+	}
+
+	if len(results) == 0 {
+		return []*types.EdgeTag{}, neo.opts.NoResults("no edge tags found")
 	}
 	return results, nil
 }
 
+// CountEdgeTags counts the tags for the edge with the specified names and last seen after the since parameter,
+// without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified edge are counted.
+func (neo *neoRepository) CountEdgeTags(edge *types.Edge, since time.Time, names ...string) (int64, error) {
+	var conds []string
+	params := map[string]interface{}{"id": edge.ID}
+
+	if len(names) > 0 {
+		conds = append(conds, propertyNameField("p")+" IN $names")
+		params["names"] = names
+	}
+	if !since.IsZero() {
+		conds = append(conds, fmt.Sprintf("p.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+
+	query := "MATCH (p:EdgeTag {edge_id: $id})"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " RETURN count(p) AS total"
+
+	return neo.count(query, params)
+}
+
 // DeleteEdgeTag removes an edge tag in the database by its ID.
 // It takes a string representing the edge tag ID and removes the corresponding tag from the database.
 // Returns an error if the tag is not found.
@@ -286,7 +584,7 @@ func (neo *neoRepository) DeleteEdgeTag(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	_, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	_, err := executeQuery(ctx, neo,
 		"MATCH (n:EdgeTag {tag_id: $tid}) DETACH DELETE n",
 		map[string]interface{}{
 			"tid": id,
@@ -297,3 +595,10 @@ func (neo *neoRepository) DeleteEdgeTag(id string) error {
 
 	return err
 }
+
+// DeleteEdgeTagsByNameGlobal removes all edge tags in the database with the specified property name.
+// The matching tags are located and removed in batches, regardless of the edge they are attached to.
+// Returns the number of edge tags that were removed or an error if the deletion fails.
+func (neo *neoRepository) DeleteEdgeTagsByNameGlobal(name string) (int64, error) {
+	return neo.deleteTagsByName("EdgeTag", name)
+}
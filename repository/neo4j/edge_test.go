@@ -7,9 +7,11 @@
 package neo4j
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/owasp-amass/asset-db/types"
 	"github.com/owasp-amass/open-asset-model/domain"
 	"github.com/owasp-amass/open-asset-model/relation"
@@ -222,3 +224,42 @@ func TestDeleteEdge(t *testing.T) {
 	_, err = store.FindEdgeById(edge.ID)
 	assert.Error(t, err)
 }
+
+func TestRelationshipTypeCasing(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "casing1.edge"})
+	assert.NoError(t, err)
+
+	to, err := store.CreateAsset(&domain.FQDN{Name: "casing2.edge"})
+	assert.NoError(t, err)
+
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation: &relation.BasicDNSRelation{
+			Name:   "dns_record",
+			Header: relation.RRHeader{RRType: 5, Class: 1},
+		},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "dns_record", edge.Relation.Label())
+
+	// an external query matches the relationship using the documented casing
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := neo4jdb.ExecuteQuery(ctx, store.db,
+		"MATCH (:FQDN {name: $from})-[r:DNS_RECORD]->(:FQDN {name: $to}) RETURN r",
+		map[string]interface{}{"from": "casing1.edge", "to": "casing2.edge"},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(store.dbname),
+	)
+	assert.NoError(t, err)
+	assert.Len(t, result.Records, 1)
+
+	// the labels provided to the repository are matched regardless of their case
+	for _, label := range []string{"dns_record", "DNS_RECORD"} {
+		edges, err := store.OutgoingEdges(from, time.Time{}, label)
+		assert.NoError(t, err)
+		assert.Len(t, edges, 1)
+	}
+}
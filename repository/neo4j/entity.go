@@ -8,9 +8,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
@@ -19,61 +20,99 @@ import (
 // CreateEntity creates a new entity in the database.
 // It takes an Entity as input and persists it in the database.
 // The asset is serialized to JSON and stored in the Content field of the Entity struct.
+// When content validation is enabled, assets missing required fields are rejected.
 // Returns the created entity as a types.Entity or an error if the creation fails.
 func (neo *neoRepository) CreateEntity(input *types.Entity) (*types.Entity, error) {
+	e, _, err := neo.CreateEntityWithPrevLastSeen(input)
+	return e, err
+}
+
+// CreateEntityWithPrevLastSeen creates a new entity in the database, as done by CreateEntity.
+// When an existing entity is matched, the last seen time of the entity before the update is also returned,
+// which allows measuring the interval between observations without another query.
+// Returns the entity and the previous last seen time, which is zero when the entity was created,
+// or an error if the creation fails.
+func (neo *neoRepository) CreateEntityWithPrevLastSeen(input *types.Entity) (*types.Entity, time.Time, error) {
+	var prev time.Time
 	var entity *types.Entity
 
 	if input == nil {
-		return nil, errors.New("the input entity is nil")
+		return nil, time.Time{}, errors.New("the input entity is nil")
+	}
+	if neo.opts.ValidateContent {
+		if err := types.ValidateAsset(input.Asset); err != nil {
+			return nil, time.Time{}, err
+		}
 	}
 	// ensure that duplicate entities are not entered into the database
-	if entities, err := neo.FindEntitiesByContent(input.Asset, time.Time{}); err == nil && len(entities) > 0 {
+	entities, err := neo.FindEntitiesByContent(input.Asset, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, time.Time{}, err
+	}
+	if len(entities) > 0 {
 		e := entities[0]
 
 		if input.Asset.AssetType() != e.Asset.AssetType() {
-			return nil, errors.New("the asset type does not match the existing entity")
+			return nil, time.Time{}, errors.New("the asset type does not match the existing entity")
+		}
+		prev = e.LastSeen
+
+		// coalesce rapid re-observations of the entity
+		if neo.opts.SkipLastSeenUpdate(prev) {
+			return e, prev, nil
 		}
 
 		qnode, err := queryNodeByAssetKey("a", e.Asset)
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
 		}
 
+		// the stored fields missing from the input are kept, since the match may come from a secondary key
+		merged, err := types.MergeAsset(e.Asset, input.Asset)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		e.Asset = merged
 		e.LastSeen = time.Now()
 		props, err := entityPropsMap(e)
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
+		}
+		if neo.opts.RunID != "" {
+			props["run_id"] = neo.opts.RunID
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
-			"MATCH "+qnode+" SET a = $props RETURN a",
+		result, err := executeQuery(ctx, neo,
+			// the run ID recorded by an earlier write is kept when the repository does not stamp a run
+			"MATCH "+qnode+" WITH a, a.run_id AS rid SET a = $props SET a.run_id = coalesce($props.run_id, rid) RETURN a",
 			map[string]interface{}{"props": props},
 			neo4jdb.EagerResultTransformer,
 			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 		)
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
 		}
 		if len(result.Records) == 0 {
-			return nil, errors.New("no records returned from the query")
+			return nil, time.Time{}, errors.New("no records returned from the query")
 		}
 
 		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "a")
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
 		}
 		if isnil {
-			return nil, errors.New("the record value for the node is nil")
+			return nil, time.Time{}, errors.New("the record value for the node is nil")
 		}
 
-		if e, err := nodeToEntity(node); err == nil && e != nil {
+		if e, err := neo.toEntity(node); err == nil && e != nil {
 			entity = e
 		}
 	} else {
-		if input.ID == "" {
+		generated := input.ID == ""
+		if generated {
 			input.ID = neo.uniqueEntityID()
 		}
 		if input.CreatedAt.IsZero() {
@@ -85,42 +124,38 @@ func (neo *neoRepository) CreateEntity(input *types.Entity) (*types.Entity, erro
 
 		props, err := entityPropsMap(input)
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
+		}
+		if neo.opts.RunID != "" {
+			props["run_id"] = neo.opts.RunID
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
 
 		query := fmt.Sprintf("CREATE (a:Entity:%s $props) RETURN a", input.Asset.AssetType())
-		result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
-			map[string]interface{}{"props": props},
-			neo4jdb.EagerResultTransformer,
-			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
-		)
+		result, err := neo.createNode(query, props, "entity_id", generated, neo.uniqueEntityID)
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
 		}
 		if len(result.Records) == 0 {
-			return nil, errors.New("no records returned from the query")
+			return nil, time.Time{}, errors.New("no records returned from the query")
 		}
 
 		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "a")
 		if err != nil {
-			return nil, err
+			return nil, time.Time{}, err
 		}
 		if isnil {
-			return nil, errors.New("the record value for the node is nil")
+			return nil, time.Time{}, errors.New("the record value for the node is nil")
 		}
 
-		if e, err := nodeToEntity(node); err == nil && e != nil {
+		if e, err := neo.toEntity(node); err == nil && e != nil {
 			entity = e
 		}
 	}
 
 	if entity == nil {
-		return nil, errors.New("failed to create the entity")
+		return nil, time.Time{}, errors.New("failed to create the entity")
 	}
-	return entity, nil
+	return entity, prev, nil
 }
 
 // CreateAsset creates a new entity in the database.
@@ -131,13 +166,35 @@ func (neo *neoRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
 	return neo.CreateEntity(&types.Entity{Asset: asset})
 }
 
-func (neo *neoRepository) uniqueEntityID() string {
-	for {
-		id := uuid.New().String()
-		if _, err := neo.FindEntityById(id); err != nil {
-			return id
+// UpsertEntity ensures that an entity exists in the database for the asset, as done by CreateAsset.
+// The uniqueness constraint on the key property of the asset type rejects the second of two concurrent
+// creations, in which case the entity created by the other writer is updated instead.
+// Returns the entity, and true if the entity was created or false if an existing entity was updated.
+func (neo *neoRepository) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	if asset == nil {
+		return nil, false, errors.New("the asset is nil")
+	}
+
+	_, ferr := neo.FindEntitiesByContent(asset, time.Time{})
+	e, err := neo.CreateEntity(&types.Entity{Asset: asset})
+	if err != nil && ferr != nil && isConstraintViolation(err) {
+		e, err = neo.CreateEntity(&types.Entity{Asset: asset})
+		if err != nil {
+			return nil, false, err
 		}
+		return e, false, nil
 	}
+	if err != nil {
+		return nil, false, err
+	}
+	return e, ferr != nil, nil
+}
+
+func (neo *neoRepository) uniqueEntityID() string {
+	return neo.newID(func(id string) bool {
+		_, err := neo.FindEntityById(id)
+		return err == nil
+	})
 }
 
 // FindEntityById finds an entity in the database by the ID.
@@ -147,7 +204,7 @@ func (neo *neoRepository) FindEntityById(id string) (*types.Entity, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	result, err := executeQuery(ctx, neo,
 		"MATCH (a:Entity {entity_id: $eid}) RETURN a",
 		map[string]interface{}{"eid": id},
 		neo4jdb.EagerResultTransformer,
@@ -157,7 +214,7 @@ func (neo *neoRepository) FindEntityById(id string) (*types.Entity, error) {
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, fmt.Errorf("the entity with ID %s was not found", id)
+		return nil, fmt.Errorf("%w: %s", types.ErrEntityNotFound, id)
 	}
 
 	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "a")
@@ -167,15 +224,25 @@ func (neo *neoRepository) FindEntityById(id string) (*types.Entity, error) {
 	if isnil {
 		return nil, errors.New("the record value for the node is nil")
 	}
-	return nodeToEntity(node)
+	return neo.toEntity(node)
 }
 
 // FindEntitiesByContent finds entities in the database that match the provided asset data and last seen after
 // the since parameter. It takes an oam.Asset as input and searches for entities with matching content in the database.
 // If since.IsZero(), the parameter will be ignored.
 // The asset data is serialized to JSON and compared against the Content field of the Entity struct.
+// When no entity matches the primary key, the other candidate key fields populated in the asset are tried in order.
 // Returns a slice of matching entities as []*types.Entity or an error if the search fails.
 func (neo *neoRepository) FindEntitiesByContent(assetData oam.Asset, since time.Time) ([]*types.Entity, error) {
+	keys, err := types.AssetKeyCandidates(assetData)
+	if err != nil {
+		return nil, err
+	}
+	// the primary key is skipped when only the other candidate key fields are populated
+	if primary, _, _ := types.AssetKey(assetData); keys[0].Field != primary {
+		return neo.findEntitiesByKeys(assetData, keys, since)
+	}
+
 	qnode, err := queryNodeByAssetKey("a", assetData)
 	if err != nil {
 		return nil, err
@@ -189,7 +256,7 @@ func (neo *neoRepository) FindEntitiesByContent(assetData oam.Asset, since time.
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, nil,
+	result, err := executeQuery(ctx, neo, query, nil,
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
@@ -197,7 +264,7 @@ func (neo *neoRepository) FindEntitiesByContent(assetData oam.Asset, since time.
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no entities found")
+		return neo.findEntitiesByKeys(assetData, keys[1:], since)
 	}
 
 	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "a")
@@ -208,35 +275,588 @@ func (neo *neoRepository) FindEntitiesByContent(assetData oam.Asset, since time.
 		return nil, errors.New("the record value for the node is nil")
 	}
 
-	e, err := nodeToEntity(node)
+	e, err := neo.toEntity(node)
 	if err != nil {
 		return nil, err
 	}
 	return []*types.Entity{e}, nil
 }
 
+// findEntitiesByKeys tries the candidate key fields in order, and returns the entities matching
+// the first field that finds any.
+func (neo *neoRepository) findEntitiesByKeys(assetData oam.Asset, keys []types.AssetKeyField, since time.Time) ([]*types.Entity, error) {
+	for _, k := range keys {
+		if entities, err := neo.FindEntitiesByField(assetData.AssetType(), k.Field, k.Value, since); err == nil && len(entities) > 0 {
+			return entities, nil
+		}
+	}
+	return []*types.Entity{}, neo.opts.NoResults("no entities found")
+}
+
+// FindEntityByContentLatest finds the entity in the database with the same content as the provided asset that was
+// seen most recently. This is useful when near-duplicate entities share the same content.
+// Returns the matching entity as a types.Entity or types.ErrEntityNotFound if there is no match.
+func (neo *neoRepository) FindEntityByContentLatest(assetData oam.Asset) (*types.Entity, error) {
+	qnode, err := queryNodeByAssetKey("a", assetData)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo,
+		"MATCH "+qnode+" RETURN a ORDER BY a.updated_at DESC LIMIT 1", nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Records) == 0 {
+		return nil, types.ErrEntityNotFound
+	}
+
+	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "a")
+	if err != nil {
+		return nil, err
+	}
+	if isnil {
+		return nil, errors.New("the record value for the node is nil")
+	}
+	return neo.toEntity(node)
+}
+
+// FindEntitiesByContentFold finds entities in the database with the same asset type and a key field equal to the
+// key of the provided asset under case folding, last seen after the since parameter.
+// Asset types without a string key field are matched exactly, as done by FindEntitiesByContent.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindEntitiesByContentFold(assetData oam.Asset, since time.Time) ([]*types.Entity, error) {
+	field, value, err := types.AssetKey(assetData)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := value.(string)
+	if !ok {
+		return neo.FindEntitiesByContent(assetData, since)
+	}
+
+	query := fmt.Sprintf("MATCH (a:%s) WHERE toLower(a[$field]) = $value RETURN a", assetData.AssetType())
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (a:%s) WHERE toLower(a[$field]) = $value AND a.updated_at >= localDateTime('%s') RETURN a", assetData.AssetType(), timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"field": field,
+			"value": strings.ToLower(key),
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil || isnil {
+			continue
+		}
+
+		if e, err := neo.toEntity(node); err == nil && e != nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
 // FindEntitiesByType finds all entities in the database of the provided asset type and last seen after the since parameter.
 // It takes an asset type and retrieves the corresponding entities from the database.
 // If since.IsZero(), the parameter will be ignored.
 // Returns a slice of matching entities as []*types.Entity or an error if the search fails.
 func (neo *neoRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
-	query := fmt.Sprintf("MATCH (a:%s) RETURN a", string(atype))
+	return neo.findEntitiesByType(atype, since, time.Time{})
+}
+
+// FindEntitiesByTypeBetween finds all entities in the database of the provided asset type and last seen
+// between start and end, inclusive. If start.IsZero(), the range has no lower bound.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindEntitiesByTypeBetween(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return neo.findEntitiesByType(atype, start, end)
+}
+
+func (neo *neoRepository) findEntitiesByType(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error) {
+	query := fmt.Sprintf("MATCH (a:%s)%s RETURN a", string(atype), lastSeenBetween("a", start, end))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Records) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("no entities of the specified type")
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil {
+			return nil, err
+		}
+		if isnil {
+			return nil, errors.New("the record value for the node is nil")
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("no entities of the specified type")
+	}
+	return results, nil
+}
+
+// FindStaleEntities finds all entities in the database of the provided asset type that were last seen
+// more than olderThan before now. These are the entities that have not been re-observed recently.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindStaleEntities(atype oam.AssetType, olderThan time.Duration) ([]*types.Entity, error) {
+	cutoff := time.Now().Add(-olderThan)
+	query := fmt.Sprintf("MATCH (a:%s) WHERE a.updated_at < localDateTime('%s') RETURN a", string(atype), timeToNeo4jTime(cutoff))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil || isnil {
+			continue
+		}
+
+		if e, err := neo.toEntity(node); err == nil && e != nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// NewEntitiesCountByType counts the entities in the database created after the since parameter, grouped by the
+// asset type. Unlike counts based on the last seen time, re-observed entities that existed before since are not counted.
+// If since.IsZero(), all entities are counted.
+// Returns the number of new entities for each asset type or an error if the count fails.
+func (neo *neoRepository) NewEntitiesCountByType(since time.Time) (map[oam.AssetType]int64, error) {
+	query := "MATCH (a:Entity) RETURN a.etype AS etype, count(a) AS total"
 	if !since.IsZero() {
-		query = fmt.Sprintf("MATCH (a:%s) WHERE a.updated_at >= localDateTime('%s') RETURN a", string(atype), timeToNeo4jTime(since))
+		query = fmt.Sprintf("MATCH (a:Entity) WHERE a.created_at >= localDateTime('%s') RETURN a.etype AS etype, count(a) AS total", timeToNeo4jTime(since))
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, nil,
+	result, err := executeQuery(ctx, neo, query, nil,
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
 	if err != nil {
 		return nil, err
 	}
+
+	results := make(map[oam.AssetType]int64, len(result.Records))
+	for _, record := range result.Records {
+		etype, isnil, err := neo4jdb.GetRecordValue[string](record, "etype")
+		if err != nil || isnil {
+			continue
+		}
+
+		total, _, err := neo4jdb.GetRecordValue[int64](record, "total")
+		if err != nil {
+			return nil, err
+		}
+		results[oam.AssetType(etype)] = total
+	}
+	return results, nil
+}
+
+// CountEntitiesByType counts the entities in the database of the provided asset type and last seen after
+// the since parameter, without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+func (neo *neoRepository) CountEntitiesByType(atype oam.AssetType, since time.Time) (int64, error) {
+	query := fmt.Sprintf("MATCH (a:%s) RETURN count(a) AS total", string(atype))
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (a:%s) WHERE a.updated_at >= localDateTime('%s') RETURN count(a) AS total", string(atype), timeToNeo4jTime(since))
+	}
+
+	return neo.count(query, nil)
+}
+
+// count executes the query and returns the value of the total column in the single record of the result.
+func (neo *neoRepository) count(query string, params map[string]interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return 0, err
+	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no entities of the specified type")
+		return 0, nil
+	}
+
+	total, _, err := neo4jdb.GetRecordValue[int64](result.Records[0], "total")
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// ChildNetblocks finds the Netblock or IPNetRecord entities in the database that are the immediate children of the
+// parent in the allocation tree, last seen after the since parameter. IPNetRecords are related by the handle and
+// parent handle fields, while Netblocks are related by CIDR containment.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of the child entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) ChildNetblocks(parent *types.Entity, since time.Time) ([]*types.Entity, error) {
+	if parent == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := types.ValidateNetblock(parent.Asset); err != nil {
+		return nil, err
+	}
+
+	candidates, err := neo.FindEntitiesByType(parent.Asset.AssetType(), since)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := types.ChildNetblocks(parent, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return children, nil
+}
+
+// ParentNetblock finds the Netblock or IPNetRecord entity in the database that is the parent of the child in the
+// allocation tree, last seen after the since parameter. For Netblocks, the smallest containing CIDR is the parent.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the parent entity as a types.Entity or an error if the parent is not found.
+func (neo *neoRepository) ParentNetblock(child *types.Entity, since time.Time) (*types.Entity, error) {
+	if child == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := types.ValidateNetblock(child.Asset); err != nil {
+		return nil, err
+	}
+
+	// without any netblocks of the type, the parent is not found
+	candidates, err := neo.FindEntitiesByType(child.Asset.AssetType(), since)
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, err
+	}
+
+	parent, err := types.ParentNetblock(child, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("the parent netblock was not found: %w", types.ErrEntityNotFound)
+	}
+	return parent, nil
+}
+
+// FindEntitiesByTypePagedWithTotal finds a page of entities in the database of the provided asset type and last seen
+// after the since parameter, along with the total number of entities matching the same criteria.
+// If since.IsZero(), the parameter will be ignored.
+// The page and the total are obtained within a single read transaction to keep the two results consistent.
+// Returns the page of matching entities as []*types.Entity, the total count, or an error if the search fails.
+func (neo *neoRepository) FindEntitiesByTypePagedWithTotal(atype oam.AssetType, since time.Time, limit, offset int) ([]*types.Entity, int64, error) {
+	match := fmt.Sprintf("MATCH (a:%s)", string(atype))
+	if !since.IsZero() {
+		match = fmt.Sprintf("MATCH (a:%s) WHERE a.updated_at >= localDateTime('%s')", string(atype), timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	session := neo.db.NewSession(ctx, neo4jdb.SessionConfig{
+		AccessMode:   neo4jdb.AccessModeRead,
+		DatabaseName: neo.dbname,
+	})
+	defer session.Close(ctx)
+
+	var total int64
+	records, err := session.ExecuteRead(ctx, func(tx neo4jdb.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, match+" RETURN count(a) AS total", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := result.Single(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		total, _, err = neo4jdb.GetRecordValue[int64](record, "total")
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(ctx, match+" RETURN a ORDER BY a.entity_id SKIP $offset LIMIT $limit",
+			map[string]interface{}{
+				"offset": int64(offset),
+				"limit":  int64(limit),
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return result.Collect(ctx)
+	})
+	neo.status.record(err, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []*types.Entity
+	for _, record := range records.([]*neo4jdb.Record) {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil {
+			return nil, total, err
+		}
+		if isnil {
+			return nil, total, errors.New("the record value for the node is nil")
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			return nil, total, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, total, neo.opts.NoResults("no entities of the specified type")
+	}
+	return results, total, nil
+}
+
+// FindEntitiesByField finds all entities in the database of the provided asset type, where the named field of the
+// asset equals the value, and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field is not valid or the search fails.
+func (neo *neoRepository) FindEntitiesByField(atype oam.AssetType, field string, value any, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("MATCH (a:%s) WHERE a[$field] = $value RETURN a", string(atype))
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (a:%s) WHERE a[$field] = $value AND a.updated_at >= localDateTime('%s') RETURN a", string(atype), timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"field": field,
+			"value": value,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil {
+			return nil, err
+		}
+		if isnil {
+			return nil, errors.New("the record value for the node is nil")
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByFieldRange finds all entities in the database of the provided asset type, where the named numeric
+// field of the asset is within the inclusive range from min to max, and last seen after the since parameter.
+// A nil bound leaves that side of the range open. If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field is not numeric or the search fails.
+func (neo *neoRepository) FindEntitiesByFieldRange(atype oam.AssetType, field string, min, max any, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateNumericAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	lo, hasMin, err := types.NumericBound(min)
+	if err != nil {
+		return nil, err
+	}
+
+	hi, hasMax, err := types.NumericBound(max)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"a[$field] IS NOT NULL"}
+	if hasMin {
+		conditions = append(conditions, "a[$field] >= $min")
+	}
+	if hasMax {
+		conditions = append(conditions, "a[$field] <= $max")
+	}
+	if !since.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("a.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+	query := fmt.Sprintf("MATCH (a:%s) WHERE %s RETURN a", string(atype), strings.Join(conditions, " AND "))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"field": field,
+			"min":   lo,
+			"max":   hi,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil {
+			return nil, err
+		}
+		if isnil {
+			return nil, errors.New("the record value for the node is nil")
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByFieldRegex finds all entities in the database of the provided asset type, where the text of the
+// named field of the asset matches the regular expression pattern, and last seen after the since parameter.
+// The pattern uses the Go regexp syntax, which differs from the Cypher regular expressions, so the entities of the
+// asset type are loaded and the match is applied to each of them.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field or pattern is not valid or the search fails.
+func (neo *neoRepository) FindEntitiesByFieldRegex(atype oam.AssetType, field, pattern string, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := neo.FindEntitiesByType(atype, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		if types.AssetFieldMatches(e.Asset, field, re) {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// SearchFQDNs finds all FQDN entities with a name containing the provided substring, ignoring case, and last seen after
+// the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) SearchFQDNs(substr string, since time.Time) ([]*types.Entity, error) {
+	query := fmt.Sprintf("MATCH (a:%s) WHERE toLower(a.name) CONTAINS toLower($substr) RETURN a", oam.FQDN)
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (a:%s) WHERE toLower(a.name) CONTAINS toLower($substr) AND a.updated_at >= localDateTime('%s') RETURN a", oam.FQDN, timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"substr": substr,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	var results []*types.Entity
@@ -249,7 +869,7 @@ func (neo *neoRepository) FindEntitiesByType(atype oam.AssetType, since time.Tim
 			return nil, errors.New("the record value for the node is nil")
 		}
 
-		e, err := nodeToEntity(node)
+		e, err := neo.toEntity(node)
 		if err != nil {
 			return nil, err
 		}
@@ -257,11 +877,181 @@ func (neo *neoRepository) FindEntitiesByType(atype oam.AssetType, since time.Tim
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("no entities of the specified type")
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
 	}
 	return results, nil
 }
 
+// SearchEntities finds all entities with a content property value containing the query, ignoring case, that are of
+// one of the provided asset types and last seen after the since parameter. Only the values of the properties that
+// hold the asset content are matched, and list values are not searched.
+// The search cannot use an index and reads every property of each entity of the asset types, so the types should be
+// provided whenever possible. The query must have at least types.MinSearchQueryLength characters.
+// If no asset types are provided, entities of all types are searched.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) SearchEntities(query string, atypes []oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateSearchQuery(query); err != nil {
+		return nil, err
+	}
+
+	conds := []string{"any(k IN keys(a) WHERE NOT k IN $internal AND toLower(toStringOrNull(a[k])) CONTAINS toLower($query))"}
+	params := map[string]interface{}{
+		"query":    query,
+		"internal": []string{"etype", "entity_id", "created_at", "updated_at", "run_id"},
+	}
+	if len(atypes) > 0 {
+		etypes := make([]string, 0, len(atypes))
+		for _, atype := range atypes {
+			etypes = append(etypes, string(atype))
+		}
+		conds = append(conds, "a.etype IN $etypes")
+		params["etypes"] = etypes
+	}
+	if !since.IsZero() {
+		conds = append(conds, fmt.Sprintf("a.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, "MATCH (a:Entity) WHERE "+strings.Join(conds, " AND ")+" RETURN a", params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil {
+			return nil, err
+		}
+		if isnil {
+			return nil, errors.New("the record value for the node is nil")
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindServicesByAttribute finds all Service entities with the provided value among the values of the
+// header attribute with the provided key.
+// The service headers are not stored as node properties by this repository, so the search is not supported.
+func (neo *neoRepository) FindServicesByAttribute(key, value string, since time.Time) ([]*types.Entity, error) {
+	return nil, errors.New("the neo4j repository does not store the service headers")
+}
+
+// FindEntitiesByRun finds all entities last written by the scan run with the provided ID.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindEntitiesByRun(runID string) ([]*types.Entity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo,
+		"MATCH (a:Entity {run_id: $rid}) RETURN a",
+		map[string]interface{}{"rid": runID},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil || isnil {
+			continue
+		}
+
+		if e, err := neo.toEntity(node); err == nil && e != nil {
+			results = append(results, e)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// UpdateEntityContentCAS replaces the content of the entity with the provided ID, only when the stored content
+// is equal to the expected asset. The comparison and the update are performed by a single query, so a
+// concurrent change to the entity causes the update to be rejected instead of lost.
+// Returns true if the content was updated, or an error if the update fails.
+func (neo *neoRepository) UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error) {
+	if expected == nil || new == nil {
+		return false, errors.New("the expected and new assets must not be nil")
+	}
+	if expected.AssetType() != new.AssetType() {
+		return false, errors.New("the new asset type does not match the expected asset type")
+	}
+	if neo.opts.ValidateContent {
+		if err := types.ValidateAsset(new); err != nil {
+			return false, err
+		}
+	}
+
+	expectedProps, err := assetContentProps(expected)
+	if err != nil {
+		return false, err
+	}
+	newProps, err := assetContentProps(new)
+	if err != nil {
+		return false, err
+	}
+	// remove the properties that are no longer part of the content
+	for k := range expectedProps {
+		if _, found := newProps[k]; !found {
+			newProps[k] = nil
+		}
+	}
+	newProps["updated_at"] = timeToNeo4jTime(time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf("MATCH (a:%s {entity_id: $eid}) WHERE all(k IN keys($expected) WHERE a[k] = $expected[k]) SET a += $props RETURN a", expected.AssetType())
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"eid":      id,
+			"expected": expectedProps,
+			"props":    newProps,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return false, err
+	}
+	return len(result.Records) > 0, nil
+}
+
+// assetContentProps returns the node properties that hold the content of the asset.
+func assetContentProps(asset oam.Asset) (map[string]interface{}, error) {
+	props, err := entityPropsMap(&types.Entity{Asset: asset})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range []string{"etype", "entity_id", "created_at", "updated_at"} {
+		delete(props, k)
+	}
+	return props, nil
+}
+
 // DeleteEntity removes an entity in the database by its ID.
 // It takes a string representing the entity ID and removes the corresponding entity from the database.
 // Returns an error if the entity is not found.
@@ -269,7 +1059,7 @@ func (neo *neoRepository) DeleteEntity(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	_, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	_, err := executeQuery(ctx, neo,
 		"MATCH (n:Entity {entity_id: $eid}) DETACH DELETE n",
 		map[string]interface{}{
 			"eid": id,
@@ -8,9 +8,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
@@ -49,7 +50,7 @@ func (neo *neoRepository) CreateEntityTag(entity *types.Entity, input *types.Ent
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+		result, err := executeQuery(ctx, neo,
 			"MATCH "+qnode+" SET p = $props RETURN p",
 			map[string]interface{}{"props": props},
 			neo4jdb.EagerResultTransformer,
@@ -70,11 +71,12 @@ func (neo *neoRepository) CreateEntityTag(entity *types.Entity, input *types.Ent
 			return nil, errors.New("the record value for the node is nil")
 		}
 
-		if extracted, err := nodeToEntityTag(node); err == nil && extracted != nil {
+		if extracted, err := neo.toEntityTag(node); err == nil && extracted != nil {
 			tag = extracted
 		}
 	} else {
-		if input.ID == "" {
+		generated := input.ID == ""
+		if generated {
 			input.ID = neo.uniqueEntityTagID()
 		}
 		if input.CreatedAt.IsZero() {
@@ -90,15 +92,8 @@ func (neo *neoRepository) CreateEntityTag(entity *types.Entity, input *types.Ent
 			return nil, err
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
 		query := fmt.Sprintf("CREATE (p:EntityTag:%s $props) RETURN p", input.Property.PropertyType())
-		result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query,
-			map[string]interface{}{"props": props},
-			neo4jdb.EagerResultTransformer,
-			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
-		)
+		result, err := neo.createNode(query, props, "tag_id", generated, neo.uniqueEntityTagID)
 		if err != nil {
 			return nil, err
 		}
@@ -114,7 +109,7 @@ func (neo *neoRepository) CreateEntityTag(entity *types.Entity, input *types.Ent
 			return nil, errors.New("the record value for the node is nil")
 		}
 
-		if t, err := nodeToEntityTag(node); err == nil && t != nil {
+		if t, err := neo.toEntityTag(node); err == nil && t != nil {
 			tag = t
 		}
 	}
@@ -133,13 +128,128 @@ func (neo *neoRepository) CreateEntityProperty(entity *types.Entity, prop oam.Pr
 	return neo.CreateEntityTag(entity, &types.EntityTag{Property: prop})
 }
 
-func (neo *neoRepository) uniqueEntityTagID() string {
-	for {
-		id := uuid.New().String()
-		if _, err := neo.FindEntityTagById(id); err != nil {
-			return id
+// CreateEntityTags creates entity tags in the database for each of the provided properties within a single
+// transaction. A property equal to an existing tag on the entity, or to an earlier property of the batch,
+// updates the last seen time of the existing tag instead.
+// Returns the created or updated entity tags, one for each distinct property, or an error if the transaction fails.
+func (neo *neoRepository) CreateEntityTags(entity *types.Entity, props []oam.Property) ([]*types.EntityTag, error) {
+	if entity == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	session := neo.db.NewSession(ctx, neo4jdb.SessionConfig{
+		AccessMode:   neo4jdb.AccessModeWrite,
+		DatabaseName: neo.dbname,
+	})
+	defer session.Close(ctx)
+
+	results, err := session.ExecuteWrite(ctx, func(tx neo4jdb.ManagedTransaction) (any, error) {
+		var results []*types.EntityTag
+
+		for _, prop := range props {
+			if prop == nil {
+				continue
+			}
+
+			tag, err := neo.createEntityTagTx(ctx, tx, entity.ID, prop)
+			if err != nil {
+				return nil, err
+			}
+			results = appendDistinctEntityTag(results, tag)
+		}
+		return results, nil
+	})
+	neo.status.record(err, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tags := results.([]*types.EntityTag)
+	for _, tag := range tags {
+		tag.Entity = entity
+	}
+	return tags, nil
+}
+
+// createEntityTagTx updates the last seen time of the tag on the entity equal to the property,
+// or creates the tag when it does not exist, using the provided transaction.
+func (neo *neoRepository) createEntityTagTx(ctx context.Context, tx neo4jdb.ManagedTransaction, id string, prop oam.Property) (*types.EntityTag, error) {
+	qnode, err := queryNodeByPropertyKeyValue("p", "EntityTag", prop)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := tx.Run(ctx, "MATCH "+qnode+" WHERE p.entity_id = $eid SET p.updated_at = $now RETURN p",
+		map[string]interface{}{
+			"eid": id,
+			"now": timeToNeo4jTime(now),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := result.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		props, err := entityTagPropsMap(&types.EntityTag{
+			ID:        neo.uniqueEntityTagID(),
+			CreatedAt: now,
+			LastSeen:  now,
+			Property:  prop,
+			Entity:    &types.Entity{ID: id},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		query := fmt.Sprintf("CREATE (p:EntityTag:%s $props) RETURN p", prop.PropertyType())
+		result, err = tx.Run(ctx, query, map[string]interface{}{"props": props})
+		if err != nil {
+			return nil, err
+		}
+
+		records, err = result.Collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.New("no records returned from the query")
 		}
 	}
+
+	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](records[0], "p")
+	if err != nil {
+		return nil, err
+	}
+	if isnil {
+		return nil, errors.New("the record value for the node is nil")
+	}
+	return neo.toEntityTag(node)
+}
+
+// appendDistinctEntityTag appends the tag unless a tag with the same ID is already in the slice.
+func appendDistinctEntityTag(tags []*types.EntityTag, tag *types.EntityTag) []*types.EntityTag {
+	for _, t := range tags {
+		if t.ID == tag.ID {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+func (neo *neoRepository) uniqueEntityTagID() string {
+	return neo.newID(func(id string) bool {
+		_, err := neo.FindEntityTagById(id)
+		return err == nil
+	})
 }
 
 // FindEntityTagById finds an entity tag in the database by the ID.
@@ -149,7 +259,7 @@ func (neo *neoRepository) FindEntityTagById(id string) (*types.EntityTag, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	result, err := executeQuery(ctx, neo,
 		"MATCH (p:EntityTag {tag_id: $tid}) RETURN p",
 		map[string]interface{}{"tid": id},
 		neo4jdb.EagerResultTransformer,
@@ -159,7 +269,7 @@ func (neo *neoRepository) FindEntityTagById(id string) (*types.EntityTag, error)
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, fmt.Errorf("the entity tag with ID %s was not found", id)
+		return nil, fmt.Errorf("entity %w: %s", types.ErrTagNotFound, id)
 	}
 
 	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "p")
@@ -169,7 +279,7 @@ func (neo *neoRepository) FindEntityTagById(id string) (*types.EntityTag, error)
 	if isnil {
 		return nil, errors.New("the record value for the node is nil")
 	}
-	return nodeToEntityTag(node)
+	return neo.toEntityTag(node)
 }
 
 // FindEntityTagsByContent finds entity tags in the database that match the provided property data and updated_at after the since parameter.
@@ -191,7 +301,7 @@ func (neo *neoRepository) FindEntityTagsByContent(prop oam.Property, since time.
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, nil,
+	result, err := executeQuery(ctx, neo, query, nil,
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
@@ -199,7 +309,7 @@ func (neo *neoRepository) FindEntityTagsByContent(prop oam.Property, since time.
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no entity tags found")
+		return []*types.EntityTag{}, neo.opts.NoResults("no entity tags found")
 	}
 
 	node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](result.Records[0], "p")
@@ -210,26 +320,195 @@ func (neo *neoRepository) FindEntityTagsByContent(prop oam.Property, since time.
 		return nil, errors.New("the record value for the node is nil")
 	}
 
-	tag, err := nodeToEntityTag(node)
+	tag, err := neo.toEntityTag(node)
 	if err != nil {
 		return nil, err
 	}
 	return []*types.EntityTag{tag}, nil
 }
 
+// FindEntityTagsBySource finds all SourceProperty entity tags, across all entities, with the provided source and
+// updated_at after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (neo *neoRepository) FindEntityTagsBySource(source string, since time.Time) ([]*types.EntityTag, error) {
+	query := fmt.Sprintf("MATCH (p:EntityTag {ttype: '%s'}) WHERE p.name = $source RETURN p", oam.SourceProperty)
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (p:EntityTag {ttype: '%s'}) WHERE p.name = $source AND p.updated_at >= localDateTime('%s') RETURN p", oam.SourceProperty, timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"source": source,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil || isnil {
+			continue
+		}
+
+		if tag, err := neo.toEntityTag(node); err == nil {
+			results = append(results, tag)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, neo.opts.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntityTagsByValuePrefix finds all SimpleProperty entity tags, across all entities, with the provided name,
+// a value starting with the prefix and updated_at after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (neo *neoRepository) FindEntityTagsByValuePrefix(name, prefix string, since time.Time) ([]*types.EntityTag, error) {
+	query := fmt.Sprintf("MATCH (p:EntityTag {ttype: '%s'}) WHERE p.property_name = $name AND p.property_value STARTS WITH $prefix RETURN p", oam.SimpleProperty)
+	if !since.IsZero() {
+		query = fmt.Sprintf("MATCH (p:EntityTag {ttype: '%s'}) WHERE p.property_name = $name AND p.property_value STARTS WITH $prefix AND p.updated_at >= localDateTime('%s') RETURN p", oam.SimpleProperty, timeToNeo4jTime(since))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query,
+		map[string]interface{}{
+			"name":   name,
+			"prefix": prefix,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil || isnil {
+			continue
+		}
+
+		if tag, err := neo.toEntityTag(node); err == nil {
+			results = append(results, tag)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, neo.opts.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByTags finds all entities with tags matching the predicates, combined by the AndOr, considering only
+// tags last seen after the since parameter. Each predicate is matched by an existential subquery on the entity tags.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (neo *neoRepository) FindEntitiesByTags(predicates []types.TagPredicate, combine types.AndOr, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateTagPredicates(predicates, combine); err != nil {
+		return nil, err
+	}
+
+	var conds []string
+	params := make(map[string]interface{})
+	for i, p := range predicates {
+		cond := fmt.Sprintf("EXISTS { MATCH (t:EntityTag {entity_id: a.entity_id}) WHERE %s = $name%d", propertyNameField("t"), i)
+		params[fmt.Sprintf("name%d", i)] = p.Name
+
+		if p.Ordered() {
+			value, _ := strconv.ParseFloat(p.Value, 64)
+			cond += fmt.Sprintf(" AND toFloat(%s) %s $value%d", propertyValueField("t"), p.Op, i)
+			params[fmt.Sprintf("value%d", i)] = value
+		} else if p.Op != types.TagExists {
+			op := string(p.Op)
+			if p.Op == types.TagNotEqual {
+				op = "<>"
+			}
+			cond += fmt.Sprintf(" AND %s %s $value%d", propertyValueField("t"), op, i)
+			params[fmt.Sprintf("value%d", i)] = p.Value
+		}
+
+		if !since.IsZero() {
+			cond += fmt.Sprintf(" AND t.updated_at >= localDateTime('%s')", timeToNeo4jTime(since))
+		}
+		conds = append(conds, cond+" }")
+	}
+
+	sep := " AND "
+	if combine == types.Or {
+		sep = " OR "
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, "MATCH (a:Entity) WHERE "+strings.Join(conds, sep)+" RETURN a", params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "a")
+		if err != nil {
+			return nil, err
+		}
+		if isnil {
+			return nil, errors.New("the record value for the node is nil")
+		}
+
+		e, err := neo.toEntity(node)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, neo.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
 // GetEntityTags finds all tags for the entity with the specified names and last seen after the since parameter.
 // If since.IsZero(), the parameter will be ignored.
 // If no names are specified, all tags for the specified entity are returned.
 func (neo *neoRepository) GetEntityTags(entity *types.Entity, since time.Time, names ...string) ([]*types.EntityTag, error) {
-	query := fmt.Sprintf("MATCH (p:EntityTag {entity_id: '%s'}) RETURN p", entity.ID)
-	if !since.IsZero() {
-		query = fmt.Sprintf("MATCH (p:EntityTag {entity_id: '%s'}) WHERE p.updated_at >= localDateTime('%s') RETURN p", entity.ID, timeToNeo4jTime(since))
+	return neo.getEntityTags(entity, since, time.Time{}, names...)
+}
+
+// GetEntityTagsBetween finds all tags for the entity with the specified names and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no names are specified, all tags for the specified entity within the range are returned.
+func (neo *neoRepository) GetEntityTagsBetween(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
 	}
+	return neo.getEntityTags(entity, start, end, names...)
+}
+
+func (neo *neoRepository) getEntityTags(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error) {
+	query := fmt.Sprintf("MATCH (p:EntityTag {entity_id: '%s'})%s RETURN p", entity.ID, lastSeenBetween("p", start, end))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, nil,
+	result, err := executeQuery(ctx, neo, query, nil,
 		neo4jdb.EagerResultTransformer,
 		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
 	)
@@ -237,7 +516,7 @@ func (neo *neoRepository) GetEntityTags(entity *types.Entity, since time.Time, n
 		return nil, err
 	}
 	if len(result.Records) == 0 {
-		return nil, errors.New("no entity tags found")
+		return []*types.EntityTag{}, neo.opts.NoResults("no entity tags found")
 	}
 
 	var results []*types.EntityTag
@@ -250,7 +529,7 @@ func (neo *neoRepository) GetEntityTags(entity *types.Entity, since time.Time, n
 			continue
 		}
 
-		tag, err := nodeToEntityTag(node)
+		tag, err := neo.toEntityTag(node)
 		if err != nil {
 			continue
 		}
@@ -274,11 +553,177 @@ func (neo *neoRepository) GetEntityTags(entity *types.Entity, since time.Time, n
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero tags found")
+		return []*types.EntityTag{}, neo.opts.NoResults("zero tags found")
+	}
+	return results, nil
+}
+
+// GetEntityTagsByType finds all tags for the entity with the specified property types and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no property types are specified, all tags for the specified entity are returned.
+func (neo *neoRepository) GetEntityTagsByType(entity *types.Entity, since time.Time, ptypes ...oam.PropertyType) ([]*types.EntityTag, error) {
+	var conds []string
+	params := map[string]interface{}{"id": entity.ID}
+
+	if len(ptypes) > 0 {
+		values := make([]string, 0, len(ptypes))
+		for _, ptype := range ptypes {
+			values = append(values, string(ptype))
+		}
+
+		conds = append(conds, "p.ttype IN $ptypes")
+		params["ptypes"] = values
+	}
+	if !since.IsZero() {
+		conds = append(conds, fmt.Sprintf("p.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+
+	query := "MATCH (p:EntityTag {entity_id: $id})"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " RETURN p"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, query, params,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil || isnil {
+			continue
+		}
+
+		tag, err := neo.toEntityTag(node)
+		if err != nil {
+			continue
+		}
+		results = append(results, tag)
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, neo.opts.NoResults("no entity tags found")
 	}
 	return results, nil
 }
 
+// CountEntityTags counts the tags for the entity with the specified names and last seen after the since parameter,
+// without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified entity are counted.
+func (neo *neoRepository) CountEntityTags(entity *types.Entity, since time.Time, names ...string) (int64, error) {
+	var conds []string
+	params := map[string]interface{}{"id": entity.ID}
+
+	if len(names) > 0 {
+		conds = append(conds, propertyNameField("p")+" IN $names")
+		params["names"] = names
+	}
+	if !since.IsZero() {
+		conds = append(conds, fmt.Sprintf("p.updated_at >= localDateTime('%s')", timeToNeo4jTime(since)))
+	}
+
+	query := "MATCH (p:EntityTag {entity_id: $id})"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " RETURN count(p) AS total"
+
+	return neo.count(query, params)
+}
+
+// propertyNameField returns the expression that selects the node property holding the value returned by the
+// Property Name method, according to the property type of the tag.
+func propertyNameField(varname string) string {
+	return fmt.Sprintf("CASE %[1]s.ttype WHEN '%[2]s' THEN %[1]s.property_name WHEN '%[3]s' THEN %[1]s.name WHEN '%[4]s' THEN %[1]s.vuln_id END",
+		varname, oam.SimpleProperty, oam.SourceProperty, oam.VulnProperty)
+}
+
+// propertyValueField returns the expression that selects the node property holding the value returned by the
+// Property Value method, according to the property type of the tag, as a string.
+func propertyValueField(varname string) string {
+	return fmt.Sprintf("toString(CASE %[1]s.ttype WHEN '%[2]s' THEN %[1]s.property_value WHEN '%[3]s' THEN %[1]s.confidence WHEN '%[4]s' THEN %[1]s.desc END)",
+		varname, oam.SimpleProperty, oam.SourceProperty, oam.VulnProperty)
+}
+
+// EntityTagTimeline finds all tags for the entity with the provided ID, ordered chronologically by the time each tag
+// was created and then by the time it was last updated.
+// Returns the entity tags as []*types.EntityTag or an error if the search fails.
+func (neo *neoRepository) EntityTagTimeline(id string) ([]*types.EntityTag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo,
+		"MATCH (p:EntityTag {entity_id: $eid}) RETURN p ORDER BY p.created_at, p.updated_at",
+		map[string]interface{}{
+			"eid": id,
+		},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil || isnil {
+			continue
+		}
+
+		tag, err := neo.toEntityTag(node)
+		if err != nil {
+			continue
+		}
+		results = append(results, tag)
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, neo.opts.NoResults("zero tags found")
+	}
+	return results, nil
+}
+
+// ExistingEntityTags returns the subset of the provided properties that are already present as tags on the
+// entity, matched by name and value. The tags of the entity are retrieved with a single query.
+// Returns an empty slice when none of the properties are present.
+func (neo *neoRepository) ExistingEntityTags(entity *types.Entity, props []oam.Property) ([]oam.Property, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo,
+		"MATCH (p:EntityTag {entity_id: $eid}) RETURN p",
+		map[string]interface{}{"eid": entity.ID},
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []*types.EntityTag
+	for _, record := range result.Records {
+		node, isnil, err := neo4jdb.GetRecordValue[neo4jdb.Node](record, "p")
+		if err != nil || isnil {
+			continue
+		}
+
+		if tag, err := neo.toEntityTag(node); err == nil {
+			existing = append(existing, tag)
+		}
+	}
+	return types.ExistingProperties(existing, props), nil
+}
+
 // DeleteEntityTag removes an entity tag in the database by its ID.
 // It takes a string representing the entity tag ID and removes the corresponding tag from the database.
 // Returns an error if the tag is not found.
@@ -286,7 +731,7 @@ func (neo *neoRepository) DeleteEntityTag(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	_, err := neo4jdb.ExecuteQuery(ctx, neo.db,
+	_, err := executeQuery(ctx, neo,
 		"MATCH (n:EntityTag {tag_id: $tid}) DETACH DELETE n",
 		map[string]interface{}{
 			"tid": id,
@@ -297,3 +742,46 @@ func (neo *neoRepository) DeleteEntityTag(id string) error {
 
 	return err
 }
+
+// DeleteEntityTagsByNameGlobal removes all entity tags in the database with the specified property name.
+// The matching tags are located and removed in batches, regardless of the entity they are attached to.
+// Returns the number of entity tags that were removed or an error if the deletion fails.
+func (neo *neoRepository) DeleteEntityTagsByNameGlobal(name string) (int64, error) {
+	return neo.deleteTagsByName("EntityTag", name)
+}
+
+// deleteTagsByName removes, in batches, all tag nodes with the provided label and property name.
+func (neo *neoRepository) deleteTagsByName(label, name string) (int64, error) {
+	query := fmt.Sprintf("MATCH (p:%s) WHERE p.property_name = $name OR p.name = $name OR p.vuln_id = $name "+
+		"WITH p LIMIT $limit DETACH DELETE p RETURN count(*) AS deleted", label)
+
+	var count int64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		result, err := executeQuery(ctx, neo, query,
+			map[string]interface{}{
+				"name":  name,
+				"limit": int64(neo.batchSize()),
+			},
+			neo4jdb.EagerResultTransformer,
+			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+		)
+		cancel()
+		if err != nil {
+			return count, err
+		}
+		if len(result.Records) == 0 {
+			break
+		}
+
+		deleted, isnil, err := neo4jdb.GetRecordValue[int64](result.Records[0], "deleted")
+		if err != nil {
+			return count, err
+		}
+		if isnil || deleted == 0 {
+			break
+		}
+		count += deleted
+	}
+	return count, nil
+}
@@ -161,3 +161,17 @@ func TestDeleteEntity(t *testing.T) {
 	_, err = store.FindEntityById(entity.ID)
 	assert.Error(t, err)
 }
+
+func TestParentNetblock(t *testing.T) {
+	parent, err := store.CreateAsset(&oamnet.Netblock{CIDR: netip.MustParsePrefix("10.78.0.0/16"), Type: "IPv4"})
+	assert.NoError(t, err)
+	child, err := store.CreateAsset(&oamnet.Netblock{CIDR: netip.MustParsePrefix("10.78.1.0/24"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	p, err := store.ParentNetblock(child, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, parent.ID, p.ID)
+
+	_, err = store.ParentNetblock(parent, time.Time{})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+}
@@ -6,7 +6,6 @@ package neo4j
 
 import (
 	"errors"
-	"strings"
 
 	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/owasp-amass/asset-db/types"
@@ -81,7 +80,7 @@ func relationshipToBasicDNSRelation(rel neo4jdb.Relationship) (*relation.BasicDN
 	ttl := int(num)
 
 	return &relation.BasicDNSRelation{
-		Name: strings.ToLower(rel.Type),
+		Name: relationLabel(rel.Type),
 		Header: relation.RRHeader{
 			RRType: rrtype,
 			Class:  class,
@@ -103,7 +102,7 @@ func relationshipToPortRelation(rel neo4jdb.Relationship) (*relation.PortRelatio
 	}
 
 	return &relation.PortRelation{
-		Name:       strings.ToLower(rel.Type),
+		Name:       relationLabel(rel.Type),
 		PortNumber: port,
 		Protocol:   protocol,
 	}, nil
@@ -135,7 +134,7 @@ func relationshipToPrefDNSRelation(rel neo4jdb.Relationship) (*relation.PrefDNSR
 	pref := int(num)
 
 	return &relation.PrefDNSRelation{
-		Name: strings.ToLower(rel.Type),
+		Name: relationLabel(rel.Type),
 		Header: relation.RRHeader{
 			RRType: rrtype,
 			Class:  class,
@@ -147,7 +146,7 @@ func relationshipToPrefDNSRelation(rel neo4jdb.Relationship) (*relation.PrefDNSR
 
 func relationshipToSimpleRelation(rel neo4jdb.Relationship) (*relation.SimpleRelation, error) {
 	return &relation.SimpleRelation{
-		Name: strings.ToLower(rel.Type),
+		Name: relationLabel(rel.Type),
 	}, nil
 }
 
@@ -189,7 +188,7 @@ func relationshipToSRVDNSRelation(rel neo4jdb.Relationship) (*relation.SRVDNSRel
 	port := int(num)
 
 	return &relation.SRVDNSRelation{
-		Name: strings.ToLower(rel.Type),
+		Name: relationLabel(rel.Type),
 		Header: relation.RRHeader{
 			RRType: rrtype,
 			Class:  class,
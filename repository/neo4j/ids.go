@@ -0,0 +1,63 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// maxIDAttempts is the number of generated IDs tried by a create before a uniqueness constraint violation is returned.
+const maxIDAttempts int = 3
+
+// newID returns a random UUID. When the unique ID check is enabled, another UUID is generated while exists
+// reports that the ID is already in use.
+func (neo *neoRepository) newID(exists func(id string) bool) string {
+	id := uuid.New().String()
+
+	for neo.opts.CheckUniqueIDs && exists(id) {
+		id = uuid.New().String()
+	}
+	return id
+}
+
+// createNode executes the query that creates a node from the props. When the ID of the node was generated,
+// a violation of the uniqueness constraint on the ID property is resolved by generating another ID and
+// executing the query again.
+func (neo *neoRepository) createNode(query string, props map[string]interface{}, idprop string, generated bool, newID func() string) (*neo4jdb.EagerResult, error) {
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		result, err := executeQuery(ctx, neo, query,
+			map[string]interface{}{"props": props},
+			neo4jdb.EagerResultTransformer,
+			neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+		)
+		cancel()
+
+		if err == nil || !generated || attempt >= maxIDAttempts || !isIDConstraintViolation(err, idprop) {
+			return result, err
+		}
+		props[idprop] = newID()
+	}
+}
+
+// isIDConstraintViolation returns true if the error was produced by the uniqueness constraint on the ID property.
+func isIDConstraintViolation(err error, idprop string) bool {
+	var nerr *neo4jdb.Neo4jError
+
+	return isConstraintViolation(err) && errors.As(err, &nerr) && strings.Contains(nerr.Msg, idprop)
+}
+
+// isConstraintViolation returns true if the error was produced by a constraint of the schema.
+func isConstraintViolation(err error) bool {
+	var nerr *neo4jdb.Neo4jError
+
+	return errors.As(err, &nerr) && nerr.Code == "Neo.ClientError.Schema.ConstraintValidationFailed"
+}
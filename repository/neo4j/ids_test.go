@@ -0,0 +1,40 @@
+//go:build integration
+
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"testing"
+
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueIDCheck(t *testing.T) {
+	var checks int
+	exists := func(id string) bool {
+		checks++
+		return false
+	}
+
+	// the check is disabled by default, so no query is issued before the ID is used
+	assert.NotEmpty(t, store.newID(exists))
+	assert.Equal(t, 0, checks)
+
+	checked := &neoRepository{db: store.db, dbname: store.dbname, opts: options.New(options.WithUniqueIDCheck())}
+	assert.NotEmpty(t, checked.newID(exists))
+	assert.Equal(t, 1, checks)
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "uniqueid.owasp.org"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, entity.ID)
+
+	// an ID provided by the caller that is already in use is not replaced
+	_, err = store.CreateEntity(&types.Entity{ID: entity.ID, Asset: &domain.FQDN{Name: "uniqueid2.owasp.org"}})
+	assert.Error(t, err)
+}
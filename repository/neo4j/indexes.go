@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	neomigrations "github.com/owasp-amass/asset-db/migrations/neo4j"
+)
+
+// EnsureIndexes creates the indexes and constraints of the schema that are missing from the database.
+// This applies the indexing to a database that predates the schema changes that introduced the indexes.
+// Neo4j maintains the statistics used by the query planner, so they are not updated.
+// Returns the names of the indexes that were created, or an error if the creation fails.
+func (neo *neoRepository) EnsureIndexes() ([]string, error) {
+	before, err := neo.indexNames()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := neomigrations.InitializeSchema(neo.db, neo.dbname); err != nil {
+		return nil, err
+	}
+
+	after, err := neo.indexNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for name := range after {
+		if _, found := before[name]; !found {
+			created = append(created, name)
+		}
+	}
+	sort.Strings(created)
+	return created, nil
+}
+
+// indexNames returns the names of the indexes in the database, including the indexes backing the constraints.
+func (neo *neoRepository) indexNames() (map[string]struct{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := executeQuery(ctx, neo, "SHOW INDEXES YIELD name RETURN name", nil,
+		neo4jdb.EagerResultTransformer,
+		neo4jdb.ExecuteQueryWithDatabase(neo.dbname),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(result.Records))
+	for _, record := range result.Records {
+		if name, isnil, err := neo4jdb.GetRecordValue[string](record, "name"); err == nil && !isnil {
+			names[name] = struct{}{}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,39 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import "strings"
+
+// The graph follows the casing conventions of Cypher, so external queries can match the stored data:
+//   - an entity node has the Entity label and the asset type as a label, such as FQDN or IPAddress
+//   - a tag node has the EntityTag or EdgeTag label and the property type as a label, such as SimpleProperty
+//   - a relationship has the relation label in upper case as the type, such as DNS_RECORD for dns_record
+//
+// For example, MATCH (:FQDN)-[:DNS_RECORD]->(:IPAddress) matches the dns_record edges from FQDNs to IP addresses.
+
+// relationshipType returns the type of the relationship that stores an edge with the relation label.
+func relationshipType(label string) string {
+	return strings.ToUpper(label)
+}
+
+// relationLabel returns the relation label of the edge stored by a relationship with the type.
+func relationLabel(rtype string) string {
+	return strings.ToLower(rtype)
+}
+
+// matchesRelationshipType returns true if no labels are provided, or if the relationship type stores one of the labels.
+// The labels are matched regardless of their case.
+func matchesRelationshipType(rtype string, labels []string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+
+	for _, label := range labels {
+		if relationshipType(label) == rtype {
+			return true
+		}
+	}
+	return false
+}
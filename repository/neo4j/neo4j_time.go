@@ -5,6 +5,8 @@
 package neo4j
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j/dbtype"
@@ -21,3 +23,20 @@ func timeToNeo4jTime(t time.Time) dbtype.LocalDateTime {
 	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
 	return dbtype.LocalDateTime(t)
 }
+
+// lastSeenBetween returns the WHERE clause that limits the variable to the nodes or relationships last seen
+// between start and end, inclusive. A zero start or end leaves the range without that bound.
+func lastSeenBetween(v string, start, end time.Time) string {
+	var conds []string
+	if !start.IsZero() {
+		conds = append(conds, fmt.Sprintf("%s.updated_at >= localDateTime('%s')", v, timeToNeo4jTime(start)))
+	}
+	if !end.IsZero() {
+		conds = append(conds, fmt.Sprintf("%s.updated_at <= localDateTime('%s')", v, timeToNeo4jTime(end)))
+	}
+
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
@@ -0,0 +1,79 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	neo4jdb "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// ConnectionStatus describes the health of the connection to the neo4j database.
+type ConnectionStatus struct {
+	// LastSuccess is when an operation last completed without an error. It is zero if none has.
+	LastSuccess time.Time
+	// LastError is the error returned by the most recent failed operation, or nil if none has failed.
+	LastError error
+	// LastErrorAt is when the most recent failed operation returned its error.
+	LastErrorAt time.Time
+	// Connected reports whether the driver verified connectivity to the database.
+	Connected bool
+}
+
+// connStatus records the outcome of the operations executed against the database.
+type connStatus struct {
+	sync.Mutex
+	lastSuccess time.Time
+	lastError   error
+	lastErrorAt time.Time
+}
+
+// record updates the status with the outcome of an operation that completed at the provided time.
+func (s *connStatus) record(err error, at time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err != nil {
+		s.lastError = err
+		s.lastErrorAt = at
+		return
+	}
+	s.lastSuccess = at
+}
+
+// snapshot returns the current status, including whether connectivity was verified.
+func (s *connStatus) snapshot(connected bool) ConnectionStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	return ConnectionStatus{
+		LastSuccess: s.lastSuccess,
+		LastError:   s.lastError,
+		LastErrorAt: s.lastErrorAt,
+		Connected:   connected,
+	}
+}
+
+// ConnectionStatus reports the time of the last successful operation, the last error, and whether
+// the driver currently verifies connectivity to the database. No query is executed.
+func (neo *neoRepository) ConnectionStatus() ConnectionStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := neo.db.VerifyConnectivity(ctx)
+	neo.status.record(err, time.Now())
+	return neo.status.snapshot(err == nil)
+}
+
+// executeQuery executes the query using the driver of the repository and records the outcome in the connection status.
+func executeQuery[T any](ctx context.Context, neo *neoRepository, query string, params map[string]any,
+	newResultTransformer func() neo4jdb.ResultTransformer[T], settings ...neo4jdb.ExecuteQueryConfigurationOption) (T, error) {
+	result, err := neo4jdb.ExecuteQuery(ctx, neo.db, query, params, newResultTransformer, settings...)
+
+	neo.status.record(err, time.Now())
+	return result, err
+}
@@ -0,0 +1,46 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package neo4j
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnStatus(t *testing.T) {
+	var s connStatus
+
+	status := s.snapshot(false)
+	assert.True(t, status.LastSuccess.IsZero())
+	assert.NoError(t, status.LastError)
+	assert.False(t, status.Connected)
+
+	success := time.Now()
+	s.record(nil, success)
+	status = s.snapshot(true)
+	assert.Equal(t, success, status.LastSuccess)
+	assert.NoError(t, status.LastError)
+	assert.True(t, status.Connected)
+
+	forced := errors.New("connection refused")
+	failed := success.Add(time.Second)
+	s.record(forced, failed)
+	status = s.snapshot(false)
+	assert.Equal(t, success, status.LastSuccess)
+	assert.ErrorIs(t, status.LastError, forced)
+	assert.Equal(t, failed, status.LastErrorAt)
+	assert.False(t, status.Connected)
+
+	// a later success does not clear the last error
+	recovered := failed.Add(time.Second)
+	s.record(nil, recovered)
+	status = s.snapshot(true)
+	assert.Equal(t, recovered, status.LastSuccess)
+	assert.ErrorIs(t, status.LastError, forced)
+	assert.Equal(t, failed, status.LastErrorAt)
+}
@@ -0,0 +1,340 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"gorm.io/gorm/logger"
+)
+
+// EdgeDedupMode determines how a new edge is compared against the existing edges
+// between the same two entities to decide whether it is a duplicate.
+type EdgeDedupMode int
+
+const (
+	// EdgeDedupRelation considers an edge a duplicate only when the full relation content is equal.
+	// This allows intentional parallel edges, such as two different SRV records, to be stored.
+	EdgeDedupRelation EdgeDedupMode = iota
+	// EdgeDedupLabel considers an edge a duplicate when the relation label is equal,
+	// collapsing all edges with the same label between two entities.
+	EdgeDedupLabel
+)
+
+// UnknownTypePolicy determines how stored content with an unrecognized asset or property type is returned by reads.
+type UnknownTypePolicy int
+
+const (
+	// UnknownTypeSkip leaves the content out of the results and logs a warning.
+	UnknownTypeSkip UnknownTypePolicy = iota
+	// UnknownTypeError fails the read with a types.UnknownTypeError.
+	UnknownTypeError
+	// UnknownTypeWrap returns the content as a types.UnknownAsset or types.UnknownProperty,
+	// preserving the raw content for the caller.
+	UnknownTypeWrap
+)
+
+// MissingEndpointPolicy determines how a new edge is handled when an entity at either end does not exist.
+type MissingEndpointPolicy int
+
+const (
+	// MissingEndpointError fails the creation of the edge with an error wrapping types.ErrEndpointNotFound.
+	MissingEndpointError MissingEndpointPolicy = iota
+	// MissingEndpointCreate creates the missing entity from the asset of the edge endpoint before the edge is created.
+	MissingEndpointCreate
+)
+
+// Options holds the settings applied to a repository when it is created.
+type Options struct {
+	EdgeDedup EdgeDedupMode
+	// BatchSize is the number of rows processed by each statement during bulk operations.
+	// When zero, each backend uses a safe default for its database dialect.
+	BatchSize int
+	// ValidateContent enables checking the required fields of each asset before it is written.
+	ValidateContent bool
+	// LastSeenWindow is the minimum interval between updates of an entity last seen time.
+	// Re-observations of an unchanged entity within the window do not update the database.
+	LastSeenWindow time.Duration
+	// RunID identifies the scan run that writes to the repository. When set, the entities and edges
+	// written by the repository are stamped with it, so the output of the run can be queried later.
+	RunID string
+	// UnknownTypes determines how content with an unrecognized asset or property type is returned by reads.
+	UnknownTypes UnknownTypePolicy
+	// Logger receives the warnings produced by the repository. When nil, the warnings are discarded.
+	Logger *slog.Logger
+	// TablePrefix is prepended to the names of the tables used by the SQL repository, which allows
+	// several deployments to share a database. The prefixed tables must already exist, with the columns
+	// created by the latest migrations, such as the deleted_at column read by every query.
+	TablePrefix string
+	// SQLLogger receives the slow statements and errors reported by the ORM of the SQL repository.
+	// When nil, the ORM output is discarded.
+	SQLLogger *slog.Logger
+	// SQLLogLevel is the level of the output of the ORM of the SQL repository, such as logger.Info to log every
+	// statement with its timing. When zero, the output is discarded, or only the slow statements and errors are
+	// sent to the SQLLogger when one is configured.
+	SQLLogLevel logger.LogLevel
+	// HealthCheckInterval is how often the postgres repository pings the database, and the time after which
+	// an idle pooled connection is closed, so connections killed by the server or a proxy are recycled.
+	// When zero, the health check is disabled.
+	HealthCheckInterval time.Duration
+	// MaxOpenConns is the maximum number of open connections to the database of the SQL repository.
+	// When zero, the default of the backend is used: 10 for postgres, 3 for a sqlite file and 50 for an
+	// in-memory sqlite database.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept by the pool of the SQL repository.
+	// When zero, the default of the backend is used: 5 for postgres and a sqlite file, and 100 for an
+	// in-memory sqlite database.
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum time a connection of the SQL repository is reused. When zero,
+	// the connections are recycled after an hour.
+	ConnMaxLifetime time.Duration
+	// CheckUniqueIDs enables querying the neo4j database for each generated ID before it is used.
+	// When disabled, the uniqueness constraints detect the rare collision and another ID is generated.
+	CheckUniqueIDs bool
+	// MissingEndpoints determines how a new edge is handled when an entity at either end does not exist.
+	MissingEndpoints MissingEndpointPolicy
+	// CanonicalContent enables storing the content of the SQL repository in canonical JSON, with sorted keys
+	// and empty fields left out, so equal assets, relations and properties produce byte-identical content.
+	CanonicalContent bool
+	// CheckpointOnClose enables checkpointing the write-ahead log of the sqlite database file into the database
+	// and truncating the log when the repository is closed.
+	CheckpointOnClose bool
+	// VacuumOnClose enables rebuilding the sqlite database file when the repository is closed, which returns
+	// the space of the deleted rows to the file system.
+	VacuumOnClose bool
+	// EmptyResults enables returning an empty slice or map and a nil error from the methods that find a list of
+	// results when nothing matches, instead of an error. Errors are then reserved for failures.
+	EmptyResults bool
+	// SoftDelete enables marking the deleted records of the SQL repository with a deletion time instead of
+	// removing the rows. The marked records are left out of the results, and can be recovered by their IDs.
+	SoftDelete bool
+}
+
+// Option is a function that modifies the repository Options.
+type Option func(*Options)
+
+// New returns the Options with the defaults set and the provided Option functions applied.
+func New(opts ...Option) *Options {
+	o := &Options{
+		EdgeDedup: EdgeDedupRelation,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// WithEdgeDedup sets the mode used to identify duplicate edges.
+func WithEdgeDedup(mode EdgeDedupMode) Option {
+	return func(o *Options) {
+		o.EdgeDedup = mode
+	}
+}
+
+// WithBatchSize sets the number of rows processed by each statement during bulk operations.
+func WithBatchSize(size int) Option {
+	return func(o *Options) {
+		o.BatchSize = size
+	}
+}
+
+// WithContentValidation enables the rejection of assets that are missing required fields or have malformed values.
+func WithContentValidation() Option {
+	return func(o *Options) {
+		o.ValidateContent = true
+	}
+}
+
+// WithLastSeenWindow sets the minimum interval between updates of an entity last seen time.
+func WithLastSeenWindow(window time.Duration) Option {
+	return func(o *Options) {
+		o.LastSeenWindow = window
+	}
+}
+
+// WithRunID sets the identifier of the scan run that is stamped on the entities and edges written.
+func WithRunID(id string) Option {
+	return func(o *Options) {
+		o.RunID = id
+	}
+}
+
+// WithUnknownTypePolicy sets how content with an unrecognized asset or property type is returned by reads.
+func WithUnknownTypePolicy(policy UnknownTypePolicy) Option {
+	return func(o *Options) {
+		o.UnknownTypes = policy
+	}
+}
+
+// WithLogger sets the logger that receives the warnings produced by the repository.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithTablePrefix sets the prefix prepended to the names of the tables used by the SQL repository.
+func WithTablePrefix(prefix string) Option {
+	return func(o *Options) {
+		o.TablePrefix = prefix
+	}
+}
+
+// WithSQLLogger sets the logger that receives the slow statements and errors reported by the ORM of the SQL repository.
+func WithSQLLogger(logger *slog.Logger) Option {
+	return func(o *Options) {
+		o.SQLLogger = logger
+	}
+}
+
+// WithLogLevel sets the level of the output of the ORM of the SQL repository.
+func WithLogLevel(level logger.LogLevel) Option {
+	return func(o *Options) {
+		o.SQLLogLevel = level
+	}
+}
+
+// WithHealthCheck sets how often the postgres repository validates and recycles its pooled connections.
+func WithHealthCheck(interval time.Duration) Option {
+	return func(o *Options) {
+		o.HealthCheckInterval = interval
+	}
+}
+
+// WithMaxOpenConns sets the maximum number of open connections to the database of the SQL repository.
+// Under heavy parallel enumeration, the postgres limit should stay below the max_connections of the server
+// divided by the number of processes sharing it.
+func WithMaxOpenConns(n int) Option {
+	return func(o *Options) {
+		o.MaxOpenConns = n
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections kept by the pool of the SQL repository.
+// A value close to the open connection limit avoids reconnecting between bursts of queries.
+func WithMaxIdleConns(n int) Option {
+	return func(o *Options) {
+		o.MaxIdleConns = n
+	}
+}
+
+// WithConnMaxLifetime sets the maximum time a connection of the SQL repository is reused.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnMaxLifetime = d
+	}
+}
+
+// WithUniqueIDCheck enables querying the neo4j database for each generated ID before it is used.
+func WithUniqueIDCheck() Option {
+	return func(o *Options) {
+		o.CheckUniqueIDs = true
+	}
+}
+
+// WithMissingEndpointPolicy sets how a new edge is handled when an entity at either end does not exist.
+func WithMissingEndpointPolicy(policy MissingEndpointPolicy) Option {
+	return func(o *Options) {
+		o.MissingEndpoints = policy
+	}
+}
+
+// WithCanonicalContent enables storing the content of the SQL repository in canonical JSON.
+func WithCanonicalContent() Option {
+	return func(o *Options) {
+		o.CanonicalContent = true
+	}
+}
+
+// WithCheckpointOnClose enables checkpointing and truncating the write-ahead log of the sqlite database on close.
+func WithCheckpointOnClose() Option {
+	return func(o *Options) {
+		o.CheckpointOnClose = true
+	}
+}
+
+// WithVacuumOnClose enables rebuilding the sqlite database file on close.
+func WithVacuumOnClose() Option {
+	return func(o *Options) {
+		o.VacuumOnClose = true
+	}
+}
+
+// WithEmptyResults enables returning an empty result instead of an error when a list method finds nothing.
+func WithEmptyResults() Option {
+	return func(o *Options) {
+		o.EmptyResults = true
+	}
+}
+
+// WithSoftDelete enables marking the records deleted from the SQL repository instead of removing the rows.
+func WithSoftDelete() Option {
+	return func(o *Options) {
+		o.SoftDelete = true
+	}
+}
+
+// Log returns the configured Logger, or a logger that discards the messages when none is configured.
+func (o *Options) Log() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return o.Logger
+}
+
+// NoResults returns the error reported by a list method that finds nothing, or nil when EmptyResults is enabled.
+// The error wraps types.ErrNoResults.
+func (o *Options) NoResults(msg string) error {
+	if o != nil && o.EmptyResults {
+		return nil
+	}
+	return types.NoResults(msg)
+}
+
+// SkipLastSeenUpdate reports whether an entity last seen at the provided time is still within the LastSeenWindow.
+func (o *Options) SkipLastSeenUpdate(last time.Time) bool {
+	return o.LastSeenWindow > 0 && time.Since(last) < o.LastSeenWindow
+}
+
+// DuplicateRelations reports whether the two relations identify the same edge under the configured EdgeDedupMode.
+// The full relations are compared by their type and canonical JSON content, so a relation held by value
+// matches the same relation held by pointer, as returned when the edge is read back from a repository.
+func (o *Options) DuplicateRelations(r1, r2 oam.Relation) bool {
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	if o.EdgeDedup == EdgeDedupLabel {
+		return r1.Label() == r2.Label()
+	}
+	if r1.RelationType() != r2.RelationType() {
+		return false
+	}
+
+	c1, err := canonicalRelation(r1)
+	if err != nil {
+		return false
+	}
+	c2, err := canonicalRelation(r2)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(c1, c2)
+}
+
+func canonicalRelation(rel oam.Relation) ([]byte, error) {
+	content, err := rel.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return types.CanonicalJSON(content)
+}
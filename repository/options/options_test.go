@@ -0,0 +1,67 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/logger"
+)
+
+func TestDefaults(t *testing.T) {
+	o := New()
+	assert.Equal(t, EdgeDedupRelation, o.EdgeDedup)
+	assert.Equal(t, 0, o.BatchSize)
+
+	assert.False(t, o.ValidateContent)
+	assert.Empty(t, o.RunID)
+	assert.Equal(t, UnknownTypeSkip, o.UnknownTypes)
+	assert.NotNil(t, o.Log())
+	assert.Equal(t, logger.LogLevel(0), o.SQLLogLevel)
+
+	o = New(WithBatchSize(500), WithContentValidation(), WithRunID("run-1"), WithUnknownTypePolicy(UnknownTypeWrap))
+	assert.Equal(t, 500, o.BatchSize)
+	assert.True(t, o.ValidateContent)
+	assert.Equal(t, "run-1", o.RunID)
+	assert.Equal(t, UnknownTypeWrap, o.UnknownTypes)
+
+	o = New(WithLogLevel(logger.Info))
+	assert.Equal(t, logger.Info, o.SQLLogLevel)
+
+	o = New(WithMaxOpenConns(20), WithMaxIdleConns(10), WithConnMaxLifetime(30*time.Minute))
+	assert.Equal(t, 20, o.MaxOpenConns)
+	assert.Equal(t, 10, o.MaxIdleConns)
+	assert.Equal(t, 30*time.Minute, o.ConnMaxLifetime)
+}
+
+func TestDuplicateRelations(t *testing.T) {
+	r1 := &relation.SRVDNSRelation{Name: "dns_record", Priority: 10, Weight: 60, Port: 5060}
+	r2 := &relation.SRVDNSRelation{Name: "dns_record", Priority: 20, Weight: 40, Port: 5061}
+
+	o := New()
+	assert.True(t, o.DuplicateRelations(r1, r1))
+	assert.False(t, o.DuplicateRelations(r1, r2))
+	assert.False(t, o.DuplicateRelations(r1, nil))
+	// a relation held by value is the same relation as the one read back by pointer
+	assert.True(t, o.DuplicateRelations(relation.SimpleRelation{Name: "node"}, &relation.SimpleRelation{Name: "node"}))
+	assert.False(t, o.DuplicateRelations(relation.SimpleRelation{Name: "node"}, &relation.SimpleRelation{Name: "contains"}))
+
+	o = New(WithEdgeDedup(EdgeDedupLabel))
+	assert.True(t, o.DuplicateRelations(r1, r2))
+	assert.False(t, o.DuplicateRelations(r1, &relation.SimpleRelation{Name: "node"}))
+}
+
+func TestNoResults(t *testing.T) {
+	assert.EqualError(t, New().NoResults("zero entities found"), "zero entities found")
+	assert.ErrorIs(t, New().NoResults("zero entities found"), types.ErrNoResults)
+	assert.NoError(t, New(WithEmptyResults()).NoResults("zero entities found"))
+
+	var o *Options
+	assert.Error(t, o.NoResults("zero entities found"))
+}
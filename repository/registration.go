@@ -0,0 +1,105 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/relation"
+)
+
+// RegistrationLookup returns the registration record of the asset, such as the DomainRecord of an FQDN or the
+// IPNetRecord of a Netblock, or nil when no registration record is known for the asset.
+type RegistrationLookup func(asset oam.Asset) (oam.Asset, error)
+
+// RegistrationLinker is a Repository wrapper that links each entity it creates to the entity of its registration
+// record with a registration edge. The record is obtained from the lookup function, and the edge is only created
+// when the entity of the record exists in the wrapped repository and the taxonomy permits the registration edge.
+// Methods that do not create entities are passed to the wrapped repository.
+type RegistrationLinker struct {
+	Repository
+	lookup RegistrationLookup
+}
+
+// NewRegistrationLinker returns a RegistrationLinker wrapping the repository that uses the lookup function
+// to find the registration records of the created entities.
+func NewRegistrationLinker(repo Repository, lookup RegistrationLookup) *RegistrationLinker {
+	return &RegistrationLinker{
+		Repository: repo,
+		lookup:     lookup,
+	}
+}
+
+// CreateEntity implements the Repository interface.
+// When the registration edge cannot be created, the created entity is returned along with the error.
+func (r *RegistrationLinker) CreateEntity(entity *types.Entity) (*types.Entity, error) {
+	e, err := r.Repository.CreateEntity(entity)
+	if err != nil {
+		return nil, err
+	}
+	return e, r.link(e)
+}
+
+// CreateEntityWithPrevLastSeen implements the Repository interface.
+// When the registration edge cannot be created, the entity is returned along with the error.
+func (r *RegistrationLinker) CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error) {
+	e, prev, err := r.Repository.CreateEntityWithPrevLastSeen(entity)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return e, prev, r.link(e)
+}
+
+// CreateAsset implements the Repository interface.
+// When the registration edge cannot be created, the created entity is returned along with the error.
+func (r *RegistrationLinker) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	e, err := r.Repository.CreateAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	return e, r.link(e)
+}
+
+// UpsertEntity implements the Repository interface.
+// When the registration edge cannot be created, the entity is returned along with the error.
+func (r *RegistrationLinker) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	e, created, err := r.Repository.UpsertEntity(asset)
+	if err != nil {
+		return nil, false, err
+	}
+	return e, created, r.link(e)
+}
+
+// link creates the registration edge from the entity to the entity of its registration record.
+func (r *RegistrationLinker) link(entity *types.Entity) error {
+	if r.lookup == nil || entity == nil || entity.Asset == nil {
+		return nil
+	}
+
+	record, err := r.lookup(entity.Asset)
+	if err != nil {
+		return fmt.Errorf("failed to look up the registration record of %s: %v", entity.Asset.Key(), err)
+	}
+	if record == nil || !oam.ValidRelationship(entity.Asset.AssetType(),
+		"registration", oam.SimpleRelation, record.AssetType()) {
+		return nil
+	}
+
+	records, err := r.Repository.FindEntitiesByContent(record, time.Time{})
+	if err != nil || len(records) == 0 {
+		// the entity of the registration record has not been created
+		return nil
+	}
+
+	_, err = r.Repository.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "registration"},
+		FromEntity: entity,
+		ToEntity:   records[0],
+	})
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	oamreg "github.com/owasp-amass/open-asset-model/registration"
+	"github.com/stretchr/testify/assert"
+)
+
+type linkingRepository struct {
+	Repository
+	entities map[string]*types.Entity
+	edges    []*types.Edge
+}
+
+func (r *linkingRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
+	e := &types.Entity{ID: strconv.Itoa(len(r.entities) + 1), Asset: asset}
+	r.entities[string(asset.AssetType())+":"+asset.Key()] = e
+	return e, nil
+}
+
+func (r *linkingRepository) CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error) {
+	e, err := r.CreateAsset(entity.Asset)
+	return e, time.Time{}, err
+}
+
+func (r *linkingRepository) FindEntitiesByContent(asset oam.Asset, since time.Time) ([]*types.Entity, error) {
+	if e, found := r.entities[string(asset.AssetType())+":"+asset.Key()]; found {
+		return []*types.Entity{e}, nil
+	}
+	return nil, errors.New("zero entities found")
+}
+
+func (r *linkingRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	r.edges = append(r.edges, edge)
+	return edge, nil
+}
+
+func TestRegistrationLinker(t *testing.T) {
+	repo := &linkingRepository{entities: make(map[string]*types.Entity)}
+	linker := NewRegistrationLinker(repo, func(asset oam.Asset) (oam.Asset, error) {
+		if fqdn, ok := asset.(*domain.FQDN); ok {
+			return &oamreg.DomainRecord{Domain: fqdn.Name}, nil
+		}
+		return nil, nil
+	})
+
+	record, err := repo.CreateAsset(&oamreg.DomainRecord{Domain: "owasp.org"})
+	assert.NoError(t, err)
+
+	fqdn, err := linker.CreateAsset(&domain.FQDN{Name: "owasp.org"})
+	assert.NoError(t, err)
+	if assert.Len(t, repo.edges, 1) {
+		assert.Equal(t, "registration", repo.edges[0].Relation.Label())
+		assert.Equal(t, fqdn.ID, repo.edges[0].FromEntity.ID)
+		assert.Equal(t, record.ID, repo.edges[0].ToEntity.ID)
+	}
+
+	// the entities created with the previous last seen time are linked as well
+	again, _, err := linker.CreateEntityWithPrevLastSeen(&types.Entity{Asset: &domain.FQDN{Name: "owasp.org"}})
+	assert.NoError(t, err)
+	if assert.Len(t, repo.edges, 2) {
+		assert.Equal(t, again.ID, repo.edges[1].FromEntity.ID)
+		assert.Equal(t, record.ID, repo.edges[1].ToEntity.ID)
+	}
+	repo.edges = repo.edges[:1]
+
+	// the entity of the registration record does not exist
+	_, err = linker.CreateAsset(&domain.FQDN{Name: "example.org"})
+	assert.NoError(t, err)
+	assert.Len(t, repo.edges, 1)
+
+	// the lookup does not return a registration record
+	_, err = linker.CreateAsset(&oamreg.DomainRecord{Domain: "example.org"})
+	assert.NoError(t, err)
+	assert.Len(t, repo.edges, 1)
+
+	failing := NewRegistrationLinker(repo, func(asset oam.Asset) (oam.Asset, error) {
+		return nil, errors.New("lookup failed")
+	})
+	e, err := failing.CreateAsset(&domain.FQDN{Name: "failing.owasp.org"})
+	assert.Error(t, err)
+	assert.NotNil(t, e)
+}
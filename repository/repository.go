@@ -9,7 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/owasp-amass/asset-db/repository/memrepo"
 	"github.com/owasp-amass/asset-db/repository/neo4j"
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/repository/sqlrepo"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
@@ -19,43 +21,105 @@ import (
 // It provides operations for creating, retrieving, tagging, and linking assets.
 type Repository interface {
 	GetDBType() string
+	ParseErrors() int64
+	EnsureIndexes() ([]string, error)
+	Stats() (types.RepositoryStats, error)
 	CreateEntity(entity *types.Entity) (*types.Entity, error)
+	CreateEntityWithPrevLastSeen(entity *types.Entity) (*types.Entity, time.Time, error)
 	CreateAsset(asset oam.Asset) (*types.Entity, error)
+	UpsertEntity(asset oam.Asset) (*types.Entity, bool, error)
 	FindEntityById(id string) (*types.Entity, error)
 	FindEntitiesByContent(asset oam.Asset, since time.Time) ([]*types.Entity, error)
+	FindEntityByContentLatest(asset oam.Asset) (*types.Entity, error)
+	FindEntitiesByContentFold(asset oam.Asset, since time.Time) ([]*types.Entity, error)
 	FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error)
+	FindEntitiesByTypeBetween(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error)
+	FindStaleEntities(atype oam.AssetType, olderThan time.Duration) ([]*types.Entity, error)
+	NewEntitiesCountByType(since time.Time) (map[oam.AssetType]int64, error)
+	CountEntitiesByType(atype oam.AssetType, since time.Time) (int64, error)
+	ChildNetblocks(parent *types.Entity, since time.Time) ([]*types.Entity, error)
+	ParentNetblock(child *types.Entity, since time.Time) (*types.Entity, error)
+	FindEntitiesByTypePagedWithTotal(atype oam.AssetType, since time.Time, limit, offset int) ([]*types.Entity, int64, error)
+	FindEntitiesByField(atype oam.AssetType, field string, value any, since time.Time) ([]*types.Entity, error)
+	FindEntitiesByFieldRegex(atype oam.AssetType, field, pattern string, since time.Time) ([]*types.Entity, error)
+	FindEntitiesByFieldRange(atype oam.AssetType, field string, min, max any, since time.Time) ([]*types.Entity, error)
+	SearchFQDNs(substr string, since time.Time) ([]*types.Entity, error)
+	SearchEntities(query string, atypes []oam.AssetType, since time.Time) ([]*types.Entity, error)
+	FindServicesByAttribute(key, value string, since time.Time) ([]*types.Entity, error)
+	FindEntitiesByRun(runID string) ([]*types.Entity, error)
+	UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error)
 	DeleteEntity(id string) error
 	CreateEdge(edge *types.Edge) (*types.Edge, error)
+	UpsertEdge(edge *types.Edge) (*types.Edge, bool, error)
+	CreateEdges(edges []*types.Edge) ([]*types.Edge, error)
 	FindEdgeById(id string) (*types.Edge, error)
 	IncomingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error)
 	OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error)
+	IncomingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error)
+	OutgoingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error)
+	CountOutgoingEdges(entity *types.Entity, since time.Time, labels ...string) (int64, error)
+	AdjacentEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error)
+	OutgoingEdgesForEntities(entities []*types.Entity, since time.Time, labels ...string) (map[string][]*types.Edge, error)
+	FindEdgesByRun(runID string) ([]*types.Edge, error)
+	FindHubEntities(minDegree int, direction string, since time.Time) ([]*types.Entity, error)
+	DistinctRelationLabels(since time.Time) ([]string, error)
+	FindEntitiesWithEdgeTo(fromType oam.AssetType, label string, toType oam.AssetType, since time.Time) ([]*types.Entity, error)
+	TraverseNeighborhood(entity *types.Entity, depth int, direction types.Direction, labels ...string) ([]*types.Entity, []*types.Edge, error)
 	DeleteEdge(id string) error
 	CreateEntityTag(entity *types.Entity, tag *types.EntityTag) (*types.EntityTag, error)
 	CreateEntityProperty(entity *types.Entity, property oam.Property) (*types.EntityTag, error)
+	CreateEntityTags(entity *types.Entity, props []oam.Property) ([]*types.EntityTag, error)
 	FindEntityTagById(id string) (*types.EntityTag, error)
 	FindEntityTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, error)
+	FindEntityTagsBySource(source string, since time.Time) ([]*types.EntityTag, error)
+	FindEntityTagsByValuePrefix(name, prefix string, since time.Time) ([]*types.EntityTag, error)
+	FindEntitiesByTags(predicates []types.TagPredicate, combine types.AndOr, since time.Time) ([]*types.Entity, error)
 	GetEntityTags(entity *types.Entity, since time.Time, names ...string) ([]*types.EntityTag, error)
+	GetEntityTagsBetween(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error)
+	GetEntityTagsByType(entity *types.Entity, since time.Time, ptypes ...oam.PropertyType) ([]*types.EntityTag, error)
+	CountEntityTags(entity *types.Entity, since time.Time, names ...string) (int64, error)
+	EntityTagTimeline(id string) ([]*types.EntityTag, error)
+	ExistingEntityTags(entity *types.Entity, props []oam.Property) ([]oam.Property, error)
 	DeleteEntityTag(id string) error
+	DeleteEntityTagsByNameGlobal(name string) (int64, error)
 	CreateEdgeTag(edge *types.Edge, tag *types.EdgeTag) (*types.EdgeTag, error)
 	CreateEdgeProperty(edge *types.Edge, property oam.Property) (*types.EdgeTag, error)
+	CreateEdgeTags(edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error)
+	CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error)
+	CreateEdgeTagsBatch(props map[string][]oam.Property) (map[string][]*types.EdgeTag, error)
 	FindEdgeTagById(id string) (*types.EdgeTag, error)
 	FindEdgeTagsByContent(prop oam.Property, since time.Time) ([]*types.EdgeTag, error)
 	GetEdgeTags(edge *types.Edge, since time.Time, names ...string) ([]*types.EdgeTag, error)
+	GetEdgeTagsBetween(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error)
+	GetEdgeTagsByType(edge *types.Edge, since time.Time, ptypes ...oam.PropertyType) ([]*types.EdgeTag, error)
+	CountEdgeTags(edge *types.Edge, since time.Time, names ...string) (int64, error)
+	FindAllTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, []*types.EdgeTag, error)
 	DeleteEdgeTag(id string) error
+	DeleteEdgeTagsByNameGlobal(name string) (int64, error)
 	Close() error
 }
 
 // New creates a new instance of the asset database repository.
-func New(dbtype, dsn string) (Repository, error) {
+// The provided options are applied to the selected backend. The dsn is ignored by the in-memory repository.
+func New(dbtype, dsn string, opts ...options.Option) (Repository, error) {
 	switch strings.ToLower(dbtype) {
+	case strings.ToLower(memrepo.Memory):
+		return memrepo.New(opts...), nil
 	case strings.ToLower(neo4j.Neo4j):
-		return neo4j.New(dbtype, dsn)
+		return neo4j.New(dbtype, dsn, opts...)
 	case strings.ToLower(sqlrepo.Postgres):
 		fallthrough
 	case strings.ToLower(sqlrepo.SQLite):
 		fallthrough
 	case strings.ToLower(sqlrepo.SQLiteMemory):
-		return sqlrepo.New(dbtype, dsn)
+		return sqlrepo.New(dbtype, dsn, opts...)
 	}
 	return nil, errors.New("unknown DB type")
 }
+
+// Transaction calls fn with a repository whose writes are stored atomically, being committed when fn returns nil
+// and rolled back when fn returns an error. Only the SQL repositories returned by New support transactions,
+// and an error is returned for the other backends and for repository wrappers.
+func Transaction(db Repository, fn func(tx Repository) error) error {
+	return sqlrepo.Transaction(db, fn)
+}
@@ -0,0 +1,340 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package repotest provides a conformance suite for implementations of the repository.Repository interface.
+package repotest
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+// RunRepositoryConformance runs the tests that every implementation of the Repository interface is expected to pass.
+// The newRepo function is called once for each test and must return a repository that is ready for use.
+// The repository is closed when the test completes.
+func RunRepositoryConformance(t *testing.T, newRepo func() repository.Repository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, db repository.Repository)
+	}{
+		{name: "Entities", run: testEntities},
+		{name: "Edges", run: testEdges},
+		{name: "EntityTags", run: testEntityTags},
+		{name: "EdgeTags", run: testEdgeTags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newRepo()
+			if db == nil {
+				t.Fatal("the repository is nil")
+			}
+			defer db.Close()
+
+			tt.run(t, db)
+		})
+	}
+}
+
+func testEntities(t *testing.T, db repository.Repository) {
+	asset := &domain.FQDN{Name: "entities.conformance.owasp.org"}
+
+	entity, err := db.CreateAsset(asset)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, entity.ID)
+
+	found, err := db.FindEntityById(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ID, found.ID)
+	assert.Equal(t, asset.Key(), found.Asset.Key())
+
+	again, err := db.CreateAsset(asset)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ID, again.ID)
+	assert.False(t, again.LastSeen.Before(entity.LastSeen))
+
+	entities, err := db.FindEntitiesByContent(asset, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, entity.ID, entities[0].ID)
+	}
+
+	entities, err = db.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	var ids []string
+	for _, e := range entities {
+		ids = append(ids, e.ID)
+	}
+	assert.Contains(t, ids, entity.ID)
+
+	assert.NoError(t, db.DeleteEntity(entity.ID))
+	_, err = db.FindEntityById(entity.ID)
+	assert.Error(t, err)
+	_, err = db.FindEntitiesByContent(asset, time.Time{})
+	assert.Error(t, err)
+}
+
+func testEdges(t *testing.T, db repository.Repository) {
+	from, err := db.CreateAsset(&domain.FQDN{Name: "edges.conformance.owasp.org"})
+	assert.NoError(t, err)
+
+	to, err := db.CreateAsset(&domain.FQDN{Name: "www.edges.conformance.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := db.CreateEdge(&types.Edge{
+		Relation: &relation.BasicDNSRelation{
+			Name:   "dns_record",
+			Header: relation.RRHeader{RRType: 5},
+		},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, edge.ID)
+
+	found, err := db.FindEdgeById(edge.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "dns_record", found.Relation.Label())
+	assert.Equal(t, from.ID, found.FromEntity.ID)
+	assert.Equal(t, to.ID, found.ToEntity.ID)
+
+	again, err := db.CreateEdge(&types.Edge{
+		Relation: &relation.BasicDNSRelation{
+			Name:   "dns_record",
+			Header: relation.RRHeader{RRType: 5},
+		},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, edge.ID, again.ID)
+
+	_, err = db.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "not_in_taxonomy"},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.Error(t, err)
+
+	outs, err := db.OutgoingEdges(from, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	if assert.Len(t, outs, 1) {
+		assert.Equal(t, to.ID, outs[0].ToEntity.ID)
+	}
+
+	ins, err := db.IncomingEdges(to, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, ins, 1) {
+		assert.Equal(t, from.ID, ins[0].FromEntity.ID)
+	}
+
+	_, err = db.OutgoingEdges(from, time.Time{}, "node")
+	assert.Error(t, err)
+
+	assert.NoError(t, db.DeleteEdge(edge.ID))
+	_, err = db.FindEdgeById(edge.ID)
+	assert.Error(t, err)
+	_, err = db.OutgoingEdges(from, time.Time{})
+	assert.Error(t, err)
+}
+
+func testEntityTags(t *testing.T, db repository.Repository) {
+	entity, err := db.CreateAsset(&domain.FQDN{Name: "entitytags.conformance.owasp.org"})
+	assert.NoError(t, err)
+
+	prop := &property.SimpleProperty{
+		PropertyName:  "conformance",
+		PropertyValue: "entity",
+	}
+
+	tag, err := db.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tag.ID)
+
+	again, err := db.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+	assert.Equal(t, tag.ID, again.ID)
+
+	found, err := db.FindEntityTagById(tag.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, prop.Value(), found.Property.Value())
+
+	tags, err := db.GetEntityTags(entity, time.Time{}, "conformance")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	_, err = db.GetEntityTags(entity, time.Time{}, "missing")
+	assert.Error(t, err)
+
+	tags, err = db.FindEntityTagsByContent(prop, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, entity.ID, tags[0].Entity.ID)
+	}
+
+	assert.NoError(t, db.DeleteEntityTag(tag.ID))
+	_, err = db.FindEntityTagById(tag.ID)
+	assert.Error(t, err)
+	_, err = db.GetEntityTags(entity, time.Time{})
+	assert.Error(t, err)
+}
+
+func testEdgeTags(t *testing.T, db repository.Repository) {
+	from, err := db.CreateAsset(&domain.FQDN{Name: "edgetags.conformance.owasp.org"})
+	assert.NoError(t, err)
+
+	to, err := db.CreateAsset(&domain.FQDN{Name: "www.edgetags.conformance.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := db.CreateEdge(&types.Edge{
+		Relation: &relation.BasicDNSRelation{
+			Name:   "dns_record",
+			Header: relation.RRHeader{RRType: 5},
+		},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	prop := &property.SimpleProperty{
+		PropertyName:  "conformance",
+		PropertyValue: "edge",
+	}
+
+	tag, err := db.CreateEdgeProperty(edge, prop)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tag.ID)
+
+	again, err := db.CreateEdgeProperty(edge, prop)
+	assert.NoError(t, err)
+	assert.Equal(t, tag.ID, again.ID)
+
+	found, err := db.FindEdgeTagById(tag.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, prop.Value(), found.Property.Value())
+
+	tags, err := db.GetEdgeTags(edge, time.Time{}, "conformance")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	tags, err = db.FindEdgeTagsByContent(prop, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, edge.ID, tags[0].Edge.ID)
+	}
+
+	assert.NoError(t, db.DeleteEdgeTag(tag.ID))
+	_, err = db.FindEdgeTagById(tag.ID)
+	assert.Error(t, err)
+	_, err = db.GetEdgeTags(edge, time.Time{})
+	assert.Error(t, err)
+}
+
+// RunEmptyResultsConformance runs the tests that every implementation of the Repository interface is expected to pass
+// when it is created with the EmptyResults option. Each list method that finds nothing must return an empty result
+// and a nil error. The newRepo function is called once and must return a repository that is ready for use.
+// The repository is closed when the test completes.
+func RunEmptyResultsConformance(t *testing.T, newRepo func() repository.Repository) {
+	db := newRepo()
+	if db == nil {
+		t.Fatal("the repository is nil")
+	}
+	defer db.Close()
+
+	lonely, err := db.CreateAsset(&domain.FQDN{Name: "lonely.empty.conformance.owasp.org"})
+	assert.NoError(t, err)
+	parent, err := db.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.99.0.0/16"), Type: "IPv4"})
+	assert.NoError(t, err)
+	from, err := db.CreateAsset(&domain.FQDN{Name: "empty.conformance.owasp.org"})
+	assert.NoError(t, err)
+	to, err := db.CreateAsset(&domain.FQDN{Name: "www.empty.conformance.owasp.org"})
+	assert.NoError(t, err)
+	edge, err := db.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5}},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	missing := &domain.FQDN{Name: "missing.empty.conformance.owasp.org"}
+	prop := &property.SimpleProperty{PropertyName: "empty_missing", PropertyValue: "none"}
+	future := time.Now().Add(time.Hour)
+
+	lists := map[string]func() (any, error){
+		"FindEntitiesByContent": func() (any, error) { return db.FindEntitiesByContent(missing, time.Time{}) },
+		"FindEntitiesByContentFold": func() (any, error) {
+			return db.FindEntitiesByContentFold(missing, time.Time{})
+		},
+		"FindEntitiesByType": func() (any, error) { return db.FindEntitiesByType(oam.IPAddress, time.Time{}) },
+		"FindStaleEntities":  func() (any, error) { return db.FindStaleEntities(oam.FQDN, 24*time.Hour) },
+		"ChildNetblocks":     func() (any, error) { return db.ChildNetblocks(parent, time.Time{}) },
+		"FindEntitiesByTypePagedWithTotal": func() (any, error) {
+			entities, _, err := db.FindEntitiesByTypePagedWithTotal(oam.IPAddress, time.Time{}, 10, 0)
+			return entities, err
+		},
+		"FindEntitiesByField": func() (any, error) {
+			return db.FindEntitiesByField(oam.FQDN, "name", missing.Name, time.Time{})
+		},
+		"FindEntitiesByFieldRegex": func() (any, error) {
+			return db.FindEntitiesByFieldRegex(oam.FQDN, "name", "^missing\\.", time.Time{})
+		},
+		"FindEntitiesByFieldRange": func() (any, error) {
+			return db.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 1, 2, time.Time{})
+		},
+		"SearchFQDNs": func() (any, error) { return db.SearchFQDNs("missing", time.Time{}) },
+		"FindServicesByAttribute": func() (any, error) {
+			return db.FindServicesByAttribute("X-Missing", "missing", time.Time{})
+		},
+		"FindEntitiesByRun": func() (any, error) { return db.FindEntitiesByRun("missing") },
+		"IncomingEdges":     func() (any, error) { return db.IncomingEdges(lonely, time.Time{}) },
+		"OutgoingEdges":     func() (any, error) { return db.OutgoingEdges(lonely, time.Time{}) },
+		"AdjacentEdges":     func() (any, error) { return db.AdjacentEdges(lonely, time.Time{}) },
+		"OutgoingEdgesForEntities": func() (any, error) {
+			return db.OutgoingEdgesForEntities([]*types.Entity{lonely}, time.Time{})
+		},
+		"FindEdgesByRun":         func() (any, error) { return db.FindEdgesByRun("missing") },
+		"FindHubEntities":        func() (any, error) { return db.FindHubEntities(100, "total", time.Time{}) },
+		"DistinctRelationLabels": func() (any, error) { return db.DistinctRelationLabels(future) },
+		"FindEntitiesWithEdgeTo": func() (any, error) {
+			return db.FindEntitiesWithEdgeTo(oam.FQDN, "missing", oam.FQDN, time.Time{})
+		},
+		"FindEntityTagsByContent": func() (any, error) { return db.FindEntityTagsByContent(prop, time.Time{}) },
+		"FindEntityTagsBySource":  func() (any, error) { return db.FindEntityTagsBySource("missing", time.Time{}) },
+		"FindEntityTagsByValuePrefix": func() (any, error) {
+			return db.FindEntityTagsByValuePrefix("empty_missing", "none", time.Time{})
+		},
+		"GetEntityTags":         func() (any, error) { return db.GetEntityTags(lonely, time.Time{}) },
+		"GetEntityTagsByType":   func() (any, error) { return db.GetEntityTagsByType(lonely, time.Time{}) },
+		"EntityTagTimeline":     func() (any, error) { return db.EntityTagTimeline(lonely.ID) },
+		"FindEdgeTagsByContent": func() (any, error) { return db.FindEdgeTagsByContent(prop, time.Time{}) },
+		"GetEdgeTags":           func() (any, error) { return db.GetEdgeTags(edge, time.Time{}) },
+		"GetEdgeTagsByType":     func() (any, error) { return db.GetEdgeTagsByType(edge, time.Time{}) },
+	}
+
+	for name, list := range lists {
+		t.Run(name, func(t *testing.T) {
+			results, err := list()
+			assert.NoError(t, err)
+			assert.NotNil(t, results)
+			assert.Empty(t, results)
+		})
+	}
+
+	entityTags, edgeTags, err := db.FindAllTagsByContent(prop, time.Time{})
+	assert.NoError(t, err)
+	assert.NotNil(t, entityTags)
+	assert.Empty(t, entityTags)
+	assert.NotNil(t, edgeTags)
+	assert.Empty(t, edgeTags)
+}
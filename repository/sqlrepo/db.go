@@ -5,13 +5,26 @@
 package sqlrepo
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	gosqlite "github.com/glebarez/go-sqlite"
 	"github.com/glebarez/sqlite"
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 const (
@@ -20,41 +33,159 @@ const (
 	SQLiteMemory string = "sqlite_memory"
 )
 
+const (
+	// defaultPostgresBatchSize keeps bulk statements well below the postgres limit of 65535 parameters.
+	defaultPostgresBatchSize int = 1000
+	// defaultSQLiteBatchSize keeps bulk statements well below the sqlite limit of 999 variables.
+	defaultSQLiteBatchSize int = 100
+)
+
 // sqlRepository is a repository implementation using GORM as the underlying ORM.
 type sqlRepository struct {
-	db     *gorm.DB
-	dbtype string
+	db          *gorm.DB
+	dbtype      string
+	opts        *options.Options
+	parseErrors *atomic.Int64
+	upserts     *sync.Mutex
+	done        chan struct{}
+	stop        sync.Once
+	wg          sync.WaitGroup
 }
 
 // New creates a new instance of the asset database repository.
-func New(dbtype, dsn string) (*sqlRepository, error) {
-	db, err := newDatabase(dbtype, dsn)
+func New(dbtype, dsn string, opts ...options.Option) (*sqlRepository, error) {
+	o := options.New(opts...)
+
+	db, err := newDatabase(dbtype, dsn, gormConfig(o))
 	if err != nil {
 		return nil, err
 	}
+	if err := configurePool(db, o); err != nil {
+		return nil, err
+	}
 
-	return &sqlRepository{
-		db:     db,
-		dbtype: dbtype,
-	}, nil
+	repo := &sqlRepository{
+		db:          db,
+		dbtype:      dbtype,
+		opts:        o,
+		parseErrors: new(atomic.Int64),
+		upserts:     new(sync.Mutex),
+	}
+	// the idle connections of the sqlite databases are not recycled, since closing the last
+	// connection to an in-memory database discards the data
+	if dbtype == Postgres && o.HealthCheckInterval > 0 {
+		if err := repo.startHealthCheck(o.HealthCheckInterval); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
+
+// startHealthCheck recycles the pooled connections that have been idle for longer than the interval, and pings
+// the database at the interval, so a connection closed by the server or a proxy is discarded by the pool
+// instead of failing the next query.
+func (sql *sqlRepository) startHealthCheck(interval time.Duration) error {
+	sqlDB, err := sql.db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetConnMaxIdleTime(interval)
+
+	sql.done = make(chan struct{})
+	sql.wg.Add(1)
+	go func() {
+		defer sql.wg.Done()
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-sql.done:
+				return
+			case <-t.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				// the pool discards a connection that fails the ping and tries another
+				if err := sqlDB.PingContext(ctx); err != nil {
+					sql.log().Warn("the database health check failed", "error", err)
+				}
+				cancel()
+			}
+		}
+	}()
+	return nil
 }
 
 // newDatabase creates a new GORM database connection based on the provided database type and data source name (dsn).
-func newDatabase(dbtype, dsn string) (*gorm.DB, error) {
+func newDatabase(dbtype, dsn string, config *gorm.Config) (*gorm.DB, error) {
 	switch dbtype {
 	case Postgres:
-		return postgresDatabase(dsn)
+		return postgresDatabase(dsn, config)
 	case SQLite:
-		return sqliteDatabase(dsn, 3, 5)
+		return sqliteDatabase(dsn, config, 3, 5)
 	case SQLiteMemory:
-		return sqliteDatabase(dsn, 50, 100)
+		return sqliteDatabase(dsn, config, 50, 100)
 	}
 	return nil, errors.New("unknown DB type")
 }
 
+// configurePool applies the connection pool settings from the options, replacing the defaults set for the backend.
+func configurePool(db *gorm.DB, o *options.Options) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	if o.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+	return nil
+}
+
+// gormConfig returns the GORM configuration with the table prefix, SQL logger and log level from the options
+// merged into the defaults, which use the standard table names and discard the ORM output.
+func gormConfig(o *options.Options) *gorm.Config {
+	config := &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		NamingStrategy: schema.NamingStrategy{TablePrefix: o.TablePrefix},
+	}
+
+	if o.SQLLogger != nil {
+		level := logger.Warn
+		if o.SQLLogLevel != 0 {
+			level = o.SQLLogLevel
+		}
+
+		config.Logger = logger.New(&slogWriter{logger: o.SQLLogger}, logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  level,
+			IgnoreRecordNotFoundError: true,
+		})
+	} else if o.SQLLogLevel != 0 {
+		config.Logger = logger.Default.LogMode(o.SQLLogLevel)
+	}
+	return config
+}
+
+// slogWriter writes the output of the GORM logger to a structured logger.
+type slogWriter struct {
+	logger *slog.Logger
+}
+
+// Printf implements the GORM logger.Writer interface.
+func (w *slogWriter) Printf(format string, args ...interface{}) {
+	w.logger.Info(fmt.Sprintf(format, args...))
+}
+
 // postgresDatabase creates a new PostgreSQL database connection using the provided data source name (dsn).
-func postgresDatabase(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+func postgresDatabase(dsn string, config *gorm.Config) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), config)
 	if err != nil {
 		return nil, err
 	}
@@ -72,8 +203,9 @@ func postgresDatabase(dsn string) (*gorm.DB, error) {
 }
 
 // sqliteDatabase creates a new SQLite database connection using the provided data source name (dsn).
-func sqliteDatabase(dsn string, conns, idles int) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+// Foreign keys are enabled on every connection of the pool, so the ON DELETE CASCADE actions of the schema are applied.
+func sqliteDatabase(dsn string, config *gorm.Config, conns, idles int) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(sqliteForeignKeys(dsn)), config)
 	if err != nil {
 		return nil, err
 	}
@@ -90,15 +222,315 @@ func sqliteDatabase(dsn string, conns, idles int) (*gorm.DB, error) {
 	return db, nil
 }
 
+// sqliteForeignKeys adds the pragma that enables foreign keys to the DSN, since sqlite only enforces
+// them on the connections that enable them.
+func sqliteForeignKeys(dsn string) string {
+	if strings.Contains(dsn, "foreign_keys") {
+		return dsn
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=foreign_keys(1)"
+}
+
 // Close implements the Repository interface.
+// When enabled by the options, the sqlite database file is vacuumed and the write-ahead log is checkpointed
+// before the connections are closed. Failures of either are logged, and the database is still closed.
 func (sql *sqlRepository) Close() error {
+	sql.stop.Do(func() {
+		if sql.done != nil {
+			close(sql.done)
+			sql.wg.Wait()
+		}
+		sql.tidySQLite()
+	})
+
 	if db, err := sql.db.DB(); err == nil {
 		return db.Close()
 	}
 	return errors.New("failed to obtain access to the database handle")
 }
 
+// tidySQLite vacuums the sqlite database file and checkpoints the write-ahead log, as enabled by the options.
+// The vacuum is performed first, since in WAL mode the rebuilt database is written to the log.
+func (sql *sqlRepository) tidySQLite() {
+	if sql.dbtype != SQLite || sql.opts == nil {
+		return
+	}
+
+	if sql.opts.VacuumOnClose {
+		if err := sql.db.Exec("VACUUM").Error; err != nil {
+			sql.log().Warn("failed to vacuum the database", "error", err)
+		}
+	}
+	if sql.opts.CheckpointOnClose {
+		if err := sql.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+			sql.log().Warn("failed to checkpoint the write-ahead log", "error", err)
+		}
+	}
+}
+
+// Transaction calls fn with a repository bound to a database transaction, which is committed when fn returns nil
+// and rolled back when fn returns an error or panics. This groups writes, such as creating an entity, an edge
+// and their tags, so they are stored atomically. The db must be a repository returned by New, and R is the
+// type fn receives it as, such as the Repository interface. The repository passed to fn must not be closed
+// or used after fn returns.
+func Transaction[R any](db R, fn func(tx R) error) error {
+	sql, ok := any(db).(*sqlRepository)
+	if !ok {
+		return errors.New("the repository does not support transactions")
+	}
+
+	return sql.db.Transaction(func(tx *gorm.DB) error {
+		txrepo, ok := any(sql.withDB(tx)).(R)
+		if !ok {
+			return errors.New("the transaction repository does not implement the requested type")
+		}
+		return fn(txrepo)
+	})
+}
+
 // GetDBType returns the type of the database.
 func (sql *sqlRepository) GetDBType() string {
 	return sql.dbtype
 }
+
+// batchSize returns the number of rows processed by each statement during bulk operations.
+// The configured batch size takes precedence over the safe default for the database dialect.
+func (sql *sqlRepository) batchSize() int {
+	if sql.opts != nil && sql.opts.BatchSize > 0 {
+		return sql.opts.BatchSize
+	}
+	if sql.dbtype == Postgres {
+		return defaultPostgresBatchSize
+	}
+	return defaultSQLiteBatchSize
+}
+
+// runWriter returns the database handle used to write entities and edges. When the repository is not
+// configured with a run ID, the run_id column is left out of the writes, so the run recorded by an
+// earlier write is preserved.
+func (sql *sqlRepository) runWriter() *gorm.DB {
+	if sql.opts == nil || sql.opts.RunID == "" {
+		return sql.db.Omit("run_id")
+	}
+	return sql.db
+}
+
+// content serializes the asset, relation or property to the JSON stored in the content column,
+// which is canonical when the CanonicalContent option is enabled.
+func (sql *sqlRepository) content(data interface{ JSON() ([]byte, error) }) ([]byte, error) {
+	content, err := data.JSON()
+	if err != nil || sql.opts == nil || !sql.opts.CanonicalContent {
+		return content, err
+	}
+	return types.CanonicalJSON(content)
+}
+
+// parseEntity parses the content of the entity, applying the UnknownTypePolicy when the asset type is not recognized.
+// The skip result reports that a list of results should leave the entity out instead of failing, which is the
+// case for malformed content and for unknown types under the UnknownTypeSkip policy.
+func (sql *sqlRepository) parseEntity(e *Entity) (oam.Asset, bool, error) {
+	asset, err := e.Parse()
+	if sql.parsed(err) == nil {
+		return asset, false, nil
+	}
+
+	var unknown *types.UnknownTypeError
+	if !errors.As(err, &unknown) {
+		return nil, true, err
+	}
+
+	switch sql.unknownTypes() {
+	case options.UnknownTypeError:
+		return nil, false, err
+	case options.UnknownTypeWrap:
+		return &types.UnknownAsset{Type: oam.AssetType(e.Type), Content: e.Content}, false, nil
+	}
+
+	sql.log().Warn("skipping an entity with an unknown asset type", "id", e.ID, "type", e.Type)
+	return nil, true, err
+}
+
+// withDB returns a repository that uses the provided database handle, such as a transaction or a session
+// with a context. The options, the upserts mutex and the parse error counter are shared with the repository,
+// so the calls made through the returned repository are serialized and counted with the others.
+func (sql *sqlRepository) withDB(db *gorm.DB) *sqlRepository {
+	return &sqlRepository{
+		db:          db,
+		dbtype:      sql.dbtype,
+		opts:        sql.opts,
+		parseErrors: sql.parseErrors,
+		upserts:     sql.upserts,
+	}
+}
+
+// ParseErrors returns the number of times that content read from the database could not be parsed,
+// such as when the content was written by a newer version of the Open Asset Model.
+func (sql *sqlRepository) ParseErrors() int64 {
+	return sql.parseErrors.Load()
+}
+
+// Stats returns the statistics of the connection pool, such as the open, idle and in-use connections and
+// the number of times that a query waited for a connection.
+func (sql *sqlRepository) Stats() (types.RepositoryStats, error) {
+	sqlDB, err := sql.db.DB()
+	if err != nil {
+		return types.RepositoryStats{}, err
+	}
+
+	stats := sqlDB.Stats()
+	return types.RepositoryStats{Pool: &stats}, nil
+}
+
+func init() {
+	// sqlite parses the REGEXP operator without providing the function that implements it. An error means the
+	// function was already registered by the application, which then decides how the patterns are matched
+	_ = gosqlite.RegisterDeterministicScalarFunction("regexp", 2, sqliteRegexp)
+}
+
+// sqliteRegexps caches the patterns compiled by sqliteRegexp, since the function is called for every row.
+// The cache is cleared once it holds maxSQLiteRegexps patterns.
+var (
+	sqliteRegexpsLock sync.Mutex
+	sqliteRegexps     = make(map[string]*regexp.Regexp)
+)
+
+const maxSQLiteRegexps int = 64
+
+// sqliteRegexp implements the REGEXP operator of sqlite with the Go regexp syntax. The operator X REGEXP Y
+// calls the function with the pattern Y and the text X. Values that are not text always match, so that the
+// caller applies the pattern to their JSON encoding, and NULL values never match.
+func sqliteRegexp(_ *gosqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("the regexp pattern must be text")
+	}
+
+	var text string
+	switch v := args[1].(type) {
+	case nil:
+		return false, nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return true, nil
+	}
+
+	sqliteRegexpsLock.Lock()
+	re, found := sqliteRegexps[pattern]
+	if !found {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			sqliteRegexpsLock.Unlock()
+			return nil, err
+		}
+		if len(sqliteRegexps) >= maxSQLiteRegexps {
+			clear(sqliteRegexps)
+		}
+		sqliteRegexps[pattern] = re
+	}
+	sqliteRegexpsLock.Unlock()
+
+	return re.MatchString(text), nil
+}
+
+// notFound wraps the error of a lookup that matched no rows with the provided sentinel error,
+// such as types.ErrEntityNotFound. Other errors are returned unchanged.
+func notFound(err, sentinel error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", sentinel, err)
+	}
+	return err
+}
+
+// lastSeenBetween limits the query to the records last seen between start and end, inclusive.
+// A zero start or end leaves the range without that bound.
+func lastSeenBetween(tx *gorm.DB, start, end time.Time) *gorm.DB {
+	if !start.IsZero() {
+		tx = tx.Where("updated_at >= ?", start.UTC())
+	}
+	if !end.IsZero() {
+		tx = tx.Where("updated_at <= ?", end.UTC())
+	}
+	return tx
+}
+
+// parsed counts the error returned by parsing content read from the database, if there is one.
+func (sql *sqlRepository) parsed(err error) error {
+	if err != nil {
+		sql.parseErrors.Add(1)
+	}
+	return err
+}
+
+// parseProperty parses the content of the tag, applying the UnknownTypePolicy when the property type is not
+// recognized. The skip result has the same meaning as for parseEntity.
+func (sql *sqlRepository) parseProperty(id uint64, ptype string, content []byte) (oam.Property, bool, error) {
+	prop, err := types.ParseProperty(oam.PropertyType(ptype), content)
+	if sql.parsed(err) == nil {
+		return prop, false, nil
+	}
+
+	var unknown *types.UnknownTypeError
+	if !errors.As(err, &unknown) {
+		return nil, true, err
+	}
+
+	switch sql.unknownTypes() {
+	case options.UnknownTypeError:
+		return nil, false, err
+	case options.UnknownTypeWrap:
+		return &types.UnknownProperty{Type: oam.PropertyType(ptype), Content: content}, false, nil
+	}
+
+	sql.log().Warn("skipping a tag with an unknown property type", "id", id, "type", ptype)
+	return nil, true, err
+}
+
+func (sql *sqlRepository) unknownTypes() options.UnknownTypePolicy {
+	if sql.opts == nil {
+		return options.UnknownTypeSkip
+	}
+	return sql.opts.UnknownTypes
+}
+
+func (sql *sqlRepository) log() *slog.Logger {
+	if sql.opts == nil {
+		return options.New().Log()
+	}
+	return sql.opts.Log()
+}
+
+// deleter returns the session used to delete rows. The rows are only marked with a deletion time
+// when the SoftDelete option is enabled, and are otherwise removed from the table.
+func (sql *sqlRepository) deleter(tx *gorm.DB) *gorm.DB {
+	if sql.opts != nil && sql.opts.SoftDelete {
+		return tx
+	}
+	return tx.Unscoped()
+}
+
+// deleteInBatches removes the rows of the model with primary keys in the provided slice,
+// splitting the IDs across statements according to the configured batch size.
+// Returns the number of rows that were removed.
+func (sql *sqlRepository) deleteInBatches(model interface{}, column string, ids []uint64) (int64, error) {
+	var count int64
+	size := sql.batchSize()
+
+	for start := 0; start < len(ids); start += size {
+		end := min(start+size, len(ids))
+
+		result := sql.deleter(sql.db).Where(column+" IN ?", ids[start:end]).Delete(model)
+		if err := result.Error; err != nil {
+			return count, err
+		}
+		count += result.RowsAffected
+	}
+	return count, nil
+}
@@ -5,12 +5,15 @@
 package sqlrepo
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
 	"gorm.io/gorm"
@@ -20,14 +23,80 @@ import (
 // The edge is established by creating a new Edge in the database, linking the two entities.
 // Returns the created edge as a types.Edge or an error if the link creation fails.
 func (sql *sqlRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
+	e, _, err := sql.UpsertEdge(edge)
+	return e, err
+}
+
+// UpsertEdge creates an edge between two entities in the database, as done by CreateEdge.
+// The unique indexes of the edges reject the duplicate inserted by a racing call, made by this or another
+// process, which is then returned as the existing edge, so racing calls produce a single edge.
+// Returns the edge, and true if the edge was inserted or false if an existing edge was updated
+// by the deduplication of relationships.
+func (sql *sqlRepository) UpsertEdge(edge *types.Edge) (*types.Edge, bool, error) {
+	return sql.upsertEdge(edge, nil)
+}
+
+// CreateEdges creates the provided edges in the database, as done by CreateEdge.
+// The outgoing edges of each source entity are read once for the batch, instead of once for each edge,
+// when checking for duplicate relationships.
+// Returns the created or existing edges in the order provided, or an error if the creation of an edge fails.
+func (sql *sqlRepository) CreateEdges(edges []*types.Edge) ([]*types.Edge, error) {
+	outs := make(outgoingEdgeCache)
+
+	var results []*types.Edge
+	for _, edge := range edges {
+		e, _, err := sql.upsertEdge(edge, outs)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	return results, nil
+}
+
+// CreateEdgesContext creates the provided edges in the database as done by CreateEdges, reporting the outcome
+// of each edge instead of failing the whole batch. Each edge is committed when it is written.
+// When the context is cancelled, the edges already written remain committed and the edges that were not written
+// are reported with the context error.
+// Returns the outcome of each edge, and the context error if the operation was cancelled.
+func (sql *sqlRepository) CreateEdgesContext(ctx context.Context, edges []*types.Edge) (*types.BulkResult[*types.Edge], error) {
+	result := types.NewBulkResult[*types.Edge](len(edges))
+	repo := sql.withDB(sql.db.WithContext(ctx))
+
+	outs := make(outgoingEdgeCache)
+	for i, edge := range edges {
+		if err := ctx.Err(); err != nil {
+			result.FailFrom(i, err)
+			return result, err
+		}
+
+		if e, _, err := repo.upsertEdge(edge, outs); err != nil {
+			result.Errors[i] = err
+		} else {
+			result.Results[i] = e
+		}
+	}
+	return result, nil
+}
+
+// outgoingEdgeCache holds the outgoing edges of source entities, keyed by the entity ID and relation label,
+// so the links made within a batch do not repeat the reads of the same outgoing edges.
+type outgoingEdgeCache map[string][]*types.Edge
+
+func outgoingEdgeKey(edge *types.Edge) string {
+	return edge.FromEntity.ID + ":" + edge.Relation.Label()
+}
+
+// upsertEdge creates or updates the edge, using the cache of outgoing edges when it is not nil.
+func (sql *sqlRepository) upsertEdge(edge *types.Edge, outs outgoingEdgeCache) (*types.Edge, bool, error) {
 	if edge == nil || edge.Relation == nil || edge.FromEntity == nil ||
 		edge.FromEntity.Asset == nil || edge.ToEntity == nil || edge.ToEntity.Asset == nil {
-		return nil, errors.New("failed input validation checks")
+		return nil, false, errors.New("failed input validation checks")
 	}
 
 	if !oam.ValidRelationship(edge.FromEntity.Asset.AssetType(),
 		edge.Relation.Label(), edge.Relation.RelationType(), edge.ToEntity.Asset.AssetType()) {
-		return &types.Edge{}, fmt.Errorf("%s -%s-> %s is not valid in the taxonomy",
+		return &types.Edge{}, false, fmt.Errorf("%s -%s-> %s is not valid in the taxonomy",
 			edge.FromEntity.Asset.AssetType(), edge.Relation.Label(), edge.ToEntity.Asset.AssetType())
 	}
 
@@ -38,23 +107,35 @@ func (sql *sqlRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 		updated = edge.LastSeen.UTC()
 	}
 	// ensure that duplicate relationships are not entered into the database
-	if e, found := sql.isDuplicateEdge(edge, updated); found {
-		return e, nil
+	if e, found := sql.isDuplicateEdge(edge, updated, outs); found {
+		return e, false, nil
+	}
+
+	resolved, err := sql.resolveEndpoints(edge)
+	if err != nil {
+		return nil, false, err
+	}
+	if resolved != edge {
+		// the entities created for the missing endpoints may already be linked by the relationship
+		if e, found := sql.isDuplicateEdge(resolved, updated, outs); found {
+			return e, false, nil
+		}
+		edge = resolved
 	}
 
 	fromEntityId, err := strconv.ParseUint(edge.FromEntity.ID, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	toEntityId, err := strconv.ParseUint(edge.ToEntity.ID, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	jsonContent, err := edge.Relation.JSON()
+	jsonContent, err := sql.content(edge.Relation)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	r := Edge{
@@ -63,6 +144,12 @@ func (sql *sqlRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 		FromEntityID: fromEntityId,
 		ToEntityID:   toEntityId,
 		UpdatedAt:    updated,
+		RunID:        sql.opts.RunID,
+	}
+	if sql.opts.EdgeDedup == options.EdgeDedupLabel {
+		// the unique index on the label rejects a racing edge with the same label and different content
+		label := edge.Relation.Label()
+		r.DedupLabel = &label
 	}
 	if edge.CreatedAt.IsZero() {
 		r.CreatedAt = time.Now().UTC()
@@ -70,34 +157,56 @@ func (sql *sqlRepository) CreateEdge(edge *types.Edge) (*types.Edge, error) {
 		r.CreatedAt = edge.CreatedAt.UTC()
 	}
 
-	result := sql.db.Create(&r)
-	if err := result.Error; err != nil {
-		return nil, err
+	// the insert is made in a nested transaction, so a rejected insert does not abort the transaction of the caller
+	err = sql.db.Transaction(func(tx *gorm.DB) error {
+		txrepo := sql.withDB(tx)
+		return txrepo.runWriter().Create(&r).Error
+	})
+	if err != nil {
+		// the unique index rejects the edge when a concurrent writer inserted the same relationship first
+		if outs != nil {
+			delete(outs, outgoingEdgeKey(edge))
+		}
+		if e, found := sql.isDuplicateEdge(edge, updated, outs); found {
+			return e, false, nil
+		}
+		return nil, false, err
 	}
-	return toEdge(r), nil
+
+	e := sql.toEdge(r)
+	if outs != nil && e != nil {
+		key := outgoingEdgeKey(edge)
+		outs[key] = append(outs[key], e)
+	}
+	return e, true, nil
 }
 
 // isDuplicateEdge checks if the relationship between source and dest already exists.
-func (sql *sqlRepository) isDuplicateEdge(edge *types.Edge, updated time.Time) (*types.Edge, bool) {
-	var dup bool
-	var e *types.Edge
-
-	if outs, err := sql.OutgoingEdges(edge.FromEntity, time.Time{}, edge.Relation.Label()); err == nil {
-		for _, out := range outs {
-			if edge.ToEntity.ID == out.ToEntity.ID && reflect.DeepEqual(edge.Relation, out.Relation) {
-				_ = sql.edgeSeen(out, updated)
-
-				e, err = sql.FindEdgeById(out.ID)
-				if err != nil {
-					return nil, false
-				}
+// When the cache of outgoing edges is not nil, the outgoing edges of the source are only read from the
+// database the first time that the source and relation label are seen.
+func (sql *sqlRepository) isDuplicateEdge(edge *types.Edge, updated time.Time, cache outgoingEdgeCache) (*types.Edge, bool) {
+	key := outgoingEdgeKey(edge)
+	outs, cached := cache[key]
+	if !cached {
+		// an error indicates that the source has no outgoing edges with the label
+		outs, _ = sql.OutgoingEdges(edge.FromEntity, time.Time{}, edge.Relation.Label())
+		if cache != nil {
+			cache[key] = outs
+		}
+	}
+
+	for _, out := range outs {
+		if edge.ToEntity.ID == out.ToEntity.ID && sql.opts.DuplicateRelations(edge.Relation, out.Relation) {
+			_ = sql.edgeSeen(out, updated)
 
-				dup = true
-				break
+			e, err := sql.FindEdgeById(out.ID)
+			if err != nil {
+				return nil, false
 			}
+			return e, true
 		}
 	}
-	return e, dup
+	return nil, false
 }
 
 // edgeSeen updates the updated_at timestamp for the specified edge.
@@ -107,7 +216,7 @@ func (sql *sqlRepository) edgeSeen(rel *types.Edge, updated time.Time) error {
 		return err
 	}
 
-	jsonContent, err := rel.Relation.JSON()
+	jsonContent, err := sql.content(rel.Relation)
 	if err != nil {
 		return err
 	}
@@ -130,9 +239,11 @@ func (sql *sqlRepository) edgeSeen(rel *types.Edge, updated time.Time) error {
 		ToEntityID:   toEntityId,
 		CreatedAt:    rel.CreatedAt,
 		UpdatedAt:    updated,
+		RunID:        sql.opts.RunID,
 	}
 
-	result := sql.db.Save(&r)
+	// the label set for the EdgeDedupLabel mode is kept by the update
+	result := sql.runWriter().Omit("dedup_label").Save(&r)
 	if err := result.Error; err != nil {
 		return err
 	}
@@ -144,28 +255,37 @@ func (sql *sqlRepository) FindEdgeById(id string) (*types.Edge, error) {
 
 	result := sql.db.Where("edge_id = ?", id).First(&rel)
 	if err := result.Error; err != nil {
-		return nil, err
+		return nil, notFound(err, types.ErrEdgeNotFound)
 	}
 
-	return toEdge(rel), nil
+	return sql.toEdge(rel), nil
 }
 
 // IncomingEdges finds all edges pointing to the entity of the specified labels and last seen after the since parameter.
 // If since.IsZero(), the parameter will be ignored.
 // If no labels are specified, all incoming eges are returned.
 func (sql *sqlRepository) IncomingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	return sql.incomingEdges(entity, since, time.Time{}, labels...)
+}
+
+// IncomingEdgesBetween finds all edges pointing to the entity of the specified labels and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no labels are specified, all incoming edges within the range are returned.
+func (sql *sqlRepository) IncomingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return sql.incomingEdges(entity, start, end, labels...)
+}
+
+func (sql *sqlRepository) incomingEdges(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
 	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
 	var edges []Edge
-	var result *gorm.DB
-	if since.IsZero() {
-		result = sql.db.Where("to_entity_id = ?", entityId).Find(&edges)
-	} else {
-		result = sql.db.Where("to_entity_id = ? AND updated_at >= ?", entityId, since.UTC()).Find(&edges)
-	}
+	result := lastSeenBetween(sql.db.Where("to_entity_id = ?", entityId), start, end).Find(&edges)
 	if err := result.Error; err != nil {
 		return nil, err
 	}
@@ -175,7 +295,7 @@ func (sql *sqlRepository) IncomingEdges(entity *types.Entity, since time.Time, l
 		for _, edge := range edges {
 			e := &edge
 
-			if rel, err := e.Parse(); err == nil {
+			if rel, err := e.Parse(); sql.parsed(err) == nil {
 				for _, label := range labels {
 					if label == rel.Label() {
 						results = append(results, edge)
@@ -189,28 +309,106 @@ func (sql *sqlRepository) IncomingEdges(entity *types.Entity, since time.Time, l
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero edges found")
+		return []*types.Edge{}, sql.opts.NoResults("zero edges found")
 	}
-	return toEdges(results), nil
+	return sql.toEdges(results), nil
 }
 
 // OutgoingEdges finds all edges from the entity of the specified labels and last seen after the since parameter.
 // If since.IsZero(), the parameter will be ignored.
 // If no labels are specified, all outgoing edges are returned.
 func (sql *sqlRepository) OutgoingEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	return sql.outgoingEdges(entity, since, time.Time{}, labels...)
+}
+
+// OutgoingEdgesBetween finds all edges from the entity of the specified labels and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no labels are specified, all outgoing edges within the range are returned.
+func (sql *sqlRepository) OutgoingEdgesBetween(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return sql.outgoingEdges(entity, start, end, labels...)
+}
+
+func (sql *sqlRepository) outgoingEdges(entity *types.Entity, start, end time.Time, labels ...string) ([]*types.Edge, error) {
 	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
 	var edges []Edge
-	var result *gorm.DB
-	if since.IsZero() {
-		result = sql.db.Where("from_entity_id = ?", entityId).Find(&edges)
+	result := lastSeenBetween(sql.db.Where("from_entity_id = ?", entityId), start, end).Find(&edges)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	var results []Edge
+	if len(labels) > 0 {
+		for _, edge := range edges {
+			e := &edge
+
+			if rel, err := e.Parse(); sql.parsed(err) == nil {
+				for _, label := range labels {
+					if label == rel.Label() {
+						results = append(results, edge)
+						break
+					}
+				}
+			}
+		}
 	} else {
-		result = sql.db.Where("from_entity_id = ? AND updated_at >= ?", entityId, since.UTC()).Find(&edges)
+		results = edges
 	}
-	if err := result.Error; err != nil {
+
+	if len(results) == 0 {
+		return []*types.Edge{}, sql.opts.NoResults("zero edges found")
+	}
+	return sql.toEdges(results), nil
+}
+
+// CountOutgoingEdges counts the edges from the entity of the specified labels and last seen after the since parameter,
+// without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are counted.
+func (sql *sqlRepository) CountOutgoingEdges(entity *types.Entity, since time.Time, labels ...string) (int64, error) {
+	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	tx := sql.db.Model(&Edge{}).Where("from_entity_id = ?", entityId)
+	if len(labels) > 0 {
+		tx = tx.Where(sql.contentField("label")+" IN ?", labels)
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// AdjacentEdges finds all edges to or from the entity of the specified labels and last seen after the since parameter.
+// The direction of each edge is indicated by whether the entity is the FromEntity or the ToEntity.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all adjacent edges are returned.
+func (sql *sqlRepository) AdjacentEdges(entity *types.Entity, since time.Time, labels ...string) ([]*types.Edge, error) {
+	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := sql.db.Where("from_entity_id = ? OR to_entity_id = ?", entityId, entityId)
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var edges []Edge
+	if err := tx.Find(&edges).Error; err != nil {
 		return nil, err
 	}
 
@@ -219,7 +417,7 @@ func (sql *sqlRepository) OutgoingEdges(entity *types.Entity, since time.Time, l
 		for _, edge := range edges {
 			e := &edge
 
-			if rel, err := e.Parse(); err == nil {
+			if rel, err := e.Parse(); sql.parsed(err) == nil {
 				for _, label := range labels {
 					if label == rel.Label() {
 						results = append(results, edge)
@@ -233,9 +431,402 @@ func (sql *sqlRepository) OutgoingEdges(entity *types.Entity, since time.Time, l
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero edges found")
+		return []*types.Edge{}, sql.opts.NoResults("zero edges found")
+	}
+	return sql.toEdges(results), nil
+}
+
+// OutgoingEdgesForEntities finds all edges from the provided entities of the specified labels and last seen after
+// the since parameter. The edges are obtained using a single query per batch of entities instead of a query per entity.
+// If since.IsZero(), the parameter will be ignored.
+// If no labels are specified, all outgoing edges are returned.
+// Returns the edges grouped by the ID of the source entity. Entities without matching edges are not included.
+func (sql *sqlRepository) OutgoingEdgesForEntities(entities []*types.Entity, since time.Time, labels ...string) (map[string][]*types.Edge, error) {
+	var ids []uint64
+	for _, entity := range entities {
+		id, err := strconv.ParseUint(entity.ID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	results := make(map[string][]*types.Edge)
+	for start := 0; start < len(ids); start += sql.batchSize() {
+		end := min(start+sql.batchSize(), len(ids))
+
+		tx := sql.db.Where("from_entity_id IN ?", ids[start:end])
+		if !since.IsZero() {
+			tx = tx.Where("updated_at >= ?", since.UTC())
+		}
+
+		var edges []Edge
+		if err := tx.Find(&edges).Error; err != nil {
+			return nil, err
+		}
+
+		for _, edge := range edges {
+			e := sql.toEdge(edge)
+			if e == nil {
+				continue
+			}
+
+			if len(labels) > 0 {
+				var found bool
+				for _, label := range labels {
+					if label == e.Relation.Label() {
+						found = true
+						break
+					}
+				}
+				if !found {
+					continue
+				}
+			}
+
+			results[e.FromEntity.ID] = append(results[e.FromEntity.ID], e)
+		}
+	}
+
+	if len(results) == 0 {
+		return map[string][]*types.Edge{}, sql.opts.NoResults("zero edges found")
 	}
-	return toEdges(results), nil
+	return results, nil
+}
+
+// FindHubEntities finds all entities with a degree of at least minDegree, counting only edges last seen after the since parameter.
+// The direction must be "outgoing", "incoming", or "total", which counts the edges in both directions.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindHubEntities(minDegree int, direction string, since time.Time) ([]*types.Entity, error) {
+	// the edges marked as deleted are left out, since the raw query is not scoped by the ORM
+	where := " WHERE deleted_at IS NULL"
+	var args []interface{}
+	if !since.IsZero() {
+		where += " AND updated_at >= ?"
+		args = append(args, since.UTC())
+	}
+
+	// the table name honors the prefix configured for the repository
+	table := sql.db.NamingStrategy.TableName("Edge")
+
+	var query string
+	switch strings.ToLower(direction) {
+	case "outgoing":
+		query = "SELECT from_entity_id FROM " + table + where + " GROUP BY from_entity_id HAVING count(*) >= ?"
+	case "incoming":
+		query = "SELECT to_entity_id FROM " + table + where + " GROUP BY to_entity_id HAVING count(*) >= ?"
+	case "total":
+		query = "SELECT entity_id FROM (SELECT from_entity_id AS entity_id FROM " + table + where +
+			" UNION ALL SELECT to_entity_id AS entity_id FROM " + table + where +
+			") AS degrees GROUP BY entity_id HAVING count(*) >= ?"
+		args = append(args, args...)
+	default:
+		return nil, fmt.Errorf("unknown edge direction: %s", direction)
+	}
+	args = append(args, minDegree)
+
+	var ids []uint64
+	if err := sql.db.Raw(query, args...).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for start := 0; start < len(ids); start += sql.batchSize() {
+		end := min(start+sql.batchSize(), len(ids))
+
+		var entities []Entity
+		if err := sql.db.Where("entity_id IN ?", ids[start:end]).Find(&entities).Error; err != nil {
+			return nil, err
+		}
+
+		for _, e := range entities {
+			asset, skip, err := sql.parseEntity(&e)
+			if skip {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			results = append(results, &types.Entity{
+				ID:        strconv.FormatUint(e.ID, 10),
+				CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+				LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+				Asset:     asset,
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// DistinctRelationLabels returns the sorted labels of the relations stored by edges last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the relation labels or an error if the search fails.
+func (sql *sqlRepository) DistinctRelationLabels(since time.Time) ([]string, error) {
+	tx := sql.db.Model(&Edge{}).Distinct(sql.contentField("label"))
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	labels := []string{}
+	if err := tx.Scan(&labels).Error; err != nil {
+		return nil, err
+	}
+
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// FindEntitiesWithEdgeTo finds all entities of the fromType with at least one outgoing edge with the relation label
+// to an entity of the toType, considering only edges last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// The edges are matched by an EXISTS subquery, so the entities of the fromType are not loaded to check their edges.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindEntitiesWithEdgeTo(fromType oam.AssetType, label string, toType oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	// the table names honor the prefix configured for the repository
+	entities := sql.db.NamingStrategy.TableName("Entity")
+	edges := sql.db.NamingStrategy.TableName("Edge")
+
+	exists := "EXISTS (SELECT 1 FROM " + edges + " r JOIN " + entities + " t ON t.entity_id = r.to_entity_id" +
+		" WHERE r.from_entity_id = " + entities + ".entity_id AND t.etype = ? AND " + sql.tableContentField("r", "label") + " = ?" +
+		" AND r.deleted_at IS NULL AND t.deleted_at IS NULL"
+	args := []interface{}{string(toType), label}
+	if !since.IsZero() {
+		exists += " AND r.updated_at >= ?"
+		args = append(args, since.UTC())
+	}
+	exists += ")"
+
+	var matches []Entity
+	if err := sql.db.Where("etype = ?", fromType).Where(exists, args...).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range matches {
+		asset, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     asset,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// TraverseNeighborhood finds the entities within depth hops of the entity, following the edges in the direction
+// with the specified labels, and the edges that were followed to reach them.
+// The traversal is performed by a single recursive query, which enumerates every path up to the depth, so a large
+// depth over densely connected entities can be expensive.
+// If no labels are specified, edges of all labels are followed.
+// Returns the entity, followed by the reached entities ordered by their distance, and the followed edges,
+// or an error if the traversal fails.
+func (sql *sqlRepository) TraverseNeighborhood(entity *types.Entity, depth int, direction types.Direction, labels ...string) ([]*types.Entity, []*types.Edge, error) {
+	if err := types.ValidateTraversal(depth, direction); err != nil {
+		return nil, nil, err
+	}
+
+	entityId, err := strconv.ParseUint(entity.ID, 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next, join string
+	switch direction {
+	case types.Outgoing:
+		next = "r.to_entity_id"
+		join = "r.from_entity_id = n.entity_id"
+	case types.Incoming:
+		next = "r.from_entity_id"
+		join = "r.to_entity_id = n.entity_id"
+	default:
+		next = "CASE WHEN r.from_entity_id = n.entity_id THEN r.to_entity_id ELSE r.from_entity_id END"
+		join = "(r.from_entity_id = n.entity_id OR r.to_entity_id = n.entity_id)"
+	}
+
+	// the edges marked as deleted are not followed, since the raw query is not scoped by the ORM
+	where := "n.depth < ? AND r.deleted_at IS NULL"
+	args := []interface{}{entityId, depth}
+	if len(labels) > 0 {
+		where += " AND " + sql.tableContentField("r", "label") + " IN ?"
+		args = append(args, labels)
+	}
+
+	// the table name honors the prefix configured for the repository
+	table := sql.db.NamingStrategy.TableName("Edge")
+	query := "WITH RECURSIVE neighborhood(entity_id, edge_id, depth) AS (" +
+		"SELECT CAST(? AS INTEGER), CAST(NULL AS INTEGER), 0 UNION " +
+		"SELECT " + next + ", r.edge_id, n.depth + 1 FROM " + table + " r JOIN neighborhood n ON " + join + " WHERE " + where +
+		") SELECT entity_id, edge_id, depth FROM neighborhood ORDER BY depth, entity_id"
+
+	var rows []struct {
+		EntityID uint64
+		EdgeID   *uint64
+		Depth    int
+	}
+	if err := sql.db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var eids, rids []uint64
+	reached := make(map[uint64]struct{})
+	followed := make(map[uint64]struct{})
+	for _, row := range rows {
+		if _, found := reached[row.EntityID]; !found {
+			reached[row.EntityID] = struct{}{}
+			eids = append(eids, row.EntityID)
+		}
+		if row.EdgeID == nil {
+			continue
+		}
+		if _, found := followed[*row.EdgeID]; !found {
+			followed[*row.EdgeID] = struct{}{}
+			rids = append(rids, *row.EdgeID)
+		}
+	}
+
+	byid := make(map[uint64]*types.Entity, len(eids))
+	for start := 0; start < len(eids); start += sql.batchSize() {
+		end := min(start+sql.batchSize(), len(eids))
+
+		var entities []Entity
+		if err := sql.db.Where("entity_id IN ?", eids[start:end]).Find(&entities).Error; err != nil {
+			return nil, nil, err
+		}
+
+		for _, e := range entities {
+			asset, skip, err := sql.parseEntity(&e)
+			if skip {
+				continue
+			} else if err != nil {
+				return nil, nil, err
+			}
+
+			byid[e.ID] = &types.Entity{
+				ID:        strconv.FormatUint(e.ID, 10),
+				CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+				LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+				Asset:     asset,
+			}
+		}
+	}
+
+	var results []*types.Entity
+	for _, id := range eids {
+		if e, found := byid[id]; found {
+			results = append(results, e)
+		}
+	}
+	if len(results) == 0 {
+		return []*types.Entity{}, []*types.Edge{}, sql.opts.NoResults("zero entities found")
+	}
+
+	edges := []*types.Edge{}
+	for start := 0; start < len(rids); start += sql.batchSize() {
+		end := min(start+sql.batchSize(), len(rids))
+
+		var batch []Edge
+		if err := sql.db.Where("edge_id IN ?", rids[start:end]).Order("edge_id").Find(&batch).Error; err != nil {
+			return nil, nil, err
+		}
+		edges = append(edges, sql.toEdges(batch)...)
+	}
+	return results, edges, nil
+}
+
+// FindEdgesByRun finds all edges last written by the scan run with the provided ID.
+// Returns a slice of matching edges as []*types.Edge or an error if the search fails.
+func (sql *sqlRepository) FindEdgesByRun(runID string) ([]*types.Edge, error) {
+	var edges []Edge
+	if err := sql.db.Where("run_id = ?", runID).Find(&edges).Error; err != nil {
+		return nil, err
+	}
+
+	results := sql.toEdges(edges)
+	if len(results) == 0 {
+		return []*types.Edge{}, sql.opts.NoResults("zero edges found")
+	}
+	return results, nil
+}
+
+// resolveEndpoints returns the edge when the entities at both ends exist. Otherwise, when the MissingEndpoints
+// option is set to create them, a copy of the edge is returned with the missing entities created from their assets.
+func (sql *sqlRepository) resolveEndpoints(edge *types.Edge) (*types.Edge, error) {
+	existing, err := sql.existingEntities(edge.FromEntity.ID, edge.ToEntity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := sql.resolveEndpoint("from", edge.FromEntity, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := sql.resolveEndpoint("to", edge.ToEntity, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == edge.FromEntity && to == edge.ToEntity {
+		return edge, nil
+	}
+
+	resolved := *edge
+	resolved.FromEntity = from
+	resolved.ToEntity = to
+	return &resolved, nil
+}
+
+// existingEntities returns the set of the provided entity IDs that are present in the database,
+// checked with a single query.
+func (sql *sqlRepository) existingEntities(ids ...string) (map[string]struct{}, error) {
+	var keys []uint64
+	for _, id := range ids {
+		if key, err := strconv.ParseUint(id, 10, 64); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	existing := make(map[string]struct{}, len(keys))
+	if len(keys) == 0 {
+		return existing, nil
+	}
+
+	var found []uint64
+	if err := sql.db.Model(&Entity{}).Where("entity_id IN ?", keys).Pluck("entity_id", &found).Error; err != nil {
+		return nil, err
+	}
+
+	for _, key := range found {
+		existing[strconv.FormatUint(key, 10)] = struct{}{}
+	}
+	return existing, nil
+}
+
+func (sql *sqlRepository) resolveEndpoint(side string, entity *types.Entity, existing map[string]struct{}) (*types.Entity, error) {
+	if _, found := existing[entity.ID]; found {
+		return entity, nil
+	}
+
+	if sql.opts.MissingEndpoints != options.MissingEndpointCreate {
+		return nil, types.EndpointNotFound(side, entity.ID)
+	}
+	return sql.CreateEntity(&types.Entity{Asset: entity.Asset})
 }
 
 // DeleteEdge removes an edge in the database by its ID.
@@ -249,16 +840,25 @@ func (sql *sqlRepository) DeleteEdge(id string) error {
 	return sql.deleteEdges([]uint64{relId})
 }
 
-// deleteEdges removes all rows in the Edges table with primary keys in the provided slice.
+// deleteEdges removes all rows in the Edges table with primary keys in the provided slice, along with
+// the tags of the edges, in a single transaction.
 func (sql *sqlRepository) deleteEdges(ids []uint64) error {
-	return sql.db.Exec("DELETE FROM edges WHERE edge_id IN ?", ids).Error
+	return sql.db.Transaction(func(tx *gorm.DB) error {
+		repo := sql.withDB(tx)
+
+		if _, err := repo.deleteInBatches(&EdgeTag{}, "edge_id", ids); err != nil {
+			return err
+		}
+		_, err := repo.deleteInBatches(&Edge{}, "edge_id", ids)
+		return err
+	})
 }
 
 // toEdge converts a database Edge to a types.Edge.
-func toEdge(r Edge) *types.Edge {
+func (sql *sqlRepository) toEdge(r Edge) *types.Edge {
 	e := &r
 	rel, err := e.Parse()
-	if err != nil {
+	if sql.parsed(err) != nil {
 		return nil
 	}
 
@@ -279,11 +879,11 @@ func toEdge(r Edge) *types.Edge {
 }
 
 // toEdges converts a slice of database Edges to a slice of types.Edge structs.
-func toEdges(edges []Edge) []*types.Edge {
+func (sql *sqlRepository) toEdges(edges []Edge) []*types.Edge {
 	var res []*types.Edge
 
 	for _, r := range edges {
-		if e := toEdge(r); e != nil {
+		if e := sql.toEdge(r); e != nil {
 			res = append(res, e)
 		}
 	}
@@ -5,15 +5,26 @@
 package sqlrepo
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
 	"github.com/owasp-amass/open-asset-model/domain"
 	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/property"
 	"github.com/owasp-amass/open-asset-model/relation"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func TestUnfilteredRelations(t *testing.T) {
@@ -109,3 +120,810 @@ func TestUnfilteredRelations(t *testing.T) {
 		t.Errorf("rr.LastSeen: %s, r2Rel.LastSeen: %s", rr.LastSeen.Format(time.RFC3339Nano), r2Rel.LastSeen.Format(time.RFC3339Nano))
 	}
 }
+
+func TestParallelSRVEdges(t *testing.T) {
+	service, err := store.CreateAsset(&domain.FQDN{Name: "_sip._tcp.parallel.owasp.org"})
+	assert.NoError(t, err)
+
+	target, err := store.CreateAsset(&domain.FQDN{Name: "sip.parallel.owasp.org"})
+	assert.NoError(t, err)
+
+	srv1 := &relation.SRVDNSRelation{
+		Name:     "dns_record",
+		Header:   relation.RRHeader{RRType: 33, Class: 1, TTL: 86400},
+		Priority: 10,
+		Weight:   60,
+		Port:     5060,
+	}
+	srv2 := &relation.SRVDNSRelation{
+		Name:     "dns_record",
+		Header:   relation.RRHeader{RRType: 33, Class: 1, TTL: 86400},
+		Priority: 20,
+		Weight:   40,
+		Port:     5061,
+	}
+
+	e1, err := store.CreateEdge(&types.Edge{Relation: srv1, FromEntity: service, ToEntity: target})
+	assert.NoError(t, err)
+	e2, err := store.CreateEdge(&types.Edge{Relation: srv2, FromEntity: service, ToEntity: target})
+	assert.NoError(t, err)
+	assert.NotEqual(t, e1.ID, e2.ID)
+
+	outs, err := store.OutgoingEdges(service, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, outs, 2)
+
+	// the same relation content must still be deduplicated
+	e3, err := store.CreateEdge(&types.Edge{Relation: srv1, FromEntity: service, ToEntity: target})
+	assert.NoError(t, err)
+	assert.Equal(t, e1.ID, e3.ID)
+
+	// deduplicating by label collapses the parallel edges into the existing edge
+	bylabel := testRepository(store.db, options.New(options.WithEdgeDedup(options.EdgeDedupLabel)))
+
+	srv3 := &relation.SRVDNSRelation{
+		Name:     "dns_record",
+		Header:   relation.RRHeader{RRType: 33, Class: 1, TTL: 86400},
+		Priority: 30,
+		Weight:   10,
+		Port:     5062,
+	}
+	_, err = bylabel.CreateEdge(&types.Edge{Relation: srv3, FromEntity: service, ToEntity: target})
+	assert.NoError(t, err)
+
+	outs, err = store.OutgoingEdges(service, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, outs, 2)
+}
+
+func TestDeleteEdgesInBatches(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "batches.owasp.org"})
+	assert.NoError(t, err)
+
+	var ids []uint64
+	var edges []*types.Edge
+	for _, name := range []string{"a.batches.owasp.org", "b.batches.owasp.org", "c.batches.owasp.org"} {
+		to, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		edge, err := store.CreateEdge(&types.Edge{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5}},
+			FromEntity: from,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+		edges = append(edges, edge)
+
+		id, err := strconv.ParseUint(edge.ID, 10, 64)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	// exceed the sqlite variable limit within a single logical batch
+	for i := uint64(1); len(ids) < 2500; i++ {
+		ids = append(ids, math.MaxInt32-i)
+	}
+
+	assert.NoError(t, store.deleteEdges(ids))
+	for _, edge := range edges {
+		_, err := store.FindEdgeById(edge.ID)
+		assert.Error(t, err)
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	assert.Equal(t, defaultPostgresBatchSize, (&sqlRepository{dbtype: Postgres, opts: options.New()}).batchSize())
+	assert.Equal(t, defaultSQLiteBatchSize, (&sqlRepository{dbtype: SQLite, opts: options.New()}).batchSize())
+	assert.Equal(t, 250, (&sqlRepository{dbtype: SQLite, opts: options.New(options.WithBatchSize(250))}).batchSize())
+}
+
+func TestFindHubEntities(t *testing.T) {
+	hub, err := store.CreateAsset(&domain.FQDN{Name: "hub.owasp.org"})
+	assert.NoError(t, err)
+
+	leaf, err := store.CreateAsset(&domain.FQDN{Name: "leaf.owasp.org"})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"a.hub.owasp.org", "b.hub.owasp.org", "c.hub.owasp.org", "d.hub.owasp.org", "e.hub.owasp.org"} {
+		to, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEdge(&types.Edge{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5}},
+			FromEntity: hub,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+	}
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5}},
+		FromEntity: leaf,
+		ToEntity:   hub,
+	})
+	assert.NoError(t, err)
+
+	for _, direction := range []string{"outgoing", "total"} {
+		hubs, err := store.FindHubEntities(4, direction, time.Time{})
+		assert.NoError(t, err)
+
+		var foundHub, foundLeaf bool
+		for _, e := range hubs {
+			if e.ID == hub.ID {
+				foundHub = true
+			} else if e.ID == leaf.ID {
+				foundLeaf = true
+			}
+		}
+		assert.True(t, foundHub, "the hub was not returned for the %s direction", direction)
+		assert.False(t, foundLeaf, "the leaf was returned for the %s direction", direction)
+	}
+
+	incoming, err := store.FindHubEntities(1, "incoming", time.Time{})
+	assert.NoError(t, err)
+
+	var found bool
+	for _, e := range incoming {
+		if e.ID == hub.ID {
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	_, err = store.FindHubEntities(1, "sideways", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestAdjacentEdges(t *testing.T) {
+	alias, err := store.CreateAsset(&domain.FQDN{Name: "alias.adjacent.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.adjacent.owasp.org"})
+	assert.NoError(t, err)
+	ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("10.8.7.6"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	in, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: alias,
+		ToEntity:   www,
+	})
+	assert.NoError(t, err)
+	out, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+		FromEntity: www,
+		ToEntity:   ip,
+	})
+	assert.NoError(t, err)
+
+	edges, err := store.AdjacentEdges(www, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, edges, 2) {
+		for _, edge := range edges {
+			switch edge.ID {
+			case in.ID:
+				assert.False(t, edge.OutgoingFrom(www.ID))
+				assert.Equal(t, alias.ID, edge.FromEntity.ID)
+			case out.ID:
+				assert.True(t, edge.OutgoingFrom(www.ID))
+				assert.Equal(t, ip.ID, edge.ToEntity.ID)
+			default:
+				t.Errorf("unexpected edge %s", edge.ID)
+			}
+		}
+	}
+
+	edges, err = store.AdjacentEdges(www, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 2)
+
+	_, err = store.AdjacentEdges(www, time.Time{}, "node")
+	assert.Error(t, err)
+}
+
+func TestTraverseNeighborhood(t *testing.T) {
+	var chain []*types.Entity
+	for _, name := range []string{"traverse.owasp.org", "www.traverse.owasp.org", "api.traverse.owasp.org", "dev.traverse.owasp.org"} {
+		e, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		if n := len(chain); n > 0 {
+			_, err = store.CreateEdge(&types.Edge{
+				Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+				FromEntity: chain[n-1],
+				ToEntity:   e,
+			})
+			assert.NoError(t, err)
+		}
+		chain = append(chain, e)
+	}
+
+	other, err := store.CreateAsset(&domain.FQDN{Name: "other.traverse.owasp.org"})
+	assert.NoError(t, err)
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: other,
+		ToEntity:   chain[0],
+	})
+	assert.NoError(t, err)
+
+	ids := func(entities []*types.Entity) []string {
+		var results []string
+		for _, e := range entities {
+			results = append(results, e.ID)
+		}
+		return results
+	}
+
+	entities, edges, err := store.TraverseNeighborhood(chain[0], 2, types.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chain[0].ID, chain[1].ID, chain[2].ID}, ids(entities))
+	assert.Len(t, edges, 2)
+
+	entities, edges, err = store.TraverseNeighborhood(chain[2], 3, types.Incoming)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chain[2].ID, chain[1].ID, chain[0].ID, other.ID}, ids(entities))
+	assert.Len(t, edges, 3)
+
+	entities, edges, err = store.TraverseNeighborhood(chain[0], 1, types.Both)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{chain[0].ID, chain[1].ID, other.ID}, ids(entities))
+	assert.Len(t, edges, 2)
+
+	// only the edges with the labels are followed
+	entities, edges, err = store.TraverseNeighborhood(chain[0], 2, types.Both, "dns_record")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{chain[0].ID, chain[1].ID, chain[2].ID}, ids(entities))
+	assert.Len(t, edges, 2)
+
+	_, _, err = store.TraverseNeighborhood(chain[0], 0, types.Outgoing)
+	assert.Error(t, err)
+}
+
+func TestOutgoingEdgesForEntities(t *testing.T) {
+	var sources []*types.Entity
+	for _, name := range []string{"a.bulk.owasp.org", "b.bulk.owasp.org", "c.bulk.owasp.org"} {
+		source, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+		sources = append(sources, source)
+	}
+
+	lonely, err := store.CreateAsset(&domain.FQDN{Name: "lonely.bulk.owasp.org"})
+	assert.NoError(t, err)
+
+	for i, source := range sources {
+		for j := 0; j <= i; j++ {
+			ip, _ := netip.ParseAddr("192.168.50." + strconv.Itoa(i*10+j+1))
+			to, err := store.CreateAsset(&network.IPAddress{Address: ip, Type: "IPv4"})
+			assert.NoError(t, err)
+
+			_, err = store.CreateEdge(&types.Edge{
+				Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1}},
+				FromEntity: source,
+				ToEntity:   to,
+			})
+			assert.NoError(t, err)
+		}
+	}
+
+	edges, err := store.OutgoingEdgesForEntities(append(sources, lonely), time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, edges, len(sources))
+
+	for i, source := range sources {
+		assert.Len(t, edges[source.ID], i+1)
+		for _, edge := range edges[source.ID] {
+			assert.Equal(t, source.ID, edge.FromEntity.ID)
+		}
+	}
+	_, found := edges[lonely.ID]
+	assert.False(t, found)
+
+	_, err = store.OutgoingEdgesForEntities(sources, time.Time{}, "node")
+	assert.Error(t, err)
+}
+
+func TestFindByRun(t *testing.T) {
+	run1 := testRepository(store.db, options.New(options.WithRunID("run-find-1")))
+	run2 := testRepository(store.db, options.New(options.WithRunID("run-find-2")))
+
+	apex, err := run1.CreateAsset(&domain.FQDN{Name: "run.owasp.org"})
+	assert.NoError(t, err)
+	www, err := run1.CreateAsset(&domain.FQDN{Name: "www.run.owasp.org"})
+	assert.NoError(t, err)
+	e1, err := run1.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+
+	api, err := run2.CreateAsset(&domain.FQDN{Name: "api.run.owasp.org"})
+	assert.NoError(t, err)
+	_, err = run2.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: api,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+
+	// writes by a repository without a run ID do not clear the recorded run
+	_, err = store.CreateAsset(&domain.FQDN{Name: "www.run.owasp.org"})
+	assert.NoError(t, err)
+
+	entities, err := store.FindEntitiesByRun("run-find-1")
+	assert.NoError(t, err)
+	var ids []string
+	for _, e := range entities {
+		ids = append(ids, e.ID)
+	}
+	assert.ElementsMatch(t, []string{apex.ID, www.ID}, ids)
+
+	edges, err := store.FindEdgesByRun("run-find-1")
+	assert.NoError(t, err)
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, e1.ID, edges[0].ID)
+	}
+
+	entities, err = store.FindEntitiesByRun("run-find-2")
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, api.ID, entities[0].ID)
+	}
+
+	_, err = store.FindEntitiesByRun("run-find-missing")
+	assert.Error(t, err)
+}
+
+func TestRelationContentRoundTrip(t *testing.T) {
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "roundtrip.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.roundtrip.owasp.org"})
+	assert.NoError(t, err)
+
+	rel := &relation.BasicDNSRelation{
+		Name:   "dns_record",
+		Header: relation.RRHeader{RRType: 5, Class: 1, TTL: 3600},
+	}
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation:   rel,
+		FromEntity: www,
+		ToEntity:   apex,
+	})
+	assert.NoError(t, err)
+
+	found, err := store.FindEdgeById(edge.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, rel, found.Relation)
+
+	outs, err := store.OutgoingEdges(www, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, outs, 1)
+	assert.Equal(t, rel, outs[0].Relation)
+
+	ins, err := store.IncomingEdges(apex, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, ins, 1)
+	assert.Equal(t, rel, ins[0].Relation)
+}
+
+func TestUpsertEdge(t *testing.T) {
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "upsert.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.upsert.owasp.org"})
+	assert.NoError(t, err)
+
+	edge := &types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	}
+
+	e1, created, err := store.UpsertEdge(edge)
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	e2, created, err := store.UpsertEdge(edge)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, e1.ID, e2.ID)
+}
+
+func TestUpsertEdgeConcurrent(t *testing.T) {
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "race.upsert.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.race.upsert.owasp.org"})
+	assert.NoError(t, err)
+
+	edge := &types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: www,
+		ToEntity:   apex,
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var created int
+	ids := make(map[string]struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			e, c, err := store.UpsertEdge(edge)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if c {
+				created++
+			}
+			ids[e.ID] = struct{}{}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, created)
+	assert.Len(t, ids, 1)
+
+	edges, err := store.OutgoingEdges(www, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 1)
+
+	// the unique index rejects a duplicate written without the deduplication of relationships
+	var r Edge
+	for id := range ids {
+		eid, _ := strconv.ParseUint(id, 10, 64)
+		assert.NoError(t, store.db.First(&r, eid).Error)
+	}
+	dup := Edge{Type: r.Type, Content: r.Content, FromEntityID: r.FromEntityID, ToEntityID: r.ToEntityID}
+	assert.Error(t, store.db.Omit("run_id").Create(&dup).Error)
+}
+
+func TestUpsertEdgeConcurrentByLabel(t *testing.T) {
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "race.label.owasp.org"})
+	assert.NoError(t, err)
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.race.label.owasp.org"})
+	assert.NoError(t, err)
+
+	bylabel := testRepository(store.db, options.New(options.WithEdgeDedup(options.EdgeDedupLabel)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func(ttl int) {
+			defer wg.Done()
+
+			// the content differs for each call, so only the index on the label can reject the duplicates
+			_, _, err := bylabel.UpsertEdge(&types.Edge{
+				Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1, TTL: ttl}},
+				FromEntity: www,
+				ToEntity:   apex,
+			})
+			assert.NoError(t, err)
+		}(i + 1)
+	}
+	wg.Wait()
+
+	edges, err := store.OutgoingEdges(www, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, edges, 1)
+}
+
+func TestCreateEdges(t *testing.T) {
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "create-edges.owasp.org"})
+	assert.NoError(t, err)
+
+	var edges []*types.Edge
+	for i := 1; i <= 3; i++ {
+		ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr(fmt.Sprintf("10.9.8.%d", i)), Type: "IPv4"})
+		assert.NoError(t, err)
+
+		edges = append(edges, &types.Edge{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+			FromEntity: apex,
+			ToEntity:   ip,
+		})
+	}
+	// the duplicate within the batch must resolve to the edge created earlier in the batch
+	edges = append(edges, edges[0])
+
+	results, err := store.CreateEdges(edges)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, results[0].ID, results[3].ID)
+		assert.NotEqual(t, results[0].ID, results[1].ID)
+	}
+
+	outs, err := store.OutgoingEdges(apex, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Len(t, outs, 3)
+}
+
+func TestCreateEdgesContext(t *testing.T) {
+	apex, err := store.CreateAsset(&domain.FQDN{Name: "edgesctx.owasp.org"})
+	assert.NoError(t, err)
+	ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("10.9.7.1"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	edges := []*types.Edge{
+		{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+			FromEntity: apex,
+			ToEntity:   ip,
+		},
+		// the relationship is not valid in the taxonomy
+		{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+			FromEntity: ip,
+			ToEntity:   apex,
+		},
+		nil,
+	}
+
+	result, err := store.CreateEdgesContext(context.Background(), edges)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, result.Failed())
+	if assert.NotNil(t, result.Results[0]) {
+		_, err := store.FindEdgeById(result.Results[0].ID)
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err = store.CreateEdgesContext(ctx, edges[:1])
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int{0}, result.Failed())
+}
+
+// queryCounter is a GORM logger that counts the statements executed.
+type queryCounter struct {
+	logger.Interface
+	count atomic.Int64
+}
+
+func (q *queryCounter) LogMode(logger.LogLevel) logger.Interface {
+	return q
+}
+
+func (q *queryCounter) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	q.count.Add(1)
+}
+
+func BenchmarkLinkOneSource(b *testing.B) {
+	counter := &queryCounter{Interface: logger.Discard}
+	repo := testRepository(store.db.Session(&gorm.Session{Logger: counter}), options.New())
+
+	src, err := repo.CreateAsset(&domain.FQDN{Name: "bench-link.owasp.org"})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var edges []*types.Edge
+	for i := 1; i <= 50; i++ {
+		ip, err := repo.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr(fmt.Sprintf("10.99.0.%d", i)), Type: "IPv4"})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		edges = append(edges, &types.Edge{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+			FromEntity: src,
+			ToEntity:   ip,
+		})
+	}
+
+	b.Run("CreateEdge", func(b *testing.B) {
+		counter.count.Store(0)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			for _, edge := range edges {
+				if _, err := repo.CreateEdge(edge); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(counter.count.Load())/float64(b.N), "queries/op")
+	})
+
+	b.Run("CreateEdges", func(b *testing.B) {
+		counter.count.Store(0)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.CreateEdges(edges); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(counter.count.Load())/float64(b.N), "queries/op")
+	})
+}
+
+func TestMissingEndpoint(t *testing.T) {
+	rel := &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}}
+	missing := &types.Entity{ID: "999999999", Asset: &domain.FQDN{Name: "missing.endpoint.owasp.org"}}
+
+	www, err := store.CreateAsset(&domain.FQDN{Name: "www.endpoint.owasp.org"})
+	assert.NoError(t, err)
+
+	// the missing endpoint is reported by default
+	_, err = store.CreateEdge(&types.Edge{Relation: rel, FromEntity: www, ToEntity: missing})
+	assert.ErrorIs(t, err, types.ErrEndpointNotFound)
+	assert.ErrorContains(t, err, "the to entity")
+
+	_, err = store.CreateEdge(&types.Edge{Relation: rel, FromEntity: missing, ToEntity: www})
+	assert.ErrorIs(t, err, types.ErrEndpointNotFound)
+	assert.ErrorContains(t, err, "the from entity")
+
+	_, err = store.FindEntitiesByContent(missing.Asset, time.Time{})
+	assert.Error(t, err)
+
+	creating := testRepository(store.db, options.New(options.WithMissingEndpointPolicy(options.MissingEndpointCreate)))
+
+	edge, err := creating.CreateEdge(&types.Edge{Relation: rel, FromEntity: www, ToEntity: missing})
+	assert.NoError(t, err)
+
+	created, err := store.FindEntitiesByContent(missing.Asset, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, created[0].ID, edge.ToEntity.ID)
+	}
+}
+
+func TestDistinctRelationLabels(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "labels.owasp.org"})
+	assert.NoError(t, err)
+	to, err := store.CreateAsset(&domain.FQDN{Name: "www.labels.owasp.org"})
+	assert.NoError(t, err)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	labels, err := store.DistinctRelationLabels(time.Time{})
+	assert.NoError(t, err)
+	assert.Contains(t, labels, "dns_record")
+	assert.Contains(t, labels, "node")
+
+	labels, err = store.DistinctRelationLabels(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, labels)
+}
+
+func TestFindEntitiesWithEdgeTo(t *testing.T) {
+	with, err := store.CreateAsset(&domain.FQDN{Name: "a.edgeto.owasp.org"})
+	assert.NoError(t, err)
+	without, err := store.CreateAsset(&domain.FQDN{Name: "cname.edgeto.owasp.org"})
+	assert.NoError(t, err)
+	ip, err := store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.0.2.77"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}},
+		FromEntity: with,
+		ToEntity:   ip,
+	})
+	assert.NoError(t, err)
+	// the edge from the other FQDN leads to an FQDN instead of an IP address
+	_, err = store.CreateEdge(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: without,
+		ToEntity:   with,
+	})
+	assert.NoError(t, err)
+
+	entities, err := store.FindEntitiesWithEdgeTo(oam.FQDN, "dns_record", oam.IPAddress, time.Time{})
+	assert.NoError(t, err)
+	var ids []string
+	for _, e := range entities {
+		ids = append(ids, e.ID)
+	}
+	assert.Contains(t, ids, with.ID)
+	assert.NotContains(t, ids, without.ID)
+
+	_, err = store.FindEntitiesWithEdgeTo(oam.FQDN, "dns_record", oam.IPAddress, time.Now().Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestBetweenQueries(t *testing.T) {
+	now := time.Now()
+	before, inside, after := now.Add(-3*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour)
+	start, end := now.Add(-150*time.Minute), now.Add(-90*time.Minute)
+
+	from, err := store.CreateAsset(&domain.FQDN{Name: "between.owasp.org"})
+	assert.NoError(t, err)
+
+	var entities []*types.Entity
+	var edges []*types.Edge
+	for i, seen := range []time.Time{before, inside, after} {
+		e, err := store.CreateEntity(&types.Entity{
+			CreatedAt: seen,
+			LastSeen:  seen,
+			Asset:     &domain.FQDN{Name: string(rune('a'+i)) + ".between.owasp.org"},
+		})
+		assert.NoError(t, err)
+		entities = append(entities, e)
+
+		edge, err := store.CreateEdge(&types.Edge{
+			CreatedAt:  seen,
+			LastSeen:   seen,
+			Relation:   &relation.SimpleRelation{Name: "node"},
+			FromEntity: from,
+			ToEntity:   e,
+		})
+		assert.NoError(t, err)
+		edges = append(edges, edge)
+
+		_, err = store.CreateEntityTag(from, &types.EntityTag{
+			CreatedAt: seen,
+			LastSeen:  seen,
+			Property:  &property.SimpleProperty{PropertyName: "between", PropertyValue: string(rune('a' + i))},
+		})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEdgeTag(edges[0], &types.EdgeTag{
+			CreatedAt: seen,
+			LastSeen:  seen,
+			Property:  &property.SimpleProperty{PropertyName: "between", PropertyValue: string(rune('a' + i))},
+		})
+		assert.NoError(t, err)
+	}
+
+	found, err := store.FindEntitiesByTypeBetween(oam.FQDN, start, end)
+	assert.NoError(t, err)
+
+	var ids []string
+	for _, e := range found {
+		ids = append(ids, e.ID)
+	}
+	assert.Contains(t, ids, entities[1].ID)
+	assert.NotContains(t, ids, entities[0].ID)
+	assert.NotContains(t, ids, entities[2].ID)
+	assert.NotContains(t, ids, from.ID)
+
+	out, err := store.OutgoingEdgesBetween(from, start, end, "node")
+	assert.NoError(t, err)
+	if assert.Len(t, out, 1) {
+		assert.Equal(t, edges[1].ID, out[0].ID)
+	}
+
+	in, err := store.IncomingEdgesBetween(entities[2], start, end)
+	assert.Error(t, err)
+	assert.Empty(t, in)
+
+	// a zero start leaves the range without a lower bound
+	in, err = store.IncomingEdgesBetween(entities[0], time.Time{}, end)
+	assert.NoError(t, err)
+	assert.Len(t, in, 1)
+
+	etags, err := store.GetEntityTagsBetween(from, start, end, "between")
+	assert.NoError(t, err)
+	if assert.Len(t, etags, 1) {
+		assert.Equal(t, "b", etags[0].Property.Value())
+	}
+
+	dtags, err := store.GetEdgeTagsBetween(edges[0], start, end)
+	assert.NoError(t, err)
+	if assert.Len(t, dtags, 1) {
+		assert.Equal(t, "b", dtags[0].Property.Value())
+	}
+
+	_, err = store.FindEntitiesByTypeBetween(oam.FQDN, end, start)
+	assert.Error(t, err)
+	_, err = store.GetEntityTagsBetween(from, start, time.Time{})
+	assert.Error(t, err)
+}
@@ -5,41 +5,91 @@
 package sqlrepo
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // CreateEntity creates a new entity in the database.
 // It takes an Entity as input and persists it in the database.
 // The asset is serialized to JSON and stored in the Content field of the Entity struct.
+// When content validation is enabled, assets missing required fields are rejected.
 // Returns the created entity as a types.Entity or an error if the creation fails.
 func (sql *sqlRepository) CreateEntity(input *types.Entity) (*types.Entity, error) {
-	jsonContent, err := input.Asset.JSON()
+	e, _, err := sql.CreateEntityWithPrevLastSeen(input)
+	return e, err
+}
+
+// CreateEntityWithPrevLastSeen creates a new entity in the database, as done by CreateEntity.
+// When an existing entity is matched, the last seen time of the entity before the update is also returned,
+// which allows measuring the interval between observations without another query.
+// Returns the entity and the previous last seen time, which is zero when the entity was created,
+// or an error if the creation fails.
+func (sql *sqlRepository) CreateEntityWithPrevLastSeen(input *types.Entity) (*types.Entity, time.Time, error) {
+	if sql.opts.ValidateContent {
+		if err := types.ValidateAsset(input.Asset); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	jsonContent, err := sql.content(input.Asset)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
+	var prev time.Time
+	asset := input.Asset
 	entity := Entity{
 		Type:    string(input.Asset.AssetType()),
 		Content: jsonContent,
+		RunID:   sql.opts.RunID,
 	}
 
 	// ensure that duplicate entities are not entered into the database
-	if entities, err := sql.FindEntitiesByContent(input.Asset, time.Time{}); err == nil && len(entities) > 0 {
+	entities, err := sql.FindEntitiesByContent(input.Asset, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, time.Time{}, err
+	}
+	if len(entities) > 0 && input.Asset.AssetType() == entities[0].Asset.AssetType() {
 		e := entities[0]
+		prev = e.LastSeen
 
-		if input.Asset.AssetType() == e.Asset.AssetType() {
-			if id, err := strconv.ParseUint(e.ID, 10, 64); err == nil {
-				entity.ID = id
-				entity.CreatedAt = e.CreatedAt
-				entity.UpdatedAt = time.Now().UTC()
-			}
+		// the stored fields missing from the input are kept, since the match may come from a secondary key
+		merged, err := types.MergeAsset(e.Asset, input.Asset)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		content, err := sql.content(merged)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		// coalesce rapid re-observations of an unchanged entity
+		if existing, err := sql.content(e.Asset); err == nil &&
+			bytes.Equal(existing, content) && sql.opts.SkipLastSeenUpdate(e.LastSeen) {
+			return e, prev, nil
 		}
+
+		id, err := strconv.ParseUint(e.ID, 10, 64)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		asset = merged
+		entity.ID = id
+		entity.Content = content
+		entity.CreatedAt = e.CreatedAt
+		entity.UpdatedAt = time.Now().UTC()
 	} else {
 		if input.CreatedAt.IsZero() {
 			entity.CreatedAt = time.Now().UTC()
@@ -54,17 +104,17 @@ func (sql *sqlRepository) CreateEntity(input *types.Entity) (*types.Entity, erro
 		}
 	}
 
-	result := sql.db.Save(&entity)
+	result := sql.runWriter().Save(&entity)
 	if err := result.Error; err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 
 	return &types.Entity{
 		ID:        strconv.FormatUint(entity.ID, 10),
 		CreatedAt: entity.CreatedAt.In(time.UTC).Local(),
 		LastSeen:  entity.UpdatedAt.In(time.UTC).Local(),
-		Asset:     input.Asset,
-	}, nil
+		Asset:     asset,
+	}, prev, nil
 }
 
 // CreateAsset creates a new entity in the database.
@@ -75,22 +125,325 @@ func (sql *sqlRepository) CreateAsset(asset oam.Asset) (*types.Entity, error) {
 	return sql.CreateEntity(&types.Entity{Asset: asset})
 }
 
+// UpsertEntity ensures that an entity exists in the database for the asset, as done by CreateAsset.
+// The entities table does not have a unique index on the content, since near-duplicate entities are tolerated,
+// so the search and the creation are serialized instead. Calls within the process are serialized by a mutex,
+// and postgres also holds an advisory lock on the asset key for the transaction, which serializes the calls
+// made by other processes.
+// Returns the entity, and true if the entity was created or false if an existing entity was updated.
+func (sql *sqlRepository) UpsertEntity(asset oam.Asset) (*types.Entity, bool, error) {
+	if asset == nil {
+		return nil, false, errors.New("the asset is nil")
+	}
+
+	sql.upserts.Lock()
+	defer sql.upserts.Unlock()
+
+	var e *types.Entity
+	var created bool
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		e, created, err = sql.upsertEntityTx(tx, asset)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return e, created, nil
+}
+
+// upsertEntityTx searches for and creates the entity for the asset using the provided transaction.
+// The caller must hold the upserts mutex.
+func (sql *sqlRepository) upsertEntityTx(tx *gorm.DB, asset oam.Asset) (*types.Entity, bool, error) {
+	field, value, err := types.AssetKey(asset)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if sql.dbtype == Postgres {
+		key := fmt.Sprintf("%s:%s:%v", asset.AssetType(), field, value)
+
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+			return nil, false, err
+		}
+	}
+
+	txrepo := sql.withDB(tx)
+	existing, err := txrepo.FindEntitiesByContent(asset, time.Time{})
+	created := err != nil || len(existing) == 0
+
+	e, err := txrepo.CreateEntity(&types.Entity{Asset: asset})
+	if err != nil {
+		return nil, false, err
+	}
+	return e, created, nil
+}
+
+// CreateEntities creates entities in the database for the provided assets, using batched inserts.
+// The existing entities are found with one query per asset type, which matches the key field of the assets,
+// and are reused with the last seen time updated instead of being inserted again. An asset repeated in the
+// input produces a single entity.
+// Returns the entities in the order of the assets or an error if the creation fails.
+func (sql *sqlRepository) CreateEntities(assets []oam.Asset) ([]*types.Entity, error) {
+	type keyedAsset struct {
+		key   string
+		asset oam.Asset
+	}
+	type keyGroup struct {
+		field  string
+		values []interface{}
+	}
+
+	inputs := make([]keyedAsset, 0, len(assets))
+	groups := make(map[oam.AssetType]*keyGroup)
+	var secondary []oam.Asset
+	for _, asset := range assets {
+		if asset == nil {
+			return nil, errors.New("the asset is nil")
+		}
+		if sql.opts.ValidateContent {
+			if err := types.ValidateAsset(asset); err != nil {
+				return nil, err
+			}
+		}
+
+		primary, _, err := types.AssetKey(asset)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := types.AssetKeyCandidates(asset)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, keyedAsset{key: entityKey(asset.AssetType(), keys[0].Field, keys[0].Value), asset: asset})
+
+		// assets identified by another candidate key field are searched for one at a time
+		if keys[0].Field != primary {
+			secondary = append(secondary, asset)
+			continue
+		}
+
+		g, found := groups[asset.AssetType()]
+		if !found {
+			g = &keyGroup{field: primary}
+			groups[asset.AssetType()] = g
+		}
+		g.values = append(g.values, sql.keyParam(keys[0].Value))
+	}
+
+	entities := make(map[string]*types.Entity)
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		txrepo := sql.withDB(tx)
+
+		for atype, g := range groups {
+			for start := 0; start < len(g.values); start += sql.batchSize() {
+				end := min(start+sql.batchSize(), len(g.values))
+
+				var rows []Entity
+				if err := tx.Where("etype = ?", atype).Where(sql.contentField(g.field)+" IN ?", g.values[start:end]).
+					Order("entity_id").Find(&rows).Error; err != nil {
+					return err
+				}
+
+				for _, row := range rows {
+					asset, skip, err := txrepo.parseEntity(&row)
+					if skip {
+						continue
+					} else if err != nil {
+						return err
+					}
+
+					field, value, err := types.AssetKey(asset)
+					if err != nil {
+						return err
+					}
+					// the entity with the lowest ID is used, as done when a single asset is created
+					if key := entityKey(atype, field, value); entities[key] == nil {
+						entities[key] = &types.Entity{
+							ID:        strconv.FormatUint(row.ID, 10),
+							CreatedAt: row.CreatedAt.In(time.UTC).Local(),
+							LastSeen:  row.UpdatedAt.In(time.UTC).Local(),
+						}
+					}
+				}
+			}
+		}
+
+		for _, asset := range secondary {
+			keys, _ := types.AssetKeyCandidates(asset)
+
+			key := entityKey(asset.AssetType(), keys[0].Field, keys[0].Value)
+			existing, err := txrepo.FindEntitiesByContent(asset, time.Time{})
+			if err != nil && !errors.Is(err, types.ErrNoResults) {
+				return err
+			}
+			if len(existing) > 0 {
+				entities[key] = &types.Entity{
+					ID:        existing[0].ID,
+					CreatedAt: existing[0].CreatedAt,
+					LastSeen:  existing[0].LastSeen,
+				}
+			}
+		}
+
+		now := time.Now().UTC()
+		var ids []uint64
+		for _, e := range entities {
+			if sql.opts.SkipLastSeenUpdate(e.LastSeen) {
+				continue
+			}
+			if id, err := strconv.ParseUint(e.ID, 10, 64); err == nil {
+				ids = append(ids, id)
+				e.LastSeen = now.Local()
+			}
+		}
+
+		for start := 0; start < len(ids); start += sql.batchSize() {
+			end := min(start+sql.batchSize(), len(ids))
+
+			if err := tx.Model(&Entity{}).Where("entity_id IN ?", ids[start:end]).
+				Update("updated_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		rows := make(map[string]*Entity)
+		var created []*Entity
+		for _, input := range inputs {
+			if entities[input.key] != nil || rows[input.key] != nil {
+				continue
+			}
+
+			content, err := sql.content(input.asset)
+			if err != nil {
+				return err
+			}
+
+			row := &Entity{
+				CreatedAt: now,
+				UpdatedAt: now,
+				Type:      string(input.asset.AssetType()),
+				Content:   content,
+				RunID:     sql.opts.RunID,
+			}
+			rows[input.key] = row
+			created = append(created, row)
+		}
+		if len(created) == 0 {
+			return nil
+		}
+
+		if err := txrepo.runWriter().CreateInBatches(created, sql.batchSize()).Error; err != nil {
+			return err
+		}
+
+		for key, row := range rows {
+			entities[key] = &types.Entity{
+				ID:        strconv.FormatUint(row.ID, 10),
+				CreatedAt: row.CreatedAt.In(time.UTC).Local(),
+				LastSeen:  row.UpdatedAt.In(time.UTC).Local(),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*types.Entity, 0, len(inputs))
+	for _, input := range inputs {
+		e := *entities[input.key]
+
+		e.Asset = input.asset
+		results = append(results, &e)
+	}
+	return results, nil
+}
+
+// CreateEntitiesContext creates entities for the provided assets as done by CreateEntities, reporting the outcome
+// of each asset instead of failing the whole batch. The assets are written in sub-batches of the batch size, each in
+// its own transaction, and a sub-batch that fails is written again one asset at a time, so only the bad assets fail.
+// When the context is cancelled, the completed sub-batches remain committed and the assets that were not written
+// are reported with the context error.
+// Returns the outcome of each asset, and the context error if the operation was cancelled.
+func (sql *sqlRepository) CreateEntitiesContext(ctx context.Context, assets []oam.Asset) (*types.BulkResult[*types.Entity], error) {
+	result := types.NewBulkResult[*types.Entity](len(assets))
+	repo := sql.withDB(sql.db.WithContext(ctx))
+
+	for start := 0; start < len(assets); start += sql.batchSize() {
+		end := min(start+sql.batchSize(), len(assets))
+
+		if err := ctx.Err(); err != nil {
+			result.FailFrom(start, err)
+			return result, err
+		}
+
+		if entities, err := repo.CreateEntities(assets[start:end]); err == nil {
+			copy(result.Results[start:end], entities)
+			continue
+		}
+
+		for i := start; i < end; i++ {
+			if err := ctx.Err(); err != nil {
+				result.FailFrom(i, err)
+				return result, err
+			}
+
+			if entities, err := repo.CreateEntities(assets[i : i+1]); err != nil {
+				result.Errors[i] = err
+			} else {
+				result.Results[i] = entities[0]
+			}
+		}
+	}
+	return result, nil
+}
+
+// entityKey returns the string that identifies the asset of the provided type by the value of the key field.
+func entityKey(atype oam.AssetType, field string, value interface{}) string {
+	return fmt.Sprintf("%s:%s:%v", atype, field, value)
+}
+
+// keyParam returns the key field value as the parameter compared with the field extracted from the content column.
+// Postgres extracts the field as text, so values that are not strings are compared using their JSON encoding.
+func (sql *sqlRepository) keyParam(value interface{}) interface{} {
+	if _, ok := value.(string); ok || sql.dbtype != Postgres {
+		return value
+	}
+	if b, err := json.Marshal(value); err == nil {
+		return string(b)
+	}
+	return value
+}
+
 // FindEntityById finds an entity in the database by the ID.
 // It takes a string representing the entity ID and retrieves the corresponding entity from the database.
 // Returns the found entity as a types.Entity or an error if the asset is not found.
 func (sql *sqlRepository) FindEntityById(id string) (*types.Entity, error) {
+	return sql.findEntityById(sql.db, id)
+}
+
+// FindEntityByIdIncludingDeleted finds an entity in the database by its ID, including an entity that was
+// marked as deleted while the SoftDelete option was enabled, which allows the entity to be recovered.
+// Returns the found entity as *types.Entity or an error if the entity is not found.
+func (sql *sqlRepository) FindEntityByIdIncludingDeleted(id string) (*types.Entity, error) {
+	return sql.findEntityById(sql.db.Unscoped(), id)
+}
+
+func (sql *sqlRepository) findEntityById(tx *gorm.DB, id string) (*types.Entity, error) {
 	entityId, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
-	entity := Entity{ID: entityId}
-	result := sql.db.First(&entity)
+	var entity Entity
+	result := tx.Where("entity_id = ?", entityId).First(&entity)
 	if err := result.Error; err != nil {
-		return nil, err
+		return nil, notFound(err, types.ErrEntityNotFound)
 	}
 
-	assetData, err := entity.Parse()
+	assetData, _, err := sql.parseEntity(&entity)
 	if err != nil {
 		return nil, err
 	}
@@ -107,8 +460,18 @@ func (sql *sqlRepository) FindEntityById(id string) (*types.Entity, error) {
 // the since parameter. It takes an oam.Asset as input and searches for entities with matching content in the database.
 // If since.IsZero(), the parameter will be ignored.
 // The asset data is serialized to JSON and compared against the Content field of the Entity struct.
+// When no entity matches the primary key, the other candidate key fields populated in the asset are tried in order.
 // Returns a slice of matching entities as []*types.Entity or an error if the search fails.
 func (sql *sqlRepository) FindEntitiesByContent(assetData oam.Asset, since time.Time) ([]*types.Entity, error) {
+	keys, err := types.AssetKeyCandidates(assetData)
+	if err != nil {
+		return nil, err
+	}
+	// the primary key is skipped when only the other candidate key fields are populated
+	if primary, _, _ := types.AssetKey(assetData); keys[0].Field != primary {
+		return sql.findEntitiesByKeys(assetData, keys, since)
+	}
+
 	jsonContent, err := assetData.JSON()
 	if err != nil {
 		return nil, err
@@ -137,18 +500,129 @@ func (sql *sqlRepository) FindEntitiesByContent(assetData oam.Asset, since time.
 
 	var results []*types.Entity
 	for _, e := range entities {
-		if assetData, err := e.Parse(); err == nil {
-			results = append(results, &types.Entity{
-				ID:        strconv.FormatUint(e.ID, 10),
-				CreatedAt: e.CreatedAt.In(time.UTC).Local(),
-				LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
-				Asset:     assetData,
-			})
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return sql.findEntitiesByKeys(assetData, keys[1:], since)
+	}
+	return results, nil
+}
+
+// findEntitiesByKeys tries the candidate key fields in order, and returns the entities matching
+// the first field that finds any.
+func (sql *sqlRepository) findEntitiesByKeys(assetData oam.Asset, keys []types.AssetKeyField, since time.Time) ([]*types.Entity, error) {
+	for _, k := range keys {
+		entities, err := sql.FindEntitiesByField(assetData.AssetType(), k.Field, k.Value, since)
+		if err != nil && !errors.Is(err, types.ErrNoResults) {
+			return nil, err
+		}
+		if len(entities) > 0 {
+			return entities, nil
+		}
+	}
+	return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+}
+
+// FindEntityByContentLatest finds the entity in the database with the same content as the provided asset that was
+// seen most recently. This is useful when near-duplicate entities share the same content.
+// The candidate key fields populated in the asset are tried in order, as done by FindEntitiesByContent.
+// Returns the matching entity as a types.Entity or types.ErrEntityNotFound if there is no match.
+func (sql *sqlRepository) FindEntityByContentLatest(assetData oam.Asset) (*types.Entity, error) {
+	keys, err := types.AssetKeyCandidates(assetData)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		var entities []Entity
+		tx := sql.db.Where("etype = ?", assetData.AssetType()).
+			Where(datatypes.JSONQuery("content").Equals(jsonKeyValue(k.Value), k.Field)).
+			Order("updated_at DESC").Limit(1).Find(&entities)
+		if err := tx.Error; err != nil {
+			return nil, err
+		}
+		if len(entities) == 0 {
+			continue
+		}
+
+		e := entities[0]
+		a, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		return &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     a,
+		}, nil
+	}
+	return nil, types.ErrEntityNotFound
+}
+
+// FindEntitiesByContentFold finds entities in the database with the same asset type and a key field equal to the
+// key of the provided asset under case folding, last seen after the since parameter. The stored content is not
+// normalized, so entities stored as example.com are found when searching for Example.com.
+// Asset types without a string key field are matched exactly, as done by FindEntitiesByContent.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindEntitiesByContentFold(assetData oam.Asset, since time.Time) ([]*types.Entity, error) {
+	field, value, err := types.AssetKey(assetData)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := value.(string)
+	if !ok {
+		return sql.FindEntitiesByContent(assetData, since)
+	}
+
+	tx := sql.db.Where("etype = ?", assetData.AssetType()).
+		Where("LOWER("+sql.contentField(field)+") = ?", strings.ToLower(key))
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero entities found")
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
 	}
 	return results, nil
 }
@@ -158,38 +632,741 @@ func (sql *sqlRepository) FindEntitiesByContent(assetData oam.Asset, since time.
 // If since.IsZero(), the parameter will be ignored.
 // Returns a slice of matching entities as []*types.Entity or an error if the search fails.
 func (sql *sqlRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	return sql.findEntitiesByType(atype, since, time.Time{})
+}
+
+// FindEntitiesByTypeBetween finds all entities in the database of the provided asset type and last seen
+// between start and end, inclusive. If start.IsZero(), the range has no lower bound.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindEntitiesByTypeBetween(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return sql.findEntitiesByType(atype, start, end)
+}
+
+func (sql *sqlRepository) findEntitiesByType(atype oam.AssetType, start, end time.Time) ([]*types.Entity, error) {
 	var entities []Entity
-	var result *gorm.DB
 
-	if since.IsZero() {
-		result = sql.db.Where("etype = ?", atype).Find(&entities)
-	} else {
-		result = sql.db.Where("etype = ? AND updated_at >= ?", atype, since.UTC()).Find(&entities)
+	result := lastSeenBetween(sql.db.Where("etype = ?", atype), start, end).Find(&entities)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		f, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     f,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("no entities of the specified type")
 	}
+	return results, nil
+}
+
+// FindStaleEntities finds all entities in the database of the provided asset type that were last seen
+// more than olderThan before now. These are the entities that have not been re-observed recently.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindStaleEntities(atype oam.AssetType, olderThan time.Duration) ([]*types.Entity, error) {
+	cutoff := time.Now().Add(-olderThan)
+
+	var entities []Entity
+	result := sql.db.Where("etype = ? AND updated_at < ?", atype, cutoff.UTC()).Find(&entities)
 	if err := result.Error; err != nil {
 		return nil, err
 	}
 
 	var results []*types.Entity
 	for _, e := range entities {
-		if f, err := e.Parse(); err == nil {
-			results = append(results, &types.Entity{
-				ID:        strconv.FormatUint(e.ID, 10),
-				CreatedAt: e.CreatedAt.In(time.UTC).Local(),
-				LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
-				Asset:     f,
-			})
+		f, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     f,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// NewEntitiesCountByType counts the entities in the database created after the since parameter, grouped by the
+// asset type. Unlike counts based on the last seen time, re-observed entities that existed before since are not counted.
+// If since.IsZero(), all entities are counted.
+// Returns the number of new entities for each asset type or an error if the count fails.
+func (sql *sqlRepository) NewEntitiesCountByType(since time.Time) (map[oam.AssetType]int64, error) {
+	var rows []struct {
+		Etype string
+		Total int64
+	}
+
+	tx := sql.db.Model(&Entity{}).Select("etype, count(*) AS total")
+	if !since.IsZero() {
+		tx = tx.Where("created_at >= ?", since.UTC())
+	}
+	if err := tx.Group("etype").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	results := make(map[oam.AssetType]int64, len(rows))
+	for _, row := range rows {
+		results[oam.AssetType(row.Etype)] = row.Total
+	}
+	return results, nil
+}
+
+// CountEntitiesByType counts the entities in the database of the provided asset type and last seen after
+// the since parameter, without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+func (sql *sqlRepository) CountEntitiesByType(atype oam.AssetType, since time.Time) (int64, error) {
+	tx := sql.db.Model(&Entity{}).Where("etype = ?", atype)
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ChildNetblocks finds the Netblock or IPNetRecord entities in the database that are the immediate children of the
+// parent in the allocation tree, last seen after the since parameter. IPNetRecords are related by the handle and
+// parent handle fields, while Netblocks are related by CIDR containment.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of the child entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) ChildNetblocks(parent *types.Entity, since time.Time) ([]*types.Entity, error) {
+	if parent == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := types.ValidateNetblock(parent.Asset); err != nil {
+		return nil, err
+	}
+
+	candidates, err := sql.netblockCandidates(parent.Asset, true, since)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := types.ChildNetblocks(parent, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return children, nil
+}
+
+// ParentNetblock finds the Netblock or IPNetRecord entity in the database that is the parent of the child in the
+// allocation tree, last seen after the since parameter. For Netblocks, the smallest containing CIDR is the parent.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the parent entity as a types.Entity or an error if the parent is not found.
+func (sql *sqlRepository) ParentNetblock(child *types.Entity, since time.Time) (*types.Entity, error) {
+	if child == nil {
+		return nil, errors.New("failed input validation checks")
+	}
+	if err := types.ValidateNetblock(child.Asset); err != nil {
+		return nil, err
+	}
+
+	// without any netblocks of the type, the parent is not found
+	candidates, err := sql.netblockCandidates(child.Asset, false, since)
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return nil, err
+	}
+
+	parent, err := types.ParentNetblock(child, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("the parent netblock was not found: %w", types.ErrEntityNotFound)
+	}
+	return parent, nil
+}
+
+// netblockCandidates finds the entities of the asset type of the Netblock or IPNetRecord that can be its children,
+// or its parent when children is false, last seen after the since parameter. IPNetRecords are matched by the handle
+// fields, while Netblocks are narrowed by the IP version and the leading octets of the CIDR text, so the caller
+// must still check the allocation tree.
+func (sql *sqlRepository) netblockCandidates(asset oam.Asset, children bool, since time.Time) ([]*types.Entity, error) {
+	cidr, handle, parentHandle, err := types.NetblockFields(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	atype := asset.AssetType()
+	if atype == oam.IPNetRecord {
+		field, value := "handle", parentHandle
+		if children {
+			field, value = "parent_handle", handle
+		}
+		if value == "" {
+			return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+		}
+		return sql.FindEntitiesByField(atype, field, value, since)
+	}
+
+	column := sql.contentField("cidr")
+	tx := sql.db.Where("etype = ?", atype)
+	if !cidr.Addr().Is4() {
+		tx = tx.Where(column+" LIKE ?", "%:%")
+	} else if octets := strings.Split(cidr.Addr().String(), "."); children {
+		// the children share the whole octets of the parent prefix
+		prefix := "%"
+		if n := cidr.Bits() / 8; n > 0 {
+			prefix = strings.Join(octets[:n], ".")
+		}
+		tx = tx.Where(column+" LIKE ?", prefix+".%")
+	} else {
+		// the parents share the first octet, unless the prefix length is a single digit
+		tx = tx.Where("("+column+" LIKE ? OR "+column+" LIKE ?)", octets[0]+".%", "%/_")
+	}
+
+	var entities []Entity
+	if err := lastSeenBetween(tx, since, time.Time{}).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByTypePagedWithTotal finds a page of entities in the database of the provided asset type and last seen
+// after the since parameter, along with the total number of entities matching the same criteria.
+// If since.IsZero(), the parameter will be ignored.
+// The page and the total are obtained within a single transaction to keep the two results consistent.
+// Returns the page of matching entities as []*types.Entity, the total count, or an error if the search fails.
+func (sql *sqlRepository) FindEntitiesByTypePagedWithTotal(atype oam.AssetType, since time.Time, limit, offset int) ([]*types.Entity, int64, error) {
+	var total int64
+	var entities []Entity
+
+	filter := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("etype = ?", atype)
+		if !since.IsZero() {
+			db = db.Where("updated_at >= ?", since.UTC())
+		}
+		return db
+	}
+
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Entity{}).Scopes(filter).Count(&total).Error; err != nil {
+			return err
+		}
+		return tx.Scopes(filter).Order("entity_id").Limit(limit).Offset(offset).Find(&entities).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		f, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     f,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, total, sql.opts.NoResults("no entities of the specified type")
+	}
+	return results, total, nil
+}
+
+// FindEntitiesByField finds all entities in the database of the provided asset type, where the named field of the
+// asset content equals the value, and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field is not valid or the search fails.
+func (sql *sqlRepository) FindEntitiesByField(atype oam.AssetType, field string, value any, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	tx := sql.db.Where("etype = ?", atype)
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	tx = tx.Where(datatypes.JSONQuery("content").Equals(value, field)).Find(&entities)
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByFieldRegex finds all entities in the database of the provided asset type, where the text of the
+// named field of the asset content matches the regular expression pattern, and last seen after the since parameter.
+// The pattern uses the Go regexp syntax and is compiled before the search. Sqlite applies the pattern in the query
+// through a REGEXP function that uses the Go regexp package. Postgres narrows the entities with the ~ operator,
+// which uses the POSIX regular expression dialect, so the pattern must be valid in both dialects. The Go regexp is
+// applied to the entities returned by either database, so the results follow the Go syntax.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field or pattern is not valid or the search fails.
+func (sql *sqlRepository) FindEntitiesByFieldRegex(atype oam.AssetType, field, pattern string, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	op := "REGEXP"
+	if sql.dbtype == Postgres {
+		op = "~"
+	}
+
+	tx := sql.db.Where("etype = ?", atype).Where(sql.contentField(field)+" "+op+" ?", pattern)
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !types.AssetFieldMatches(assetData, field, re) {
+			continue
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByFieldRange finds all entities in the database of the provided asset type, where the named numeric
+// field of the asset content is within the inclusive range from min to max, and last seen after the since parameter.
+// A nil bound leaves that side of the range open. If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the field is not numeric or the search fails.
+func (sql *sqlRepository) FindEntitiesByFieldRange(atype oam.AssetType, field string, min, max any, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateNumericAssetField(atype, field); err != nil {
+		return nil, err
+	}
+
+	lo, hasMin, err := types.NumericBound(min)
+	if err != nil {
+		return nil, err
+	}
+
+	hi, hasMax, err := types.NumericBound(max)
+	if err != nil {
+		return nil, err
+	}
+
+	column := sql.contentField(field)
+	if sql.dbtype == Postgres {
+		column = "CAST(" + column + " AS numeric)"
+	}
+
+	tx := sql.db.Where("etype = ?", atype)
+	if hasMin {
+		tx = tx.Where(column+" >= ?", lo)
+	}
+	if hasMax {
+		tx = tx.Where(column+" <= ?", hi)
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// SearchFQDNs finds all FQDN entities with a name containing the provided substring, ignoring case, and last seen after
+// the since parameter.
+// The wildcard characters % and _ in the substring are matched literally.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) SearchFQDNs(substr string, since time.Time) ([]*types.Entity, error) {
+	// LIKE ignores case in sqlite, while postgres requires ILIKE
+	op := "LIKE"
+	if sql.dbtype == Postgres {
+		op = "ILIKE"
+	}
+
+	tx := sql.db.Where("etype = ?", oam.FQDN).Where(sql.contentField("name")+" "+op+` ? ESCAPE '\'`, "%"+escapeLike(substr)+"%")
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// SearchEntities finds all entities with serialized content containing the query, ignoring case, that are of one of
+// the provided asset types and last seen after the since parameter. The query is matched against the stored JSON,
+// so field names also match, and characters escaped by the JSON encoding only match in their escaped form.
+// The wildcard characters % and _ in the query are matched literally.
+// The search cannot use an index and scans the content of every entity of the asset types, so the types should be
+// provided whenever possible. The query must have at least types.MinSearchQueryLength characters.
+// If no asset types are provided, entities of all types are searched.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) SearchEntities(query string, atypes []oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateSearchQuery(query); err != nil {
+		return nil, err
+	}
+
+	// the LIKE operator of sqlite ignores the case of ASCII characters
+	op := "LIKE"
+	column := "content"
+	if sql.dbtype == Postgres {
+		op = "ILIKE"
+		column = "content::text"
+	}
+
+	tx := sql.db.Where(column+" "+op+` ? ESCAPE '\'`, "%"+escapeLike(query)+"%")
+	if len(atypes) > 0 {
+		tx = tx.Where("etype IN ?", atypes)
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("no entities of the specified type")
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
 	}
 	return results, nil
 }
 
+// contentField returns the SQL expression that extracts the text of the top-level content field with the provided name.
+// The field name is written into the expression, so it must not come from user input.
+func (sql *sqlRepository) contentField(field string) string {
+	if sql.dbtype == Postgres {
+		return "content->>'" + field + "'"
+	}
+	return "json_extract(content, '$." + field + "')"
+}
+
+// tableContentField returns the expression that extracts the text of the named field from the content column
+// of the provided table or alias, for queries that join tables.
+func (sql *sqlRepository) tableContentField(table, field string) string {
+	if sql.dbtype == Postgres {
+		return table + ".content->>'" + field + "'"
+	}
+	return "json_extract(" + table + ".content, '$." + field + "')"
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern using the backslash.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// FindServicesByAttribute finds all Service entities with the provided value among the values of the
+// header attribute with the provided key, last seen after the since parameter.
+// The key is matched exactly, so canonical header keys must be used for headers set with http.Header.Set.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindServicesByAttribute(key, value string, since time.Time) ([]*types.Entity, error) {
+	tx := sql.db.Where("etype = ?", oam.Service)
+	if sql.dbtype == Postgres {
+		// the containment operator matches the value as an element of the attribute array
+		contains, err := json.Marshal(map[string][]string{key: {value}})
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("content->'headers' @> ?::jsonb", string(contains))
+	} else {
+		path := `$.headers."` + key + `"`
+		tx = tx.Where("EXISTS (SELECT 1 FROM json_each(content, ?) WHERE json_each.value = ?)", path, value)
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var entities []Entity
+	if err := tx.Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByRun finds all entities last written by the scan run with the provided ID.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindEntitiesByRun(runID string) ([]*types.Entity, error) {
+	var entities []Entity
+	if err := sql.db.Where("run_id = ?", runID).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range entities {
+		assetData, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     assetData,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
+	}
+	return results, nil
+}
+
+// UpdateEntityContentCAS replaces the content of the entity with the provided ID, only when the stored content
+// is equal to the expected asset. The comparison and the update are performed by a single statement, so a
+// concurrent change to the entity causes the update to be rejected instead of lost.
+// Returns true if the content was updated, or an error if the entity is not found or the update fails.
+func (sql *sqlRepository) UpdateEntityContentCAS(id string, expected, new oam.Asset) (bool, error) {
+	if expected == nil || new == nil {
+		return false, errors.New("the expected and new assets must not be nil")
+	}
+	if expected.AssetType() != new.AssetType() {
+		return false, errors.New("the new asset type does not match the expected asset type")
+	}
+	if sql.opts.ValidateContent {
+		if err := types.ValidateAsset(new); err != nil {
+			return false, err
+		}
+	}
+
+	entityId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	var entity Entity
+	if err := sql.db.Where("entity_id = ?", entityId).First(&entity).Error; err != nil {
+		return false, notFound(err, types.ErrEntityNotFound)
+	}
+	if entity.Type != string(expected.AssetType()) {
+		return false, nil
+	}
+
+	current, err := entity.Parse()
+	if err != nil {
+		return false, err
+	}
+
+	// compare the serialized assets, since the stored JSON may not be byte-for-byte equal
+	currentContent, err := current.JSON()
+	if err != nil {
+		return false, err
+	}
+	expectedContent, err := expected.JSON()
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(currentContent, expectedContent) {
+		return false, nil
+	}
+
+	newContent, err := sql.content(new)
+	if err != nil {
+		return false, err
+	}
+
+	// the stored content must still be what was read above when the update is applied
+	result := sql.db.Model(&Entity{}).
+		Where("entity_id = ? AND content = ?", entityId, entity.Content).
+		Updates(map[string]interface{}{
+			"content":    datatypes.JSON(newContent),
+			"updated_at": time.Now().UTC(),
+		})
+	if err := result.Error; err != nil {
+		return false, err
+	}
+	return result.RowsAffected == 1, nil
+}
+
 // DeleteEntity removes an entity in the database by its ID.
-// It takes a string representing the entity ID and removes the corresponding entity from the database.
+// It takes a string representing the entity ID and removes the corresponding entity from the database,
+// along with the tags of the entity, the edges to and from the entity, and the tags of those edges.
+// The records are removed in one transaction, so no orphaned tags or edges are left behind.
+// When the SoftDelete option is enabled, the records are marked as deleted instead of being removed.
 // Returns an error if the entity is not found.
 func (sql *sqlRepository) DeleteEntity(id string) error {
 	entityId, err := strconv.ParseUint(id, 10, 64)
@@ -197,7 +1374,19 @@ func (sql *sqlRepository) DeleteEntity(id string) error {
 		return err
 	}
 
-	entity := Entity{ID: entityId}
-	result := sql.db.Delete(&entity)
-	return result.Error
+	return sql.db.Transaction(func(tx *gorm.DB) error {
+		edges := tx.Model(&Edge{}).Select("edge_id").
+			Where("from_entity_id = ? OR to_entity_id = ?", entityId, entityId)
+
+		if err := sql.deleter(tx).Where("edge_id IN (?)", edges).Delete(&EdgeTag{}).Error; err != nil {
+			return err
+		}
+		if err := sql.deleter(tx).Where("from_entity_id = ? OR to_entity_id = ?", entityId, entityId).Delete(&Edge{}).Error; err != nil {
+			return err
+		}
+		if err := sql.deleter(tx).Where("entity_id = ?", entityId).Delete(&EntityTag{}).Error; err != nil {
+			return err
+		}
+		return sql.deleter(tx).Delete(&Entity{ID: entityId}).Error
+	})
 }
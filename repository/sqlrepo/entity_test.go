@@ -5,30 +5,48 @@
 package sqlrepo
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/netip"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/glebarez/sqlite"
 	pgmigrations "github.com/owasp-amass/asset-db/migrations/postgres"
 	sqlitemigrations "github.com/owasp-amass/asset-db/migrations/sqlite3"
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/contact"
 	"github.com/owasp-amass/open-asset-model/domain"
 	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/people"
 	oamreg "github.com/owasp-amass/open-asset-model/registration"
 	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/owasp-amass/open-asset-model/service"
 	migrate "github.com/rubenv/sql-migrate"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var store *sqlRepository
 
+// storeDSN is the data source name of the database used by the store.
+var storeDSN string
+
 type testSetup struct {
 	name     string
 	dsn      string
@@ -161,6 +179,7 @@ func TestMain(m *testing.M) {
 		}
 
 		store, _ = New(w.name, w.dsn)
+		storeDSN = w.dsn
 		exitCodes[i] = m.Run()
 		if w.teardown != nil {
 			w.teardown(w.dsn)
@@ -176,6 +195,14 @@ func TestMain(m *testing.M) {
 	os.Exit(0)
 }
 
+// testRepository returns a repository using the database handle and the options, which shares the upserts
+// mutex and the parse error counter of the store, as done by the repositories of its transactions.
+func testRepository(db *gorm.DB, opts *options.Options) *sqlRepository {
+	repo := store.withDB(db)
+	repo.opts = opts
+	return repo
+}
+
 func TestLastSeenUpdates(t *testing.T) {
 	ip, _ := netip.ParseAddr("45.73.25.1")
 	asset := &network.IPAddress{Address: ip, Type: "IPv4"}
@@ -372,3 +399,955 @@ func TestGetDBType(t *testing.T) {
 		t.Errorf("Unexpected result. Expected: %s, Got: %s", expected, result)
 	}
 }
+
+func TestFindEntitiesByTypePagedWithTotal(t *testing.T) {
+	for i := 1; i <= 5; i++ {
+		_, err := store.CreateAsset(&network.AutonomousSystem{Number: 64500 + i})
+		assert.NoError(t, err)
+	}
+
+	ids := make(map[string]struct{})
+	page, total, err := store.FindEntitiesByTypePagedWithTotal(oam.AutonomousSystem, time.Time{}, 2, 0)
+	assert.NoError(t, err)
+	assert.Len(t, page, 2)
+	if total < 5 {
+		t.Fatalf("expected a total of at least 5 entities, got %d", total)
+	}
+
+	for offset := 0; int64(offset) < total; offset += 2 {
+		entities, count, err := store.FindEntitiesByTypePagedWithTotal(oam.AutonomousSystem, time.Time{}, 2, offset)
+		assert.NoError(t, err)
+		assert.Equal(t, total, count)
+
+		for _, e := range entities {
+			if _, found := ids[e.ID]; found {
+				t.Errorf("entity %s was returned on more than one page", e.ID)
+			}
+			ids[e.ID] = struct{}{}
+		}
+	}
+	assert.Equal(t, total, int64(len(ids)))
+
+	_, count, err := store.FindEntitiesByTypePagedWithTotal(oam.AutonomousSystem, time.Time{}, 2, int(total))
+	assert.Error(t, err)
+	assert.Equal(t, total, count)
+}
+
+func TestFindEntityByIdReturnsRequestedEntity(t *testing.T) {
+	first, err := store.CreateAsset(&domain.FQDN{Name: "first.byid.owasp.org"})
+	assert.NoError(t, err)
+
+	second, err := store.CreateAsset(&domain.FQDN{Name: "second.byid.owasp.org"})
+	assert.NoError(t, err)
+
+	for _, expected := range []*types.Entity{second, first} {
+		entity, err := store.FindEntityById(expected.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, expected.ID, entity.ID)
+		assert.Equal(t, expected.Asset, entity.Asset)
+	}
+
+	_, err = store.FindEntityById("999999999")
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByField(t *testing.T) {
+	for _, o := range []*org.Organization{
+		{Name: "Tech One, Inc.", Industry: "Byfield Technology"},
+		{Name: "Tech Two, Inc.", Industry: "Byfield Technology"},
+		{Name: "Farm, Inc.", Industry: "Byfield Agriculture"},
+	} {
+		_, err := store.CreateAsset(o)
+		assert.NoError(t, err)
+	}
+
+	entities, err := store.FindEntitiesByField(oam.Organization, "industry", "Byfield Technology", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 2)
+	for _, e := range entities {
+		assert.Equal(t, "Byfield Technology", e.Asset.(*org.Organization).Industry)
+	}
+
+	_, err = store.CreateAsset(&people.Person{FullName: "Jane Byfield", FirstName: "Jane", FamilyName: "Byfield"})
+	assert.NoError(t, err)
+	_, err = store.CreateAsset(&people.Person{FullName: "John Smith", FirstName: "John", FamilyName: "Smith"})
+	assert.NoError(t, err)
+
+	entities, err = store.FindEntitiesByField(oam.Person, "family_name", "Byfield", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "Jane Byfield", entities[0].Asset.(*people.Person).FullName)
+
+	_, err = store.FindEntitiesByField(oam.Person, "industry", "Byfield Technology", time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByField(oam.Organization, "industry", "Byfield Mining", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByFieldRange(t *testing.T) {
+	for _, num := range []int{4200000100, 4200000110, 4200000120, 4200000130} {
+		_, err := store.CreateAsset(&network.AutonomousSystem{Number: num})
+		assert.NoError(t, err)
+	}
+
+	entities, err := store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 4200000105, 4200000120, time.Time{})
+	assert.NoError(t, err)
+	var nums []int
+	for _, e := range entities {
+		nums = append(nums, e.Asset.(*network.AutonomousSystem).Number)
+	}
+	assert.ElementsMatch(t, []int{4200000110, 4200000120}, nums)
+
+	entities, err = store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 4200000125, nil, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	_, err = store.FindEntitiesByFieldRange(oam.AutonomousSystem, "number", 4200000200, 4200000300, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByFieldRange(oam.FQDN, "name", 1, 2, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByFieldRegex(t *testing.T) {
+	for _, name := range []string{"dev1.regex.owasp.org", "staging22.regex.owasp.org", "prod1.regex.owasp.org", "devx.regex.owasp.org"} {
+		_, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+
+	entities, err := store.FindEntitiesByFieldRegex(oam.FQDN, "name", `^(dev|staging)\d+\.regex\.owasp\.org$`, time.Time{})
+	assert.NoError(t, err)
+	var names []string
+	for _, e := range entities {
+		names = append(names, e.Asset.(*domain.FQDN).Name)
+	}
+	assert.ElementsMatch(t, []string{"dev1.regex.owasp.org", "staging22.regex.owasp.org"}, names)
+
+	// the flags of the Go syntax are honored by the database
+	entities, err = store.FindEntitiesByFieldRegex(oam.FQDN, "name", `(?i)^PROD\d+\.regex\.owasp\.org$`, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, "prod1.regex.owasp.org", entities[0].Asset.(*domain.FQDN).Name)
+	}
+
+	_, err = store.FindEntitiesByFieldRegex(oam.FQDN, "name", `^qa\d+\.regex\.owasp\.org$`, time.Time{})
+	assert.Error(t, err)
+
+	// the pattern is validated before the search
+	_, err = store.FindEntitiesByFieldRegex(oam.FQDN, "name", `^(dev`, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByFieldRegex(oam.FQDN, "industry", `.*`, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestSearchFQDNs(t *testing.T) {
+	for _, name := range []string{"admin.search.owasp.org", "www.search.owasp.org", "100%.search.owasp.org", "1000.search.owasp.org"} {
+		_, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+
+	entities, err := store.SearchFQDNs("admin.search", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "admin.search.owasp.org", entities[0].Asset.(*domain.FQDN).Name)
+
+	_, err = store.SearchFQDNs("nomatch.search", time.Time{})
+	assert.Error(t, err)
+
+	// the percent sign must be matched literally instead of as a wildcard
+	entities, err = store.SearchFQDNs("100%.search", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "100%.search.owasp.org", entities[0].Asset.(*domain.FQDN).Name)
+
+	// the substring is matched regardless of case
+	entities, err = store.SearchFQDNs("ADMIN.Search", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "admin.search.owasp.org", entities[0].Asset.(*domain.FQDN).Name)
+}
+
+func TestSearchEntities(t *testing.T) {
+	_, err := store.CreateAsset(&domain.FQDN{Name: "www.FullText.owasp.org"})
+	assert.NoError(t, err)
+	_, err = store.CreateAsset(&network.IPAddress{Address: netip.MustParseAddr("192.168.77.1"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	entities, err := store.SearchEntities("FULLTEXT.owasp", nil, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "www.FullText.owasp.org", entities[0].Asset.Key())
+
+	entities, err = store.SearchEntities("192.168.77", []oam.AssetType{oam.IPAddress}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+
+	_, err = store.SearchEntities("192.168.77", []oam.AssetType{oam.FQDN}, time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.SearchEntities("fulltext", nil, time.Now().Add(time.Hour))
+	assert.Error(t, err)
+
+	// the query is validated before the search
+	_, err = store.SearchEntities("ow", nil, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestCreateEntityContentValidation(t *testing.T) {
+	validating := testRepository(store.db, options.New(options.WithContentValidation()))
+
+	_, err := validating.CreateAsset(&domain.FQDN{Name: "validated.owasp.org"})
+	assert.NoError(t, err)
+
+	_, err = validating.CreateAsset(&domain.FQDN{})
+	assert.Error(t, err)
+
+	_, err = validating.CreateAsset(&network.IPAddress{Type: "IPv4"})
+	assert.Error(t, err)
+
+	// lenient callers are not affected by default
+	_, err = store.CreateAsset(&network.AutonomousSystem{Number: 0})
+	assert.NoError(t, err)
+}
+
+func TestLastSeenWindow(t *testing.T) {
+	windowed := testRepository(store.db, options.New(options.WithLastSeenWindow(time.Hour)))
+
+	ip, _ := netip.ParseAddr("45.73.25.2")
+	asset := &network.IPAddress{Address: ip, Type: "IPv4"}
+	a1, err := windowed.CreateAsset(asset)
+	assert.NoError(t, err)
+
+	// Nanoseconds are truncated by the database, so we need to sleep for a bit.
+	time.Sleep(1000 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		a2, err := windowed.CreateAsset(asset)
+		assert.NoError(t, err)
+		assert.Equal(t, a1.ID, a2.ID)
+		assert.Equal(t, a1.LastSeen.Unix(), a2.LastSeen.Unix())
+	}
+
+	// without the window, the re-observation updates the last seen time
+	a3, err := store.CreateAsset(asset)
+	assert.NoError(t, err)
+	assert.Greater(t, a3.LastSeen.Unix(), a1.LastSeen.Unix())
+}
+
+func TestUpdateEntityContentCAS(t *testing.T) {
+	original := &domain.FQDN{Name: "cas.owasp.org"}
+	entity, err := store.CreateAsset(original)
+	assert.NoError(t, err)
+
+	updated := &domain.FQDN{Name: "cas-updated.owasp.org"}
+	applied, err := store.UpdateEntityContentCAS(entity.ID, original, updated)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	found, err := store.FindEntityById(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, found.Asset)
+
+	// the expected value is now stale, so the update must be rejected
+	applied, err = store.UpdateEntityContentCAS(entity.ID, original, &domain.FQDN{Name: "cas-stale.owasp.org"})
+	assert.NoError(t, err)
+	assert.False(t, applied)
+
+	found, err = store.FindEntityById(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, found.Asset)
+
+	_, err = store.UpdateEntityContentCAS(entity.ID, updated, &network.AutonomousSystem{Number: 64496})
+	assert.Error(t, err)
+}
+
+func TestFindServicesByAttribute(t *testing.T) {
+	nginx, err := store.CreateAsset(&service.Service{
+		Identifier: "attr-service-nginx",
+		Headers:    http.Header{"Server": []string{"nginx"}, "X-Frame-Options": []string{"DENY", "SAMEORIGIN"}},
+	})
+	assert.NoError(t, err)
+
+	apache, err := store.CreateAsset(&service.Service{
+		Identifier: "attr-service-apache",
+		Headers:    http.Header{"Server": []string{"Apache"}, "X-Frame-Options": []string{"DENY"}},
+	})
+	assert.NoError(t, err)
+
+	_, err = store.CreateAsset(&service.Service{Identifier: "attr-service-none"})
+	assert.NoError(t, err)
+
+	entities, err := store.FindServicesByAttribute("Server", "nginx", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, nginx.ID, entities[0].ID)
+	}
+
+	// the value only needs to be one of the values for the attribute
+	entities, err = store.FindServicesByAttribute("X-Frame-Options", "SAMEORIGIN", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, nginx.ID, entities[0].ID)
+	}
+
+	entities, err = store.FindServicesByAttribute("X-Frame-Options", "DENY", time.Time{})
+	assert.NoError(t, err)
+	var ids []string
+	for _, e := range entities {
+		ids = append(ids, e.ID)
+	}
+	assert.Contains(t, ids, nginx.ID)
+	assert.Contains(t, ids, apache.ID)
+
+	_, err = store.FindServicesByAttribute("Server", "IIS", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByContentFold(t *testing.T) {
+	stored, err := store.CreateAsset(&domain.FQDN{Name: "fold.example.com"})
+	assert.NoError(t, err)
+
+	_, err = store.FindEntitiesByContent(&domain.FQDN{Name: "Fold.Example.com"}, time.Time{})
+	assert.Error(t, err)
+
+	entities, err := store.FindEntitiesByContentFold(&domain.FQDN{Name: "Fold.Example.com"}, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, stored.ID, entities[0].ID)
+		assert.Equal(t, "fold.example.com", entities[0].Asset.Key())
+	}
+
+	// asset types with a numeric key are matched exactly
+	as, err := store.CreateAsset(&network.AutonomousSystem{Number: 64499})
+	assert.NoError(t, err)
+	entities, err = store.FindEntitiesByContentFold(&network.AutonomousSystem{Number: 64499}, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, as.ID, entities[0].ID)
+	}
+
+	_, err = store.FindEntitiesByContentFold(&domain.FQDN{Name: "Missing.Example.com"}, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindStaleEntities(t *testing.T) {
+	stale, err := store.CreateEntity(&types.Entity{
+		CreatedAt: time.Now().Add(-72 * time.Hour),
+		LastSeen:  time.Now().Add(-48 * time.Hour),
+		Asset:     &domain.FQDN{Name: "stale.owasp.org"},
+	})
+	assert.NoError(t, err)
+
+	fresh, err := store.CreateAsset(&domain.FQDN{Name: "fresh.owasp.org"})
+	assert.NoError(t, err)
+
+	entities, err := store.FindStaleEntities(oam.FQDN, 24*time.Hour)
+	assert.NoError(t, err)
+
+	var ids []string
+	for _, e := range entities {
+		ids = append(ids, e.ID)
+		assert.True(t, e.LastSeen.Before(time.Now().Add(-24*time.Hour)))
+	}
+	assert.Contains(t, ids, stale.ID)
+	assert.NotContains(t, ids, fresh.ID)
+}
+
+func TestUnknownTypePolicy(t *testing.T) {
+	unknown := Entity{
+		Type:      "FutureAsset",
+		Content:   []byte(`{"name":"future.owasp.org"}`),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	assert.NoError(t, store.db.Omit("run_id").Create(&unknown).Error)
+
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "unknown-policy.owasp.org"})
+	assert.NoError(t, err)
+	eid, _ := strconv.ParseUint(entity.ID, 10, 64)
+
+	tag := EntityTag{
+		Type:      "FutureProperty",
+		Content:   []byte(`{"name":"future"}`),
+		EntityID:  eid,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	assert.NoError(t, store.db.Create(&tag).Error)
+
+	var buf bytes.Buffer
+	skip := testRepository(store.db, options.New(options.WithLogger(slog.New(slog.NewTextHandler(&buf, nil)))))
+	_, err = skip.FindEntitiesByType("FutureAsset", time.Time{})
+	assert.Error(t, err)
+	_, err = skip.GetEntityTags(entity, time.Time{})
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "unknown asset type")
+	assert.Contains(t, buf.String(), "unknown property type")
+
+	strict := testRepository(store.db, options.New(options.WithUnknownTypePolicy(options.UnknownTypeError)))
+	var uerr *types.UnknownTypeError
+	_, err = strict.FindEntitiesByType("FutureAsset", time.Time{})
+	assert.True(t, errors.As(err, &uerr))
+	_, err = strict.GetEntityTags(entity, time.Time{})
+	assert.True(t, errors.As(err, &uerr))
+
+	wrap := testRepository(store.db, options.New(options.WithUnknownTypePolicy(options.UnknownTypeWrap)))
+	entities, err := wrap.FindEntitiesByType("FutureAsset", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		asset, ok := entities[0].Asset.(*types.UnknownAsset)
+		assert.True(t, ok)
+		assert.Equal(t, oam.AssetType("FutureAsset"), asset.AssetType())
+		assert.JSONEq(t, `{"name":"future.owasp.org"}`, asset.Key())
+	}
+	tags, err := wrap.GetEntityTags(entity, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		prop, ok := tags[0].Property.(*types.UnknownProperty)
+		assert.True(t, ok)
+		assert.Equal(t, oam.PropertyType("FutureProperty"), prop.PropertyType())
+	}
+}
+
+func TestNewEntitiesCountByType(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+
+	before, err := store.NewEntitiesCountByType(since)
+	assert.NoError(t, err)
+
+	for _, name := range []string{"existing1.newcount.owasp.org", "existing2.newcount.owasp.org"} {
+		_, err := store.CreateEntity(&types.Entity{
+			CreatedAt: time.Now().Add(-48 * time.Hour),
+			LastSeen:  time.Now(),
+			Asset:     &domain.FQDN{Name: name},
+		})
+		assert.NoError(t, err)
+	}
+	for _, name := range []string{"new1.newcount.owasp.org", "new2.newcount.owasp.org"} {
+		_, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+	}
+
+	after, err := store.NewEntitiesCountByType(since)
+	assert.NoError(t, err)
+	assert.Equal(t, before[oam.FQDN]+2, after[oam.FQDN])
+
+	all, err := store.NewEntitiesCountByType(time.Time{})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, all[oam.FQDN], after[oam.FQDN]+2)
+}
+
+func TestNetblockHierarchy(t *testing.T) {
+	parent, err := store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.77.0.0/16"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	var children []*types.Entity
+	for _, cidr := range []string{"10.77.1.0/24", "10.77.2.0/24"} {
+		child, err := store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix(cidr), Type: "IPv4"})
+		assert.NoError(t, err)
+		children = append(children, child)
+	}
+	// the neighbour shares the leading text of the parent CIDR without being within it
+	neighbour, err := store.CreateAsset(&network.Netblock{CIDR: netip.MustParsePrefix("10.7.0.0/24"), Type: "IPv4"})
+	assert.NoError(t, err)
+
+	found, err := store.ChildNetblocks(parent, time.Time{})
+	assert.NoError(t, err)
+	var ids []string
+	for _, e := range found {
+		ids = append(ids, e.ID)
+	}
+	assert.ElementsMatch(t, []string{children[0].ID, children[1].ID}, ids)
+
+	for _, child := range children {
+		p, err := store.ParentNetblock(child, time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, parent.ID, p.ID)
+	}
+
+	_, err = store.ChildNetblocks(children[0], time.Time{})
+	assert.Error(t, err)
+
+	_, err = store.ParentNetblock(parent, time.Time{})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+
+	_, err = store.ParentNetblock(neighbour, time.Time{})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+
+	rparent, err := store.CreateAsset(&oamreg.IPNetRecord{
+		CIDR:   netip.MustParsePrefix("172.18.0.0/16"),
+		Type:   "IPv4",
+		Handle: "NET-172-18-0-0-1",
+	})
+	assert.NoError(t, err)
+	rchild, err := store.CreateAsset(&oamreg.IPNetRecord{
+		CIDR:         netip.MustParsePrefix("172.18.5.0/24"),
+		Type:         "IPv4",
+		Handle:       "NET-172-18-5-0-1",
+		ParentHandle: "NET-172-18-0-0-1",
+	})
+	assert.NoError(t, err)
+
+	found, err = store.ChildNetblocks(rparent, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, rchild.ID, found[0].ID)
+	}
+
+	p, err := store.ParentNetblock(rchild, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, rparent.ID, p.ID)
+
+	_, err = store.ParentNetblock(rparent, time.Time{})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+
+	fqdn, err := store.CreateAsset(&domain.FQDN{Name: "netblock.owasp.org"})
+	assert.NoError(t, err)
+	_, err = store.ParentNetblock(fqdn, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntityByContentLatest(t *testing.T) {
+	asset := &domain.FQDN{Name: "latest.owasp.org"}
+
+	older, err := store.CreateEntity(&types.Entity{
+		CreatedAt: time.Now().Add(-2 * time.Hour),
+		LastSeen:  time.Now().Add(-time.Hour),
+		Asset:     asset,
+	})
+	assert.NoError(t, err)
+
+	content, err := asset.JSON()
+	assert.NoError(t, err)
+	fresher := Entity{
+		Type:      string(oam.FQDN),
+		Content:   content,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	assert.NoError(t, store.db.Omit("run_id").Create(&fresher).Error)
+
+	entity, err := store.FindEntityByContentLatest(asset)
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.FormatUint(fresher.ID, 10), entity.ID)
+	assert.NotEqual(t, older.ID, entity.ID)
+
+	_, err = store.FindEntityByContentLatest(&domain.FQDN{Name: "missing.latest.owasp.org"})
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	_, err := store.FindEntityById("999999999")
+	assert.ErrorIs(t, err, types.ErrEntityNotFound)
+	_, err = store.FindEdgeById("999999999")
+	assert.ErrorIs(t, err, types.ErrEdgeNotFound)
+	_, err = store.FindEntityTagById("999999999")
+	assert.ErrorIs(t, err, types.ErrTagNotFound)
+	_, err = store.FindEdgeTagById("999999999")
+	assert.ErrorIs(t, err, types.ErrTagNotFound)
+
+	_, err = store.FindEntitiesByContent(&domain.FQDN{Name: "missing.notfound.owasp.org"}, time.Time{})
+	assert.ErrorIs(t, err, types.ErrNoResults)
+	_, err = store.FindEntitiesByType(oam.FQDN, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, types.ErrNoResults)
+}
+
+func TestUpsertEntity(t *testing.T) {
+	asset := &domain.FQDN{Name: "upsert.sqlrepo.owasp.org"}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var created int
+	ids := make(map[string]struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			e, c, err := store.UpsertEntity(asset)
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if c {
+				created++
+			}
+			ids[e.ID] = struct{}{}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, created)
+	assert.Len(t, ids, 1)
+
+	entities, err := store.FindEntitiesByContent(asset, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+}
+
+func TestCreateEntityWithPrevLastSeen(t *testing.T) {
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+	first, prev, err := store.CreateEntityWithPrevLastSeen(&types.Entity{
+		CreatedAt: seen,
+		LastSeen:  seen,
+		Asset:     &domain.FQDN{Name: "prev.sqlrepo.owasp.org"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, prev.IsZero())
+
+	second, prev, err := store.CreateEntityWithPrevLastSeen(&types.Entity{Asset: &domain.FQDN{Name: "prev.sqlrepo.owasp.org"}})
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.True(t, prev.Equal(first.LastSeen))
+	assert.True(t, second.LastSeen.After(prev))
+}
+
+func TestTransaction(t *testing.T) {
+	committed := &domain.FQDN{Name: "committed.tx.owasp.org"}
+	err := Transaction(store, func(tx *sqlRepository) error {
+		from, err := tx.CreateAsset(committed)
+		if err != nil {
+			return err
+		}
+
+		to, err := tx.CreateAsset(&domain.FQDN{Name: "target.tx.owasp.org"})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateEdge(&types.Edge{
+			Relation:   &relation.SimpleRelation{Name: "node"},
+			FromEntity: from,
+			ToEntity:   to,
+		})
+		return err
+	})
+	assert.NoError(t, err)
+
+	entities, err := store.FindEntitiesByContent(committed, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		edges, err := store.OutgoingEdges(entities[0], time.Time{}, "node")
+		assert.NoError(t, err)
+		assert.Len(t, edges, 1)
+	}
+
+	rolledBack := &domain.FQDN{Name: "rolledback.tx.owasp.org"}
+	err = Transaction(store, func(tx *sqlRepository) error {
+		if _, err := tx.CreateAsset(rolledBack); err != nil {
+			return err
+		}
+		return errors.New("abort the transaction")
+	})
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByContent(rolledBack, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestSecondaryKeyDedup(t *testing.T) {
+	phone, err := store.CreateAsset(&contact.Phone{Raw: "+1 (555) 555-0142", E164: "+15555550142"})
+	assert.NoError(t, err)
+
+	// the asset only populates the secondary key, so the primary key finds nothing
+	partial := &contact.Phone{E164: "+15555550142"}
+	entities, err := store.FindEntitiesByContent(partial, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, phone.ID, entities[0].ID)
+	}
+
+	again, err := store.CreateAsset(partial)
+	assert.NoError(t, err)
+	assert.Equal(t, phone.ID, again.ID)
+	// the stored fields missing from the partial asset are kept
+	assert.Equal(t, "+1 (555) 555-0142", again.Asset.(*contact.Phone).Raw)
+
+	found, err := store.FindEntityById(phone.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &contact.Phone{Raw: "+1 (555) 555-0142", E164: "+15555550142"}, found.Asset)
+
+	latest, err := store.FindEntityByContentLatest(partial)
+	assert.NoError(t, err)
+	assert.Equal(t, phone.ID, latest.ID)
+
+	_, err = store.FindEntitiesByContent(&contact.Phone{E164: "+15555550143"}, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestParseErrors(t *testing.T) {
+	malformed := Entity{
+		Type:      string(oam.FQDN),
+		Content:   []byte(`{"name":5}`),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	assert.NoError(t, store.db.Omit("run_id").Create(&malformed).Error)
+	defer store.db.Delete(&Entity{}, malformed.ID)
+
+	repo := testRepository(store.db, options.New())
+	before := repo.ParseErrors()
+
+	_, err := repo.CreateAsset(&domain.FQDN{Name: "parse-errors.owasp.org"})
+	assert.NoError(t, err)
+
+	entities, err := repo.FindEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	for _, e := range entities {
+		assert.NotEqual(t, strconv.FormatUint(malformed.ID, 10), e.ID)
+	}
+	assert.Equal(t, before+1, repo.ParseErrors())
+	// the counter is shared with the repository the handle was derived from
+	assert.Equal(t, repo.ParseErrors(), store.ParseErrors())
+}
+
+func TestTablePrefix(t *testing.T) {
+	repo, err := New(SQLiteMemory, "file:tableprefix?mode=memory&cache=shared", options.WithTablePrefix("amass_"))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	err = repo.db.Exec(`CREATE TABLE amass_entities(
+		entity_id INTEGER PRIMARY KEY,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		etype TEXT,
+		content TEXT,
+		run_id VARCHAR(255),
+		deleted_at DATETIME
+	)`).Error
+	assert.NoError(t, err)
+
+	entity, err := repo.CreateAsset(&domain.FQDN{Name: "prefix.owasp.org"})
+	assert.NoError(t, err)
+
+	var count int64
+	assert.NoError(t, repo.db.Raw("SELECT count(*) FROM amass_entities WHERE entity_id = ?", entity.ID).Scan(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	found, err := repo.FindEntityById(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "prefix.owasp.org", found.Asset.Key())
+}
+
+func TestConnectionPool(t *testing.T) {
+	repo, err := New(SQLiteMemory, "file:connpool?mode=memory&cache=shared", options.WithMaxOpenConns(7))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	sqlDB, err := repo.db.DB()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+
+	stats, err := repo.Stats()
+	assert.NoError(t, err)
+	if assert.NotNil(t, stats.Pool) {
+		assert.Equal(t, 7, stats.Pool.MaxOpenConnections)
+	}
+}
+
+func TestSQLLogLevel(t *testing.T) {
+	assert.Equal(t, logger.Default.LogMode(logger.Silent), gormConfig(options.New()).Logger)
+
+	var buf bytes.Buffer
+	o := options.New(options.WithSQLLogger(slog.New(slog.NewTextHandler(&buf, nil))), options.WithLogLevel(logger.Info))
+	repo := testRepository(store.db.Session(&gorm.Session{Logger: gormConfig(o).Logger}), o)
+
+	_, err := repo.CreateAsset(&domain.FQDN{Name: "loglevel.owasp.org"})
+	assert.NoError(t, err)
+	// every statement is logged at the info level, not only the slow statements and errors
+	assert.Contains(t, buf.String(), "loglevel.owasp.org")
+}
+
+func TestCanonicalContent(t *testing.T) {
+	repo := testRepository(store.db, options.New(options.WithCanonicalContent()))
+
+	first, err := types.ParseAsset(oam.Phone, []byte(`{"type":"","raw":"+1 555 555 0177","e164":"+15555550177"}`))
+	assert.NoError(t, err)
+	entity, err := repo.CreateAsset(first)
+	assert.NoError(t, err)
+
+	stored := func() []byte {
+		var e Entity
+		assert.NoError(t, store.db.First(&e, "entity_id = ?", entity.ID).Error)
+		return e.Content
+	}
+	content1 := stored()
+
+	second, err := types.ParseAsset(oam.Phone, []byte(`{"e164":"+15555550177","raw":"+1 555 555 0177","ext":""}`))
+	assert.NoError(t, err)
+	again, err := repo.CreateAsset(second)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ID, again.ID)
+	assert.Equal(t, content1, stored())
+
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content1, &fields))
+	assert.NotContains(t, fields, "type")
+	if store.dbtype != Postgres {
+		// postgres stores the content as jsonb, which normalizes the bytes
+		assert.Equal(t, `{"e164":"+15555550177","raw":"+1 555 555 0177"}`, string(content1))
+	}
+
+	// the canonical content and the content written without the option are both parsed
+	found, err := store.FindEntityById(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, first, found.Asset)
+}
+
+func TestHealthCheck(t *testing.T) {
+	if store.dbtype != Postgres {
+		t.Skip("the health check only applies to postgres")
+	}
+
+	repo, err := New(Postgres, storeDSN, options.WithHealthCheck(250*time.Millisecond))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	var pid int64
+	assert.NoError(t, repo.db.Raw("SELECT pg_backend_pid()").Scan(&pid).Error)
+
+	// simulate the server killing the idle pooled connection
+	assert.NoError(t, store.db.Exec("SELECT pg_terminate_backend(?)", pid).Error)
+	time.Sleep(1500 * time.Millisecond)
+
+	_, err = repo.CreateAsset(&domain.FQDN{Name: "healthcheck.owasp.org"})
+	assert.NoError(t, err)
+
+	var next int64
+	assert.NoError(t, repo.db.Raw("SELECT pg_backend_pid()").Scan(&next).Error)
+	assert.NotEqual(t, pid, next)
+}
+
+func TestCheckpointOnClose(t *testing.T) {
+	walSize := func(opts ...options.Option) int64 {
+		path := filepath.Join(t.TempDir(), "checkpoint.sqlite")
+
+		repo, err := New(SQLite, path, opts...)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.db.Exec("PRAGMA journal_mode=WAL").Error)
+		assert.NoError(t, repo.db.Exec("CREATE TABLE samples (id INTEGER PRIMARY KEY, value TEXT)").Error)
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, repo.db.Exec("INSERT INTO samples (value) VALUES (?)", fmt.Sprintf("value%d", i)).Error)
+		}
+
+		// another open connection keeps sqlite from checkpointing the log when the repository closes
+		reader, err := sqliteDatabase(path, &gorm.Config{}, 1, 1)
+		assert.NoError(t, err)
+		var count int64
+		assert.NoError(t, reader.Raw("SELECT count(*) FROM samples").Scan(&count).Error)
+		assert.Equal(t, int64(100), count)
+		defer func() {
+			if db, err := reader.DB(); err == nil {
+				db.Close()
+			}
+		}()
+
+		assert.NoError(t, repo.Close())
+		info, err := os.Stat(path + "-wal")
+		assert.NoError(t, err)
+		return info.Size()
+	}
+
+	assert.Greater(t, walSize(), int64(0))
+	assert.Equal(t, int64(0), walSize(options.WithCheckpointOnClose(), options.WithVacuumOnClose()))
+}
+
+func TestCreateEntities(t *testing.T) {
+	existing, err := store.CreateAsset(&domain.FQDN{Name: "existing.bulk.owasp.org"})
+	assert.NoError(t, err)
+	time.Sleep(250 * time.Millisecond)
+
+	assets := []oam.Asset{
+		&domain.FQDN{Name: "one.bulk.owasp.org"},
+		&domain.FQDN{Name: "existing.bulk.owasp.org"},
+		&domain.FQDN{Name: "two.bulk.owasp.org"},
+		&domain.FQDN{Name: "one.bulk.owasp.org"},
+	}
+
+	entities, err := store.CreateEntities(assets)
+	assert.NoError(t, err)
+	if !assert.Len(t, entities, len(assets)) {
+		return
+	}
+
+	// the entities preserve the order of the assets
+	for i, e := range entities {
+		assert.Equal(t, assets[i].Key(), e.Asset.Key())
+	}
+
+	// the existing entity is reused with the last seen time updated
+	assert.Equal(t, existing.ID, entities[1].ID)
+	assert.True(t, entities[1].LastSeen.After(existing.LastSeen))
+	found, err := store.FindEntityById(existing.ID)
+	assert.NoError(t, err)
+	assert.True(t, found.LastSeen.After(existing.LastSeen))
+
+	// the repeated asset produces a single entity
+	assert.Equal(t, entities[0].ID, entities[3].ID)
+	assert.NotEqual(t, entities[0].ID, entities[2].ID)
+	for _, name := range []string{"one.bulk.owasp.org", "two.bulk.owasp.org"} {
+		found, err := store.FindEntitiesByContent(&domain.FQDN{Name: name}, time.Time{})
+		assert.NoError(t, err)
+		assert.Len(t, found, 1)
+	}
+
+	// a second call inserts nothing
+	again, err := store.CreateEntities(assets)
+	assert.NoError(t, err)
+	for i, e := range again {
+		assert.Equal(t, entities[i].ID, e.ID)
+	}
+
+	_, err = store.CreateEntities([]oam.Asset{nil})
+	assert.Error(t, err)
+}
+
+func TestCreateEntitiesContext(t *testing.T) {
+	repo := testRepository(store.db, options.New(options.WithBatchSize(2), options.WithContentValidation()))
+
+	// the invalid asset fails the first sub-batch, which is then written one asset at a time
+	assets := []oam.Asset{
+		&domain.FQDN{Name: "one.bulkctx.owasp.org"},
+		&domain.FQDN{},
+		&domain.FQDN{Name: "two.bulkctx.owasp.org"},
+		&domain.FQDN{Name: "three.bulkctx.owasp.org"},
+	}
+
+	result, err := repo.CreateEntitiesContext(context.Background(), assets)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, result.Failed())
+	assert.Nil(t, result.Results[1])
+	for _, i := range []int{0, 2, 3} {
+		if assert.NotNil(t, result.Results[i]) {
+			found, err := store.FindEntityById(result.Results[i].ID)
+			assert.NoError(t, err)
+			assert.Equal(t, assets[i].Key(), found.Asset.Key())
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err = repo.CreateEntitiesContext(ctx, []oam.Asset{&domain.FQDN{Name: "cancelled.bulkctx.owasp.org"}})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, result.Errors[0], context.Canceled)
+	_, err = store.FindEntitiesByContent(&domain.FQDN{Name: "cancelled.bulkctx.owasp.org"}, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestSQLiteForeignKeys(t *testing.T) {
+	assert.Equal(t, "test.db?_pragma=foreign_keys(1)", sqliteForeignKeys("test.db"))
+	assert.Equal(t, "file:mem1?mode=memory&_pragma=foreign_keys(1)", sqliteForeignKeys("file:mem1?mode=memory"))
+	assert.Equal(t, "test.db?_pragma=foreign_keys(0)", sqliteForeignKeys("test.db?_pragma=foreign_keys(0)"))
+
+	if store.dbtype != SQLite {
+		return
+	}
+
+	var enabled int
+	assert.NoError(t, store.db.Raw("PRAGMA foreign_keys").Scan(&enabled).Error)
+	assert.Equal(t, 1, enabled)
+}
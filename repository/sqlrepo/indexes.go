@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlrepo
+
+import (
+	"fmt"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// sqlIndex declares an index created by the migrations that the queries of the repository rely on.
+type sqlIndex struct {
+	name string
+	// model is the name of the model stored in the indexed table
+	model string
+	// column is the indexed column, or the comma separated columns, when the index is not on a field of the entity content
+	column string
+	// field is the indexed field of the content of the entities with the asset type
+	field string
+	atype oam.AssetType
+}
+
+var declaredIndexes = []sqlIndex{
+	{name: "idx_entities_updated_at", model: "Entity", column: "updated_at"},
+	{name: "idx_entities_etype", model: "Entity", column: "etype"},
+	{name: "idx_entities_run_id", model: "Entity", column: "run_id"},
+	{name: "idx_entities_etype_updated_at", model: "Entity", column: "etype, updated_at"},
+	{name: "idx_enttag_updated_at", model: "EntityTag", column: "updated_at"},
+	{name: "idx_enttag_entity_id", model: "EntityTag", column: "entity_id"},
+	{name: "idx_enttag_ttype_updated_at", model: "EntityTag", column: "ttype, updated_at"},
+	{name: "idx_edge_updated_at", model: "Edge", column: "updated_at"},
+	{name: "idx_edge_from_entity_id", model: "Edge", column: "from_entity_id"},
+	{name: "idx_edge_to_entity_id", model: "Edge", column: "to_entity_id"},
+	{name: "idx_edge_run_id", model: "Edge", column: "run_id"},
+	{name: "idx_edgetag_updated_at", model: "EdgeTag", column: "updated_at"},
+	{name: "idx_edgetag_edge_id", model: "EdgeTag", column: "edge_id"},
+	{name: "idx_edgetag_ttype_updated_at", model: "EdgeTag", column: "ttype, updated_at"},
+	{name: "idx_autnum_content_handle", model: "Entity", field: "handle", atype: oam.AutnumRecord},
+	{name: "idx_autnum_content_number", model: "Entity", field: "number", atype: oam.AutnumRecord},
+	{name: "idx_autsys_content_number", model: "Entity", field: "number", atype: oam.AutonomousSystem},
+	{name: "idx_domainrec_content_domain", model: "Entity", field: "domain", atype: oam.DomainRecord},
+	{name: "idx_email_content_address", model: "Entity", field: "address", atype: oam.EmailAddress},
+	{name: "idx_fqdn_content_name", model: "Entity", field: "name", atype: oam.FQDN},
+	{name: "idx_ipaddr_content_address", model: "Entity", field: "address", atype: oam.IPAddress},
+	{name: "idx_ipnetrec_content_cidr", model: "Entity", field: "cidr", atype: oam.IPNetRecord},
+	{name: "idx_ipnetrec_content_handle", model: "Entity", field: "handle", atype: oam.IPNetRecord},
+	{name: "idx_netblock_content_cidr", model: "Entity", field: "cidr", atype: oam.Netblock},
+	{name: "idx_org_content_name", model: "Entity", field: "name", atype: oam.Organization},
+	{name: "idx_person_content_full_name", model: "Entity", field: "full_name", atype: oam.Person},
+	{name: "idx_tls_content_serial_number", model: "Entity", field: "serial_number", atype: oam.TLSCertificate},
+	{name: "idx_url_content_url", model: "Entity", field: "url", atype: oam.URL},
+}
+
+// statement returns the SQL that creates the index on the table for the database type.
+func (idx sqlIndex) statement(dbtype, table, name string) string {
+	if idx.field == "" {
+		return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", name, table, idx.column)
+	}
+	// the postgres content indexes assume that the pg_trgm extension is created in the database
+	if dbtype == Postgres {
+		return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING gin ((content->>'%s') gin_trgm_ops) WHERE etype = '%s'",
+			name, table, idx.field, idx.atype)
+	}
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (content->>'%s' COLLATE NOCASE) WHERE etype = '%s'",
+		name, table, idx.field, idx.atype)
+}
+
+// EnsureIndexes creates the indexes declared by the repository that are missing from the database, and then
+// updates the statistics used by the query planner. This applies the indexing to a database that predates
+// the migrations that introduced the indexes. The indexes are named with the configured table prefix.
+// Returns the names of the indexes that were created, or an error if the creation fails.
+func (sql *sqlRepository) EnsureIndexes() ([]string, error) {
+	var prefix string
+	if sql.opts != nil {
+		prefix = sql.opts.TablePrefix
+	}
+
+	var created []string
+	for _, idx := range declaredIndexes {
+		name := prefix + idx.name
+		table := sql.db.NamingStrategy.TableName(idx.model)
+
+		if sql.db.Migrator().HasIndex(table, name) {
+			continue
+		}
+		if err := sql.db.Exec(idx.statement(sql.dbtype, table, name)).Error; err != nil {
+			return created, err
+		}
+		created = append(created, name)
+	}
+
+	if err := sql.db.Exec("ANALYZE").Error; err != nil {
+		return created, err
+	}
+	return created, nil
+}
@@ -0,0 +1,87 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlrepo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEnsureIndexes(t *testing.T) {
+	// simulate a database that predates the migration of the index
+	assert.NoError(t, store.db.Exec("DROP INDEX IF EXISTS idx_fqdn_content_name").Error)
+	assert.False(t, store.db.Migrator().HasIndex("entities", "idx_fqdn_content_name"))
+
+	created, err := store.EnsureIndexes()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"idx_fqdn_content_name"}, created)
+	assert.True(t, store.db.Migrator().HasIndex("entities", "idx_fqdn_content_name"))
+
+	// the indexes are only created once
+	created, err = store.EnsureIndexes()
+	assert.NoError(t, err)
+	assert.Empty(t, created)
+}
+
+func TestWindowedTypeScanIndexes(t *testing.T) {
+	since := time.Now().Add(-time.Hour).UTC()
+
+	plan := explainQuery(t, "SELECT * FROM entities WHERE etype = ? AND updated_at >= ?", string(oam.FQDN), since)
+	assert.Contains(t, plan, "idx_entities_etype_updated_at")
+
+	plan = explainQuery(t, "SELECT * FROM entity_tags WHERE ttype = ? AND updated_at >= ?", string(oam.SimpleProperty), since)
+	assert.Contains(t, plan, "idx_enttag_ttype_updated_at")
+
+	plan = explainQuery(t, "SELECT * FROM edge_tags WHERE ttype = ? AND updated_at >= ?", string(oam.SimpleProperty), since)
+	assert.Contains(t, plan, "idx_edgetag_ttype_updated_at")
+}
+
+// explainQuery returns the text of the plan selected by the database for the query.
+func explainQuery(t *testing.T, query string, args ...interface{}) string {
+	var plan []string
+
+	err := store.db.Transaction(func(tx *gorm.DB) error {
+		explain := "EXPLAIN QUERY PLAN "
+		if store.dbtype == Postgres {
+			// the test tables are small enough that a sequential scan would otherwise be preferred
+			if err := tx.Exec("SET LOCAL enable_seqscan = off").Error; err != nil {
+				return err
+			}
+			explain = "EXPLAIN "
+		}
+
+		rows, err := tx.Raw(explain+query, args...).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			values := make([]interface{}, len(cols))
+			for i := range values {
+				values[i] = new(interface{})
+			}
+			if err := rows.Scan(values...); err != nil {
+				return err
+			}
+			// the plan detail is in the last column for both databases
+			plan = append(plan, fmt.Sprint(*values[len(values)-1].(*interface{})))
+		}
+		return rows.Err()
+	})
+	assert.NoError(t, err)
+	return strings.Join(plan, "\n")
+}
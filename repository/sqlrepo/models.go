@@ -5,24 +5,15 @@
 package sqlrepo
 
 import (
-	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
-	oamtls "github.com/owasp-amass/open-asset-model/certificate"
-	"github.com/owasp-amass/open-asset-model/contact"
-	"github.com/owasp-amass/open-asset-model/domain"
-	oamfile "github.com/owasp-amass/open-asset-model/file"
-	"github.com/owasp-amass/open-asset-model/network"
-	"github.com/owasp-amass/open-asset-model/org"
-	"github.com/owasp-amass/open-asset-model/people"
 	"github.com/owasp-amass/open-asset-model/property"
-	oamreg "github.com/owasp-amass/open-asset-model/registration"
-	"github.com/owasp-amass/open-asset-model/relation"
-	"github.com/owasp-amass/open-asset-model/service"
-	"github.com/owasp-amass/open-asset-model/url"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // Entity represents an entity stored in the database.
@@ -32,6 +23,8 @@ type Entity struct {
 	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP();column:updated_at"`
 	Type      string    `gorm:"column:etype"`
 	Content   datatypes.JSON
+	RunID     string         `gorm:"column:run_id"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at"`
 }
 
 // EntityTag represents additional metadata added to an entity in the asset database.
@@ -41,7 +34,8 @@ type EntityTag struct {
 	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP();column:updated_at"`
 	Type      string    `gorm:"column:ttype"`
 	Content   datatypes.JSON
-	EntityID  uint64 `gorm:"column:entity_id"`
+	EntityID  uint64         `gorm:"column:entity_id"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at"`
 }
 
 // Edge represents a relationship between two entities stored in the database.
@@ -51,8 +45,11 @@ type Edge struct {
 	UpdatedAt    time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP();column:updated_at"`
 	Type         string    `gorm:"column:etype"`
 	Content      datatypes.JSON
-	FromEntityID uint64 `gorm:"column:from_entity_id"`
-	ToEntityID   uint64 `gorm:"column:to_entity_id"`
+	FromEntityID uint64         `gorm:"column:from_entity_id"`
+	ToEntityID   uint64         `gorm:"column:to_entity_id"`
+	RunID        string         `gorm:"column:run_id"`
+	DedupLabel   *string        `gorm:"column:dedup_label"`
+	DeletedAt    gorm.DeletedAt `gorm:"column:deleted_at"`
 	FromEntity   Entity
 	ToEntity     Entity
 }
@@ -64,109 +61,18 @@ type EdgeTag struct {
 	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP();column:updated_at"`
 	Type      string    `gorm:"column:ttype"`
 	Content   datatypes.JSON
-	EdgeID    uint64 `gorm:"column:edge_id"`
+	EdgeID    uint64         `gorm:"column:edge_id"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at"`
 }
 
 // Parse parses the content of the entity into the corresponding Open Asset Model (OAM) asset type.
 // It returns the parsed asset and an error, if any.
 func (e *Entity) Parse() (oam.Asset, error) {
-	var err error
-	var asset oam.Asset
-
-	switch e.Type {
-	case string(oam.FQDN):
-		var fqdn domain.FQDN
-
-		err = json.Unmarshal(e.Content, &fqdn)
-		asset = &fqdn
-	case string(oam.IPAddress):
-		var ip network.IPAddress
-
-		err = json.Unmarshal(e.Content, &ip)
-		asset = &ip
-	case string(oam.AutonomousSystem):
-		var as network.AutonomousSystem
-
-		err = json.Unmarshal(e.Content, &as)
-		asset = &as
-	case string(oam.AutnumRecord):
-		var ar oamreg.AutnumRecord
-
-		err = json.Unmarshal(e.Content, &ar)
-		asset = &ar
-	case string(oam.Netblock):
-		var netblock network.Netblock
-
-		err = json.Unmarshal(e.Content, &netblock)
-		asset = &netblock
-	case string(oam.IPNetRecord):
-		var ipnetrec oamreg.IPNetRecord
-
-		err = json.Unmarshal(e.Content, &ipnetrec)
-		asset = &ipnetrec
-	case string(oam.DomainRecord):
-		var dr oamreg.DomainRecord
-
-		err = json.Unmarshal(e.Content, &dr)
-		asset = &dr
-	case string(oam.Organization):
-		var organization org.Organization
-
-		err = json.Unmarshal(e.Content, &organization)
-		asset = &organization
-	case string(oam.Person):
-		var person people.Person
-
-		err = json.Unmarshal(e.Content, &person)
-		asset = &person
-	case string(oam.Phone):
-		var phone contact.Phone
-
-		err = json.Unmarshal(e.Content, &phone)
-		asset = &phone
-	case string(oam.EmailAddress):
-		var emailAddress contact.EmailAddress
-
-		err = json.Unmarshal(e.Content, &emailAddress)
-		asset = &emailAddress
-	case string(oam.Location):
-		var location contact.Location
-
-		err = json.Unmarshal(e.Content, &location)
-		asset = &location
-	case string(oam.ContactRecord):
-		var cr contact.ContactRecord
-
-		err = json.Unmarshal(e.Content, &cr)
-		asset = &cr
-	case string(oam.TLSCertificate):
-		var tlsCertificate oamtls.TLSCertificate
-
-		err = json.Unmarshal(e.Content, &tlsCertificate)
-		asset = &tlsCertificate
-	case string(oam.URL):
-		var url url.URL
-
-		err = json.Unmarshal(e.Content, &url)
-		asset = &url
-	case string(oam.Service):
-		var serv service.Service
-
-		err = json.Unmarshal(e.Content, &serv)
-		asset = &serv
-	case string(oam.File):
-		var f oamfile.File
-
-		err = json.Unmarshal(e.Content, &f)
-		asset = &f
-	default:
-		return nil, fmt.Errorf("unknown asset type: %s", e.Type)
-	}
-
-	return asset, err
+	return types.ParseAsset(oam.AssetType(e.Type), e.Content)
 }
 
 // JSONQuery generates a JSON query expression based on the entity's content.
+// The query matches the field that identifies the asset type, as returned by types.AssetKey.
 // It returns the generated JSON query expression and an error, if any.
 func (e *Entity) JSONQuery() (*datatypes.JSONQueryExpression, error) {
 	asset, err := e.Parse()
@@ -174,123 +80,43 @@ func (e *Entity) JSONQuery() (*datatypes.JSONQueryExpression, error) {
 		return nil, err
 	}
 
-	jsonQuery := datatypes.JSONQuery("content")
-	switch v := asset.(type) {
-	case *domain.FQDN:
-		return jsonQuery.Equals(v.Name, "name"), nil
-	case *network.IPAddress:
-		return jsonQuery.Equals(v.Address.String(), "address"), nil
-	case *network.AutonomousSystem:
-		return jsonQuery.Equals(v.Number, "number"), nil
-	case *network.Netblock:
-		return jsonQuery.Equals(v.CIDR.String(), "cidr"), nil
-	case *oamreg.IPNetRecord:
-		return jsonQuery.Equals(v.Handle, "handle"), nil
-	case *oamreg.AutnumRecord:
-		return jsonQuery.Equals(v.Handle, "handle"), nil
-	case *oamreg.DomainRecord:
-		return jsonQuery.Equals(v.Domain, "domain"), nil
-	case *org.Organization:
-		return jsonQuery.Equals(v.Name, "name"), nil
-	case *people.Person:
-		return jsonQuery.Equals(v.FullName, "full_name"), nil
-	case *contact.Phone:
-		return jsonQuery.Equals(v.Raw, "raw"), nil
-	case *contact.EmailAddress:
-		return jsonQuery.Equals(v.Address, "address"), nil
-	case *contact.Location:
-		return jsonQuery.Equals(v.Address, "address"), nil
-	case *contact.ContactRecord:
-		return jsonQuery.Equals(v.DiscoveredAt, "discovered_at"), nil
-	case *oamtls.TLSCertificate:
-		return jsonQuery.Equals(v.SerialNumber, "serial_number"), nil
-	case *url.URL:
-		return jsonQuery.Equals(v.Raw, "url"), nil
-	case *service.Service:
-		return jsonQuery.Equals(v.Identifier, "identifier"), nil
-	case *oamfile.File:
-		return jsonQuery.Equals(v.URL, "url"), nil
+	field, value, err := types.AssetKey(asset)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("unknown asset type: %s", e.Type)
+	return datatypes.JSONQuery("content").Equals(jsonKeyValue(value), field), nil
+}
+
+// jsonKeyValue converts the serialized value of a key field for a JSON query. Whole numbers are decoded
+// as float64, which would be formatted with an exponent, and a missing field matches the empty string.
+func jsonKeyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v)
+		}
+	}
+	return value
 }
 
 // Parse parses the content of the edge into the corresponding Open Asset Model (OAM) relation type.
 // It returns the parsed relation and an error, if any.
 func (e *Edge) Parse() (oam.Relation, error) {
-	var err error
-	var rel oam.Relation
-
-	switch e.Type {
-	case string(oam.BasicDNSRelation):
-		var bdr relation.BasicDNSRelation
-
-		err = json.Unmarshal(e.Content, &bdr)
-		rel = &bdr
-	case string(oam.PortRelation):
-		var pr relation.PortRelation
-
-		err = json.Unmarshal(e.Content, &pr)
-		rel = &pr
-	case string(oam.PrefDNSRelation):
-		var pdr relation.PrefDNSRelation
-
-		err = json.Unmarshal(e.Content, &pdr)
-		rel = &pdr
-	case string(oam.SimpleRelation):
-		var sr relation.SimpleRelation
-
-		err = json.Unmarshal(e.Content, &sr)
-		rel = &sr
-	case string(oam.SRVDNSRelation):
-		var sdr relation.SRVDNSRelation
-
-		err = json.Unmarshal(e.Content, &sdr)
-		rel = &sdr
-	default:
-		return nil, fmt.Errorf("unknown relation type: %s", e.Type)
-	}
-
-	return rel, err
+	return types.ParseRelation(oam.RelationType(e.Type), e.Content)
 }
 
 // Parse parses the content of the entity tag into the corresponding Open Asset Model (OAM) property type.
 // It returns the parsed property and an error, if any.
 func (e *EntityTag) Parse() (oam.Property, error) {
-	return parseProperty(e.Type, e.Content)
+	return types.ParseProperty(oam.PropertyType(e.Type), e.Content)
 }
 
 // Parse parses the content of the edge tag into the corresponding Open Asset Model (OAM) property type.
 // It returns the parsed property and an error, if any.
 func (e *EdgeTag) Parse() (oam.Property, error) {
-	return parseProperty(e.Type, e.Content)
-}
-
-func parseProperty(ptype string, content datatypes.JSON) (oam.Property, error) {
-	var err error
-	var prop oam.Property
-
-	switch ptype {
-	case string(oam.SimpleProperty):
-		var sp property.SimpleProperty
-
-		err = json.Unmarshal(content, &sp)
-		prop = &sp
-	case string(oam.SourceProperty):
-		var sp property.SourceProperty
-
-		err = json.Unmarshal(content, &sp)
-		prop = &sp
-	case string(oam.VulnProperty):
-		var vp property.VulnProperty
-
-		err = json.Unmarshal(content, &vp)
-		prop = &vp
-	default:
-		return nil, fmt.Errorf("unknown property type: %s", ptype)
-	}
-
-	return prop, err
+	return types.ParseProperty(oam.PropertyType(e.Type), e.Content)
 }
 
 // NameJSONQuery generates the JSON query for the field returned by the Property Name method.
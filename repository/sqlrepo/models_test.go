@@ -244,4 +244,15 @@ func TestModels(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("JSONQuery for every asset type", func(t *testing.T) {
+		// the query is derived from the key fields in the types package, so every asset type is covered
+		for _, atype := range oam.AssetList {
+			entity := &Entity{Type: string(atype), Content: []byte("{}")}
+
+			if _, err := entity.JSONQuery(); err != nil {
+				t.Fatalf("failed to generate the json query for %s: %s", atype, err)
+			}
+		}
+	})
 }
@@ -0,0 +1,113 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlrepo
+
+import (
+	"fmt"
+
+	"github.com/owasp-amass/asset-db/types"
+	"gorm.io/gorm"
+)
+
+// Observe stores a batch of observations, upserting the entities as done by UpsertEntity, the edges as done by
+// UpsertEdge, and the tags as done by CreateEntityTags and CreateEdgeTags. Storing the same observations
+// again creates no records, and only refreshes the last seen times.
+// The observations are written in transactions of up to the batch size. When a transaction fails, the
+// observations of the earlier transactions remain committed and are counted in the returned result.
+// Returns the counts of the created and updated records, or an error if an observation is invalid or a write fails.
+func (sql *sqlRepository) Observe(observations []types.Observation) (*types.ObserveResult, error) {
+	for i := range observations {
+		if err := observations[i].Validate(); err != nil {
+			return nil, fmt.Errorf("observation %d: %w", i, err)
+		}
+	}
+
+	sql.upserts.Lock()
+	defer sql.upserts.Unlock()
+
+	result := &types.ObserveResult{}
+	outs := make(outgoingEdgeCache)
+	for start := 0; start < len(observations); start += sql.batchSize() {
+		end := min(start+sql.batchSize(), len(observations))
+
+		var batch types.ObserveResult
+		err := sql.db.Transaction(func(tx *gorm.DB) error {
+			txrepo := sql.withDB(tx)
+
+			for i := start; i < end; i++ {
+				if err := sql.observeTx(tx, txrepo, &observations[i], outs, &batch); err != nil {
+					return fmt.Errorf("observation %d: %w", i, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			// the edges cached by the failed transaction were rolled back
+			clear(outs)
+			return result, err
+		}
+
+		result.EntitiesCreated += batch.EntitiesCreated
+		result.EntitiesUpdated += batch.EntitiesUpdated
+		result.EdgesCreated += batch.EdgesCreated
+		result.EdgesUpdated += batch.EdgesUpdated
+		result.Tags += batch.Tags
+	}
+	return result, nil
+}
+
+// observeTx stores the observation using the provided transaction and adds the stored records to the counts.
+func (sql *sqlRepository) observeTx(tx *gorm.DB, txrepo *sqlRepository, o *types.Observation, outs outgoingEdgeCache, counts *types.ObserveResult) error {
+	from, created, err := sql.upsertEntityTx(tx, o.Asset)
+	if err != nil {
+		return err
+	}
+	countUpsert(created, &counts.EntitiesCreated, &counts.EntitiesUpdated)
+
+	if len(o.Properties) > 0 {
+		tags, err := sql.createEntityTagsTx(tx, from, o.Properties)
+		if err != nil {
+			return err
+		}
+		counts.Tags += len(tags)
+	}
+
+	if o.Relation == nil {
+		return nil
+	}
+
+	to, created, err := sql.upsertEntityTx(tx, o.Target)
+	if err != nil {
+		return err
+	}
+	countUpsert(created, &counts.EntitiesCreated, &counts.EntitiesUpdated)
+
+	edge, created, err := txrepo.upsertEdge(&types.Edge{
+		Relation:   o.Relation,
+		FromEntity: from,
+		ToEntity:   to,
+	}, outs)
+	if err != nil {
+		return err
+	}
+	countUpsert(created, &counts.EdgesCreated, &counts.EdgesUpdated)
+
+	if len(o.EdgeProperties) > 0 {
+		tags, err := sql.createEdgeTagsTx(tx, edge, o.EdgeProperties)
+		if err != nil {
+			return err
+		}
+		counts.Tags += len(tags)
+	}
+	return nil
+}
+
+func countUpsert(created bool, inserted, updated *int) {
+	if created {
+		*inserted++
+	} else {
+		*updated++
+	}
+}
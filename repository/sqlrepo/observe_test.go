@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlrepo
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository/options"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserve(t *testing.T) {
+	// a small batch size splits the observations across transactions
+	repo := testRepository(store.db, options.New(options.WithBatchSize(2)))
+
+	www := &domain.FQDN{Name: "www.observe.owasp.org"}
+	mail := &domain.FQDN{Name: "mail.observe.owasp.org"}
+	ip := &network.IPAddress{Address: netip.MustParseAddr("10.20.30.40"), Type: "IPv4"}
+	source := &property.SourceProperty{Source: "observe_dns", Confidence: 80}
+	arecord := func() oam.Relation {
+		return &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 1, Class: 1}}
+	}
+
+	observations := []types.Observation{
+		{Asset: www, Properties: []oam.Property{source}, Relation: arecord(), Target: ip, EdgeProperties: []oam.Property{source}},
+		{Asset: mail, Relation: arecord(), Target: ip, EdgeProperties: []oam.Property{source}},
+		{Asset: ip, Properties: []oam.Property{source}},
+	}
+
+	result, err := repo.Observe(observations)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ObserveResult{
+		EntitiesCreated: 3,
+		EntitiesUpdated: 2,
+		EdgesCreated:    2,
+		Tags:            4,
+	}, result)
+
+	for _, fqdn := range []*domain.FQDN{www, mail} {
+		entities, err := repo.FindEntitiesByContent(fqdn, time.Time{})
+		assert.NoError(t, err)
+		if !assert.Len(t, entities, 1) {
+			continue
+		}
+
+		edges, err := repo.OutgoingEdges(entities[0], time.Time{}, "dns_record")
+		assert.NoError(t, err)
+		if assert.Len(t, edges, 1) {
+			// the edges only hold the IDs of the entities
+			to, err := repo.FindEntityById(edges[0].ToEntity.ID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, ip.Address, to.Asset.(*network.IPAddress).Address)
+			}
+
+			tags, err := repo.GetEdgeTags(edges[0], time.Time{}, source.Name())
+			assert.NoError(t, err)
+			assert.Len(t, tags, 1)
+		}
+	}
+
+	ips, err := repo.FindEntitiesByContent(ip, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, ips, 1) {
+		tags, err := repo.GetEntityTags(ips[0], time.Time{}, source.Name())
+		assert.NoError(t, err)
+		assert.Len(t, tags, 1)
+	}
+
+	// observing the same batch again only refreshes the existing records
+	result, err = repo.Observe(observations)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ObserveResult{
+		EntitiesUpdated: 5,
+		EdgesUpdated:    2,
+		Tags:            4,
+	}, result)
+
+	ips, err = repo.FindEntitiesByContent(ip, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, ips, 1)
+
+	_, err = repo.Observe([]types.Observation{{Asset: www, Relation: arecord()}})
+	assert.Error(t, err)
+}
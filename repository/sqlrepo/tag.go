@@ -5,12 +5,15 @@
 package sqlrepo
 
 import (
+	"context"
 	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/property"
 	"gorm.io/gorm"
 )
 
@@ -24,7 +27,7 @@ func (sql *sqlRepository) CreateEntityTag(entity *types.Entity, input *types.Ent
 		return nil, err
 	}
 
-	jsonContent, err := input.Property.JSON()
+	jsonContent, err := sql.content(input.Property)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +86,124 @@ func (sql *sqlRepository) CreateEntityProperty(entity *types.Entity, prop oam.Pr
 	return sql.CreateEntityTag(entity, &types.EntityTag{Property: prop})
 }
 
+// CreateEntityTags creates entity tags in the database for each of the provided properties within a single
+// transaction. The existing tags of the entity are read with one query, and a property equal to an existing
+// tag, or to an earlier property of the batch, updates the last seen time of the existing tag instead.
+// Returns the created or updated entity tags, one for each distinct property, or an error if the transaction fails.
+func (sql *sqlRepository) CreateEntityTags(entity *types.Entity, props []oam.Property) ([]*types.EntityTag, error) {
+	var results []*types.EntityTag
+
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := sql.createEntityTagsTx(tx, entity, props)
+		results = tags
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// createEntityTagsTx creates or updates the tags for the properties on the entity using the provided transaction.
+func (sql *sqlRepository) createEntityTagsTx(tx *gorm.DB, entity *types.Entity, props []oam.Property) ([]*types.EntityTag, error) {
+	type key struct {
+		ptype oam.PropertyType
+		name  string
+		value string
+	}
+
+	entityid, err := strconv.ParseUint(entity.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []EntityTag
+	if err := tx.Where("entity_id = ?", entityid).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	tags := make(map[key]*EntityTag, len(existing))
+	for i := range existing {
+		if prop, err := existing[i].Parse(); err == nil {
+			tags[key{prop.PropertyType(), prop.Name(), prop.Value()}] = &existing[i]
+		}
+	}
+
+	now := time.Now().UTC()
+	seen := make(map[key]struct{}, len(props))
+
+	var results []*types.EntityTag
+	for _, prop := range props {
+		if prop == nil {
+			continue
+		}
+
+		k := key{prop.PropertyType(), prop.Name(), prop.Value()}
+		if _, found := seen[k]; found {
+			continue
+		}
+		seen[k] = struct{}{}
+
+		tag, found := tags[k]
+		if found {
+			tag.UpdatedAt = now
+		} else {
+			jsonContent, err := sql.content(prop)
+			if err != nil {
+				return nil, err
+			}
+
+			tag = &EntityTag{
+				Type:      string(prop.PropertyType()),
+				Content:   jsonContent,
+				EntityID:  entityid,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+		}
+
+		if err := tx.Save(tag).Error; err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.EntityTag{
+			ID:        strconv.FormatUint(tag.ID, 10),
+			CreatedAt: tag.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  tag.UpdatedAt.In(time.UTC).Local(),
+			Property:  prop,
+			Entity:    entity,
+		})
+	}
+	return results, nil
+}
+
+// CreateEntityTagsContext creates a tag on the entity for each of the provided properties, as done by
+// CreateEntityProperty, reporting the outcome of each property instead of failing the whole batch.
+// Each tag is committed when it is written.
+// When the context is cancelled, the tags already written remain committed and the properties that were not
+// written are reported with the context error.
+// Returns the outcome of each property, and the context error if the operation was cancelled.
+func (sql *sqlRepository) CreateEntityTagsContext(ctx context.Context, entity *types.Entity, props []oam.Property) (*types.BulkResult[*types.EntityTag], error) {
+	result := types.NewBulkResult[*types.EntityTag](len(props))
+	repo := sql.withDB(sql.db.WithContext(ctx))
+
+	for i, prop := range props {
+		if err := ctx.Err(); err != nil {
+			result.FailFrom(i, err)
+			return result, err
+		}
+
+		if prop == nil {
+			result.Errors[i] = errors.New("the property is nil")
+		} else if tag, err := repo.CreateEntityProperty(entity, prop); err != nil {
+			result.Errors[i] = err
+		} else {
+			result.Results[i] = tag
+		}
+	}
+	return result, nil
+}
+
 // FindEntityTagById finds an entity tag in the database by the ID.
 // It takes a string representing the entity tag ID and retrieves the corresponding tag from the database.
 // Returns the discovered tag as a types.EntityTag or an error if the asset is not found.
@@ -92,13 +213,13 @@ func (sql *sqlRepository) FindEntityTagById(id string) (*types.EntityTag, error)
 		return nil, err
 	}
 
-	tag := EntityTag{ID: tagId}
-	result := sql.db.First(&tag)
+	var tag EntityTag
+	result := sql.db.Where("tag_id = ?", tagId).First(&tag)
 	if err := result.Error; err != nil {
-		return nil, err
+		return nil, notFound(err, types.ErrTagNotFound)
 	}
 
-	data, err := tag.Parse()
+	data, _, err := sql.parseProperty(tag.ID, tag.Type, tag.Content)
 	if err != nil {
 		return nil, err
 	}
@@ -151,19 +272,183 @@ func (sql *sqlRepository) FindEntityTagsByContent(prop oam.Property, since time.
 
 	var results []*types.EntityTag
 	for _, t := range tags {
-		if propData, err := t.Parse(); err == nil {
-			results = append(results, &types.EntityTag{
-				ID:        strconv.FormatUint(t.ID, 10),
-				CreatedAt: t.CreatedAt.In(time.UTC).Local(),
-				LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
-				Property:  propData,
-				Entity:    &types.Entity{ID: strconv.FormatUint(t.EntityID, 10)},
-			})
+		propData, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.EntityTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  propData,
+			Entity:    &types.Entity{ID: strconv.FormatUint(t.EntityID, 10)},
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, sql.opts.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntityTagsBySource finds all SourceProperty entity tags, across all entities, with the provided source and
+// updated_at after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (sql *sqlRepository) FindEntityTagsBySource(source string, since time.Time) ([]*types.EntityTag, error) {
+	nameQuery, err := propertyNameJSONQuery(&property.SourceProperty{Source: source})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := sql.db.Where("ttype = ?", oam.SourceProperty)
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var tags []EntityTag
+	tx = tx.Where(nameQuery).Find(&tags)
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, t := range tags {
+		propData, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+
+		results = append(results, &types.EntityTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  propData,
+			Entity:    &types.Entity{ID: strconv.FormatUint(t.EntityID, 10)},
+		})
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero entity tags found")
+		return []*types.EntityTag{}, sql.opts.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntityTagsByValuePrefix finds all SimpleProperty entity tags, across all entities, with the provided name,
+// a value starting with the prefix and updated_at after the since parameter.
+// The wildcard characters % and _ in the prefix are matched literally.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entity tags as []*types.EntityTag or an error if the search fails.
+func (sql *sqlRepository) FindEntityTagsByValuePrefix(name, prefix string, since time.Time) ([]*types.EntityTag, error) {
+	tx := sql.db.Where("ttype = ?", oam.SimpleProperty).
+		Where(sql.contentField("property_name")+" = ?", name).
+		Where(sql.contentField("property_value")+` LIKE ? ESCAPE '\'`, escapeLike(prefix)+"%")
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var tags []EntityTag
+	if err := tx.Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, t := range tags {
+		propData, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.EntityTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  propData,
+			Entity:    &types.Entity{ID: strconv.FormatUint(t.EntityID, 10)},
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, sql.opts.NoResults("zero entity tags found")
+	}
+	return results, nil
+}
+
+// FindEntitiesByTags finds all entities with tags matching the predicates, combined by the AndOr, considering only
+// tags last seen after the since parameter. Each predicate is matched by an EXISTS subquery on the entity tags,
+// so the tags are not loaded to filter the entities.
+// If since.IsZero(), the parameter will be ignored.
+// Returns a slice of matching entities as []*types.Entity or an error if the search fails.
+func (sql *sqlRepository) FindEntitiesByTags(predicates []types.TagPredicate, combine types.AndOr, since time.Time) ([]*types.Entity, error) {
+	if err := types.ValidateTagPredicates(predicates, combine); err != nil {
+		return nil, err
+	}
+
+	// the table names honor the prefix configured for the repository
+	entities := sql.db.NamingStrategy.TableName("Entity")
+	tags := sql.db.NamingStrategy.TableName("EntityTag")
+
+	var conds []string
+	var args []interface{}
+	for _, p := range predicates {
+		// the unqualified columns of the tag fields refer to the entity tag of the subquery, and
+		// the tags marked as deleted are left out, since the subquery is not scoped by the ORM
+		cond := "EXISTS (SELECT 1 FROM " + tags + " t WHERE t.entity_id = " + entities + ".entity_id AND " +
+			"t.deleted_at IS NULL AND " + sql.propertyNameField() + " = ?"
+		args = append(args, p.Name)
+
+		if p.Ordered() {
+			value, _ := strconv.ParseFloat(p.Value, 64)
+			cond += " AND " + sql.numericField(sql.propertyValueField()) + " " + string(p.Op) + " ?"
+			args = append(args, value)
+		} else if p.Op != types.TagExists {
+			cond += " AND " + sql.propertyValueField() + " " + string(p.Op) + " ?"
+			args = append(args, p.Value)
+		}
+
+		if !since.IsZero() {
+			cond += " AND t.updated_at >= ?"
+			args = append(args, since.UTC())
+		}
+		conds = append(conds, cond+")")
+	}
+
+	sep := " AND "
+	if combine == types.Or {
+		sep = " OR "
+	}
+
+	var matches []Entity
+	if err := sql.db.Where("("+strings.Join(conds, sep)+")", args...).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.Entity
+	for _, e := range matches {
+		asset, skip, err := sql.parseEntity(&e)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.Entity{
+			ID:        strconv.FormatUint(e.ID, 10),
+			CreatedAt: e.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  e.UpdatedAt.In(time.UTC).Local(),
+			Asset:     asset,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.Entity{}, sql.opts.NoResults("zero entities found")
 	}
 	return results, nil
 }
@@ -172,18 +457,27 @@ func (sql *sqlRepository) FindEntityTagsByContent(prop oam.Property, since time.
 // If since.IsZero(), the parameter will be ignored.
 // If no names are specified, all tags for the specified entity are returned.
 func (sql *sqlRepository) GetEntityTags(entity *types.Entity, since time.Time, names ...string) ([]*types.EntityTag, error) {
+	return sql.getEntityTags(entity, since, time.Time{}, names...)
+}
+
+// GetEntityTagsBetween finds all tags for the entity with the specified names and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no names are specified, all tags for the specified entity within the range are returned.
+func (sql *sqlRepository) GetEntityTagsBetween(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return sql.getEntityTags(entity, start, end, names...)
+}
+
+func (sql *sqlRepository) getEntityTags(entity *types.Entity, start, end time.Time, names ...string) ([]*types.EntityTag, error) {
 	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
 	var tags []EntityTag
-	var result *gorm.DB
-	if since.IsZero() {
-		result = sql.db.Where("entity_id = ?", entityId).Find(&tags)
-	} else {
-		result = sql.db.Where("entity_id = ? AND updated_at >= ?", entityId, since.UTC()).Find(&tags)
-	}
+	result := lastSeenBetween(sql.db.Where("entity_id = ?", entityId), start, end).Find(&tags)
 	if err := result.Error; err != nil {
 		return nil, err
 	}
@@ -192,39 +486,245 @@ func (sql *sqlRepository) GetEntityTags(entity *types.Entity, since time.Time, n
 	for _, tag := range tags {
 		t := &tag
 
-		if prop, err := t.Parse(); err == nil {
-			found := true
+		prop, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found := true
 
-			if len(names) > 0 {
-				found = false
-				n := prop.Name()
+		if len(names) > 0 {
+			found = false
+			n := prop.Name()
 
-				for _, name := range names {
-					if name == n {
-						found = true
-						break
-					}
+			for _, name := range names {
+				if name == n {
+					found = true
+					break
 				}
 			}
+		}
 
-			if found {
-				results = append(results, &types.EntityTag{
-					ID:        strconv.Itoa(int(t.ID)),
-					CreatedAt: t.CreatedAt.In(time.UTC).Local(),
-					LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
-					Property:  prop,
-					Entity:    entity,
-				})
-			}
+		if found {
+			results = append(results, &types.EntityTag{
+				ID:        strconv.Itoa(int(t.ID)),
+				CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+				LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+				Property:  prop,
+				Entity:    entity,
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, sql.opts.NoResults("zero tags found")
+	}
+	return results, nil
+}
+
+// GetEntityTagsByType finds all tags for the entity with the specified property types and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no property types are specified, all tags for the specified entity are returned.
+func (sql *sqlRepository) GetEntityTagsByType(entity *types.Entity, since time.Time, ptypes ...oam.PropertyType) ([]*types.EntityTag, error) {
+	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := sql.db.Where("entity_id = ?", entityId)
+	if len(ptypes) > 0 {
+		tx = tx.Where("ttype IN ?", propertyTypeStrings(ptypes))
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var tags []EntityTag
+	if err := tx.Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, tag := range tags {
+		t := &tag
+
+		prop, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.EntityTag{
+			ID:        strconv.Itoa(int(t.ID)),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  prop,
+			Entity:    entity,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.EntityTag{}, sql.opts.NoResults("zero tags found")
+	}
+	return results, nil
+}
+
+// CountEntityTags counts the tags for the entity with the specified names and last seen after the since parameter,
+// without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified entity are counted.
+func (sql *sqlRepository) CountEntityTags(entity *types.Entity, since time.Time, names ...string) (int64, error) {
+	entityId, err := strconv.ParseInt(entity.ID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	tx := sql.db.Model(&EntityTag{}).Where("entity_id = ?", entityId)
+	if len(names) > 0 {
+		tx = tx.Where(sql.propertyNameField()+" IN ?", names)
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// deleteTagsByName removes the tags of the model with the specified property name. The IDs of the matching tags
+// are selected in chunks of the configured batch size, so the deletion does not hold a lock on the whole table.
+// Returns the number of tags that were removed.
+func (sql *sqlRepository) deleteTagsByName(model interface{}, name string) (int64, error) {
+	var count int64
+
+	for {
+		var ids []uint64
+		if err := sql.db.Model(model).Where(sql.propertyNameField()+" = ?", name).
+			Limit(sql.batchSize()).Pluck("tag_id", &ids).Error; err != nil {
+			return count, err
+		}
+		if len(ids) == 0 {
+			return count, nil
+		}
+
+		n, err := sql.deleteInBatches(model, "tag_id", ids)
+		count += n
+		if err != nil {
+			return count, err
+		}
+		// stop when none of the selected tags could be removed
+		if n == 0 {
+			return count, nil
+		}
+	}
+}
+
+// propertyNameField returns the expression that extracts the field returned by the Property Name method
+// from the content column, according to the property type stored in the ttype column.
+func (sql *sqlRepository) propertyNameField() string {
+	return "CASE ttype" +
+		" WHEN '" + string(oam.SimpleProperty) + "' THEN " + sql.contentField("property_name") +
+		" WHEN '" + string(oam.SourceProperty) + "' THEN " + sql.contentField("name") +
+		" WHEN '" + string(oam.VulnProperty) + "' THEN " + sql.contentField("id") + " END"
+}
+
+// propertyValueField returns the expression that extracts the text of the field returned by the Property Value
+// method from the content column, according to the property type stored in the ttype column.
+func (sql *sqlRepository) propertyValueField() string {
+	field := "CASE ttype" +
+		" WHEN '" + string(oam.SimpleProperty) + "' THEN " + sql.contentField("property_value") +
+		" WHEN '" + string(oam.SourceProperty) + "' THEN " + sql.contentField("confidence") +
+		" WHEN '" + string(oam.VulnProperty) + "' THEN " + sql.contentField("desc") + " END"
+	if sql.dbtype == Postgres {
+		return field
+	}
+	// sqlite extracts the numbers in the content as integers
+	return "CAST(" + field + " AS TEXT)"
+}
+
+// numericField returns the expression that converts the text of the field to a number,
+// which is NULL when the text is not a number.
+func (sql *sqlRepository) numericField(field string) string {
+	if sql.dbtype == Postgres {
+		return "CASE WHEN " + field + " ~ '^-{0,1}[0-9]+([.][0-9]+){0,1}$' THEN CAST(" + field + " AS NUMERIC) END"
+	}
+	return "CASE WHEN " + field + " <> '' AND trim(" + field + ", '0123456789.-') = '' THEN CAST(" + field + " AS REAL) END"
+}
+
+// EntityTagTimeline finds all tags for the entity with the provided ID, ordered chronologically by the time each tag
+// was created and then by the time it was last updated.
+// Returns the entity tags as []*types.EntityTag or an error if the search fails.
+func (sql *sqlRepository) EntityTagTimeline(id string) ([]*types.EntityTag, error) {
+	entityId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []EntityTag
+	result := sql.db.Where("entity_id = ?", entityId).Order("created_at ASC, updated_at ASC, tag_id ASC").Find(&tags)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.EntityTag
+	for _, t := range tags {
+		prop, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+
+		results = append(results, &types.EntityTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  prop,
+			Entity:    &types.Entity{ID: id},
+		})
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero tags found")
+		return []*types.EntityTag{}, sql.opts.NoResults("zero tags found")
 	}
 	return results, nil
 }
 
+// ExistingEntityTags returns the subset of the provided properties that are already present as tags on the
+// entity, matched by name and value. The tags of the entity are retrieved with a single query.
+// Returns an empty slice when none of the properties are present.
+func (sql *sqlRepository) ExistingEntityTags(entity *types.Entity, props []oam.Property) ([]oam.Property, error) {
+	entityId, err := strconv.ParseUint(entity.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []EntityTag
+	result := sql.db.Where("entity_id = ?", entityId).Find(&tags)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	var existing []*types.EntityTag
+	for _, tag := range tags {
+		prop, skip, err := sql.parseProperty(tag.ID, tag.Type, tag.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		existing = append(existing, &types.EntityTag{Property: prop})
+	}
+	return types.ExistingProperties(existing, props), nil
+}
+
 // DeleteEntityTag removes an entity tag in the database by its ID.
 // It takes a string representing the entity tag ID and removes the corresponding tag from the database.
 // Returns an error if the tag is not found.
@@ -235,13 +735,20 @@ func (sql *sqlRepository) DeleteEntityTag(id string) error {
 	}
 
 	tag := EntityTag{ID: tagId}
-	result := sql.db.Delete(&tag)
+	result := sql.deleter(sql.db).Delete(&tag)
 	if err := result.Error; err != nil {
 		return err
 	}
 	return nil
 }
 
+// DeleteEntityTagsByNameGlobal removes all entity tags in the database with the specified property name.
+// The matching tags are removed in batches, regardless of the entity they are attached to.
+// Returns the number of entity tags that were removed or an error if the deletion fails.
+func (sql *sqlRepository) DeleteEntityTagsByNameGlobal(name string) (int64, error) {
+	return sql.deleteTagsByName(&EntityTag{}, name)
+}
+
 // CreateEdgeTag creates a new edge tag in the database.
 // It takes an EdgeTag as input and persists it in the database.
 // The property is serialized to JSON and stored in the Content field of the EdgeTag struct.
@@ -252,7 +759,7 @@ func (sql *sqlRepository) CreateEdgeTag(edge *types.Edge, input *types.EdgeTag)
 		return nil, err
 	}
 
-	jsonContent, err := input.Property.JSON()
+	jsonContent, err := sql.content(input.Property)
 	if err != nil {
 		return nil, err
 	}
@@ -311,6 +818,143 @@ func (sql *sqlRepository) CreateEdgeProperty(edge *types.Edge, prop oam.Property
 	return sql.CreateEdgeTag(edge, &types.EdgeTag{Property: prop})
 }
 
+// CreateEdgeTags creates edge tags in the database for each of the provided properties within a single transaction.
+// A property equal to an existing tag on the edge updates the last seen time of the existing tag instead.
+// Returns the created or updated edge tags, one for each distinct property, or an error if the transaction fails.
+func (sql *sqlRepository) CreateEdgeTags(edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error) {
+	var results []*types.EdgeTag
+
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := sql.createEdgeTagsTx(tx, edge, props)
+		results = tags
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateEdgeWithProperties creates the edge, as done by CreateEdge, and the edge tags for the provided properties,
+// as done by CreateEdgeTags, within a single transaction, so neither is stored when either fails.
+// Returns the edge and the created or updated edge tags, or an error if the transaction fails.
+func (sql *sqlRepository) CreateEdgeWithProperties(edge *types.Edge, props []oam.Property) (*types.Edge, []*types.EdgeTag, error) {
+	var e *types.Edge
+	var tags []*types.EdgeTag
+
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		txrepo := sql.withDB(tx)
+
+		var err error
+		e, _, err = txrepo.UpsertEdge(edge)
+		if err != nil {
+			return err
+		}
+
+		tags, err = sql.createEdgeTagsTx(tx, e, props)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, tags, nil
+}
+
+// CreateEdgeTagsBatch creates the edge tags for the properties provided for each edge ID within a single transaction,
+// using the same deduplication as CreateEdgeTags.
+// Returns the created or updated edge tags by edge ID, or an error if the transaction fails.
+func (sql *sqlRepository) CreateEdgeTagsBatch(props map[string][]oam.Property) (map[string][]*types.EdgeTag, error) {
+	results := make(map[string][]*types.EdgeTag, len(props))
+
+	err := sql.db.Transaction(func(tx *gorm.DB) error {
+		for id, list := range props {
+			tags, err := sql.createEdgeTagsTx(tx, &types.Edge{ID: id}, list)
+			if err != nil {
+				return err
+			}
+			results[id] = tags
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// createEdgeTagsTx creates or updates the tags for the properties on the edge using the provided transaction.
+func (sql *sqlRepository) createEdgeTagsTx(tx *gorm.DB, edge *types.Edge, props []oam.Property) ([]*types.EdgeTag, error) {
+	type key struct {
+		ptype oam.PropertyType
+		name  string
+		value string
+	}
+
+	edgeid, err := strconv.ParseUint(edge.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []EdgeTag
+	if err := tx.Where("edge_id = ?", edgeid).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	tags := make(map[key]*EdgeTag, len(existing))
+	for i := range existing {
+		if prop, err := existing[i].Parse(); err == nil {
+			tags[key{prop.PropertyType(), prop.Name(), prop.Value()}] = &existing[i]
+		}
+	}
+
+	now := time.Now().UTC()
+	seen := make(map[key]struct{}, len(props))
+
+	var results []*types.EdgeTag
+	for _, prop := range props {
+		if prop == nil {
+			continue
+		}
+
+		k := key{prop.PropertyType(), prop.Name(), prop.Value()}
+		if _, found := seen[k]; found {
+			continue
+		}
+		seen[k] = struct{}{}
+
+		tag, found := tags[k]
+		if found {
+			tag.UpdatedAt = now
+		} else {
+			jsonContent, err := sql.content(prop)
+			if err != nil {
+				return nil, err
+			}
+
+			tag = &EdgeTag{
+				Type:      string(prop.PropertyType()),
+				Content:   jsonContent,
+				EdgeID:    edgeid,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+		}
+
+		if err := tx.Save(tag).Error; err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.EdgeTag{
+			ID:        strconv.FormatUint(tag.ID, 10),
+			CreatedAt: tag.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  tag.UpdatedAt.In(time.UTC).Local(),
+			Property:  prop,
+			Edge:      edge,
+		})
+	}
+	return results, nil
+}
+
 // FindEdgeTagById finds an edge tag in the database by the ID.
 // It takes a string representing the edge tag ID and retrieves the corresponding tag from the database.
 // Returns the discovered tag as a types.EdgeTag or an error if the asset is not found.
@@ -320,13 +964,13 @@ func (sql *sqlRepository) FindEdgeTagById(id string) (*types.EdgeTag, error) {
 		return nil, err
 	}
 
-	tag := EdgeTag{ID: tagId}
-	result := sql.db.First(&tag)
+	var tag EdgeTag
+	result := sql.db.Where("tag_id = ?", tagId).First(&tag)
 	if err := result.Error; err != nil {
-		return nil, err
+		return nil, notFound(err, types.ErrTagNotFound)
 	}
 
-	data, err := tag.Parse()
+	data, _, err := sql.parseProperty(tag.ID, tag.Type, tag.Content)
 	if err != nil {
 		return nil, err
 	}
@@ -384,39 +1028,138 @@ func (sql *sqlRepository) FindEdgeTagsByContent(prop oam.Property, since time.Ti
 
 	var results []*types.EdgeTag
 	for _, t := range tags {
-		if propData, err := t.Parse(); err == nil {
-			results = append(results, &types.EdgeTag{
-				ID:        strconv.FormatUint(t.ID, 10),
-				CreatedAt: t.CreatedAt.In(time.UTC).Local(),
-				LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
-				Property:  propData,
-				Edge:      &types.Edge{ID: strconv.FormatUint(t.EdgeID, 10)},
-			})
+		propData, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
 		}
+
+		results = append(results, &types.EdgeTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  propData,
+			Edge:      &types.Edge{ID: strconv.FormatUint(t.EdgeID, 10)},
+		})
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero edge tags found")
+		return []*types.EdgeTag{}, sql.opts.NoResults("zero edge tags found")
 	}
 	return results, nil
 }
 
+// FindAllTagsByContent finds both the entity tags and the edge tags in the database that match the provided property
+// data and updated_at after the since parameter. The same name and value JSON queries are used for both tag tables.
+// If since.IsZero(), the parameter will be ignored.
+// Returns the matching entity tags and edge tags, or an error if the search fails or neither kind of tag was found.
+func (sql *sqlRepository) FindAllTagsByContent(prop oam.Property, since time.Time) ([]*types.EntityTag, []*types.EdgeTag, error) {
+	jsonContent, err := prop.JSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tag := EntityTag{
+		Type:    string(prop.PropertyType()),
+		Content: jsonContent,
+	}
+
+	nameQuery, err := tag.NameJSONQuery()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	valueQuery, err := tag.ValueJSONQuery()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := func() *gorm.DB {
+		tx := sql.db.Where("ttype = ?", tag.Type)
+		if !since.IsZero() {
+			tx = tx.Where("updated_at >= ?", since.UTC())
+		}
+		return tx.Where(nameQuery).Where(valueQuery)
+	}
+
+	var etags []EntityTag
+	if err := query().Find(&etags).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var edtags []EdgeTag
+	if err := query().Find(&edtags).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var entityTags []*types.EntityTag
+	for _, t := range etags {
+		propData, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		entityTags = append(entityTags, &types.EntityTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  propData,
+			Entity:    &types.Entity{ID: strconv.FormatUint(t.EntityID, 10)},
+		})
+	}
+
+	var edgeTags []*types.EdgeTag
+	for _, t := range edtags {
+		propData, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		edgeTags = append(edgeTags, &types.EdgeTag{
+			ID:        strconv.FormatUint(t.ID, 10),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  propData,
+			Edge:      &types.Edge{ID: strconv.FormatUint(t.EdgeID, 10)},
+		})
+	}
+
+	if len(entityTags) == 0 && len(edgeTags) == 0 {
+		return []*types.EntityTag{}, []*types.EdgeTag{}, sql.opts.NoResults("zero tags found")
+	}
+	return entityTags, edgeTags, nil
+}
+
 // GetEdgeTags finds all tags for the edge with the specified names and last seen after the since parameter.
 // If since.IsZero(), the parameter will be ignored.
 // If no names are specified, all tags for the specified edge are returned.
 func (sql *sqlRepository) GetEdgeTags(edge *types.Edge, since time.Time, names ...string) ([]*types.EdgeTag, error) {
+	return sql.getEdgeTags(edge, since, time.Time{}, names...)
+}
+
+// GetEdgeTagsBetween finds all tags for the edge with the specified names and last seen between start and end,
+// inclusive. If start.IsZero(), the range has no lower bound.
+// If no names are specified, all tags for the specified edge within the range are returned.
+func (sql *sqlRepository) GetEdgeTagsBetween(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error) {
+	if err := types.ValidateTimeRange(start, end); err != nil {
+		return nil, err
+	}
+	return sql.getEdgeTags(edge, start, end, names...)
+}
+
+func (sql *sqlRepository) getEdgeTags(edge *types.Edge, start, end time.Time, names ...string) ([]*types.EdgeTag, error) {
 	edgeId, err := strconv.ParseInt(edge.ID, 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
 	var tags []EdgeTag
-	var result *gorm.DB
-	if since.IsZero() {
-		result = sql.db.Where("edge_id = ?", edgeId).Find(&tags)
-	} else {
-		result = sql.db.Where("edge_id = ? AND updated_at >= ?", edgeId, since.UTC()).Find(&tags)
-	}
+	result := lastSeenBetween(sql.db.Where("edge_id = ?", edgeId), start, end).Find(&tags)
 	if err := result.Error; err != nil {
 		return nil, err
 	}
@@ -425,39 +1168,126 @@ func (sql *sqlRepository) GetEdgeTags(edge *types.Edge, since time.Time, names .
 	for _, tag := range tags {
 		t := &tag
 
-		if prop, err := t.Parse(); err == nil {
-			found := true
+		prop, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found := true
 
-			if len(names) > 0 {
-				found = false
-				n := prop.Name()
+		if len(names) > 0 {
+			found = false
+			n := prop.Name()
 
-				for _, name := range names {
-					if name == n {
-						found = true
-						break
-					}
+			for _, name := range names {
+				if name == n {
+					found = true
+					break
 				}
 			}
+		}
 
-			if found {
-				results = append(results, &types.EdgeTag{
-					ID:        strconv.Itoa(int(t.ID)),
-					CreatedAt: t.CreatedAt.In(time.UTC).Local(),
-					LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
-					Property:  prop,
-					Edge:      edge,
-				})
-			}
+		if found {
+			results = append(results, &types.EdgeTag{
+				ID:        strconv.Itoa(int(t.ID)),
+				CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+				LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+				Property:  prop,
+				Edge:      edge,
+			})
 		}
 	}
 
 	if len(results) == 0 {
-		return nil, errors.New("zero tags found")
+		return []*types.EdgeTag{}, sql.opts.NoResults("zero tags found")
 	}
 	return results, nil
 }
 
+// GetEdgeTagsByType finds all tags for the edge with the specified property types and last seen after the since parameter.
+// If since.IsZero(), the parameter will be ignored.
+// If no property types are specified, all tags for the specified edge are returned.
+func (sql *sqlRepository) GetEdgeTagsByType(edge *types.Edge, since time.Time, ptypes ...oam.PropertyType) ([]*types.EdgeTag, error) {
+	edgeId, err := strconv.ParseInt(edge.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := sql.db.Where("edge_id = ?", edgeId)
+	if len(ptypes) > 0 {
+		tx = tx.Where("ttype IN ?", propertyTypeStrings(ptypes))
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var tags []EdgeTag
+	if err := tx.Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var results []*types.EdgeTag
+	for _, tag := range tags {
+		t := &tag
+
+		prop, skip, err := sql.parseProperty(t.ID, t.Type, t.Content)
+		if skip {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &types.EdgeTag{
+			ID:        strconv.Itoa(int(t.ID)),
+			CreatedAt: t.CreatedAt.In(time.UTC).Local(),
+			LastSeen:  t.UpdatedAt.In(time.UTC).Local(),
+			Property:  prop,
+			Edge:      edge,
+		})
+	}
+
+	if len(results) == 0 {
+		return []*types.EdgeTag{}, sql.opts.NoResults("zero tags found")
+	}
+	return results, nil
+}
+
+// CountEdgeTags counts the tags for the edge with the specified names and last seen after the since parameter,
+// without retrieving them.
+// If since.IsZero(), the parameter will be ignored.
+// If no names are specified, all tags for the specified edge are counted.
+func (sql *sqlRepository) CountEdgeTags(edge *types.Edge, since time.Time, names ...string) (int64, error) {
+	edgeId, err := strconv.ParseInt(edge.ID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	tx := sql.db.Model(&EdgeTag{}).Where("edge_id = ?", edgeId)
+	if len(names) > 0 {
+		tx = tx.Where(sql.propertyNameField()+" IN ?", names)
+	}
+	if !since.IsZero() {
+		tx = tx.Where("updated_at >= ?", since.UTC())
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// propertyTypeStrings returns the property types as the values stored in the ttype column.
+func propertyTypeStrings(ptypes []oam.PropertyType) []string {
+	values := make([]string, 0, len(ptypes))
+	for _, ptype := range ptypes {
+		values = append(values, string(ptype))
+	}
+	return values
+}
+
 // DeleteEdgeTag removes an edge tag in the database by its ID.
 // It takes a string representing the edge tag ID and removes the corresponding tag from the database.
 // Returns an error if the tag is not found.
@@ -468,9 +1298,16 @@ func (sql *sqlRepository) DeleteEdgeTag(id string) error {
 	}
 
 	tag := EdgeTag{ID: tagId}
-	result := sql.db.Delete(&tag)
+	result := sql.deleter(sql.db).Delete(&tag)
 	if err := result.Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+// DeleteEdgeTagsByNameGlobal removes all edge tags in the database with the specified property name.
+// The matching tags are removed in batches, regardless of the edge they are attached to.
+// Returns the number of edge tags that were removed or an error if the deletion fails.
+func (sql *sqlRepository) DeleteEdgeTagsByNameGlobal(name string) (int64, error) {
+	return sql.deleteTagsByName(&EdgeTag{}, name)
+}
@@ -5,9 +5,11 @@
 package sqlrepo
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/owasp-amass/asset-db/repository/options"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
 	"github.com/owasp-amass/open-asset-model/domain"
@@ -83,6 +85,32 @@ func TestEntityTag(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCreateEntityTagsContext(t *testing.T) {
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "tagsctx.owasp.org"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SimpleProperty{PropertyName: "bulk", PropertyValue: "one"},
+		nil,
+		&property.SimpleProperty{PropertyName: "bulk", PropertyValue: "two"},
+	}
+
+	result, err := store.CreateEntityTagsContext(context.Background(), entity, props)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, result.Failed())
+
+	tags, err := store.GetEntityTags(entity, time.Time{}, "bulk")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err = store.CreateEntityTagsContext(ctx, entity, props[:1])
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, result.Err(), context.Canceled)
+}
+
 func TestEdgeTag(t *testing.T) {
 	e1, err := store.CreateAsset(&domain.FQDN{Name: "owasp.org"})
 	assert.NoError(t, err)
@@ -162,3 +190,701 @@ func TestEdgeTag(t *testing.T) {
 	_, err = store.FindEdgeTagById(ct3.ID)
 	assert.Error(t, err)
 }
+
+func TestDeleteEntityTagsByNameGlobal(t *testing.T) {
+	var entities []*types.Entity
+	for _, name := range []string{"global1.owasp.org", "global2.owasp.org", "global3.owasp.org"} {
+		entity, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+		entities = append(entities, entity)
+
+		_, err = store.CreateEntityProperty(entity, &property.SimpleProperty{
+			PropertyName:  "global_retired",
+			PropertyValue: name,
+		})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEntityProperty(entity, &property.SimpleProperty{
+			PropertyName:  "global_kept",
+			PropertyValue: name,
+		})
+		assert.NoError(t, err)
+	}
+
+	// the small batch size removes the matching tags across several chunks
+	repo := testRepository(store.db, options.New(options.WithBatchSize(2)))
+	count, err := repo.DeleteEntityTagsByNameGlobal("global_retired")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(entities)), count)
+
+	for _, entity := range entities {
+		_, err := store.GetEntityTags(entity, time.Time{}, "global_retired")
+		assert.Error(t, err)
+
+		tags, err := store.GetEntityTags(entity, time.Time{}, "global_kept")
+		assert.NoError(t, err)
+		assert.Len(t, tags, 1)
+	}
+
+	count, err = store.DeleteEntityTagsByNameGlobal("global_retired")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestDeleteEdgeTagsByNameGlobal(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "edges.global.owasp.org"})
+	assert.NoError(t, err)
+
+	var edges []*types.Edge
+	for _, name := range []string{"a.global.owasp.org", "b.global.owasp.org"} {
+		to, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		edge, err := store.CreateEdge(&types.Edge{
+			Relation: &relation.BasicDNSRelation{
+				Name:   "dns_record",
+				Header: relation.RRHeader{RRType: 5},
+			},
+			FromEntity: from,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+		edges = append(edges, edge)
+
+		_, err = store.CreateEdgeProperty(edge, &property.SimpleProperty{
+			PropertyName:  "global_retired",
+			PropertyValue: name,
+		})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEdgeProperty(edge, &property.SimpleProperty{
+			PropertyName:  "global_kept",
+			PropertyValue: name,
+		})
+		assert.NoError(t, err)
+	}
+
+	count, err := store.DeleteEdgeTagsByNameGlobal("global_retired")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(edges)), count)
+
+	for _, edge := range edges {
+		_, err := store.GetEdgeTags(edge, time.Time{}, "global_retired")
+		assert.Error(t, err)
+
+		tags, err := store.GetEdgeTags(edge, time.Time{}, "global_kept")
+		assert.NoError(t, err)
+		assert.Len(t, tags, 1)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	repo := testRepository(store.db, options.New(options.WithSoftDelete()))
+
+	entity, err := repo.CreateAsset(&domain.FQDN{Name: "soft.owasp.org"})
+	assert.NoError(t, err)
+	prop := &property.SimpleProperty{PropertyName: "soft_check", PropertyValue: "entity"}
+	etag, err := repo.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+
+	err = repo.DeleteEntityTag(etag.ID)
+	assert.NoError(t, err)
+	_, err = repo.FindEntityTagById(etag.ID)
+	assert.Error(t, err)
+
+	// the raw subqueries leave out the tags marked as deleted
+	tagged, _ := repo.FindEntitiesByTags([]types.TagPredicate{{Name: "soft_check"}}, types.And, time.Time{})
+	assert.Empty(t, tagged)
+
+	err = repo.DeleteEntity(entity.ID)
+	assert.NoError(t, err)
+	_, err = repo.FindEntityById(entity.ID)
+	assert.Error(t, err)
+
+	// the rows are kept, so the entity can be recovered
+	found, err := repo.FindEntityByIdIncludingDeleted(entity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ID, found.ID)
+
+	var count int64
+	assert.NoError(t, store.db.Unscoped().Model(&EntityTag{}).Where("tag_id = ?", etag.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// without the option, the rows are removed
+	other, err := store.CreateAsset(&domain.FQDN{Name: "hard.owasp.org"})
+	assert.NoError(t, err)
+	err = store.DeleteEntity(other.ID)
+	assert.NoError(t, err)
+	_, err = store.FindEntityByIdIncludingDeleted(other.ID)
+	assert.Error(t, err)
+}
+
+func TestSoftDeleteEdge(t *testing.T) {
+	repo := testRepository(store.db, options.New(options.WithSoftDelete()))
+
+	from, err := repo.CreateAsset(&domain.FQDN{Name: "soft.edge.owasp.org"})
+	assert.NoError(t, err)
+	to, err := repo.CreateAsset(&domain.FQDN{Name: "to.soft.edge.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := repo.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+	prop := &property.SimpleProperty{PropertyName: "soft_check", PropertyValue: "edge"}
+	dtag, err := repo.CreateEdgeProperty(edge, prop)
+	assert.NoError(t, err)
+
+	err = repo.DeleteEdge(edge.ID)
+	assert.NoError(t, err)
+	_, err = repo.FindEdgeById(edge.ID)
+	assert.Error(t, err)
+	_, err = repo.FindEdgeTagById(dtag.ID)
+	assert.Error(t, err)
+
+	// the traversal does not follow the edges marked as deleted
+	entities, edges, err := repo.TraverseNeighborhood(from, 1, types.Outgoing)
+	assert.NoError(t, err)
+	assert.Empty(t, edges)
+	if assert.Len(t, entities, 1) {
+		assert.Equal(t, from.ID, entities[0].ID)
+	}
+
+	// the tags of the edge are marked as deleted along with it
+	var count int64
+	assert.NoError(t, store.db.Unscoped().Model(&EdgeTag{}).
+		Where("tag_id = ? AND deleted_at IS NOT NULL", dtag.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDeleteEntityRemovesTags(t *testing.T) {
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "orphan.owasp.org"})
+	assert.NoError(t, err)
+	neighbor, err := store.CreateAsset(&domain.FQDN{Name: "neighbor.orphan.owasp.org"})
+	assert.NoError(t, err)
+
+	prop := &property.SimpleProperty{PropertyName: "orphan_check", PropertyValue: "entity"}
+	etag, err := store.CreateEntityProperty(entity, prop)
+	assert.NoError(t, err)
+
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: neighbor,
+		ToEntity:   entity,
+	})
+	assert.NoError(t, err)
+
+	eprop := &property.SimpleProperty{PropertyName: "orphan_check", PropertyValue: "edge"}
+	dtag, err := store.CreateEdgeProperty(edge, eprop)
+	assert.NoError(t, err)
+
+	err = store.DeleteEntity(entity.ID)
+	assert.NoError(t, err)
+
+	tags, err := store.FindEntityTagsByContent(prop, time.Time{})
+	assert.Error(t, err)
+	assert.Empty(t, tags)
+	_, err = store.FindEntityTagById(etag.ID)
+	assert.Error(t, err)
+
+	_, err = store.FindEdgeById(edge.ID)
+	assert.Error(t, err)
+	_, err = store.FindEdgeTagById(dtag.ID)
+	assert.Error(t, err)
+
+	// the neighbor is left intact
+	_, err = store.FindEntityById(neighbor.ID)
+	assert.NoError(t, err)
+}
+
+func TestFindEntityTagsBySource(t *testing.T) {
+	sources := map[string]string{
+		"source1.owasp.org": "tag_source_one",
+		"source2.owasp.org": "tag_source_one",
+		"source3.owasp.org": "tag_source_two",
+	}
+
+	for name, src := range sources {
+		entity, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEntityProperty(entity, &property.SourceProperty{
+			Source:     src,
+			Confidence: 100,
+		})
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.FindEntityTagsBySource("tag_source_one", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+
+	for _, tag := range tags {
+		src, ok := tag.Property.(*property.SourceProperty)
+		assert.True(t, ok)
+		assert.Equal(t, "tag_source_one", src.Source)
+
+		entity, err := store.FindEntityById(tag.Entity.ID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "source3.owasp.org", entity.Asset.Key())
+	}
+
+	_, err = store.FindEntityTagsBySource("tag_source_missing", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntityTagsByValuePrefix(t *testing.T) {
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "prefix.tags.owasp.org"})
+	assert.NoError(t, err)
+
+	for _, value := range []string{"dns/crtsh", "web/wayback", "dns_%/literal"} {
+		_, err = store.CreateEntityProperty(entity, &property.SimpleProperty{
+			PropertyName:  "prefix_path",
+			PropertyValue: value,
+		})
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.FindEntityTagsByValuePrefix("prefix_path", "dns/", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, "dns/crtsh", tags[0].Property.Value())
+		assert.Equal(t, entity.ID, tags[0].Entity.ID)
+	}
+
+	// the wildcard characters in the prefix are matched literally
+	tags, err = store.FindEntityTagsByValuePrefix("prefix_path", "dns_%", time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, "dns_%/literal", tags[0].Property.Value())
+	}
+
+	_, err = store.FindEntityTagsByValuePrefix("prefix_missing", "dns/", time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFindEntitiesByTags(t *testing.T) {
+	both, err := store.CreateAsset(&domain.FQDN{Name: "both.predicates.owasp.org"})
+	assert.NoError(t, err)
+	one, err := store.CreateAsset(&domain.FQDN{Name: "one.predicates.owasp.org"})
+	assert.NoError(t, err)
+	neither, err := store.CreateAsset(&domain.FQDN{Name: "neither.predicates.owasp.org"})
+	assert.NoError(t, err)
+
+	for _, tag := range []struct {
+		entity *types.Entity
+		prop   oam.Property
+	}{
+		{both, &property.SourceProperty{Source: "predicate_dns", Confidence: 90}},
+		{both, &property.SimpleProperty{PropertyName: "predicate_env", PropertyValue: "prod"}},
+		{one, &property.SourceProperty{Source: "predicate_dns", Confidence: 80}},
+		{one, &property.SimpleProperty{PropertyName: "predicate_env", PropertyValue: "dev"}},
+		{neither, &property.SourceProperty{Source: "predicate_dns", Confidence: 20}},
+	} {
+		_, err := store.CreateEntityProperty(tag.entity, tag.prop)
+		assert.NoError(t, err)
+	}
+
+	predicates := []types.TagPredicate{
+		{Name: "predicate_dns", Op: types.TagGreaterEqual, Value: "80"},
+		{Name: "predicate_env", Op: types.TagEqual, Value: "prod"},
+	}
+	ids := func(entities []*types.Entity) []string {
+		var results []string
+		for _, e := range entities {
+			results = append(results, e.ID)
+		}
+		return results
+	}
+
+	entities, err := store.FindEntitiesByTags(predicates, types.And, time.Time{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{both.ID}, ids(entities))
+
+	entities, err = store.FindEntitiesByTags(predicates, types.Or, time.Time{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{both.ID, one.ID}, ids(entities))
+
+	// the predicate without an operator matches any value of the tag
+	entities, err = store.FindEntitiesByTags([]types.TagPredicate{{Name: "predicate_env"}}, types.And, time.Time{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{both.ID, one.ID}, ids(entities))
+
+	_, err = store.FindEntitiesByTags(predicates, types.And, time.Now().Add(time.Hour))
+	assert.Error(t, err)
+
+	_, err = store.FindEntitiesByTags(nil, types.And, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestExistingEntityTags(t *testing.T) {
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "existing.tags.owasp.org"})
+	assert.NoError(t, err)
+
+	present := []oam.Property{
+		&property.SimpleProperty{PropertyName: "existing_tag", PropertyValue: "one"},
+		&property.SourceProperty{Source: "existing_source", Confidence: 90},
+	}
+	for _, prop := range present {
+		_, err := store.CreateEntityProperty(entity, prop)
+		assert.NoError(t, err)
+	}
+
+	absent := []oam.Property{
+		&property.SimpleProperty{PropertyName: "existing_tag", PropertyValue: "two"},
+		&property.SimpleProperty{PropertyName: "missing_tag", PropertyValue: "one"},
+		&property.SourceProperty{Source: "missing_source", Confidence: 90},
+	}
+
+	candidates := []oam.Property{absent[0], present[0], absent[1], present[1], absent[2]}
+	existing, err := store.ExistingEntityTags(entity, candidates)
+	assert.NoError(t, err)
+	assert.Equal(t, present, existing)
+
+	existing, err = store.ExistingEntityTags(entity, absent)
+	assert.NoError(t, err)
+	assert.Empty(t, existing)
+}
+
+func TestCreateEntityTags(t *testing.T) {
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "bulk.entity.tags.owasp.org"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "bulk_source", Confidence: 80},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "two"},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "one"},
+	}
+
+	tags, err := store.CreateEntityTags(entity, props)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 3)
+
+	// creating the same tags again updates the existing tags
+	time.Sleep(time.Second)
+	again, err := store.CreateEntityTags(entity, props[1:2])
+	assert.NoError(t, err)
+	if assert.Len(t, again, 1) {
+		assert.Equal(t, tags[1].ID, again[0].ID)
+		assert.True(t, again[0].LastSeen.After(tags[1].LastSeen))
+	}
+
+	existing, err := store.GetEntityTags(entity, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 3)
+}
+
+func TestCreateEdgeTags(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "bulk.tags.owasp.org"})
+	assert.NoError(t, err)
+	to1, err := store.CreateAsset(&domain.FQDN{Name: "bulk1.tags.owasp.org"})
+	assert.NoError(t, err)
+	to2, err := store.CreateAsset(&domain.FQDN{Name: "bulk2.tags.owasp.org"})
+	assert.NoError(t, err)
+
+	rel := &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}}
+	edge1, err := store.CreateEdge(&types.Edge{Relation: rel, FromEntity: from, ToEntity: to1})
+	assert.NoError(t, err)
+	edge2, err := store.CreateEdge(&types.Edge{Relation: rel, FromEntity: from, ToEntity: to2})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "bulk_source", Confidence: 80},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "two"},
+		&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "one"},
+	}
+
+	tags, err := store.CreateEdgeTags(edge1, props)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 3)
+
+	// creating the same tags again updates the existing tags
+	time.Sleep(time.Second)
+	again, err := store.CreateEdgeTags(edge1, props[1:2])
+	assert.NoError(t, err)
+	if assert.Len(t, again, 1) {
+		assert.Equal(t, tags[1].ID, again[0].ID)
+		assert.True(t, again[0].LastSeen.After(tags[1].LastSeen))
+	}
+
+	existing, err := store.GetEdgeTags(edge1, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 3)
+
+	batch, err := store.CreateEdgeTagsBatch(map[string][]oam.Property{
+		edge1.ID: {&property.SimpleProperty{PropertyName: "bulk_tag", PropertyValue: "three"}, props[0]},
+		edge2.ID: props,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, batch[edge1.ID], 2)
+	assert.Len(t, batch[edge2.ID], 3)
+
+	existing, err = store.GetEdgeTags(edge1, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 4)
+
+	existing, err = store.GetEdgeTags(edge2, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 3)
+}
+
+func TestFindAllTagsByContent(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "alltags.owasp.org"})
+	assert.NoError(t, err)
+
+	to, err := store.CreateAsset(&domain.FQDN{Name: "www.alltags.owasp.org"})
+	assert.NoError(t, err)
+
+	edge, err := store.CreateEdge(&types.Edge{
+		Relation: &relation.BasicDNSRelation{
+			Name:   "dns_record",
+			Header: relation.RRHeader{RRType: 5},
+		},
+		FromEntity: from,
+		ToEntity:   to,
+	})
+	assert.NoError(t, err)
+
+	prop := &property.SimpleProperty{
+		PropertyName:  "all_tags",
+		PropertyValue: "shared",
+	}
+
+	etag, err := store.CreateEntityProperty(from, prop)
+	assert.NoError(t, err)
+
+	edtag, err := store.CreateEdgeProperty(edge, prop)
+	assert.NoError(t, err)
+
+	entityTags, edgeTags, err := store.FindAllTagsByContent(prop, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entityTags, 1) {
+		assert.Equal(t, etag.ID, entityTags[0].ID)
+		assert.Equal(t, from.ID, entityTags[0].Entity.ID)
+	}
+	if assert.Len(t, edgeTags, 1) {
+		assert.Equal(t, edtag.ID, edgeTags[0].ID)
+		assert.Equal(t, edge.ID, edgeTags[0].Edge.ID)
+	}
+
+	_, _, err = store.FindAllTagsByContent(&property.SimpleProperty{
+		PropertyName:  "all_tags",
+		PropertyValue: "missing",
+	}, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestEntityTagTimeline(t *testing.T) {
+	entity, err := store.CreateAsset(&domain.FQDN{Name: "timeline.owasp.org"})
+	assert.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	for i, offset := range []time.Duration{-2 * time.Hour, -3 * time.Hour, -time.Hour} {
+		_, err := store.CreateEntityTag(entity, &types.EntityTag{
+			CreatedAt: now.Add(offset),
+			LastSeen:  now.Add(offset),
+			Property: &property.SimpleProperty{
+				PropertyName:  "timeline",
+				PropertyValue: string(rune('a' + i)),
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.EntityTagTimeline(entity.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, tags, 3) {
+		assert.Equal(t, "b", tags[0].Property.Value())
+		assert.Equal(t, "a", tags[1].Property.Value())
+		assert.Equal(t, "c", tags[2].Property.Value())
+		for i := 1; i < len(tags); i++ {
+			assert.False(t, tags[i].CreatedAt.Before(tags[i-1].CreatedAt))
+		}
+	}
+}
+
+func TestCreateEdgeWithProperties(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "props.tags.owasp.org"})
+	assert.NoError(t, err)
+	to, err := store.CreateAsset(&domain.FQDN{Name: "props1.tags.owasp.org"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "props_source", Confidence: 90},
+		&property.SimpleProperty{PropertyName: "props_tag", PropertyValue: "one"},
+	}
+
+	edge, tags, err := store.CreateEdgeWithProperties(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: from,
+		ToEntity:   to,
+	}, props)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+
+	existing, err := store.GetEdgeTags(edge, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, existing, 2)
+
+	// an invalid edge produces neither the edge nor the tags
+	_, _, err = store.CreateEdgeWithProperties(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "not_in_taxonomy"},
+		FromEntity: from,
+		ToEntity:   to,
+	}, props)
+	assert.Error(t, err)
+
+	outs, err := store.OutgoingEdges(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, outs, 1)
+}
+
+func TestGetTagsByType(t *testing.T) {
+	from, err := store.CreateAsset(&domain.FQDN{Name: "tags.bytype.owasp.org"})
+	assert.NoError(t, err)
+	to, err := store.CreateAsset(&domain.FQDN{Name: "tags.bytype1.owasp.org"})
+	assert.NoError(t, err)
+
+	props := []oam.Property{
+		&property.SourceProperty{Source: "bytype_source", Confidence: 90},
+		&property.SimpleProperty{PropertyName: "bytype_one", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "bytype_two", PropertyValue: "two"},
+	}
+	for _, prop := range props {
+		_, err := store.CreateEntityProperty(from, prop)
+		assert.NoError(t, err)
+	}
+
+	tags, err := store.GetEntityTagsByType(from, time.Time{}, oam.SimpleProperty)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	for _, tag := range tags {
+		assert.Equal(t, oam.SimpleProperty, tag.Property.PropertyType())
+		assert.Equal(t, from.ID, tag.Entity.ID)
+	}
+
+	tags, err = store.GetEntityTagsByType(from, time.Time{}, oam.SourceProperty)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "bytype_source", tags[0].Property.Name())
+
+	// no property types returns all the tags
+	tags, err = store.GetEntityTagsByType(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, tags, 3)
+
+	_, err = store.GetEntityTagsByType(from, time.Time{}, oam.VulnProperty)
+	assert.Error(t, err)
+
+	edge, _, err := store.CreateEdgeWithProperties(&types.Edge{
+		Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+		FromEntity: from,
+		ToEntity:   to,
+	}, props)
+	assert.NoError(t, err)
+
+	etags, err := store.GetEdgeTagsByType(edge, time.Time{}, oam.SourceProperty)
+	assert.NoError(t, err)
+	assert.Len(t, etags, 1)
+	assert.Equal(t, oam.SourceProperty, etags[0].Property.PropertyType())
+	assert.Equal(t, edge.ID, etags[0].Edge.ID)
+
+	etags, err = store.GetEdgeTagsByType(edge, time.Time{}, oam.SimpleProperty, oam.SourceProperty)
+	assert.NoError(t, err)
+	assert.Len(t, etags, 3)
+}
+
+func TestCountMethods(t *testing.T) {
+	before, err := store.CountEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+
+	from, err := store.CreateAsset(&domain.FQDN{Name: "tags.count.owasp.org"})
+	assert.NoError(t, err)
+	for _, name := range []string{"tags.count1.owasp.org", "tags.count2.owasp.org"} {
+		to, err := store.CreateAsset(&domain.FQDN{Name: name})
+		assert.NoError(t, err)
+
+		_, err = store.CreateEdge(&types.Edge{
+			Relation:   &relation.BasicDNSRelation{Name: "dns_record", Header: relation.RRHeader{RRType: 5, Class: 1}},
+			FromEntity: from,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+	}
+
+	after, err := store.CountEntitiesByType(oam.FQDN, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, before+3, after)
+
+	total, err := store.CountEntitiesByType(oam.IPAddress, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+
+	total, err = store.CountOutgoingEdges(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.CountOutgoingEdges(from, time.Time{}, "dns_record")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.CountOutgoingEdges(from, time.Time{}, "node")
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+
+	for _, prop := range []oam.Property{
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "two"},
+		&property.SourceProperty{Source: "count_source", Confidence: 90},
+		&property.VulnProperty{ID: "CVE-2024-0001", Description: "count vuln"},
+	} {
+		_, err := store.CreateEntityProperty(from, prop)
+		assert.NoError(t, err)
+	}
+
+	total, err = store.CountEntityTags(from, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = store.CountEntityTags(from, time.Time{}, "count_tag", "count_source", "CVE-2024-0001")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = store.CountEntityTags(from, time.Time{}, "count_source")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	total, err = store.CountEntityTags(from, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+
+	edges, err := store.OutgoingEdges(from, time.Time{})
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, edges) {
+		return
+	}
+
+	tags, err := store.CreateEdgeTags(edges[0], []oam.Property{
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "one"},
+		&property.SimpleProperty{PropertyName: "count_tag", PropertyValue: "two"},
+		&property.SourceProperty{Source: "count_source", Confidence: 90},
+	})
+	assert.NoError(t, err)
+
+	// the count matches the number of tags created
+	total, err = store.CountEdgeTags(edges[0], time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(tags)), total)
+
+	total, err = store.CountEdgeTags(edges[0], time.Time{}, "count_tag")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	total, err = store.CountEdgeTags(edges[0], time.Time{}, "missing")
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+}
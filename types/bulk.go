@@ -0,0 +1,55 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BulkResult reports the outcome of each item of a bulk operation. The Results and Errors slices are in the
+// order of the input items, and each item has either a result or an error.
+type BulkResult[T any] struct {
+	Results []T
+	Errors  []error
+}
+
+// NewBulkResult returns a BulkResult for a bulk operation on n items.
+func NewBulkResult[T any](n int) *BulkResult[T] {
+	return &BulkResult[T]{
+		Results: make([]T, n),
+		Errors:  make([]error, n),
+	}
+}
+
+// FailFrom reports the error for every item starting at position i, such as when the operation is cancelled
+// before the remaining items are processed.
+func (r *BulkResult[T]) FailFrom(i int, err error) {
+	for ; i < len(r.Errors); i++ {
+		r.Errors[i] = err
+	}
+}
+
+// Failed returns the positions of the items that failed.
+func (r *BulkResult[T]) Failed() []int {
+	var failed []int
+	for i, err := range r.Errors {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// Err returns the errors of the failed items, prefixed with their positions, or nil if every item succeeded.
+func (r *BulkResult[T]) Err() error {
+	var errs []error
+	for i, err := range r.Errors {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
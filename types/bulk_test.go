@@ -0,0 +1,27 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkResult(t *testing.T) {
+	r := NewBulkResult[string](4)
+	assert.NoError(t, r.Err())
+	assert.Empty(t, r.Failed())
+
+	r.Results[0] = "ok"
+	r.Errors[1] = errors.New("bad item")
+	r.FailFrom(2, context.Canceled)
+
+	assert.Equal(t, []int{1, 2, 3}, r.Failed())
+	assert.ErrorIs(t, r.Err(), context.Canceled)
+	assert.ErrorContains(t, r.Err(), "item 1: bad item")
+}
@@ -0,0 +1,179 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// CopyAsset returns a deep copy of the asset, obtained by parsing its JSON encoding,
+// so changes made to the copy are not seen by the holders of the original.
+func CopyAsset(asset oam.Asset) (oam.Asset, error) {
+	if asset == nil {
+		return nil, nil
+	}
+	if u, ok := asset.(*UnknownAsset); ok {
+		return &UnknownAsset{Type: u.Type, Content: append([]byte(nil), u.Content...)}, nil
+	}
+
+	content, err := asset.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return ParseAsset(asset.AssetType(), content)
+}
+
+// MergeAsset returns a copy of the stored asset with the fields populated in the update written over it, so the
+// fields that are empty in the update keep their stored values. This is used when an asset matched by one of its
+// key fields is observed again with only some of its fields. Assets with unknown types are not merged.
+func MergeAsset(stored, update oam.Asset) (oam.Asset, error) {
+	if stored == nil || update == nil {
+		return nil, errors.New("the asset is nil")
+	}
+	if stored.AssetType() != update.AssetType() {
+		return nil, fmt.Errorf("a %s asset cannot be merged into a %s asset", update.AssetType(), stored.AssetType())
+	}
+	if _, ok := stored.(*UnknownAsset); ok {
+		return CopyAsset(update)
+	}
+	if _, ok := update.(*UnknownAsset); ok {
+		return CopyAsset(update)
+	}
+
+	merged, err := jsonFields(stored)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := jsonFields(update)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range fields {
+		if !emptyJSONValue(value) {
+			merged[name] = value
+		}
+	}
+
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAsset(update.AssetType(), content)
+}
+
+// jsonFields decodes the serialized fields of the asset, keeping the numbers exact.
+func jsonFields(asset oam.Asset) (map[string]interface{}, error) {
+	content, err := asset.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	fields := make(map[string]interface{})
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func emptyJSONValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// CopyRelation returns a deep copy of the relation, obtained by parsing its JSON encoding.
+func CopyRelation(rel oam.Relation) (oam.Relation, error) {
+	if rel == nil {
+		return nil, nil
+	}
+
+	content, err := rel.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return ParseRelation(rel.RelationType(), content)
+}
+
+// CopyProperty returns a deep copy of the property, obtained by parsing its JSON encoding.
+func CopyProperty(prop oam.Property) (oam.Property, error) {
+	if prop == nil {
+		return nil, nil
+	}
+	if u, ok := prop.(*UnknownProperty); ok {
+		return &UnknownProperty{Type: u.Type, Content: append([]byte(nil), u.Content...)}, nil
+	}
+
+	content, err := prop.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return ParseProperty(prop.PropertyType(), content)
+}
+
+// Copy returns a deep copy of the entity, including its asset.
+func (e *Entity) Copy() (*Entity, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	asset, err := CopyAsset(e.Asset)
+	if err != nil {
+		return nil, err
+	}
+
+	c := *e
+	c.Asset = asset
+	return &c, nil
+}
+
+// Copy returns a deep copy of the edge, including its relation and the entities at both ends.
+func (e *Edge) Copy() (*Edge, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	rel, err := CopyRelation(e.Relation)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := e.FromEntity.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := e.ToEntity.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *e
+	c.Relation = rel
+	c.FromEntity = from
+	c.ToEntity = to
+	return &c, nil
+}
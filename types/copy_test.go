@@ -0,0 +1,71 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEdgeCopy(t *testing.T) {
+	edge := &Edge{
+		ID:         "3",
+		Relation:   &relation.SimpleRelation{Name: "node"},
+		FromEntity: &Entity{ID: "1", Asset: &domain.FQDN{Name: "owasp.org"}},
+		ToEntity:   &Entity{ID: "2", Asset: &domain.FQDN{Name: "www.owasp.org"}},
+	}
+
+	c, err := edge.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, edge, c)
+
+	// changes made to the copy are not seen by the original
+	c.FromEntity.Asset.(*domain.FQDN).Name = "example.com"
+	c.Relation.(*relation.SimpleRelation).Name = "contains"
+	assert.Equal(t, "owasp.org", edge.FromEntity.Asset.(*domain.FQDN).Name)
+	assert.Equal(t, "node", edge.Relation.Label())
+}
+
+func TestCopyProperty(t *testing.T) {
+	prop := &property.SimpleProperty{PropertyName: "color", PropertyValue: "blue"}
+
+	c, err := CopyProperty(prop)
+	assert.NoError(t, err)
+	assert.Equal(t, prop, c)
+
+	c.(*property.SimpleProperty).PropertyValue = "red"
+	assert.Equal(t, "blue", prop.PropertyValue)
+
+	unknown := &UnknownProperty{Type: "FutureProperty", Content: []byte(`{"a":1}`)}
+	u, err := CopyProperty(unknown)
+	assert.NoError(t, err)
+	assert.Equal(t, unknown, u)
+
+	u.(*UnknownProperty).Content[0] = '['
+	assert.Equal(t, byte('{'), unknown.Content[0])
+}
+
+func TestMergeAsset(t *testing.T) {
+	stored := &contact.Phone{Raw: "+1 (555) 555-0100", E164: "+15555550100", CountryCode: 1}
+
+	merged, err := MergeAsset(stored, &contact.Phone{E164: "+15555550100", Type: "mobile"})
+	assert.NoError(t, err)
+	// the fields missing from the update keep their stored values
+	assert.Equal(t, &contact.Phone{
+		Raw:         "+1 (555) 555-0100",
+		E164:        "+15555550100",
+		CountryCode: 1,
+		Type:        "mobile",
+	}, merged)
+	assert.Equal(t, "", stored.Type)
+
+	_, err = MergeAsset(stored, &domain.FQDN{Name: "owasp.org"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,318 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// AssetFields returns the names of the JSON fields serialized for the provided asset type.
+func AssetFields(atype oam.AssetType) ([]string, error) {
+	asset, err := ParseAsset(atype, []byte("{}"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(asset)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// ValidateAssetField returns an error if the field is not serialized for the provided asset type.
+func ValidateAssetField(atype oam.AssetType, field string) error {
+	fields, err := AssetFields(atype)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if f == field {
+			return nil
+		}
+	}
+	return fmt.Errorf("the %s asset type does not have a %s field", atype, field)
+}
+
+// assetKeyFields holds the candidate fields that identify an asset of each type, in the order they are tried.
+// The primary key is always first, and is the only field used by the backends to index the content.
+var assetKeyFields = map[oam.AssetType][]string{
+	oam.FQDN:             {"name"},
+	oam.IPAddress:        {"address"},
+	oam.AutonomousSystem: {"number"},
+	oam.Netblock:         {"cidr"},
+	oam.IPNetRecord:      {"handle"},
+	oam.AutnumRecord:     {"handle"},
+	oam.DomainRecord:     {"domain"},
+	oam.Organization:     {"name"},
+	oam.Person:           {"full_name"},
+	oam.Phone:            {"raw", "e164"},
+	oam.EmailAddress:     {"address"},
+	oam.Location:         {"address"},
+	oam.ContactRecord:    {"discovered_at"},
+	oam.TLSCertificate:   {"serial_number"},
+	oam.URL:              {"url"},
+	oam.Service:          {"identifier"},
+	oam.File:             {"url"},
+}
+
+var assetKeyFieldsLock sync.RWMutex
+
+// RegisterAssetKeyFields replaces the candidate fields that identify an asset of the provided type.
+// The first field must be the primary key of the asset type, which cannot be changed, and every field
+// must be serialized for the asset type. The remaining fields are tried in order when an asset cannot
+// be found by the primary key, such as when only some of the fields of the asset are populated.
+func RegisterAssetKeyFields(atype oam.AssetType, fields ...string) error {
+	assetKeyFieldsLock.Lock()
+	defer assetKeyFieldsLock.Unlock()
+
+	current, found := assetKeyFields[atype]
+	if !found {
+		return fmt.Errorf("unknown asset type: %s", atype)
+	}
+	if len(fields) == 0 || fields[0] != current[0] {
+		return fmt.Errorf("the first key field of the %s asset type must be %s", atype, current[0])
+	}
+
+	for _, field := range fields[1:] {
+		if err := ValidateAssetField(atype, field); err != nil {
+			return err
+		}
+	}
+
+	assetKeyFields[atype] = append([]string(nil), fields...)
+	return nil
+}
+
+// keyFields returns the candidate fields that identify an asset of the provided type.
+func keyFields(atype oam.AssetType) ([]string, bool) {
+	assetKeyFieldsLock.RLock()
+	defer assetKeyFieldsLock.RUnlock()
+
+	fields, found := assetKeyFields[atype]
+	return fields, found
+}
+
+// AssetFromKey returns an asset of the provided type with only the field that identifies the asset set to the key.
+// The returned asset can be used to find the matching entity by content.
+func AssetFromKey(atype oam.AssetType, key string) (oam.Asset, error) {
+	fields, found := keyFields(atype)
+	if !found {
+		return nil, fmt.Errorf("unknown asset type: %s", atype)
+	}
+
+	var value interface{} = key
+	if atype == oam.AutonomousSystem {
+		num, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key %s: %v", atype, key, err)
+		}
+		value = num
+	}
+
+	content, err := json.Marshal(map[string]interface{}{fields[0]: value})
+	if err != nil {
+		return nil, err
+	}
+	return ParseAsset(atype, content)
+}
+
+// AssetKey returns the name and the serialized value of the field that identifies the asset.
+func AssetKey(asset oam.Asset) (string, interface{}, error) {
+	if asset == nil {
+		return "", nil, errors.New("the asset is nil")
+	}
+
+	fields, found := keyFields(asset.AssetType())
+	if !found {
+		return "", nil, fmt.Errorf("unknown asset type: %s", asset.AssetType())
+	}
+
+	value, err := AssetFieldValue(asset, fields[0])
+	if err != nil {
+		return "", nil, err
+	}
+	return fields[0], value, nil
+}
+
+// AssetKeyField is the name and the serialized value of a field that can identify an asset.
+type AssetKeyField struct {
+	Field string
+	Value interface{}
+}
+
+// AssetKeyCandidates returns the fields populated in the asset that can identify it, in the order they should
+// be tried, starting with the primary key. When none of the fields are populated, only the primary key is returned.
+func AssetKeyCandidates(asset oam.Asset) ([]AssetKeyField, error) {
+	if asset == nil {
+		return nil, errors.New("the asset is nil")
+	}
+
+	fields, found := keyFields(asset.AssetType())
+	if !found {
+		return nil, fmt.Errorf("unknown asset type: %s", asset.AssetType())
+	}
+
+	var primary AssetKeyField
+	var candidates []AssetKeyField
+	for i, field := range fields {
+		value, err := AssetFieldValue(asset, field)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			primary = AssetKeyField{Field: field, Value: value}
+		}
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			continue
+		}
+		candidates = append(candidates, AssetKeyField{Field: field, Value: value})
+	}
+
+	if len(candidates) == 0 {
+		return []AssetKeyField{primary}, nil
+	}
+	return candidates, nil
+}
+
+// AssetFieldValue returns the serialized value of the named field in the asset, or nil if the field is missing.
+func AssetFieldValue(asset oam.Asset, field string) (interface{}, error) {
+	content, err := asset.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return m[field], nil
+}
+
+// AssetFieldMatches reports whether the text of the named field in the serialized asset matches the regular
+// expression. Fields that are not strings are matched against their JSON encoding, and missing fields never match.
+func AssetFieldMatches(asset oam.Asset, field string, re *regexp.Regexp) bool {
+	if asset == nil || re == nil {
+		return false
+	}
+
+	content, err := asset.JSON()
+	if err != nil {
+		return false
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(content, &m); err != nil {
+		return false
+	}
+
+	raw, found := m[field]
+	if !found || string(raw) == "null" {
+		return false
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		text = string(raw)
+	}
+	return re.MatchString(text)
+}
+
+// ValidateNumericAssetField returns an error if the field is not serialized for the provided asset type
+// or does not hold a number.
+func ValidateNumericAssetField(atype oam.AssetType, field string) error {
+	asset, err := ParseAsset(atype, []byte("{}"))
+	if err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(asset)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != field {
+			continue
+		}
+
+		switch t.Field(i).Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return nil
+		}
+		return fmt.Errorf("the %s field of the %s asset type is not numeric", field, atype)
+	}
+	return fmt.Errorf("the %s asset type does not have a %s field", atype, field)
+}
+
+// NumericBound converts a bound of a range over a numeric field to a float64.
+// The returned bool is false for a nil bound, which leaves that side of the range open.
+func NumericBound(v any) (float64, bool, error) {
+	if v == nil {
+		return 0, false, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true, nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true, nil
+	}
+	return 0, false, fmt.Errorf("the range bound %v is not a number", v)
+}
+
+// AssetFieldInRange reports whether the named numeric field in the serialized asset is within the inclusive range.
+// A nil bound leaves that side of the range open, and missing or non-numeric fields never match.
+func AssetFieldInRange(asset oam.Asset, field string, min, max any) bool {
+	if asset == nil {
+		return false
+	}
+
+	content, err := asset.JSON()
+	if err != nil {
+		return false
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return false
+	}
+
+	num, ok := m[field].(float64)
+	if !ok {
+		return false
+	}
+
+	if lo, set, err := NumericBound(min); err != nil || (set && num < lo) {
+		return false
+	}
+	if hi, set, err := NumericBound(max); err != nil || (set && num > hi) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+type entityJSON struct {
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	LastSeen  time.Time       `json:"last_seen"`
+	AssetType oam.AssetType   `json:"asset_type,omitempty"`
+	Asset     json.RawMessage `json:"asset,omitempty"`
+}
+
+type entityTagJSON struct {
+	ID           string           `json:"id"`
+	CreatedAt    time.Time        `json:"created_at"`
+	LastSeen     time.Time        `json:"last_seen"`
+	PropertyType oam.PropertyType `json:"property_type,omitempty"`
+	Property     json.RawMessage  `json:"property,omitempty"`
+	Entity       *Entity          `json:"entity,omitempty"`
+}
+
+type edgeJSON struct {
+	ID           string           `json:"id"`
+	CreatedAt    time.Time        `json:"created_at"`
+	LastSeen     time.Time        `json:"last_seen"`
+	RelationType oam.RelationType `json:"relation_type,omitempty"`
+	Relation     json.RawMessage  `json:"relation,omitempty"`
+	FromEntity   *Entity          `json:"from_entity,omitempty"`
+	ToEntity     *Entity          `json:"to_entity,omitempty"`
+}
+
+type edgeTagJSON struct {
+	ID           string           `json:"id"`
+	CreatedAt    time.Time        `json:"created_at"`
+	LastSeen     time.Time        `json:"last_seen"`
+	PropertyType oam.PropertyType `json:"property_type,omitempty"`
+	Property     json.RawMessage  `json:"property,omitempty"`
+	Edge         *Edge            `json:"edge,omitempty"`
+}
+
+// MarshalJSON encodes the entity, including the asset type, so the concrete asset can be reconstructed.
+func (e Entity) MarshalJSON() ([]byte, error) {
+	v := entityJSON{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		LastSeen:  e.LastSeen,
+	}
+
+	if e.Asset != nil {
+		content, err := e.Asset.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		v.AssetType = e.Asset.AssetType()
+		v.Asset = content
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the entity and reconstructs the concrete asset using the asset type.
+func (e *Entity) UnmarshalJSON(data []byte) error {
+	var v entityJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var asset oam.Asset
+	if len(v.Asset) > 0 {
+		a, err := ParseAsset(v.AssetType, v.Asset)
+		if err != nil {
+			return err
+		}
+		asset = a
+	}
+
+	*e = Entity{
+		ID:        v.ID,
+		CreatedAt: v.CreatedAt,
+		LastSeen:  v.LastSeen,
+		Asset:     asset,
+	}
+	return nil
+}
+
+// MarshalJSON encodes the entity tag, including the property type, so the concrete property can be reconstructed.
+func (t EntityTag) MarshalJSON() ([]byte, error) {
+	v := entityTagJSON{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		LastSeen:  t.LastSeen,
+		Entity:    t.Entity,
+	}
+
+	if t.Property != nil {
+		content, err := t.Property.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		v.PropertyType = t.Property.PropertyType()
+		v.Property = content
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the entity tag and reconstructs the concrete property using the property type.
+func (t *EntityTag) UnmarshalJSON(data []byte) error {
+	var v entityTagJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var prop oam.Property
+	if len(v.Property) > 0 {
+		p, err := ParseProperty(v.PropertyType, v.Property)
+		if err != nil {
+			return err
+		}
+		prop = p
+	}
+
+	*t = EntityTag{
+		ID:        v.ID,
+		CreatedAt: v.CreatedAt,
+		LastSeen:  v.LastSeen,
+		Property:  prop,
+		Entity:    v.Entity,
+	}
+	return nil
+}
+
+// MarshalJSON encodes the edge, including the relation type, so the concrete relation can be reconstructed.
+func (e Edge) MarshalJSON() ([]byte, error) {
+	v := edgeJSON{
+		ID:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		LastSeen:   e.LastSeen,
+		FromEntity: e.FromEntity,
+		ToEntity:   e.ToEntity,
+	}
+
+	if e.Relation != nil {
+		content, err := e.Relation.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		v.RelationType = e.Relation.RelationType()
+		v.Relation = content
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the edge and reconstructs the concrete relation using the relation type.
+func (e *Edge) UnmarshalJSON(data []byte) error {
+	var v edgeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var rel oam.Relation
+	if len(v.Relation) > 0 {
+		r, err := ParseRelation(v.RelationType, v.Relation)
+		if err != nil {
+			return err
+		}
+		rel = r
+	}
+
+	*e = Edge{
+		ID:         v.ID,
+		CreatedAt:  v.CreatedAt,
+		LastSeen:   v.LastSeen,
+		Relation:   rel,
+		FromEntity: v.FromEntity,
+		ToEntity:   v.ToEntity,
+	}
+	return nil
+}
+
+// MarshalJSON encodes the edge tag, including the property type, so the concrete property can be reconstructed.
+func (t EdgeTag) MarshalJSON() ([]byte, error) {
+	v := edgeTagJSON{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		LastSeen:  t.LastSeen,
+		Edge:      t.Edge,
+	}
+
+	if t.Property != nil {
+		content, err := t.Property.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		v.PropertyType = t.Property.PropertyType()
+		v.Property = content
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the edge tag and reconstructs the concrete property using the property type.
+func (t *EdgeTag) UnmarshalJSON(data []byte) error {
+	var v edgeTagJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var prop oam.Property
+	if len(v.Property) > 0 {
+		p, err := ParseProperty(v.PropertyType, v.Property)
+		if err != nil {
+			return err
+		}
+		prop = p
+	}
+
+	*t = EdgeTag{
+		ID:        v.ID,
+		CreatedAt: v.CreatedAt,
+		LastSeen:  v.LastSeen,
+		Property:  prop,
+		Edge:      v.Edge,
+	}
+	return nil
+}
+
+// CanonicalJSON returns the content with the object keys sorted and the fields holding null, an empty string,
+// an empty array or an empty object left out, so equal content is always serialized to identical bytes.
+// Numbers are copied as written. Parsing the canonical content produces the same value as the original,
+// since the fields left out are decoded as their zero values.
+func CanonicalJSON(content []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	// encoding/json writes the keys of maps in sorted order
+	return json.Marshal(omitEmpty(v))
+}
+
+// omitEmpty removes the empty fields from the objects within the decoded JSON value.
+func omitEmpty(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, f := range t {
+			if f = omitEmpty(f); isEmptyJSON(f) {
+				delete(t, k)
+				continue
+			}
+			t[k] = f
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = omitEmpty(e)
+		}
+	}
+	return v
+}
+
+// isEmptyJSON reports whether the decoded JSON value is null, an empty string, an empty array or an empty object.
+func isEmptyJSON(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case []interface{}:
+		return len(t) == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityJSON(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	entity := &Entity{
+		ID:        "1",
+		CreatedAt: now,
+		LastSeen:  now,
+		Asset:     &network.IPAddress{Address: netip.MustParseAddr("192.168.1.1"), Type: "IPv4"},
+	}
+
+	data, err := json.Marshal(entity)
+	assert.NoError(t, err)
+
+	var decoded Entity
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, entity.ID, decoded.ID)
+	assert.True(t, entity.CreatedAt.Equal(decoded.CreatedAt))
+	assert.True(t, entity.LastSeen.Equal(decoded.LastSeen))
+	assert.Equal(t, entity.Asset, decoded.Asset)
+
+	var bad Entity
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"2","asset_type":"Unknown","asset":{}}`), &bad))
+}
+
+func TestEntityTagJSON(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tag := &EntityTag{
+		ID:        "2",
+		CreatedAt: now,
+		LastSeen:  now,
+		Property:  &property.SourceProperty{Source: "DNS", Confidence: 100},
+		Entity:    &Entity{ID: "1", Asset: &domain.FQDN{Name: "owasp.org"}},
+	}
+
+	data, err := json.Marshal(tag)
+	assert.NoError(t, err)
+
+	var decoded EntityTag
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, tag.ID, decoded.ID)
+	assert.True(t, tag.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, tag.Property, decoded.Property)
+	assert.Equal(t, tag.Entity.ID, decoded.Entity.ID)
+	assert.Equal(t, tag.Entity.Asset, decoded.Entity.Asset)
+}
+
+func TestEdgeJSON(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	edge := &Edge{
+		ID:        "3",
+		CreatedAt: now,
+		LastSeen:  now,
+		Relation: &relation.SRVDNSRelation{
+			Name:     "dns_record",
+			Header:   relation.RRHeader{RRType: 33, Class: 1, TTL: 3600},
+			Priority: 10,
+			Weight:   5,
+			Port:     5060,
+		},
+		FromEntity: &Entity{ID: "1", Asset: &domain.FQDN{Name: "_sip._tcp.owasp.org"}},
+		ToEntity:   &Entity{ID: "2"},
+	}
+
+	data, err := json.Marshal(edge)
+	assert.NoError(t, err)
+
+	var decoded Edge
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, edge.ID, decoded.ID)
+	assert.True(t, edge.LastSeen.Equal(decoded.LastSeen))
+	assert.Equal(t, edge.Relation, decoded.Relation)
+	assert.Equal(t, edge.FromEntity.Asset, decoded.FromEntity.Asset)
+	assert.Equal(t, edge.ToEntity.ID, decoded.ToEntity.ID)
+	assert.Nil(t, decoded.ToEntity.Asset)
+}
+
+func TestEdgeTagJSON(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tag := &EdgeTag{
+		ID:        "4",
+		CreatedAt: now,
+		LastSeen:  now,
+		Property:  &property.SimpleProperty{PropertyName: "test", PropertyValue: "foo"},
+		Edge: &Edge{
+			ID:       "3",
+			Relation: &relation.SimpleRelation{Name: "node"},
+		},
+	}
+
+	data, err := json.Marshal(tag)
+	assert.NoError(t, err)
+
+	var decoded EdgeTag
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, tag.ID, decoded.ID)
+	assert.True(t, tag.LastSeen.Equal(decoded.LastSeen))
+	assert.Equal(t, tag.Property, decoded.Property)
+	assert.Equal(t, tag.Edge.ID, decoded.Edge.ID)
+	assert.Equal(t, tag.Edge.Relation, decoded.Edge.Relation)
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	first, err := ParseAsset(oam.Phone, []byte(`{"raw":"+1 555 555 0100","e164":"+15555550100","type":""}`))
+	assert.NoError(t, err)
+	second, err := ParseAsset(oam.Phone, []byte(`{"e164":"+15555550100","ext":"","raw":"+1 555 555 0100"}`))
+	assert.NoError(t, err)
+
+	content1, err := first.JSON()
+	assert.NoError(t, err)
+	content2, err := second.JSON()
+	assert.NoError(t, err)
+
+	canonical1, err := CanonicalJSON(content1)
+	assert.NoError(t, err)
+	canonical2, err := CanonicalJSON(content2)
+	assert.NoError(t, err)
+	assert.Equal(t, canonical1, canonical2)
+	assert.Equal(t, `{"e164":"+15555550100","raw":"+1 555 555 0100"}`, string(canonical1))
+
+	// both the original and the canonical content parse to the same asset
+	parsed, err := ParseAsset(oam.Phone, canonical1)
+	assert.NoError(t, err)
+	assert.Equal(t, first, parsed)
+
+	as := &network.AutonomousSystem{Number: 26808}
+	content, err := as.JSON()
+	assert.NoError(t, err)
+	canonical, err := CanonicalJSON(content)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"number":26808}`, string(canonical))
+
+	_, err = CanonicalJSON([]byte(`{"raw":`))
+	assert.Error(t, err)
+}
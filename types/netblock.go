@@ -0,0 +1,145 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/network"
+	oamreg "github.com/owasp-amass/open-asset-model/registration"
+)
+
+type netblockInfo struct {
+	cidr         netip.Prefix
+	handle       string
+	parentHandle string
+}
+
+func netblockFromAsset(asset oam.Asset) (*netblockInfo, error) {
+	switch v := asset.(type) {
+	case *network.Netblock:
+		return &netblockInfo{cidr: v.CIDR.Masked()}, nil
+	case network.Netblock:
+		return &netblockInfo{cidr: v.CIDR.Masked()}, nil
+	case *oamreg.IPNetRecord:
+		return &netblockInfo{cidr: v.CIDR.Masked(), handle: v.Handle, parentHandle: v.ParentHandle}, nil
+	case oamreg.IPNetRecord:
+		return &netblockInfo{cidr: v.CIDR.Masked(), handle: v.Handle, parentHandle: v.ParentHandle}, nil
+	}
+
+	if asset == nil {
+		return nil, errors.New("the asset is nil")
+	}
+	return nil, fmt.Errorf("the %s asset type is not a %s or %s", asset.AssetType(), oam.Netblock, oam.IPNetRecord)
+}
+
+// ValidateNetblock returns an error if the asset is not a Netblock or an IPNetRecord.
+func ValidateNetblock(asset oam.Asset) error {
+	_, err := netblockFromAsset(asset)
+	return err
+}
+
+// NetblockFields returns the masked CIDR, the handle, and the parent handle of the Netblock or IPNetRecord asset.
+// The handles are empty for Netblocks.
+func NetblockFields(asset oam.Asset) (netip.Prefix, string, string, error) {
+	info, err := netblockFromAsset(asset)
+	if err != nil {
+		return netip.Prefix{}, "", "", err
+	}
+	return info.cidr, info.handle, info.parentHandle, nil
+}
+
+// strictlyContains returns true if the prefix p contains the prefix o and is not equal to it.
+func strictlyContains(p, o netip.Prefix) bool {
+	return p.IsValid() && o.IsValid() && p.Bits() < o.Bits() &&
+		p.Addr().Is4() == o.Addr().Is4() && p.Contains(o.Addr())
+}
+
+// ChildNetblocks returns the candidates that are the immediate children of the parent in the allocation tree.
+// The children of an IPNetRecord are the records with a parent handle equal to the handle of the parent.
+// The children of a Netblock are the netblocks within the CIDR of the parent that are not within another candidate
+// that is also within the parent.
+func ChildNetblocks(parent *Entity, candidates []*Entity) ([]*Entity, error) {
+	pinfo, err := netblockFromAsset(parent.Asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var within []*Entity
+	var infos []*netblockInfo
+	for _, c := range candidates {
+		if c.ID == parent.ID || c.Asset.AssetType() != parent.Asset.AssetType() {
+			continue
+		}
+
+		info, err := netblockFromAsset(c.Asset)
+		if err != nil {
+			continue
+		}
+
+		if parent.Asset.AssetType() == oam.IPNetRecord {
+			if pinfo.handle != "" && info.parentHandle == pinfo.handle {
+				within = append(within, c)
+			}
+		} else if strictlyContains(pinfo.cidr, info.cidr) {
+			within = append(within, c)
+			infos = append(infos, info)
+		}
+	}
+	if parent.Asset.AssetType() == oam.IPNetRecord {
+		return within, nil
+	}
+
+	var children []*Entity
+	for i, c := range within {
+		immediate := true
+		for j := range within {
+			if i != j && strictlyContains(infos[j].cidr, infos[i].cidr) {
+				immediate = false
+				break
+			}
+		}
+		if immediate {
+			children = append(children, c)
+		}
+	}
+	return children, nil
+}
+
+// ParentNetblock returns the candidate that is the parent of the child in the allocation tree, or nil if the
+// parent is not among the candidates.
+// The parent of an IPNetRecord is the record with a handle equal to the parent handle of the child.
+// The parent of a Netblock is the smallest netblock that contains the CIDR of the child.
+func ParentNetblock(child *Entity, candidates []*Entity) (*Entity, error) {
+	cinfo, err := netblockFromAsset(child.Asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent *Entity
+	var pinfo *netblockInfo
+	for _, c := range candidates {
+		if c.ID == child.ID || c.Asset.AssetType() != child.Asset.AssetType() {
+			continue
+		}
+
+		info, err := netblockFromAsset(c.Asset)
+		if err != nil {
+			continue
+		}
+
+		if child.Asset.AssetType() == oam.IPNetRecord {
+			if cinfo.parentHandle != "" && info.handle == cinfo.parentHandle {
+				return c, nil
+			}
+		} else if strictlyContains(info.cidr, cinfo.cidr) && (pinfo == nil || info.cidr.Bits() > pinfo.cidr.Bits()) {
+			parent, pinfo = c, info
+		}
+	}
+	return parent, nil
+}
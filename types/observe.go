@@ -0,0 +1,47 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// Observation is a finding reported by a data source, such as an FQDN with an A record pointing to an IP address.
+// The Asset is always stored, and is linked to the Target by the Relation when both are set.
+// The Properties are stored as tags on the entity of the Asset, and the EdgeProperties as tags on the edge.
+type Observation struct {
+	Asset          oam.Asset
+	Properties     []oam.Property
+	Relation       oam.Relation
+	Target         oam.Asset
+	EdgeProperties []oam.Property
+}
+
+// Validate checks that the observation has an asset, and that the relation and the target are set together.
+func (o *Observation) Validate() error {
+	if o.Asset == nil {
+		return errors.New("the observation has no asset")
+	}
+	if (o.Relation == nil) != (o.Target == nil) {
+		return errors.New("the observation must have both a relation and a target, or neither")
+	}
+	if o.Relation == nil && len(o.EdgeProperties) > 0 {
+		return errors.New("the observation has edge properties without a relation")
+	}
+	return nil
+}
+
+// ObserveResult counts the records stored by a batch of observations. An entity or edge that already existed
+// is counted as updated, since its last seen time is refreshed. Tags counts the entity and edge tags that were
+// created or refreshed.
+type ObserveResult struct {
+	EntitiesCreated int
+	EntitiesUpdated int
+	EdgesCreated    int
+	EdgesUpdated    int
+	Tags            int
+}
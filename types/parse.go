@@ -0,0 +1,224 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"encoding/json"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	oamtls "github.com/owasp-amass/open-asset-model/certificate"
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/domain"
+	oamfile "github.com/owasp-amass/open-asset-model/file"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/people"
+	"github.com/owasp-amass/open-asset-model/property"
+	oamreg "github.com/owasp-amass/open-asset-model/registration"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/owasp-amass/open-asset-model/service"
+	"github.com/owasp-amass/open-asset-model/url"
+)
+
+// ParseAsset parses the JSON content into the Open Asset Model (OAM) asset of the provided type.
+// It returns the parsed asset and an error, if any.
+func ParseAsset(atype oam.AssetType, content []byte) (oam.Asset, error) {
+	var err error
+	var asset oam.Asset
+
+	switch atype {
+	case oam.FQDN:
+		var fqdn domain.FQDN
+
+		err = json.Unmarshal(content, &fqdn)
+		asset = &fqdn
+	case oam.IPAddress:
+		var ip network.IPAddress
+
+		err = json.Unmarshal(content, &ip)
+		asset = &ip
+	case oam.AutonomousSystem:
+		var as network.AutonomousSystem
+
+		err = json.Unmarshal(content, &as)
+		asset = &as
+	case oam.AutnumRecord:
+		var ar oamreg.AutnumRecord
+
+		err = json.Unmarshal(content, &ar)
+		asset = &ar
+	case oam.Netblock:
+		var netblock network.Netblock
+
+		err = json.Unmarshal(content, &netblock)
+		asset = &netblock
+	case oam.IPNetRecord:
+		var ipnetrec oamreg.IPNetRecord
+
+		err = json.Unmarshal(content, &ipnetrec)
+		asset = &ipnetrec
+	case oam.DomainRecord:
+		var dr oamreg.DomainRecord
+
+		err = json.Unmarshal(content, &dr)
+		asset = &dr
+	case oam.Organization:
+		var organization org.Organization
+
+		err = json.Unmarshal(content, &organization)
+		asset = &organization
+	case oam.Person:
+		var person people.Person
+
+		err = json.Unmarshal(content, &person)
+		asset = &person
+	case oam.Phone:
+		var phone contact.Phone
+
+		err = json.Unmarshal(content, &phone)
+		asset = &phone
+	case oam.EmailAddress:
+		var emailAddress contact.EmailAddress
+
+		err = json.Unmarshal(content, &emailAddress)
+		asset = &emailAddress
+	case oam.Location:
+		var location contact.Location
+
+		err = json.Unmarshal(content, &location)
+		asset = &location
+	case oam.ContactRecord:
+		var cr contact.ContactRecord
+
+		err = json.Unmarshal(content, &cr)
+		asset = &cr
+	case oam.TLSCertificate:
+		var tlsCertificate oamtls.TLSCertificate
+
+		err = json.Unmarshal(content, &tlsCertificate)
+		asset = &tlsCertificate
+	case oam.URL:
+		var url url.URL
+
+		err = json.Unmarshal(content, &url)
+		asset = &url
+	case oam.Service:
+		var serv service.Service
+
+		err = json.Unmarshal(content, &serv)
+		asset = &serv
+	case oam.File:
+		var f oamfile.File
+
+		err = json.Unmarshal(content, &f)
+		asset = &f
+	default:
+		return nil, &UnknownTypeError{Kind: "asset", Type: string(atype)}
+	}
+
+	return asset, err
+}
+
+var relationTypes = []oam.RelationType{
+	oam.BasicDNSRelation,
+	oam.PortRelation,
+	oam.PrefDNSRelation,
+	oam.SimpleRelation,
+	oam.SRVDNSRelation,
+}
+
+var propertyTypes = []oam.PropertyType{
+	oam.SimpleProperty,
+	oam.SourceProperty,
+	oam.VulnProperty,
+}
+
+// SupportedRelationTypes returns the relation types that can be parsed from the stored content.
+func SupportedRelationTypes() []string {
+	var results []string
+
+	for _, rtype := range relationTypes {
+		results = append(results, string(rtype))
+	}
+	return results
+}
+
+// SupportedPropertyTypes returns the property types that can be parsed from the stored content.
+func SupportedPropertyTypes() []string {
+	var results []string
+
+	for _, ptype := range propertyTypes {
+		results = append(results, string(ptype))
+	}
+	return results
+}
+
+// ParseRelation parses the JSON content into the Open Asset Model (OAM) relation of the provided type.
+// It returns the parsed relation and an error, if any.
+func ParseRelation(rtype oam.RelationType, content []byte) (oam.Relation, error) {
+	var err error
+	var rel oam.Relation
+
+	switch rtype {
+	case oam.BasicDNSRelation:
+		var bdr relation.BasicDNSRelation
+
+		err = json.Unmarshal(content, &bdr)
+		rel = &bdr
+	case oam.PortRelation:
+		var pr relation.PortRelation
+
+		err = json.Unmarshal(content, &pr)
+		rel = &pr
+	case oam.PrefDNSRelation:
+		var pdr relation.PrefDNSRelation
+
+		err = json.Unmarshal(content, &pdr)
+		rel = &pdr
+	case oam.SimpleRelation:
+		var sr relation.SimpleRelation
+
+		err = json.Unmarshal(content, &sr)
+		rel = &sr
+	case oam.SRVDNSRelation:
+		var sdr relation.SRVDNSRelation
+
+		err = json.Unmarshal(content, &sdr)
+		rel = &sdr
+	default:
+		return nil, &UnknownTypeError{Kind: "relation", Type: string(rtype)}
+	}
+
+	return rel, err
+}
+
+// ParseProperty parses the JSON content into the Open Asset Model (OAM) property of the provided type.
+// It returns the parsed property and an error, if any.
+func ParseProperty(ptype oam.PropertyType, content []byte) (oam.Property, error) {
+	var err error
+	var prop oam.Property
+
+	switch ptype {
+	case oam.SimpleProperty:
+		var sp property.SimpleProperty
+
+		err = json.Unmarshal(content, &sp)
+		prop = &sp
+	case oam.SourceProperty:
+		var sp property.SourceProperty
+
+		err = json.Unmarshal(content, &sp)
+		prop = &sp
+	case oam.VulnProperty:
+		var vp property.VulnProperty
+
+		err = json.Unmarshal(content, &vp)
+		prop = &vp
+	default:
+		return nil, &UnknownTypeError{Kind: "property", Type: string(ptype)}
+	}
+
+	return prop, err
+}
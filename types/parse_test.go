@@ -0,0 +1,39 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"testing"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportedRelationTypes(t *testing.T) {
+	supported := SupportedRelationTypes()
+	for _, rtype := range []oam.RelationType{
+		oam.BasicDNSRelation, oam.PortRelation, oam.PrefDNSRelation, oam.SimpleRelation, oam.SRVDNSRelation,
+	} {
+		assert.Contains(t, supported, string(rtype))
+	}
+
+	// every reported type must be parsed from stored content
+	for _, rtype := range supported {
+		_, err := ParseRelation(oam.RelationType(rtype), []byte("{}"))
+		assert.NoError(t, err)
+	}
+}
+
+func TestSupportedPropertyTypes(t *testing.T) {
+	supported := SupportedPropertyTypes()
+	for _, ptype := range []oam.PropertyType{oam.SimpleProperty, oam.SourceProperty, oam.VulnProperty} {
+		assert.Contains(t, supported, string(ptype))
+	}
+
+	for _, ptype := range supported {
+		_, err := ParseProperty(oam.PropertyType(ptype), []byte("{}"))
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// TagOperator compares the value of a tag property with the value of a TagPredicate.
+type TagOperator string
+
+// The operators of a TagPredicate. TagExists matches any value of the tag property.
+const (
+	TagExists       TagOperator = ""
+	TagEqual        TagOperator = "="
+	TagNotEqual     TagOperator = "!="
+	TagLess         TagOperator = "<"
+	TagLessEqual    TagOperator = "<="
+	TagGreater      TagOperator = ">"
+	TagGreaterEqual TagOperator = ">="
+)
+
+// AndOr selects how the predicates of a tag query are combined.
+type AndOr string
+
+const (
+	// And matches the entities that have a tag matching each of the predicates.
+	And AndOr = "and"
+	// Or matches the entities that have a tag matching at least one of the predicates.
+	Or AndOr = "or"
+)
+
+// TagPredicate matches the tags with a property of the name, as returned by the Property Name method, and a value,
+// as returned by the Property Value method, that compares with the Value using the operator.
+// The equality operators compare the values as strings, while the ordering operators compare them as numbers,
+// such as the confidence of a SourceProperty, and never match values that are not numbers.
+type TagPredicate struct {
+	Name  string
+	Op    TagOperator
+	Value string
+}
+
+// Ordered reports whether the operator of the predicate compares the values as numbers.
+func (p TagPredicate) Ordered() bool {
+	switch p.Op {
+	case TagLess, TagLessEqual, TagGreater, TagGreaterEqual:
+		return true
+	}
+	return false
+}
+
+// Validate checks that the predicate has a name and a known operator, and that the value
+// compared by an ordering operator is a number.
+func (p TagPredicate) Validate() error {
+	if p.Name == "" {
+		return errors.New("the tag predicate must have a name")
+	}
+
+	switch p.Op {
+	case TagExists, TagEqual, TagNotEqual:
+		return nil
+	case TagLess, TagLessEqual, TagGreater, TagGreaterEqual:
+		if _, err := strconv.ParseFloat(p.Value, 64); err != nil {
+			return fmt.Errorf("the tag predicate value %s is not a number", p.Value)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown tag predicate operator: %s", p.Op)
+}
+
+// Matches reports whether the property satisfies the predicate.
+func (p TagPredicate) Matches(prop oam.Property) bool {
+	if prop == nil || prop.Name() != p.Name {
+		return false
+	}
+
+	value := prop.Value()
+	switch p.Op {
+	case TagExists:
+		return true
+	case TagEqual:
+		return value == p.Value
+	case TagNotEqual:
+		return value != p.Value
+	}
+
+	have, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false
+	}
+	want, err := strconv.ParseFloat(p.Value, 64)
+	if err != nil {
+		return false
+	}
+
+	switch p.Op {
+	case TagLess:
+		return have < want
+	case TagLessEqual:
+		return have <= want
+	case TagGreater:
+		return have > want
+	case TagGreaterEqual:
+		return have >= want
+	}
+	return false
+}
+
+// ValidateTagPredicates checks that at least one predicate is provided, that each predicate is valid,
+// and that the predicates are combined with a known AndOr.
+func ValidateTagPredicates(predicates []TagPredicate, combine AndOr) error {
+	if len(predicates) == 0 {
+		return errors.New("at least one tag predicate must be provided")
+	}
+	if combine != And && combine != Or {
+		return fmt.Errorf("unknown combination of tag predicates: %s", combine)
+	}
+
+	for _, p := range predicates {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
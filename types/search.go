@@ -0,0 +1,24 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MinSearchQueryLength is the minimum number of characters in a query searched for in the content of entities.
+// Shorter queries match most of the stored content and defeat the purpose of the search.
+const MinSearchQueryLength int = 3
+
+// ValidateSearchQuery checks that the query searched for in the content of entities, ignoring surrounding
+// whitespace, has at least MinSearchQueryLength characters.
+func ValidateSearchQuery(query string) error {
+	if utf8.RuneCountInString(strings.TrimSpace(query)) < MinSearchQueryLength {
+		return fmt.Errorf("the search query must have at least %d characters", MinSearchQueryLength)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import "database/sql"
+
+// RepositoryStats holds the metrics reported by a repository. The fields that do not apply
+// to a repository are left at their zero values.
+type RepositoryStats struct {
+	// Pool holds the connection pool statistics of a SQL database, or nil for the other backends.
+	Pool *sql.DBStats
+	// CacheHits is the number of reads of a cache served without querying the database.
+	CacheHits int64
+	// CacheMisses is the number of reads of a cache that queried the database.
+	CacheMisses int64
+	// QueuedWrites is the number of database writes waiting in the queue of a cache.
+	QueuedWrites int
+	// CachedEntities is the number of entities held by a cache.
+	CachedEntities int64
+	// LastError is the last error produced by a database write from the queue of a cache.
+	LastError error
+}
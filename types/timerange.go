@@ -0,0 +1,23 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ValidateTimeRange checks the bounds of a search for the records last seen between start and end.
+// The start may be zero, which leaves the range without a lower bound, but the end is required
+// and must not be before the start.
+func ValidateTimeRange(start, end time.Time) error {
+	if end.IsZero() {
+		return errors.New("the end of the time range is required")
+	}
+	if end.Before(start) {
+		return errors.New("the end of the time range is before the start")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Direction selects the edges followed from each entity when traversing the graph.
+type Direction string
+
+const (
+	// Outgoing follows the edges from an entity to the entities it points to.
+	Outgoing Direction = "outgoing"
+	// Incoming follows the edges that point to an entity back to their source entities.
+	Incoming Direction = "incoming"
+	// Both follows the edges in both directions.
+	Both Direction = "both"
+)
+
+// ValidateTraversal checks that the depth of a traversal is at least one hop and the direction is known.
+func ValidateTraversal(depth int, direction Direction) error {
+	if depth < 1 {
+		return errors.New("the traversal depth must be at least 1")
+	}
+
+	switch direction {
+	case Outgoing, Incoming, Both:
+		return nil
+	}
+	return fmt.Errorf("unknown traversal direction: %s", direction)
+}
@@ -5,11 +5,48 @@
 package types
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	oam "github.com/owasp-amass/open-asset-model"
 )
 
+// ErrEntityNotFound is returned when a search for a single entity does not find a match.
+var ErrEntityNotFound = errors.New("entity not found")
+
+// ErrEdgeNotFound is returned when a search for a single edge does not find a match.
+var ErrEdgeNotFound = errors.New("edge not found")
+
+// ErrTagNotFound is returned when a search for a single entity tag or edge tag does not find a match.
+var ErrTagNotFound = errors.New("tag not found")
+
+// ErrNoResults is returned when a search for a list of entities, edges or tags does not find any matches.
+var ErrNoResults = errors.New("no results")
+
+// ErrEndpointNotFound is returned when an entity at either end of a new edge does not exist.
+var ErrEndpointNotFound = errors.New("edge endpoint not found")
+
+// EndpointNotFound returns an error wrapping ErrEndpointNotFound that identifies the side of the edge,
+// from or to, where the entity with the provided ID does not exist.
+func EndpointNotFound(side, id string) error {
+	return fmt.Errorf("%w: the %s entity %q does not exist", ErrEndpointNotFound, side, id)
+}
+
+// NoResults returns an error with the provided message that wraps ErrNoResults, so callers can detect an
+// empty search with errors.Is without matching the message.
+func NoResults(msg string) error {
+	return &noResultsError{msg: msg}
+}
+
+type noResultsError struct {
+	msg string
+}
+
+func (e *noResultsError) Error() string { return e.msg }
+
+func (e *noResultsError) Unwrap() error { return ErrNoResults }
+
 // Entity represents an entity in the asset database.
 type Entity struct {
 	ID        string
@@ -37,6 +74,12 @@ type Edge struct {
 	ToEntity   *Entity
 }
 
+// OutgoingFrom reports whether the edge leaves the entity with the provided ID.
+// It indicates the direction of the edges returned for an entity by AdjacentEdges.
+func (e *Edge) OutgoingFrom(id string) bool {
+	return e.FromEntity != nil && e.FromEntity.ID == id
+}
+
 // EdgeTag represents additional metadata added to an edge in the asset database.
 type EdgeTag struct {
 	ID        string
@@ -45,3 +88,31 @@ type EdgeTag struct {
 	Property  oam.Property
 	Edge      *Edge
 }
+
+// ExistingProperties returns the properties that are already present in the tags, matched by the property type,
+// name and value. The returned properties are in the same order as the props parameter.
+func ExistingProperties(tags []*EntityTag, props []oam.Property) []oam.Property {
+	type key struct {
+		ptype oam.PropertyType
+		name  string
+		value string
+	}
+
+	present := make(map[key]struct{}, len(tags))
+	for _, tag := range tags {
+		if tag.Property != nil {
+			present[key{tag.Property.PropertyType(), tag.Property.Name(), tag.Property.Value()}] = struct{}{}
+		}
+	}
+
+	var results []oam.Property
+	for _, prop := range props {
+		if prop == nil {
+			continue
+		}
+		if _, found := present[key{prop.PropertyType(), prop.Name(), prop.Value()}]; found {
+			results = append(results, prop)
+		}
+	}
+	return results
+}
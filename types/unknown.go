@@ -0,0 +1,72 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"fmt"
+
+	oam "github.com/owasp-amass/open-asset-model"
+)
+
+// UnknownTypeError is returned when content is parsed with a type that is not recognized,
+// such as content stored by a newer version of the Open Asset Model.
+type UnknownTypeError struct {
+	// Kind is asset, relation or property.
+	Kind string
+	Type string
+}
+
+// Error implements the error interface.
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown %s type: %s", e.Kind, e.Type)
+}
+
+// UnknownAsset preserves the raw content of an asset with a type that is not recognized.
+type UnknownAsset struct {
+	Type    oam.AssetType
+	Content []byte
+}
+
+// Key returns the raw content of the asset.
+func (u *UnknownAsset) Key() string {
+	return string(u.Content)
+}
+
+// AssetType returns the stored asset type.
+func (u *UnknownAsset) AssetType() oam.AssetType {
+	return u.Type
+}
+
+// JSON returns the raw content of the asset.
+func (u *UnknownAsset) JSON() ([]byte, error) {
+	return u.Content, nil
+}
+
+// UnknownProperty preserves the raw content of a property with a type that is not recognized.
+// The name and value of the property cannot be determined, so both are empty.
+type UnknownProperty struct {
+	Type    oam.PropertyType
+	Content []byte
+}
+
+// Name returns an empty string, since the name of the property is not known.
+func (u *UnknownProperty) Name() string {
+	return ""
+}
+
+// Value returns an empty string, since the value of the property is not known.
+func (u *UnknownProperty) Value() string {
+	return ""
+}
+
+// PropertyType returns the stored property type.
+func (u *UnknownProperty) PropertyType() oam.PropertyType {
+	return u.Type
+}
+
+// JSON returns the raw content of the property.
+func (u *UnknownProperty) JSON() ([]byte, error) {
+	return u.Content, nil
+}
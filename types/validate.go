@@ -0,0 +1,89 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	oamtls "github.com/owasp-amass/open-asset-model/certificate"
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/domain"
+	oamfile "github.com/owasp-amass/open-asset-model/file"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/people"
+	oamreg "github.com/owasp-amass/open-asset-model/registration"
+	"github.com/owasp-amass/open-asset-model/service"
+	"github.com/owasp-amass/open-asset-model/url"
+)
+
+// ValidateAsset checks that the fields required to identify the asset are present and well formed.
+// It returns a descriptive error for the first problem found, or nil if the asset is valid.
+func ValidateAsset(asset oam.Asset) error {
+	if asset == nil {
+		return errors.New("the asset is nil")
+	}
+
+	switch v := asset.(type) {
+	case *domain.FQDN:
+		return requireField(asset, "name", v.Name)
+	case *network.IPAddress:
+		if !v.Address.IsValid() {
+			return fmt.Errorf("the %s asset has an invalid address", asset.AssetType())
+		}
+		if (v.Type == "IPv4" && !v.Address.Is4()) || (v.Type == "IPv6" && !v.Address.Is6()) {
+			return fmt.Errorf("the %s asset address %s does not match the %s type", asset.AssetType(), v.Address, v.Type)
+		}
+	case *network.AutonomousSystem:
+		if v.Number <= 0 {
+			return fmt.Errorf("the %s asset has an invalid number: %d", asset.AssetType(), v.Number)
+		}
+	case *network.Netblock:
+		if !v.CIDR.IsValid() {
+			return fmt.Errorf("the %s asset has an invalid cidr", asset.AssetType())
+		}
+	case *oamreg.IPNetRecord:
+		if !v.CIDR.IsValid() {
+			return fmt.Errorf("the %s asset has an invalid cidr", asset.AssetType())
+		}
+		return requireField(asset, "handle", v.Handle)
+	case *oamreg.AutnumRecord:
+		return requireField(asset, "handle", v.Handle)
+	case *oamreg.DomainRecord:
+		return requireField(asset, "domain", v.Domain)
+	case *org.Organization:
+		return requireField(asset, "name", v.Name)
+	case *people.Person:
+		return requireField(asset, "full_name", v.FullName)
+	case *contact.Phone:
+		return requireField(asset, "raw", v.Raw)
+	case *contact.EmailAddress:
+		return requireField(asset, "address", v.Address)
+	case *contact.Location:
+		return requireField(asset, "address", v.Address)
+	case *contact.ContactRecord:
+		return requireField(asset, "discovered_at", v.DiscoveredAt)
+	case *oamtls.TLSCertificate:
+		return requireField(asset, "serial_number", v.SerialNumber)
+	case *url.URL:
+		return requireField(asset, "url", v.Raw)
+	case *service.Service:
+		return requireField(asset, "identifier", v.Identifier)
+	case *oamfile.File:
+		return requireField(asset, "url", v.URL)
+	default:
+		return fmt.Errorf("unknown asset type: %s", asset.AssetType())
+	}
+	return nil
+}
+
+func requireField(asset oam.Asset, field, value string) error {
+	if value == "" {
+		return fmt.Errorf("the %s asset has an empty %s field", asset.AssetType(), field)
+	}
+	return nil
+}
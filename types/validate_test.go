@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"net/netip"
+	"testing"
+
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/property"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAsset(t *testing.T) {
+	valid := []oam.Asset{
+		&domain.FQDN{Name: "owasp.org"},
+		&network.IPAddress{Address: netip.MustParseAddr("192.168.1.1"), Type: "IPv4"},
+		&network.Netblock{CIDR: netip.MustParsePrefix("192.168.1.0/24"), Type: "IPv4"},
+		&org.Organization{Name: "OWASP Foundation"},
+	}
+	for _, asset := range valid {
+		assert.NoError(t, ValidateAsset(asset), "%s should be valid", asset.AssetType())
+	}
+
+	invalid := []oam.Asset{
+		nil,
+		&domain.FQDN{},
+		&network.IPAddress{Type: "IPv4"},
+		&network.IPAddress{Address: netip.MustParseAddr("2001:db8::1"), Type: "IPv4"},
+		&network.AutonomousSystem{Number: 0},
+		&network.Netblock{Type: "IPv4"},
+		&contact.EmailAddress{Username: "admin"},
+	}
+	for _, asset := range invalid {
+		assert.Error(t, ValidateAsset(asset))
+	}
+}
+
+func TestValidateSearchQuery(t *testing.T) {
+	assert.NoError(t, ValidateSearchQuery("owa"))
+	assert.NoError(t, ValidateSearchQuery("ünï"))
+	assert.Error(t, ValidateSearchQuery("ow"))
+	assert.Error(t, ValidateSearchQuery("  ow  "))
+	assert.Error(t, ValidateSearchQuery(""))
+}
+
+func TestValidateTraversal(t *testing.T) {
+	assert.NoError(t, ValidateTraversal(1, Outgoing))
+	assert.NoError(t, ValidateTraversal(3, Incoming))
+	assert.NoError(t, ValidateTraversal(2, Both))
+	assert.Error(t, ValidateTraversal(0, Outgoing))
+	assert.Error(t, ValidateTraversal(2, Direction("total")))
+}
+
+func TestTagPredicate(t *testing.T) {
+	source := &property.SourceProperty{Source: "dns", Confidence: 80}
+
+	assert.True(t, TagPredicate{Name: "dns"}.Matches(source))
+	assert.True(t, TagPredicate{Name: "dns", Op: TagGreaterEqual, Value: "80"}.Matches(source))
+	assert.False(t, TagPredicate{Name: "dns", Op: TagGreater, Value: "80"}.Matches(source))
+	assert.True(t, TagPredicate{Name: "dns", Op: TagNotEqual, Value: "50"}.Matches(source))
+	assert.False(t, TagPredicate{Name: "whois", Op: TagEqual, Value: "80"}.Matches(source))
+
+	// the ordering operators do not match values that are not numbers
+	simple := &property.SimpleProperty{PropertyName: "env", PropertyValue: "prod"}
+	assert.True(t, TagPredicate{Name: "env", Op: TagEqual, Value: "prod"}.Matches(simple))
+	assert.False(t, TagPredicate{Name: "env", Op: TagLess, Value: "100"}.Matches(simple))
+
+	assert.NoError(t, ValidateTagPredicates([]TagPredicate{{Name: "dns", Op: TagLess, Value: "1.5"}}, Or))
+	assert.Error(t, ValidateTagPredicates(nil, And))
+	assert.Error(t, ValidateTagPredicates([]TagPredicate{{Name: "dns"}}, AndOr("xor")))
+	assert.Error(t, ValidateTagPredicates([]TagPredicate{{Op: TagEqual, Value: "80"}}, And))
+	assert.Error(t, ValidateTagPredicates([]TagPredicate{{Name: "dns", Op: TagGreater, Value: "high"}}, And))
+	assert.Error(t, ValidateTagPredicates([]TagPredicate{{Name: "dns", Op: "~", Value: "80"}}, And))
+}
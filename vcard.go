@@ -0,0 +1,203 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/people"
+)
+
+// ExportVCard writes a vCard 4.0 entry to w for each contact record in the repository last seen after the since parameter.
+// The person, organization, email address, phone and location entities linked from a contact record are combined into a single card.
+// The edges of the contact records are found in one query, and the linked entities with one query per asset type.
+// If since.IsZero(), the parameter will be ignored.
+// Contact records without a person, organization or email address to name the card are skipped.
+func ExportVCard(db repository.Repository, w io.Writer, since time.Time) error {
+	records, err := db.FindEntitiesByType(oam.ContactRecord, since)
+	if errors.Is(err, types.ErrNoResults) {
+		// no contact records to export
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	edges, err := db.OutgoingEdgesForEntities(records, time.Time{})
+	if err != nil && !errors.Is(err, types.ErrNoResults) {
+		return err
+	}
+
+	linked := make(map[string]oam.Asset)
+	for _, list := range edges {
+		for _, edge := range list {
+			linked[edge.ToEntity.ID] = nil
+		}
+	}
+	if len(linked) > 0 {
+		for _, atype := range vcardAssetTypes {
+			entities, err := db.FindEntitiesByType(atype, time.Time{})
+			if errors.Is(err, types.ErrNoResults) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			for _, e := range entities {
+				if _, found := linked[e.ID]; found {
+					linked[e.ID] = e.Asset
+				}
+			}
+		}
+	}
+
+	for _, record := range records {
+		var assets []oam.Asset
+
+		for _, edge := range edges[record.ID] {
+			if asset := linked[edge.ToEntity.ID]; asset != nil {
+				assets = append(assets, asset)
+			}
+		}
+
+		if card := vcardFromAssets(assets); card != "" {
+			if _, err := io.WriteString(w, card); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vcardAssetTypes are the types of the entities linked from a contact record that are added to its card.
+var vcardAssetTypes = []oam.AssetType{oam.Person, oam.Organization, oam.EmailAddress, oam.Phone, oam.Location}
+
+func vcardFromAssets(assets []oam.Asset) string {
+	var lines []string
+	var person, organization, email string
+
+	for _, asset := range assets {
+		switch v := asset.(type) {
+		case *people.Person:
+			if v.FullName == "" {
+				continue
+			}
+			person = v.FullName
+			lines = append(lines, "N:"+strings.Join([]string{
+				vcardEscape(v.FamilyName), vcardEscape(v.FirstName), vcardEscape(v.MiddleName), "", "",
+			}, ";"))
+		case *org.Organization:
+			if v.Name == "" {
+				continue
+			}
+			organization = v.Name
+			lines = append(lines, "ORG:"+vcardEscape(v.Name))
+		case *contact.EmailAddress:
+			if v.Address == "" {
+				continue
+			}
+			email = v.Address
+			lines = append(lines, "EMAIL:"+vcardEscape(v.Address))
+		case *contact.Phone:
+			number := v.E164
+			if number == "" {
+				number = v.Raw
+			}
+			if number == "" {
+				continue
+			}
+			if v.Ext != "" {
+				number += ";ext=" + v.Ext
+			}
+			lines = append(lines, "TEL;VALUE=uri:tel:"+strings.ReplaceAll(number, " ", "-"))
+		case *contact.Location:
+			if adr := vcardAddress(v); adr != "" {
+				lines = append(lines, adr)
+			}
+		}
+	}
+
+	// the formatted name is required, so prefer the person and fall back to the organization or email address
+	name := person
+	if name == "" {
+		name = organization
+	}
+	if name == "" {
+		name = email
+	}
+	if name == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\r\n")
+	b.WriteString("VERSION:4.0\r\n")
+	b.WriteString(vcardFold("FN:" + vcardEscape(name)))
+	for _, line := range lines {
+		b.WriteString(vcardFold(line))
+	}
+	b.WriteString("END:VCARD\r\n")
+	return b.String()
+}
+
+func vcardAddress(loc *contact.Location) string {
+	street := strings.TrimSpace(loc.BuildingNumber + " " + loc.StreetName)
+	if street == "" && loc.POBox == "" && loc.City == "" && loc.Province == "" && loc.PostalCode == "" && loc.Country == "" {
+		if loc.Address == "" {
+			return ""
+		}
+		// only the unstructured address is available
+		street = loc.Address
+	}
+
+	city := loc.City
+	if city == "" {
+		city = loc.Locality
+	}
+
+	return "ADR:" + strings.Join([]string{
+		vcardEscape(loc.POBox),
+		vcardEscape(loc.Unit),
+		vcardEscape(street),
+		vcardEscape(city),
+		vcardEscape(loc.Province),
+		vcardEscape(loc.PostalCode),
+		vcardEscape(loc.Country),
+	}, ";")
+}
+
+// vcardEscape escapes a text value as described in RFC 6350, section 3.4.
+func vcardEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// vcardFold terminates the content line and folds it at 75 octets as described in RFC 6350, section 3.2.
+func vcardFold(line string) string {
+	var b strings.Builder
+
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// do not split a multi-octet UTF-8 sequence
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// the leading space of a continuation line counts toward the limit
+		limit = 74
+	}
+
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
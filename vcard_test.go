@@ -0,0 +1,113 @@
+// Copyright © by Jeff Foley 2017-2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package assetdb
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/asset-db/repository"
+	"github.com/owasp-amass/asset-db/repository/sqlrepo"
+	"github.com/owasp-amass/asset-db/types"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/contact"
+	"github.com/owasp-amass/open-asset-model/org"
+	"github.com/owasp-amass/open-asset-model/people"
+	"github.com/owasp-amass/open-asset-model/relation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportVCard(t *testing.T) {
+	db, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	record, err := db.CreateAsset(&contact.ContactRecord{DiscoveredAt: "https://owasp.org/contact"})
+	assert.NoError(t, err)
+
+	for label, asset := range map[string]oam.Asset{
+		"person":       &people.Person{FullName: "Jane Q. Doe", FirstName: "Jane", MiddleName: "Q.", FamilyName: "Doe"},
+		"organization": &org.Organization{Name: "OWASP Foundation, Inc."},
+		"email":        &contact.EmailAddress{Address: "jane.doe@owasp.org", Username: "jane.doe", Domain: "owasp.org"},
+		"phone":        &contact.Phone{Raw: "+1 555 0100", E164: "+15550100"},
+		"location":     &contact.Location{Address: "1 Main St, Bel Air, MD 21014", BuildingNumber: "1", StreetName: "Main St", City: "Bel Air", Province: "MD", PostalCode: "21014", Country: "US"},
+	} {
+		to, err := db.CreateAsset(asset)
+		assert.NoError(t, err)
+
+		_, err = db.CreateEdge(&types.Edge{
+			Relation:   &relation.SimpleRelation{Name: label},
+			FromEntity: record,
+			ToEntity:   to,
+		})
+		assert.NoError(t, err)
+	}
+
+	// a contact record with only a phone number cannot be named and is skipped
+	incomplete, err := db.CreateAsset(&contact.ContactRecord{DiscoveredAt: "https://example.com/contact"})
+	assert.NoError(t, err)
+	phone, err := db.CreateAsset(&contact.Phone{Raw: "+1 555 0199", E164: "+15550199"})
+	assert.NoError(t, err)
+	_, err = db.CreateEdge(&types.Edge{
+		Relation:   &relation.SimpleRelation{Name: "phone"},
+		FromEntity: incomplete,
+		ToEntity:   phone,
+	})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, ExportVCard(db, &buf, time.Time{}))
+
+	out := buf.String()
+	assert.Equal(t, 1, strings.Count(out, "BEGIN:VCARD\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCARD\r\n"))
+	for _, line := range []string{
+		"VERSION:4.0",
+		"FN:Jane Q. Doe",
+		"N:Doe;Jane;Q.;;",
+		`ORG:OWASP Foundation\, Inc.`,
+		"EMAIL:jane.doe@owasp.org",
+		"TEL;VALUE=uri:tel:+15550100",
+		"ADR:;;1 Main St;Bel Air;MD;21014;US",
+	} {
+		assert.Contains(t, out, line+"\r\n")
+	}
+	assert.NotContains(t, out, "+15550199")
+}
+
+type failingRepository struct {
+	repository.Repository
+}
+
+func (r *failingRepository) FindEntitiesByType(atype oam.AssetType, since time.Time) ([]*types.Entity, error) {
+	return nil, errors.New("the database connection was lost")
+}
+
+func TestExportVCardErrors(t *testing.T) {
+	db, err := New(sqlrepo.SQLiteMemory, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// a repository without contact records has nothing to export
+	var buf bytes.Buffer
+	assert.NoError(t, ExportVCard(db, &buf, time.Time{}))
+	assert.Empty(t, buf.String())
+
+	// other errors are returned
+	assert.Error(t, ExportVCard(&failingRepository{Repository: db}, &buf, time.Time{}))
+}
+
+func TestVCardFold(t *testing.T) {
+	line := "NOTE:" + strings.Repeat("x", 200)
+
+	folded := vcardFold(line)
+	for _, l := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(l), 75)
+	}
+	assert.Equal(t, line, strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""))
+}